@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,7 +18,8 @@ import (
 	entity "github.com/myestatia/myestatia-go/internal/domain/entity"
 	database "github.com/myestatia/myestatia-go/internal/infrastructure/database"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
-	googleoauth "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/migration"
+	oauthconfig "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
 	repository "github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/seed"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/storage"
@@ -44,6 +46,30 @@ func main() {
 
 	db := database.InitDB(cfg)
 
+	// Companies used to be limited to one inbox by a unique index on company_id
+	if db.Migrator().HasIndex(&entity.CompanyEmailConfig{}, "idx_company_email_configs_company_id") {
+		if err := db.Migrator().DropIndex(&entity.CompanyEmailConfig{}, "idx_company_email_configs_company_id"); err != nil {
+			log.Fatalf("Error dropping email config company index: %v", err)
+		}
+	}
+
+	// Processed emails used to be recorded without a unique index; drop the
+	// duplicates so it can be created
+	if db.Migrator().HasTable(&entity.ProcessedEmail{}) && !db.Migrator().HasIndex(&entity.ProcessedEmail{}, "idx_processed_email_company_message") {
+		if err := db.Exec(`DELETE FROM processed_emails a USING processed_emails b
+			WHERE a.company_id = b.company_id AND a.message_id = b.message_id AND a.id > b.id`).Error; err != nil {
+			log.Fatalf("Error removing duplicate processed emails: %v", err)
+		}
+	}
+
+	// Lead emails used to be unique across companies, so a buyer contacting two
+	// agencies became a lead of whichever saw them first
+	if db.Migrator().HasIndex(&entity.Lead{}, "idx_leads_email") {
+		if err := db.Migrator().DropIndex(&entity.Lead{}, "idx_leads_email"); err != nil {
+			log.Fatalf("Error dropping lead email index: %v", err)
+		}
+	}
+
 	err := db.AutoMigrate(
 		&entity.Lead{},
 		&entity.Message{},
@@ -55,7 +81,25 @@ func main() {
 		&entity.Company{},
 		&entity.CompanyEmailConfig{},
 		&entity.ProcessedEmail{},
+		&entity.EmailSyncCursor{},
+		&entity.EmailSyncRun{},
+		&entity.EmailWorkerLease{},
 		&entity.PasswordReset{},
+		&entity.Session{},
+		&entity.Invitation{},
+		&entity.RecoveryCode{},
+		&entity.LoginThrottle{},
+		&entity.LockoutEvent{},
+		&entity.APIKey{},
+		&entity.EmailParserTemplate{},
+		&entity.FailedEmail{},
+		&entity.InboundEmail{},
+		&entity.InboundEmailAttachment{},
+		&entity.LeadStatusChange{},
+		&entity.LeadAssignmentRule{},
+		&entity.LeadAssignment{},
+		&entity.LeadScoreWeights{},
+		&entity.PresentationView{},
 	)
 	if err != nil {
 		log.Fatalf("Error migrating database: %v", err)
@@ -67,11 +111,31 @@ func main() {
 		log.Printf("Error seeding subtypes: %v", err)
 	}
 
-	leadRepo := repository.NewLeadRepository(db)
-	leadSvc := service.NewLeadService(leadRepo)
-	leadHandler := handlers.NewLeadHandler(leadSvc)
+	// Inbound contacts used to be appended to lead notes instead of the conversation
+	if err := migration.SplitLeadNotes(db); err != nil {
+		log.Printf("Error moving lead notes to conversations: %v", err)
+	}
+	if err := migration.NormalizeLeadPhones(db); err != nil {
+		log.Printf("Error normalizing lead phones: %v", err)
+	}
+	if err := migration.LeadEmailIndex(db); err != nil {
+		log.Printf("Error creating lead email index: %v", err)
+	}
 
+	leadRepo := repository.NewLeadRepository(db)
 	propertyRepo := repository.NewPropertyRepository(db)
+	leadAssignmentService := service.NewLeadAssignmentService(repository.NewLeadAssignmentRepository(db), leadRepo, propertyRepo)
+	leadAssignmentHandler := handlers.NewLeadAssignmentHandler(leadAssignmentService)
+	leadSvc := service.NewLeadService(leadRepo, leadAssignmentService)
+	leadSvc.Properties = propertyRepo
+	leadStatusService := service.NewLeadStatusService(leadRepo, repository.NewLeadStatusChangeRepository(db))
+	leadHandler := handlers.NewLeadHandler(leadSvc, leadStatusService)
+
+	// Lead scores, refreshed on every activity of the lead
+	leadScoreService := service.NewLeadScoreService(repository.NewLeadScoreRepository(db), leadRepo)
+	leadScoreHandler := handlers.NewLeadScoreHandler(leadScoreService)
+	leadSvc.OnLeadActivity(leadScoreService.Refresh)
+
 	propertyService := service.NewPropertyService(propertyRepo)
 
 	companyRepo := repository.NewCompanyRepository(db)
@@ -82,16 +146,23 @@ func main() {
 	agentService := service.NewAgentService(agentRepo, propertyRepo)
 	agentHandler := handlers.NewAgentHandler(agentService)
 
+	sessionRepo := repository.NewSessionRepository(db)
+	sessionService := service.NewSessionService(sessionRepo, agentRepo)
+
+	// Brute-force protection for login and password reset
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
+	loginThrottleService := service.NewLoginThrottleService(loginThrottleRepo, agentRepo)
+	securityHandler := handlers.NewSecurityHandler(loginThrottleService)
+
 	// Storage
 	storageService := storage.NewLocalStorageService("uploads", "http://localhost:8080/uploads")
 	propertyHandler := handlers.NewPropertyHandler(propertyService, agentService, companyService, storageService)
 
 	messageRepo := repository.NewMessageRepository(db)
 	messageService := service.NewMessageService(messageRepo)
+	messageService.OnLeadActivity(leadScoreService.Refresh)
 	messageHandler := handlers.NewMessageHandler(messageService)
 
-	// Auth handler will be initialized later with invitation service
-
 	// Email Configuration Service and Manager
 	encryptionKey := os.Getenv("ENCRYPTION_KEY")
 	if len(encryptionKey) != 32 {
@@ -99,30 +170,80 @@ func main() {
 	}
 
 	emailConfigRepo := repository.NewCompanyEmailConfigRepository(db)
-	emailConfigService := service.NewCompanyEmailConfigService(emailConfigRepo, encryptionKey)
+	emailSyncRunRepo := repository.NewEmailSyncRunRepository(db)
+	emailConfigService := service.NewCompanyEmailConfigService(emailConfigRepo, agentRepo, emailSyncRunRepo, encryptionKey)
 	emailConfigHandler := handlers.NewCompanyEmailConfigHandler(emailConfigService)
 
 	// Repositories for worker
 	processedEmailRepo := repository.NewProcessedEmailRepository(db)
+	syncCursorRepo := repository.NewEmailSyncCursorRepository(db)
+	workerLeaseRepo := repository.NewEmailWorkerLeaseRepository(db)
 	passwordResetRepo := repository.NewPasswordResetRepository(db)
 
+	// Company-defined email parsers
+	parserTemplateRepo := repository.NewEmailParserTemplateRepository(db)
+	parserTemplateService := service.NewEmailParserTemplateService(parserTemplateRepo)
+	parserTemplateHandler := handlers.NewEmailParserTemplateHandler(parserTemplateService)
+
+	// Originals of the inbound emails, attachments included
+	inboundEmailRepo := repository.NewInboundEmailRepository(db)
+	// Attachments of inbound emails hold buyers' personal data: kept out of the
+	// public uploads directory and only served through the API
+	inboundEmailService := service.NewInboundEmailService(inboundEmailRepo, storage.NewLocalPrivateStorageService("private/email-attachments"))
+	inboundEmailHandler := handlers.NewInboundEmailHandler(inboundEmailService)
+
+	// Dead-letter queue of inbound emails; failures on an unknown reference are retried when the property is created
+	failedEmailRepo := repository.NewFailedEmailRepository(db)
+	// Retries are processed with the settings of the inbox the email came in through
+	retryEmailLeadService := func(ctx context.Context, companyID, configID string) (service.EmailProcessor, error) {
+		leadConfig := email.Config{DefaultCompanyID: companyID}
+		if configID != "" {
+			config, err := emailConfigService.GetConfig(ctx, companyID, configID)
+			switch {
+			case err == nil:
+				leadConfig = service.EmailLeadConfig(config)
+			case !strings.Contains(err.Error(), "not found"):
+				return nil, err
+			}
+			// A disconnected inbox leaves the company defaults
+		}
+		processor := service.NewEmailLeadService(propertyRepo, leadRepo, parserTemplateRepo, messageRepo, inboundEmailService, leadStatusService, leadAssignmentService, leadConfig)
+		processor.OnLeadActivity(leadScoreService.Refresh)
+		return processor, nil
+	}
+	failedEmailService := service.NewFailedEmailService(failedEmailRepo, retryEmailLeadService)
+	failedEmailHandler := handlers.NewFailedEmailHandler(failedEmailService)
+	propertyService.OnCreated(failedEmailService.RetryForProperty)
+
 	// Start Email Worker Manager (multi-company support)
 	emailWorkerManager := worker.NewEmailWorkerManager(
 		emailConfigService,
 		propertyRepo,
 		leadRepo,
+		messageRepo,
 		processedEmailRepo,
+		syncCursorRepo,
+		workerLeaseRepo,
+		parserTemplateRepo,
+		failedEmailService,
+		inboundEmailService,
+		leadStatusService,
+		leadAssignmentService,
 	)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	emailWorkerManager.OnLeadActivity(leadScoreService.Refresh)
 	go emailWorkerManager.Start(ctx)
 
 	// Start Password Reset Cleanup Worker
-	passwordResetCleanupWorker := worker.NewPasswordResetCleanupWorker(passwordResetRepo)
+	passwordResetCleanupWorker := worker.NewPasswordResetCleanupWorker(passwordResetRepo, sessionRepo)
 	go passwordResetCleanupWorker.Start(ctx)
 
+	// Start Lead Score Worker
+	go worker.NewLeadScoreWorker(leadScoreService).Start(ctx)
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -141,48 +262,80 @@ func main() {
 		log.Println("See GOOGLE_OAUTH_SETUP.md for instructions")
 	}
 
-	oauth2Config := googleoauth.NewOAuth2Config(googleClientID, googleClientSecret, googleRedirectURL)
+	oauth2Config := oauthconfig.NewOAuth2Config(googleClientID, googleClientSecret, googleRedirectURL)
 	googleOAuthHandler := handlers.NewGoogleOAuthHandler(oauth2Config, emailConfigService, encryptionKey)
 
-	// Password Reset Setup - Try Resend first, fallback to SMTP
+	// Microsoft OAuth2 Configuration (Microsoft 365 / Outlook mailboxes)
+	microsoftClientID := os.Getenv("MICROSOFT_CLIENT_ID")
+	microsoftClientSecret := os.Getenv("MICROSOFT_CLIENT_SECRET")
+	microsoftRedirectURL := os.Getenv("MICROSOFT_REDIRECT_URL")
+
+	if microsoftRedirectURL == "" {
+		microsoftRedirectURL = "http://localhost:8080/api/v1/auth/microsoft/callback" // Default for development
+	}
+
+	if microsoftClientID == "" || microsoftClientSecret == "" {
+		log.Println("WARNING: MICROSOFT_CLIENT_ID or MICROSOFT_CLIENT_SECRET not set. Microsoft 365 mailboxes will not work.")
+	}
+
+	microsoftOAuth2Config := oauthconfig.NewMicrosoftOAuth2Config(microsoftClientID, microsoftClientSecret, microsoftRedirectURL, os.Getenv("MICROSOFT_TENANT_ID"))
+	microsoftOAuthHandler := handlers.NewMicrosoftOAuthHandler(microsoftOAuth2Config, emailConfigService, encryptionKey)
+
+	// Password Reset and Invitation emails - Try Resend first, fallback to SMTP
 	resendConfig := email.LoadResendConfig()
 	smtpConfig := email.LoadSMTPConfig()
 
-	var passwordResetEmailSender interface {
+	var accountEmailSender interface {
 		SendPasswordResetEmail(to, token string) error
+		SendInvitationEmail(to, companyName, token string) error
 	}
 
 	if resendConfig.IsValid() {
-		log.Println("✓ Using Resend for password reset emails")
-		passwordResetEmailSender = email.NewResendEmailSender(resendConfig)
+		log.Println("✓ Using Resend for password reset and invitation emails")
+		accountEmailSender = email.NewResendEmailSender(resendConfig)
 	} else {
 		log.Println("Resend not configured, trying SMTP...")
 		if smtpConfig.IsValid() {
-			log.Println("✓ Using SMTP for password reset emails")
-			passwordResetEmailSender = email.NewEmailSender(smtpConfig)
+			log.Println("✓ Using SMTP for password reset and invitation emails")
+			accountEmailSender = email.NewEmailSender(smtpConfig)
 		} else {
-			log.Println("WARNING: Neither Resend nor SMTP configured. Password reset emails will not be sent.")
+			log.Println("WARNING: Neither Resend nor SMTP configured. Password reset and invitation emails will not be sent.")
 			log.Println("For Resend (recommended): Set RESEND_API_KEY and RESEND_FROM_EMAIL")
 			log.Println("For SMTP: Set SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, and SMTP_FROM")
 			// Create a dummy sender that will fail gracefully
-			passwordResetEmailSender = email.NewEmailSender(smtpConfig)
+			accountEmailSender = email.NewEmailSender(smtpConfig)
 		}
 	}
 
-	passwordResetHandler := handlers.NewPasswordResetHandler(agentService, passwordResetRepo, passwordResetEmailSender)
+	passwordResetHandler := handlers.NewPasswordResetHandler(agentService, sessionService, loginThrottleService, passwordResetRepo, accountEmailSender)
 
 	// Presentation Service
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = "default-secret-key-change-me"
 	}
-	presentationService := service.NewPresentationService(leadRepo, propertyRepo, agentRepo, companyRepo, jwtSecret)
+	presentationService := service.NewPresentationService(leadRepo, propertyRepo, agentRepo, companyRepo, repository.NewPresentationViewRepository(db), jwtSecret)
+	presentationService.OnLeadActivity(leadScoreService.Refresh)
 	presentationHandler := handlers.NewPresentationHandler(presentationService)
 
-	// Initialize auth handler (invitation service removed)
-	authHandler := handlers.NewAuthHandler(agentService, companyService)
+	// TOTP two-factor authentication
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	twoFactorService := service.NewTwoFactorService(agentRepo, companyRepo, recoveryCodeRepo, encryptionKey)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, sessionService, loginThrottleService)
+
+	// Invitations to join an existing company
+	invitationRepo := repository.NewInvitationRepository(db)
+	invitationService := service.NewInvitationService(invitationRepo, agentRepo, companyRepo, accountEmailSender)
+	invitationHandler := handlers.NewInvitationHandler(invitationService, sessionService, twoFactorService)
+
+	// API keys
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
 
-	mux := router.NewRouter(leadHandler, propertyHandler, companyHandler, agentHandler, messageHandler, authHandler, emailConfigHandler, googleOAuthHandler, passwordResetHandler, presentationHandler)
+	authHandler := handlers.NewAuthHandler(agentService, companyService, sessionService, twoFactorService, loginThrottleService)
+
+	mux := router.NewRouter(leadHandler, propertyHandler, companyHandler, agentHandler, messageHandler, authHandler, emailConfigHandler, googleOAuthHandler, microsoftOAuthHandler, passwordResetHandler, presentationHandler, invitationHandler, twoFactorHandler, securityHandler, apiKeyHandler, parserTemplateHandler, failedEmailHandler, inboundEmailHandler, leadAssignmentHandler, leadScoreHandler, sessionService, apiKeyService)
 
 	// Wrap the router with CORS middleware
 	// Add static file handler for uploads
@@ -196,10 +349,16 @@ func main() {
 		middleware.CorsMiddleware(mux).ServeHTTP(w, r)
 	})
 
+	// X-Forwarded-For is only read from the proxies listed in TRUSTED_PROXIES
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: finalHandler,
+		Handler: middleware.RealIP(trustedProxies)(finalHandler),
 	}
 
 	// Start server in goroutine
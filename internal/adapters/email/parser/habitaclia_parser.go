@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type HabitacliaParser struct{}
+
+var habitacliaFields = leadFields{
+	Name:       []string{"Nombre"},
+	Email:      []string{"Email", "E-mail", "Correo electrónico"},
+	Phone:      []string{"Teléfono", "Telèfon"},
+	Reference:  []string{"Referencia", "Referència", "Ref."},
+	Message:    []string{"Comentarios", "Mensaje", "Missatge"},
+	MessageEnd: []string{"Responder", "Ver inmueble", "Este mensaje"},
+}
+
+// CanParse determines if this email is from Habitaclia
+func (p *HabitacliaParser) CanParse(subject, from string) bool {
+	return strings.Contains(strings.ToLower(from), "habitaclia") ||
+		strings.Contains(strings.ToLower(subject), "habitaclia")
+}
+
+// Parse extracts lead data from a Habitaclia contact email.
+// Habitaclia sends them in Spanish or Catalan depending on the user.
+func (p *HabitacliaParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSourceHabitaclia,
+		ContactDate: time.Now(),
+	}
+
+	habitacliaFields.extract(textNodes(body), lead)
+
+	// Pattern in subject: "... con referencia 2345-PB" or "... amb referència 2345-PB"
+	if lead.PropertyReference == "" {
+		refRegex := regexp.MustCompile(`(?i)refer[eè]ncia\s+(\S+)`)
+		if matches := refRegex.FindStringSubmatch(subject); len(matches) > 1 {
+			lead.PropertyReference = matches[1]
+		}
+	}
+
+	if lead.PropertyReference == "" {
+		return nil, fmt.Errorf("could not extract property reference from Habitaclia email")
+	}
+	if lead.Email == "" {
+		return nil, fmt.Errorf("could not extract email from Habitaclia email")
+	}
+
+	return lead, nil
+}
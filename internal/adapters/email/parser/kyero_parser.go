@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type KyeroParser struct{}
+
+// Kyero writes its own listing ID ("Kyero ref") next to the agency
+// reference; only the agency one matches our properties.
+var kyeroFields = leadFields{
+	Name:       []string{"Name"},
+	Email:      []string{"Email", "E-mail"},
+	Phone:      []string{"Phone", "Telephone"},
+	Reference:  []string{"Agent ref", "Agent reference", "Your reference"},
+	Message:    []string{"Message", "Enquiry"},
+	MessageEnd: []string{"Reply to", "View property", "Kyero ref"},
+}
+
+// CanParse determines if this email is from Kyero
+func (p *KyeroParser) CanParse(subject, from string) bool {
+	return strings.Contains(strings.ToLower(from), "kyero") ||
+		strings.Contains(strings.ToLower(subject), "kyero")
+}
+
+// Parse extracts lead data from a Kyero enquiry, which is always in English
+func (p *KyeroParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSourceKyero,
+		ContactDate: time.Now(),
+	}
+
+	kyeroFields.extract(textNodes(body), lead)
+
+	// Pattern in subject: "New enquiry for your property (ref. V-1020)"
+	if lead.PropertyReference == "" {
+		refRegex := regexp.MustCompile(`(?i)\bref[.:]?\s*([A-Za-z0-9][\w/-]*)`)
+		if matches := refRegex.FindStringSubmatch(subject); len(matches) > 1 {
+			lead.PropertyReference = matches[1]
+		}
+	}
+
+	if lead.PropertyReference == "" {
+		return nil, fmt.Errorf("could not extract property reference from Kyero email")
+	}
+	if lead.Email == "" {
+		return nil, fmt.Errorf("could not extract email from Kyero email")
+	}
+
+	return lead, nil
+}
@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"golang.org/x/net/html"
+)
+
+var (
+	emailAddressRegex = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	referenceRegex    = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9_/.-]*[A-Za-z0-9]|[A-Za-z0-9]`)
+)
+
+// leadFields describes where a portal puts each field of a contact email.
+// Every field lists the labels it may appear under; the first one found wins.
+type leadFields struct {
+	Name      []string
+	Email     []string
+	Phone     []string
+	Reference []string
+	Message   []string
+
+	// MessageEnd is text that always follows the message (buttons, footer),
+	// so a message spread over several paragraphs stops there
+	MessageEnd []string
+}
+
+// extract fills lead with the labelled values found in the body text nodes
+func (f leadFields) extract(nodes []string, lead *entity.ParsedLead) {
+	if v := labelledValue(nodes, f.Name); v != "" {
+		lead.Name = v
+	}
+	if v := emailAddressRegex.FindString(labelledValue(nodes, f.Email)); v != "" {
+		lead.Email = strings.ToLower(v)
+	}
+	if v := cleanPhone(labelledValue(nodes, f.Phone)); v != "" {
+		lead.Phone = v
+	}
+	if v := referenceRegex.FindString(labelledValue(nodes, f.Reference)); v != "" {
+		lead.PropertyReference = v
+	}
+	if v := f.message(nodes); v != "" {
+		lead.Message = v
+	}
+}
+
+// message joins the text nodes after the message label until the next
+// known label or the end marker
+func (f leadFields) message(nodes []string) string {
+	start, inline := findLabel(nodes, f.Message)
+	if start == -1 {
+		return ""
+	}
+
+	var labels []string
+	for _, group := range [][]string{f.Name, f.Email, f.Phone, f.Reference, f.Message} {
+		labels = append(labels, group...)
+	}
+
+	var parts []string
+	if inline != "" {
+		parts = append(parts, inline)
+	}
+	for _, node := range nodes[start+1:] {
+		if i, _ := findLabel([]string{node}, labels); i != -1 || hasAnyPrefix(node, f.MessageEnd) {
+			break
+		}
+		parts = append(parts, node)
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// labelledValue returns the value written after one of labels, either in the
+// same text node ("Nombre: Ana") or in the next one ("Nombre:" then "Ana")
+func labelledValue(nodes []string, labels []string) string {
+	i, inline := findLabel(nodes, labels)
+	if i == -1 {
+		return ""
+	}
+	if inline != "" {
+		return inline
+	}
+	if i+1 < len(nodes) {
+		return nodes[i+1]
+	}
+	return ""
+}
+
+// findLabel returns the index of the first node starting with one of labels
+// followed by a colon (or nothing), and the text after the colon
+func findLabel(nodes []string, labels []string) (int, string) {
+	for i, node := range nodes {
+		for _, label := range labels {
+			if len(node) < len(label) || !strings.EqualFold(node[:len(label)], label) {
+				continue
+			}
+			rest := strings.TrimSpace(node[len(label):])
+			if rest == "" {
+				return i, ""
+			}
+			if strings.HasPrefix(rest, ":") {
+				return i, strings.TrimSpace(rest[1:])
+			}
+		}
+	}
+	return -1, ""
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPhone keeps the digits of a phone number and a leading +
+func cleanPhone(s string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			// Anything else ends the number ("600 000 000 (mañana)" keeps the digits only)
+			if b.Len() > 0 {
+				return validPhone(b.String())
+			}
+		}
+	}
+	return validPhone(b.String())
+}
+
+func validPhone(phone string) string {
+	if len(strings.TrimPrefix(phone, "+")) < 6 {
+		return ""
+	}
+	return phone
+}
+
+// textNodes returns the non-empty lines of text of an HTML (or plain text)
+// body, in document order
+func textNodes(body string) []string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		var nodes []string
+		return appendLines(nodes, body)
+	}
+	return nodeText(doc)
+}
+
+// nodeText returns the non-empty lines of text under n, skipping styles and scripts
+func nodeText(n *html.Node) []string {
+	var nodes []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "style" || n.Data == "script" || n.Data == "head") {
+			return
+		}
+		if n.Type == html.TextNode {
+			nodes = appendLines(nodes, n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return nodes
+}
+
+func appendLines(nodes []string, text string) []string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			nodes = append(nodes, line)
+		}
+	}
+	return nodes
+}
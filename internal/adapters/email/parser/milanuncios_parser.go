@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type MilanunciosParser struct{}
+
+var milanunciosFields = leadFields{
+	Name:       []string{"Nombre"},
+	Email:      []string{"Email", "E-mail", "Correo"},
+	Phone:      []string{"Teléfono"},
+	Reference:  []string{"Tu referencia", "Referencia"},
+	Message:    []string{"Mensaje"},
+	MessageEnd: []string{"Responder", "Ver anuncio", "Milanuncios"},
+}
+
+// CanParse determines if this email is from Milanuncios
+func (p *MilanunciosParser) CanParse(subject, from string) bool {
+	return strings.Contains(strings.ToLower(from), "milanuncios") ||
+		strings.Contains(strings.ToLower(subject), "milanuncios")
+}
+
+// Parse extracts lead data from a Milanuncios contact email. The reference
+// is the one the agency wrote in the ad, not the Milanuncios ad number.
+func (p *MilanunciosParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSourceMilanuncios,
+		ContactDate: time.Now(),
+	}
+
+	milanunciosFields.extract(textNodes(body), lead)
+
+	// Pattern in subject: "Nuevo mensaje sobre tu anuncio (ref. MA-301)"
+	if lead.PropertyReference == "" {
+		refRegex := regexp.MustCompile(`(?i)\bref[.:]?\s*([A-Za-z0-9][\w/-]*)`)
+		if matches := refRegex.FindStringSubmatch(subject); len(matches) > 1 {
+			lead.PropertyReference = matches[1]
+		}
+	}
+
+	if lead.PropertyReference == "" {
+		return nil, fmt.Errorf("could not extract property reference from Milanuncios email")
+	}
+	if lead.Email == "" {
+		return nil, fmt.Errorf("could not extract email from Milanuncios email")
+	}
+
+	return lead, nil
+}
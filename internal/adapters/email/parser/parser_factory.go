@@ -16,6 +16,12 @@ func NewParserFactory() *ParserFactory {
 	}
 
 	factory.RegisterParser(&FotocasaParser{})
+	factory.RegisterParser(&HabitacliaParser{})
+	factory.RegisterParser(&PisosParser{})
+	factory.RegisterParser(&KyeroParser{})
+	factory.RegisterParser(&ThinkSpainParser{})
+	factory.RegisterParser(&MilanunciosParser{})
+	// Idealista goes last: it also claims any "nuevo mensaje" subject
 	factory.RegisterParser(&IdealistaParser{})
 
 	return factory
@@ -25,6 +31,15 @@ func (f *ParserFactory) RegisterParser(parser port.EmailParser) {
 	f.parsers = append(f.parsers, parser)
 }
 
+// With returns a factory that tries parsers before the registered ones,
+// e.g. the templates of one company
+func (f *ParserFactory) With(parsers ...port.EmailParser) *ParserFactory {
+	combined := make([]port.EmailParser, 0, len(parsers)+len(f.parsers))
+	combined = append(combined, parsers...)
+	combined = append(combined, f.parsers...)
+	return &ParserFactory{parsers: combined}
+}
+
 func (f *ParserFactory) GetParser(subject, from string) (port.EmailParser, error) {
 	for _, parser := range f.parsers {
 		if parser.CanParse(subject, from) {
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type PisosParser struct{}
+
+var pisosFields = leadFields{
+	Name:       []string{"Nombre"},
+	Email:      []string{"E-mail", "Email"},
+	Phone:      []string{"Teléfono"},
+	Reference:  []string{"Referencia del anuncio", "Tu referencia", "Referencia"},
+	Message:    []string{"Mensaje", "Comentario"},
+	MessageEnd: []string{"Contestar", "Ver anuncio", "pisos.com"},
+}
+
+// CanParse determines if this email is from pisos.com
+func (p *PisosParser) CanParse(subject, from string) bool {
+	return strings.Contains(strings.ToLower(from), "pisos.com") ||
+		strings.Contains(strings.ToLower(subject), "pisos.com")
+}
+
+// Parse extracts lead data from a pisos.com information request
+func (p *PisosParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSourcePisos,
+		ContactDate: time.Now(),
+	}
+
+	pisosFields.extract(textNodes(body), lead)
+
+	// Pattern in subject: "pisos.com - Solicitud de información (Ref: 8891)"
+	if lead.PropertyReference == "" {
+		refRegex := regexp.MustCompile(`(?i)ref[.:]?\s*([A-Za-z0-9][\w/-]*)`)
+		if matches := refRegex.FindStringSubmatch(subject); len(matches) > 1 {
+			lead.PropertyReference = matches[1]
+		}
+	}
+
+	if lead.PropertyReference == "" {
+		return nil, fmt.Errorf("could not extract property reference from pisos.com email")
+	}
+	if lead.Email == "" {
+		return nil, fmt.Errorf("could not extract email from pisos.com email")
+	}
+
+	return lead, nil
+}
@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is the subset of CSS selectors needed to point at a piece of an
+// email layout: compounds of tag, #id, .class, [attr] and [attr=value],
+// joined by the descendant (space) and child (>) combinators.
+type selector []selectorStep
+
+type selectorStep struct {
+	child bool // joined to the previous step with ">"
+	tag   string
+	id    string
+	class []string
+	attrs []attrMatch
+}
+
+type attrMatch struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+func compileSelector(s string) (selector, error) {
+	var sel selector
+	child := false
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '>':
+			if len(sel) == 0 || child {
+				return nil, fmt.Errorf("invalid selector %q: unexpected '>'", s)
+			}
+			child = true
+			i++
+		default:
+			step, next, err := parseCompound(s, i)
+			if err != nil {
+				return nil, err
+			}
+			step.child = child
+			sel = append(sel, step)
+			child = false
+			i = next
+		}
+	}
+	if len(sel) == 0 || child {
+		return nil, fmt.Errorf("invalid selector %q", s)
+	}
+	return sel, nil
+}
+
+func parseCompound(s string, i int) (selectorStep, int, error) {
+	var step selectorStep
+	start := i
+
+	if s[i] == '*' {
+		i++
+	} else {
+		step.tag, i = readIdent(s, i)
+		step.tag = strings.ToLower(step.tag)
+	}
+
+	for i < len(s) {
+		var name string
+		switch s[i] {
+		case '#':
+			name, i = readIdent(s, i+1)
+			if name == "" {
+				return step, i, fmt.Errorf("invalid selector %q: empty id", s)
+			}
+			step.id = name
+		case '.':
+			name, i = readIdent(s, i+1)
+			if name == "" {
+				return step, i, fmt.Errorf("invalid selector %q: empty class", s)
+			}
+			step.class = append(step.class, name)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return step, i, fmt.Errorf("invalid selector %q: missing ']'", s)
+			}
+			attr := attrMatch{name: strings.TrimSpace(s[i+1 : i+end])}
+			if eq := strings.IndexByte(attr.name, '='); eq != -1 {
+				attr.value = strings.Trim(strings.TrimSpace(attr.name[eq+1:]), `"'`)
+				attr.name = strings.TrimSpace(attr.name[:eq])
+				attr.hasValue = true
+			}
+			if attr.name == "" {
+				return step, i, fmt.Errorf("invalid selector %q: empty attribute", s)
+			}
+			attr.name = strings.ToLower(attr.name)
+			step.attrs = append(step.attrs, attr)
+			i += end + 1
+		case ' ', '\t', '\n', '>':
+			return step, i, nil
+		default:
+			return step, i, fmt.Errorf("invalid selector %q: unsupported %q", s, s[i])
+		}
+	}
+
+	if i == start {
+		return step, i, fmt.Errorf("invalid selector %q", s)
+	}
+	return step, i, nil
+}
+
+func readIdent(s string, i int) (string, int) {
+	start := i
+	for i < len(s) {
+		c := s[i]
+		if c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			i++
+			continue
+		}
+		break
+	}
+	return s[start:i], i
+}
+
+// first returns the first element of doc, in document order, that matches
+func (sel selector) first(doc *html.Node) *html.Node {
+	var found *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && sel.matches(n, len(sel)-1) {
+			found = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found
+}
+
+// matches reports whether n matches the selector up to step i
+func (sel selector) matches(n *html.Node, i int) bool {
+	if !sel[i].matchesNode(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if sel[i].child {
+		return n.Parent != nil && n.Parent.Type == html.ElementNode && sel.matches(n.Parent, i-1)
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if sel.matches(p, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (step selectorStep) matchesNode(n *html.Node) bool {
+	if step.tag != "" && n.Data != step.tag {
+		return false
+	}
+	if step.id != "" && attrValue(n, "id") != step.id {
+		return false
+	}
+	if len(step.class) > 0 {
+		classes := strings.Fields(attrValue(n, "class"))
+		for _, want := range step.class {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, attr := range step.attrs {
+		value, ok := lookupAttr(n, attr.name)
+		if !ok || attr.hasValue && value != attr.value {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupAttr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attrValue(n *html.Node, name string) string {
+	v, _ := lookupAttr(n, name)
+	return v
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
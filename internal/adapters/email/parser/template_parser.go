@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"golang.org/x/net/html"
+)
+
+// TemplateParser runs the rules of a company-defined EmailParserTemplate
+type TemplateParser struct {
+	template *entity.EmailParserTemplate
+	from     *regexp.Regexp
+	subject  *regexp.Regexp
+
+	name      fieldRule
+	email     fieldRule
+	phone     fieldRule
+	message   fieldRule
+	reference fieldRule
+}
+
+// fieldRule is a compiled entity.TemplateFieldRule
+type fieldRule struct {
+	selector selector
+	regex    *regexp.Regexp
+	subject  bool
+}
+
+// NewTemplateParser compiles the rules of t, so invalid patterns are reported
+// when the template is saved rather than when an email arrives
+func NewTemplateParser(t *entity.EmailParserTemplate) (*TemplateParser, error) {
+	if t.FromPattern == "" && t.SubjectPattern == "" {
+		return nil, fmt.Errorf("invalid template: a from or subject pattern is required")
+	}
+
+	p := &TemplateParser{template: t}
+	var err error
+	if p.from, err = compileMatchPattern("from", t.FromPattern); err != nil {
+		return nil, err
+	}
+	if p.subject, err = compileMatchPattern("subject", t.SubjectPattern); err != nil {
+		return nil, err
+	}
+
+	rules := t.Rules.Data()
+	if rules.Email.IsEmpty() {
+		return nil, fmt.Errorf("invalid template: an email rule is required")
+	}
+	if rules.PropertyReference.IsEmpty() {
+		return nil, fmt.Errorf("invalid template: a property reference rule is required")
+	}
+
+	for _, f := range []struct {
+		name string
+		rule entity.TemplateFieldRule
+		dst  *fieldRule
+	}{
+		{"name", rules.Name, &p.name},
+		{"email", rules.Email, &p.email},
+		{"phone", rules.Phone, &p.phone},
+		{"message", rules.Message, &p.message},
+		{"propertyReference", rules.PropertyReference, &p.reference},
+	} {
+		compiled, err := compileFieldRule(f.name, f.rule)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = compiled
+	}
+
+	return p, nil
+}
+
+func compileMatchPattern(field, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", field, err)
+	}
+	return re, nil
+}
+
+func compileFieldRule(field string, rule entity.TemplateFieldRule) (fieldRule, error) {
+	compiled := fieldRule{subject: rule.Subject}
+	if rule.Subject && rule.Selector != "" {
+		return compiled, fmt.Errorf("invalid %s rule: a selector cannot be used on the subject", field)
+	}
+	if rule.Selector != "" {
+		sel, err := compileSelector(rule.Selector)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid %s rule: %w", field, err)
+		}
+		compiled.selector = sel
+	}
+	if rule.Regex != "" {
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid %s rule: %w", field, err)
+		}
+		compiled.regex = re
+	}
+	return compiled, nil
+}
+
+// CanParse checks the sender and subject against the template patterns
+func (p *TemplateParser) CanParse(subject, from string) bool {
+	if p.from != nil && !p.from.MatchString(from) {
+		return false
+	}
+	if p.subject != nil && !p.subject.MatchString(subject) {
+		return false
+	}
+	return true
+}
+
+// Parse extracts lead data with the template rules
+func (p *TemplateParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead, err := p.Extract(subject, body)
+	if err != nil {
+		return nil, err
+	}
+	return lead, nil
+}
+
+// Extract is Parse, but it also returns whatever it could extract when a
+// required field is missing, so a dry run can show it
+func (p *TemplateParser) Extract(subject, body string) (*entity.ParsedLead, error) {
+	source := p.template.Source
+	if source == "" {
+		source = p.template.Name
+	}
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSource(source),
+		ContactDate: time.Now(),
+	}
+
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s email: %w", p.template.Name, err)
+	}
+	bodyText := strings.Join(nodeText(doc), "\n")
+
+	extract := func(rule fieldRule) string {
+		text := bodyText
+		switch {
+		case rule.subject:
+			text = subject
+		case rule.selector != nil:
+			n := rule.selector.first(doc)
+			if n == nil {
+				return ""
+			}
+			text = strings.Join(nodeText(n), "\n")
+		}
+		if rule.regex == nil {
+			if rule.selector == nil {
+				return ""
+			}
+			return strings.TrimSpace(text)
+		}
+		matches := rule.regex.FindStringSubmatch(text)
+		switch {
+		case len(matches) > 1:
+			return strings.TrimSpace(matches[1])
+		case len(matches) == 1:
+			return strings.TrimSpace(matches[0])
+		}
+		return ""
+	}
+
+	lead.Name = extract(p.name)
+	lead.Email = strings.ToLower(emailAddressRegex.FindString(extract(p.email)))
+	lead.Phone = cleanPhone(extract(p.phone))
+	lead.Message = extract(p.message)
+	lead.PropertyReference = extract(p.reference)
+
+	if lead.PropertyReference == "" {
+		return lead, fmt.Errorf("could not extract property reference from %s email", p.template.Name)
+	}
+	if lead.Email == "" {
+		return lead, fmt.Errorf("could not extract email from %s email", p.template.Name)
+	}
+
+	return lead, nil
+}
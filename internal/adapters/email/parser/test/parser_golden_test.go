@@ -0,0 +1,78 @@
+package test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/email/parser"
+	"github.com/myestatia/myestatia-go/internal/domain/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with -update to rewrite the .golden files after changing a parser
+var update = flag.Bool("update", false, "update golden files")
+
+var portalEmails = []struct {
+	name    string
+	from    string
+	subject string
+	parser  port.EmailParser
+}{
+	{"habitaclia", "habitaclia <noreply@habitaclia.com>", "Nuevo contacto para tu inmueble con referencia HB-2345", &parser.HabitacliaParser{}},
+	{"pisos", "pisos.com <avisos@pisos.com>", "Nueva solicitud de información sobre tu anuncio", &parser.PisosParser{}},
+	{"kyero", "Kyero <enquiries@kyero.com>", "New enquiry for your property (ref. V-1020)", &parser.KyeroParser{}},
+	{"thinkspain", "thinkSPAIN <enquiries@thinkspain.com>", "thinkSPAIN enquiry - Ref: TS-4411", &parser.ThinkSpainParser{}},
+	{"milanuncios", "Milanuncios <no-reply@mail.milanuncios.com>", "Nuevo mensaje sobre tu anuncio", &parser.MilanunciosParser{}},
+}
+
+func TestParsers_GoldenFiles(t *testing.T) {
+	for _, tc := range portalEmails {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			body, err := os.ReadFile(filepath.Join("testdata", tc.name+".html"))
+			require.NoError(t, err)
+
+			// WHEN
+			lead, err := tc.parser.Parse(tc.subject, string(body))
+
+			// THEN
+			require.NoError(t, err)
+			lead.ContactDate = time.Time{}
+			got, err := json.MarshalIndent(lead, "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func TestParserFactory_RoutesEachPortal(t *testing.T) {
+	factory := parser.NewParserFactory()
+
+	for _, tc := range portalEmails {
+		p, err := factory.GetParser(tc.subject, tc.from)
+
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, reflect.TypeOf(tc.parser), reflect.TypeOf(p), tc.name)
+	}
+}
+
+func TestParsers_MissingEmailIsAnError(t *testing.T) {
+	for _, tc := range portalEmails {
+		_, err := tc.parser.Parse(tc.subject, "<html><body><p>Nombre: Ana</p><p>Referencia: X-1</p></body></html>")
+
+		assert.ErrorContains(t, err, "could not extract email", tc.name)
+	}
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/email/parser"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func websiteTemplate() *entity.EmailParserTemplate {
+	return &entity.EmailParserTemplate{
+		Name:           "Web",
+		FromPattern:    `@inmobiliaria-ejemplo\.com`,
+		SubjectPattern: `^formulario`,
+		Rules: datatypes.NewJSONType(entity.ParserTemplateRules{
+			Name:              entity.TemplateFieldRule{Selector: "table.form td#name"},
+			Email:             entity.TemplateFieldRule{Selector: "td#email > a[href]"},
+			Phone:             entity.TemplateFieldRule{Selector: "#phone"},
+			Message:           entity.TemplateFieldRule{Selector: "div.message"},
+			PropertyReference: entity.TemplateFieldRule{Selector: "p.footer", Regex: `REF:\s*(\S+)`},
+		}),
+	}
+}
+
+func TestTemplateParser_SelectorsAndRegex(t *testing.T) {
+	// GIVEN
+	body, err := os.ReadFile(filepath.Join("testdata", "website_form.html"))
+	require.NoError(t, err)
+	p, err := parser.NewTemplateParser(websiteTemplate())
+	require.NoError(t, err)
+
+	// WHEN
+	lead, err := p.Parse("Formulario de contacto", string(body))
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "Lucía Fernández", lead.Name)
+	assert.Equal(t, "lucia.fernandez@example.com", lead.Email)
+	assert.Equal(t, "611223344", lead.Phone)
+	assert.Equal(t, "Me interesa la casa de la playa.\n¿Tiene garaje?", lead.Message)
+	assert.Equal(t, "CASA-77", lead.PropertyReference)
+	assert.Equal(t, entity.EmailSource("Web"), lead.Source)
+}
+
+func TestTemplateParser_CanParseNeedsEveryPattern(t *testing.T) {
+	p, err := parser.NewTemplateParser(websiteTemplate())
+	require.NoError(t, err)
+
+	assert.True(t, p.CanParse("FORMULARIO web", "Web <no-reply@inmobiliaria-ejemplo.com>"))
+	assert.False(t, p.CanParse("Formulario web", "someone@example.com"))
+	assert.False(t, p.CanParse("Re: formulario", "no-reply@inmobiliaria-ejemplo.com"))
+}
+
+func TestTemplateParser_RegexOnSubject(t *testing.T) {
+	// GIVEN
+	template := websiteTemplate()
+	template.Rules = datatypes.NewJSONType(entity.ParserTemplateRules{
+		Email:             entity.TemplateFieldRule{Regex: `Email:\s*(\S+)`},
+		PropertyReference: entity.TemplateFieldRule{Regex: `\[(\w+-\d+)\]`, Subject: true},
+	})
+	p, err := parser.NewTemplateParser(template)
+	require.NoError(t, err)
+
+	// WHEN
+	lead, err := p.Parse("Formulario [PISO-12]", "Email: ana@example.com\nGracias")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", lead.Email)
+	assert.Equal(t, "PISO-12", lead.PropertyReference)
+}
+
+func TestNewTemplateParser_InvalidRules(t *testing.T) {
+	cases := map[string]func(*entity.EmailParserTemplate){
+		"from pattern": func(t *entity.EmailParserTemplate) { t.FromPattern = "(" },
+		"selector": func(t *entity.EmailParserTemplate) {
+			t.Rules = datatypes.NewJSONType(entity.ParserTemplateRules{
+				Email:             entity.TemplateFieldRule{Selector: "td:first-child"},
+				PropertyReference: entity.TemplateFieldRule{Regex: "x"},
+			})
+		},
+		"email rule": func(t *entity.EmailParserTemplate) {
+			t.Rules = datatypes.NewJSONType(entity.ParserTemplateRules{PropertyReference: entity.TemplateFieldRule{Regex: "x"}})
+		},
+		"match pattern": func(t *entity.EmailParserTemplate) { t.FromPattern, t.SubjectPattern = "", "" },
+	}
+
+	for name, change := range cases {
+		template := websiteTemplate()
+		change(template)
+
+		_, err := parser.NewTemplateParser(template)
+
+		assert.ErrorContains(t, err, "invalid", name)
+	}
+}
+
+func TestParserFactory_TemplatesGoFirst(t *testing.T) {
+	// GIVEN
+	template := websiteTemplate()
+	template.FromPattern = "idealista"
+	template.SubjectPattern = ""
+	p, err := parser.NewTemplateParser(template)
+	require.NoError(t, err)
+
+	// WHEN
+	found, err := parser.NewParserFactory().With(p).GetParser("Nuevo mensaje", "noreply@idealista.com")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Same(t, p, found)
+}
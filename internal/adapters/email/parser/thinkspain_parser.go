@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type ThinkSpainParser struct{}
+
+var thinkSpainFields = leadFields{
+	Name:       []string{"Name", "Full name"},
+	Email:      []string{"Email", "Email address"},
+	Phone:      []string{"Telephone", "Phone"},
+	Reference:  []string{"Your ref", "Reference", "Ref"},
+	Message:    []string{"Comments", "Message"},
+	MessageEnd: []string{"Reply", "This enquiry", "thinkSPAIN"},
+}
+
+// CanParse determines if this email is from ThinkSpain
+func (p *ThinkSpainParser) CanParse(subject, from string) bool {
+	return strings.Contains(strings.ToLower(from), "thinkspain") ||
+		strings.Contains(strings.ToLower(subject), "thinkspain")
+}
+
+// Parse extracts lead data from a ThinkSpain enquiry
+func (p *ThinkSpainParser) Parse(subject, body string) (*entity.ParsedLead, error) {
+	lead := &entity.ParsedLead{
+		Source:      entity.EmailSourceThinkSpain,
+		ContactDate: time.Now(),
+	}
+
+	thinkSpainFields.extract(textNodes(body), lead)
+
+	// Pattern in subject: "thinkSPAIN enquiry - Ref: TS-4411"
+	if lead.PropertyReference == "" {
+		refRegex := regexp.MustCompile(`(?i)\bref[.:]?\s*([A-Za-z0-9][\w/-]*)`)
+		if matches := refRegex.FindStringSubmatch(subject); len(matches) > 1 {
+			lead.PropertyReference = matches[1]
+		}
+	}
+
+	if lead.PropertyReference == "" {
+		return nil, fmt.Errorf("could not extract property reference from ThinkSpain email")
+	}
+	if lead.Email == "" {
+		return nil, fmt.Errorf("could not extract email from ThinkSpain email")
+	}
+
+	return lead, nil
+}
@@ -1,12 +1,12 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
 	"github.com/myestatia/myestatia-go/internal/application/service"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 )
@@ -36,10 +36,22 @@ func (h *AgentHandler) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
+	if req.Role == "" {
+		req.Role = entity.RoleAgent
+	}
+	if !req.Role.IsValid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
+	if req.Role == entity.RoleOwner && entity.AgentRole(executorRole) != entity.RoleOwner {
+		http.Error(w, "Only an owner can create another owner", http.StatusForbidden)
+		return
+	}
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = time.Now()
 
-	createdAgent, created, err := h.Service.Create(context.Background(), &req)
+	createdAgent, created, err := h.Service.Create(r.Context(), &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -61,7 +73,7 @@ func (h *AgentHandler) GetAllAgents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	companies, err := h.Service.FindAll(context.Background())
+	companies, err := h.Service.FindAll(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -84,7 +96,7 @@ func (h *AgentHandler) GetAgentByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	agent, err := h.Service.FindByID(context.Background(), id)
+	agent, err := h.Service.FindByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -111,17 +123,34 @@ func (h *AgentHandler) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req map[string]interface{}
+	// Only the profile can be edited here; other fields in the body are ignored
+	var req struct {
+		Name     *string `json:"name"`
+		Phone    *string `json:"phone"`
+		Password *string `json:"password"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
+	if req.Password != nil {
+		http.Error(w, "password is only changed through the password reset", http.StatusBadRequest)
+		return
+	}
 
-	req["id"] = id
-	req["updated_at"] = time.Now()
-
-	if err := h.Service.UpdatePartial(context.Background(), id, req); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
+	profile := service.AgentProfile{Name: req.Name, Phone: req.Phone}
+	if err := h.Service.UpdateProfile(r.Context(), id, profile, entity.AgentRole(executorRole)); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "required"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "unauthorized"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -141,10 +170,80 @@ func (h *AgentHandler) DeleteAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.Delete(context.Background(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	executorID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
+	if err := h.Service.Delete(r.Context(), id, executorID, entity.AgentRole(executorRole)); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "unauthorized"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// PUT /api/v1/agents/{id}/role
+func (h *AgentHandler) ChangeAgentRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Role entity.AgentRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	executorID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
+
+	if err := h.Service.ChangeRole(r.Context(), id, req.Role, executorID, entity.AgentRole(executorRole)); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid role"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "unauthorized"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /api/v1/roles
+func (h *AgentHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type roleDTO struct {
+		Role        entity.AgentRole    `json:"role"`
+		Permissions []entity.Permission `json:"permissions"`
+	}
+	roles := make([]roleDTO, 0, len(entity.Roles))
+	for _, role := range entity.Roles {
+		roles = append(roles, roleDTO{Role: role, Permissions: role.Permissions()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(roles)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type APIKeyHandler struct {
+	Service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{Service: s}
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string               `json:"name"`
+	Scopes []entity.APIKeyScope `json:"scopes"`
+}
+
+// CreateAPIKeyResponse is the only time the plain key is shown
+type CreateAPIKeyResponse struct {
+	APIKey *entity.APIKey `json:"apiKey"`
+	Key    string         `json:"key"`
+}
+
+// POST /api/v1/api-keys
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	companyID, _ := r.Context().Value(middleware.CompanyIDKey).(string)
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	key, plain, err := h.Service.Create(r.Context(), companyID, req.Name, req.Scopes, agentID)
+	if err != nil {
+		if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "invalid scope") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: key, Key: plain})
+}
+
+// GET /api/v1/api-keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(keys)
+}
+
+// DELETE /api/v1/api-keys/{id}
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Revoke(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,9 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
 	"github.com/myestatia/myestatia-go/internal/application/service"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
@@ -12,14 +15,26 @@ import (
 )
 
 type AuthHandler struct {
-	agentService   *service.AgentService
-	companyService *service.CompanyService
+	agentService     *service.AgentService
+	companyService   *service.CompanyService
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+	throttleService  *service.LoginThrottleService
 }
 
-func NewAuthHandler(agentService *service.AgentService, companyService *service.CompanyService) *AuthHandler {
+func NewAuthHandler(
+	agentService *service.AgentService,
+	companyService *service.CompanyService,
+	sessionService *service.SessionService,
+	twoFactorService *service.TwoFactorService,
+	throttleService *service.LoginThrottleService,
+) *AuthHandler {
 	return &AuthHandler{
-		agentService:   agentService,
-		companyService: companyService,
+		agentService:     agentService,
+		companyService:   companyService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		throttleService:  throttleService,
 	}
 }
 
@@ -35,9 +50,27 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type AuthResponse struct {
-	Token string       `json:"token"`
-	Agent entity.Agent `json:"agent"`
+	Token         string       `json:"token"`
+	RefreshToken  string       `json:"refreshToken"`
+	Agent         entity.Agent `json:"agent"`
+	RecoveryCodes []string     `json:"recoveryCodes,omitempty"` // Only right after enrolling in 2FA during login
+}
+
+// TwoFactorChallengeResponse is returned by Login instead of the tokens when a second factor is needed
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired      bool   `json:"twoFactorRequired"`
+	TwoFactorSetupRequired bool   `json:"twoFactorSetupRequired"`
+	ChallengeToken         string `json:"challengeToken"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -62,12 +95,16 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Name:   req.CompanyName,
 		Email1: req.Email, // Ensure required field Email1 is set (using agent email as fallback)
 	}
-	// Capture the returned company which has the ID (whether verified existing or newly created)
-	createdCompany, _, err := h.companyService.Create(r.Context(), company)
+	createdCompany, created, err := h.companyService.Create(r.Context(), company)
 	if err != nil {
 		http.Error(w, "Failed to create company: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Registering must never attach a new admin to someone else's company
+	if !created {
+		http.Error(w, "Company with this name already exists", http.StatusConflict)
+		return
+	}
 
 	// 2. Hash Password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -81,7 +118,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Name:      req.Name,
 		Email:     req.Email,
 		Password:  string(hashedPassword),
-		Role:      "admin", // First user is admin
+		Role:      entity.RoleOwner, // Whoever registers the company owns it
 		CompanyID: createdCompany.ID,
 	}
 
@@ -90,15 +127,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Generate Token
-	token, err := security.GenerateToken(agent.ID, agent.CompanyID, agent.Role)
+	// 4. Start Session
+	tokens, err := h.sessionService.Start(r.Context(), agent, r.UserAgent(), clientIP(r))
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(AuthResponse{Token: token, Agent: *agent})
+	json.NewEncoder(w).Encode(AuthResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, Agent: *agent})
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -110,26 +147,124 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Normalize email to lowercase
 	req.Email = strings.ToLower(req.Email)
+	ip := clientIP(r)
+
+	// 0. Refuse while the account or the IP is locked, without checking the password
+	retryAfter, err := h.throttleService.CheckLogin(r.Context(), req.Email, ip)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
 
 	// 1. Find Agent by Email
 	agent, err := h.agentService.GetByEmail(r.Context(), req.Email)
 	if err != nil || agent == nil { // Check for nil if repo returns nil on not found
+		h.recordLoginFailure(r, req.Email, ip)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	// 2. Check Password
 	if err := bcrypt.CompareHashAndPassword([]byte(agent.Password), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(r, req.Email, ip)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	// 3. Generate Token
-	token, err := security.GenerateToken(agent.ID, agent.CompanyID, agent.Role)
+	// 3. Second factor, if enabled for the agent or required by the company
+	step, err := h.twoFactorService.NextLoginStep(r.Context(), agent)
+	if err != nil {
+		http.Error(w, "Failed to check two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if step != service.LoginStepDone {
+		audience := security.ChallengeTwoFactor
+		if step == service.LoginStepTwoFactorSetup {
+			audience = security.ChallengeTwoFactorSetup
+		}
+		challenge, err := security.GenerateChallengeToken(agent.ID, audience)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(TwoFactorChallengeResponse{
+			TwoFactorRequired:      true,
+			TwoFactorSetupRequired: step == service.LoginStepTwoFactorSetup,
+			ChallengeToken:         challenge,
+		})
+		return
+	}
+
+	// 4. Start Session
+	tokens, err := h.sessionService.Start(r.Context(), agent, r.UserAgent(), ip)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
+	if err := h.throttleService.RecordLoginSuccess(r.Context(), agent.Email); err != nil {
+		log.Printf("[Auth] ERROR: Failed to reset login attempts of %s: %v", agent.Email, err)
+	}
+
+	json.NewEncoder(w).Encode(AuthResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, Agent: *agent})
+}
+
+// recordLoginFailure counts a failed attempt; errors are logged so they never change the answer
+func (h *AuthHandler) recordLoginFailure(r *http.Request, email, ip string) {
+	if err := h.throttleService.RecordLoginFailure(r.Context(), email, ip); err != nil {
+		log.Printf("[Auth] ERROR: Failed to record failed login: %v", err)
+	}
+}
+
+// Refresh rotates the refresh token and returns a new access token
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(TokenResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken})
+}
+
+// Logout revokes the session of the token used for this request
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, _ := r.Context().Value(middleware.SessionIDKey).(string)
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	if err := h.sessionService.Revoke(r.Context(), sessionID, agentID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every session of the caller, including the current one
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	if err := h.sessionService.RevokeAll(r.Context(), agentID); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
 
-	json.NewEncoder(w).Encode(AuthResponse{Token: token, Agent: *agent})
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -21,38 +21,62 @@ func NewCompanyEmailConfigHandler(emailConfigService *service.CompanyEmailConfig
 }
 
 type EmailConfigRequest struct {
-	IMAPHost         string `json:"imapHost"`
-	IMAPPort         int    `json:"imapPort"`
-	IMAPUsername     string `json:"imapUsername"`
-	IMAPPassword     string `json:"imapPassword"` // Only for create/update
-	InboxFolder      string `json:"inboxFolder"`
-	PollIntervalSecs int    `json:"pollIntervalSecs"`
+	Label            string  `json:"label"`
+	DefaultAgentID   *string `json:"defaultAgentId"`
+	IMAPHost         string  `json:"imapHost"`
+	IMAPPort         int     `json:"imapPort"`
+	IMAPUsername     string  `json:"imapUsername"`
+	IMAPPassword     string  `json:"imapPassword"` // Only for create/update
+	InboxFolder      string  `json:"inboxFolder"`
+	PollIntervalSecs int     `json:"pollIntervalSecs"`
+	SyncMode         string  `json:"syncMode"` // "poll" (default) or "idle"
 }
 
 type EmailConfigResponse struct {
 	ID               string  `json:"id"`
 	CompanyID        string  `json:"companyId"`
+	Label            string  `json:"label"`
+	DefaultAgentID   *string `json:"defaultAgentId"`
 	AuthMethod       string  `json:"authMethod"` // "password" or "oauth2"
 	IMAPHost         string  `json:"imapHost"`
 	IMAPPort         int     `json:"imapPort"`
 	IMAPUsername     string  `json:"imapUsername"`
 	InboxFolder      string  `json:"inboxFolder"`
 	PollIntervalSecs int     `json:"pollIntervalSecs"`
+	SyncMode         string  `json:"syncMode"`
 	IsEnabled        bool    `json:"isEnabled"`
 	LastSyncAt       *string `json:"lastSyncAt"` // ISO format string
+
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	DisabledReason      string `json:"disabledReason,omitempty"`
+}
+
+// EmailInboxStatusResponse is the ingestion health of one inbox
+type EmailInboxStatusResponse struct {
+	Inbox      EmailConfigResponse   `json:"inbox"`
+	Healthy    bool                  `json:"healthy"`
+	RecentRuns []entity.EmailSyncRun `json:"recentRuns"` // newest first
 }
 
 func toResponse(config *entity.CompanyEmailConfig) EmailConfigResponse {
 	response := EmailConfigResponse{
 		ID:               config.ID,
 		CompanyID:        config.CompanyID,
+		Label:            config.Label,
+		DefaultAgentID:   config.DefaultAgentID,
 		AuthMethod:       config.AuthMethod,
 		IMAPHost:         config.IMAPHost,
 		IMAPPort:         config.IMAPPort,
 		IMAPUsername:     config.IMAPUsername,
 		InboxFolder:      config.InboxFolder,
 		PollIntervalSecs: config.PollIntervalSecs,
+		SyncMode:         config.SyncMode,
 		IsEnabled:        config.IsEnabled,
+
+		ConsecutiveFailures: config.ConsecutiveFailures,
+		LastError:           config.LastError,
+		DisabledReason:      config.DisabledReason,
 	}
 
 	if config.LastSyncAt != nil {
@@ -63,17 +87,31 @@ func toResponse(config *entity.CompanyEmailConfig) EmailConfigResponse {
 	return response
 }
 
+func (req EmailConfigRequest) toInput() service.EmailConfigInput {
+	return service.EmailConfigInput{
+		Label:            req.Label,
+		DefaultAgentID:   req.DefaultAgentID,
+		IMAPHost:         req.IMAPHost,
+		IMAPPort:         req.IMAPPort,
+		IMAPUsername:     req.IMAPUsername,
+		IMAPPassword:     req.IMAPPassword,
+		InboxFolder:      req.InboxFolder,
+		PollIntervalSecs: req.PollIntervalSecs,
+		SyncMode:         req.SyncMode,
+	}
+}
+
 // CreateEmailConfig handles POST /api/companies/{id}/email-config
 func (h *CompanyEmailConfigHandler) CreateEmailConfig(w http.ResponseWriter, r *http.Request) {
-	// Extract company ID from URL path
-	companyID := extractCompanyIDFromPath(r.URL.Path)
+	companyID := r.PathValue("id")
 	if companyID == "" {
 		http.Error(w, "Invalid company ID", http.StatusBadRequest)
 		return
 	}
 
-	// TODO: Add authorization check - only agents from same company can create config
-	// For now, we'll skip this until auth is implemented
+	if !requireSameCompany(w, r, companyID) {
+		return
+	}
 
 	var req EmailConfigRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -87,20 +125,11 @@ func (h *CompanyEmailConfigHandler) CreateEmailConfig(w http.ResponseWriter, r *
 		return
 	}
 
-	config, err := h.emailConfigService.CreateConfig(
-		r.Context(),
-		companyID,
-		req.IMAPHost,
-		req.IMAPUsername,
-		req.IMAPPassword,
-		req.IMAPPort,
-		req.PollIntervalSecs,
-		req.InboxFolder,
-	)
+	config, err := h.emailConfigService.CreateConfig(r.Context(), companyID, req.toInput())
 	if err != nil {
 		log.Printf("[CompanyEmailConfigHandler] Error creating config: %v", err)
-		if strings.Contains(err.Error(), "already exists") {
-			http.Error(w, err.Error(), http.StatusConflict)
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		} else {
 			http.Error(w, "Failed to create email configuration", http.StatusInternalServerError)
 		}
@@ -112,51 +141,85 @@ func (h *CompanyEmailConfigHandler) CreateEmailConfig(w http.ResponseWriter, r *
 	json.NewEncoder(w).Encode(toResponse(config))
 }
 
-// GetEmailConfig handles GET /api/companies/{id}/email-config
-func (h *CompanyEmailConfigHandler) GetEmailConfig(w http.ResponseWriter, r *http.Request) {
-	companyID := extractCompanyIDFromPath(r.URL.Path)
+// ListEmailConfigs handles GET /api/companies/{id}/email-config
+func (h *CompanyEmailConfigHandler) ListEmailConfigs(w http.ResponseWriter, r *http.Request) {
+	companyID := r.PathValue("id")
 	if companyID == "" {
 		http.Error(w, "Invalid company ID", http.StatusBadRequest)
 		return
 	}
 
-	// TODO: Add authorization check
+	if !requireSameCompany(w, r, companyID) {
+		return
+	}
 
-	config, err := h.emailConfigService.GetConfigByCompanyID(r.Context(), companyID)
+	configs, err := h.emailConfigService.ListConfigs(r.Context(), companyID)
 	if err != nil {
-		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
-		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
+		log.Printf("[CompanyEmailConfigHandler] Error fetching configs: %v", err)
+		http.Error(w, "Failed to fetch email configurations", http.StatusInternalServerError)
 		return
 	}
 
-	if config == nil {
-		http.Error(w, "Email configuration not found", http.StatusNotFound)
-		return
+	response := make([]EmailConfigResponse, 0, len(configs))
+	for _, config := range configs {
+		response = append(response, toResponse(config))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(toResponse(config))
+	json.NewEncoder(w).Encode(response)
 }
 
-// UpdateEmailConfig handles PUT /api/companies/{id}/email-config
-func (h *CompanyEmailConfigHandler) UpdateEmailConfig(w http.ResponseWriter, r *http.Request) {
-	companyID := extractCompanyIDFromPath(r.URL.Path)
+// GetIngestionStatus handles GET /api/companies/{id}/email-config/status
+func (h *CompanyEmailConfigHandler) GetIngestionStatus(w http.ResponseWriter, r *http.Request) {
+	companyID := r.PathValue("id")
 	if companyID == "" {
 		http.Error(w, "Invalid company ID", http.StatusBadRequest)
 		return
 	}
 
-	// TODO: Add authorization check
+	if !requireSameCompany(w, r, companyID) {
+		return
+	}
 
-	// First, get existing config to get its ID
-	existingConfig, err := h.emailConfigService.GetConfigByCompanyID(r.Context(), companyID)
+	statuses, err := h.emailConfigService.GetStatus(r.Context(), companyID)
 	if err != nil {
-		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
-		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
+		log.Printf("[CompanyEmailConfigHandler] Error fetching ingestion status: %v", err)
+		http.Error(w, "Failed to fetch ingestion status", http.StatusInternalServerError)
 		return
 	}
-	if existingConfig == nil {
-		http.Error(w, "Email configuration not found", http.StatusNotFound)
+
+	response := make([]EmailInboxStatusResponse, 0, len(statuses))
+	for _, status := range statuses {
+		runs := status.RecentRuns
+		if runs == nil {
+			runs = []entity.EmailSyncRun{}
+		}
+		response = append(response, EmailInboxStatusResponse{
+			Inbox:      toResponse(status.Config),
+			Healthy:    status.Config.IsEnabled && status.Config.ConsecutiveFailures == 0,
+			RecentRuns: runs,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetEmailConfig handles GET /api/companies/{id}/email-config/{configId}
+func (h *CompanyEmailConfigHandler) GetEmailConfig(w http.ResponseWriter, r *http.Request) {
+	config, ok := h.configFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toResponse(config))
+}
+
+// UpdateEmailConfig handles PUT /api/companies/{id}/email-config/{configId}
+func (h *CompanyEmailConfigHandler) UpdateEmailConfig(w http.ResponseWriter, r *http.Request) {
+	existingConfig, ok := h.configFromPath(w, r)
+	if !ok {
 		return
 	}
 
@@ -167,23 +230,18 @@ func (h *CompanyEmailConfigHandler) UpdateEmailConfig(w http.ResponseWriter, r *
 	}
 
 	// Validate required fields (password is optional for update)
-	if req.IMAPHost == "" || req.IMAPUsername == "" {
+	if existingConfig.AuthMethod == entity.EmailAuthPassword && (req.IMAPHost == "" || req.IMAPUsername == "") {
 		http.Error(w, "imapHost and imapUsername are required", http.StatusBadRequest)
 		return
 	}
 
-	config, err := h.emailConfigService.UpdateConfig(
-		r.Context(),
-		existingConfig.ID,
-		req.IMAPHost,
-		req.IMAPUsername,
-		req.IMAPPassword, // Empty string means don't change password
-		req.IMAPPort,
-		req.PollIntervalSecs,
-		req.InboxFolder,
-	)
+	config, err := h.emailConfigService.UpdateConfig(r.Context(), existingConfig.ID, req.toInput())
 	if err != nil {
 		log.Printf("[CompanyEmailConfigHandler] Error updating config: %v", err)
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to update email configuration", http.StatusInternalServerError)
 		return
 	}
@@ -192,25 +250,10 @@ func (h *CompanyEmailConfigHandler) UpdateEmailConfig(w http.ResponseWriter, r *
 	json.NewEncoder(w).Encode(toResponse(config))
 }
 
-// DeleteEmailConfig handles DELETE /api/companies/{id}/email-config
+// DeleteEmailConfig handles DELETE /api/companies/{id}/email-config/{configId}
 func (h *CompanyEmailConfigHandler) DeleteEmailConfig(w http.ResponseWriter, r *http.Request) {
-	companyID := extractCompanyIDFromPath(r.URL.Path)
-	if companyID == "" {
-		http.Error(w, "Invalid company ID", http.StatusBadRequest)
-		return
-	}
-
-	// TODO: Add authorization check
-
-	// Get config to get its ID
-	config, err := h.emailConfigService.GetConfigByCompanyID(r.Context(), companyID)
-	if err != nil {
-		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
-		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
-		return
-	}
-	if config == nil {
-		http.Error(w, "Email configuration not found", http.StatusNotFound)
+	config, ok := h.configFromPath(w, r)
+	if !ok {
 		return
 	}
 
@@ -223,24 +266,10 @@ func (h *CompanyEmailConfigHandler) DeleteEmailConfig(w http.ResponseWriter, r *
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// TestConnection handles POST /api/companies/{id}/email-config/test
+// TestConnection handles POST /api/companies/{id}/email-config/{configId}/test
 func (h *CompanyEmailConfigHandler) TestConnection(w http.ResponseWriter, r *http.Request) {
-	companyID := extractCompanyIDFromPath(r.URL.Path)
-	if companyID == "" {
-		http.Error(w, "Invalid company ID", http.StatusBadRequest)
-		return
-	}
-
-	// TODO: Add authorization check
-
-	config, err := h.emailConfigService.GetConfigByCompanyID(r.Context(), companyID)
-	if err != nil {
-		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
-		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
-		return
-	}
-	if config == nil {
-		http.Error(w, "Email configuration not found", http.StatusNotFound)
+	config, ok := h.configFromPath(w, r)
+	if !ok {
 		return
 	}
 
@@ -264,16 +293,13 @@ func (h *CompanyEmailConfigHandler) TestConnection(w http.ResponseWriter, r *htt
 	json.NewEncoder(w).Encode(response)
 }
 
-// ToggleEnabled handles PATCH /api/companies/{id}/email-config/toggle
+// ToggleEnabled handles PATCH /api/companies/{id}/email-config/{configId}/toggle
 func (h *CompanyEmailConfigHandler) ToggleEnabled(w http.ResponseWriter, r *http.Request) {
-	companyID := extractCompanyIDFromPath(r.URL.Path)
-	if companyID == "" {
-		http.Error(w, "Invalid company ID", http.StatusBadRequest)
+	config, ok := h.configFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	// TODO: Add authorization check
-
 	var req struct {
 		Enabled bool `json:"enabled"`
 	}
@@ -282,17 +308,6 @@ func (h *CompanyEmailConfigHandler) ToggleEnabled(w http.ResponseWriter, r *http
 		return
 	}
 
-	config, err := h.emailConfigService.GetConfigByCompanyID(r.Context(), companyID)
-	if err != nil {
-		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
-		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
-		return
-	}
-	if config == nil {
-		http.Error(w, "Email configuration not found", http.StatusNotFound)
-		return
-	}
-
 	if err := h.emailConfigService.ToggleEnabled(r.Context(), config.ID, req.Enabled); err != nil {
 		log.Printf("[CompanyEmailConfigHandler] Error toggling config: %v", err)
 		http.Error(w, "Failed to toggle email configuration", http.StatusInternalServerError)
@@ -302,14 +317,29 @@ func (h *CompanyEmailConfigHandler) ToggleEnabled(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// extractCompanyIDFromPath extracts company ID from URL path
-// Example: /api/companies/123-456/email-config -> "123-456"
-func extractCompanyIDFromPath(path string) string {
-	parts := strings.Split(path, "/")
-	for i, part := range parts {
-		if part == "companies" && i+1 < len(parts) {
-			return parts[i+1]
+// configFromPath loads the inbox named by the {id} and {configId} path values,
+// answering the request itself when it can't
+func (h *CompanyEmailConfigHandler) configFromPath(w http.ResponseWriter, r *http.Request) (*entity.CompanyEmailConfig, bool) {
+	companyID := r.PathValue("id")
+	configID := r.PathValue("configId")
+	if companyID == "" || configID == "" {
+		http.Error(w, "Invalid company or config ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !requireSameCompany(w, r, companyID) {
+		return nil, false
+	}
+
+	config, err := h.emailConfigService.GetConfig(r.Context(), companyID, configID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Email configuration not found", http.StatusNotFound)
+			return nil, false
 		}
+		log.Printf("[CompanyEmailConfigHandler] Error fetching config: %v", err)
+		http.Error(w, "Failed to fetch email configuration", http.StatusInternalServerError)
+		return nil, false
 	}
-	return ""
+	return config, true
 }
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -39,7 +38,7 @@ func (h *CompanyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = time.Now()
 
-	createdCompany, created, err := h.Service.Create(context.Background(), &req)
+	createdCompany, created, err := h.Service.Create(r.Context(), &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -61,7 +60,7 @@ func (h *CompanyHandler) GetAllCompanies(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	companies, err := h.Service.FindAll(context.Background())
+	companies, err := h.Service.FindAll(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -84,7 +83,7 @@ func (h *CompanyHandler) GetCompanyByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	company, err := h.Service.FindByID(context.Background(), id)
+	company, err := h.Service.FindByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -119,8 +118,14 @@ func (h *CompanyHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
 
 	req["id"] = id
 	req["updated_at"] = time.Now()
-
-	if err := h.Service.UpdatePartial(context.Background(), id, req); err != nil {
+	// The 2FA requirement is only changed through PUT /api/v1/companies/{id}/two-factor
+	delete(req, "require_two_factor")
+
+	if err := h.Service.UpdatePartial(r.Context(), id, req); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -141,7 +146,11 @@ func (h *CompanyHandler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.Delete(context.Background(), id); err != nil {
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
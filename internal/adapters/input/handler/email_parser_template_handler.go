@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/datatypes"
+)
+
+type EmailParserTemplateHandler struct {
+	Service *service.EmailParserTemplateService
+}
+
+func NewEmailParserTemplateHandler(s *service.EmailParserTemplateService) *EmailParserTemplateHandler {
+	return &EmailParserTemplateHandler{Service: s}
+}
+
+type EmailParserTemplateRequest struct {
+	Name           string                     `json:"name"`
+	Source         string                     `json:"source"`
+	FromPattern    string                     `json:"fromPattern"`
+	SubjectPattern string                     `json:"subjectPattern"`
+	Rules          entity.ParserTemplateRules `json:"rules"`
+	Priority       int                        `json:"priority"`
+	IsEnabled      *bool                      `json:"isEnabled"` // Defaults to true
+}
+
+func (req EmailParserTemplateRequest) toEntity() *entity.EmailParserTemplate {
+	template := &entity.EmailParserTemplate{
+		Name:           req.Name,
+		Source:         req.Source,
+		FromPattern:    req.FromPattern,
+		SubjectPattern: req.SubjectPattern,
+		Rules:          datatypes.NewJSONType(req.Rules),
+		Priority:       req.Priority,
+		IsEnabled:      true,
+	}
+	if req.IsEnabled != nil {
+		template.IsEnabled = *req.IsEnabled
+	}
+	return template
+}
+
+// DryRunRequest runs a pasted email through a saved template (templateId)
+// or through rules that have not been saved yet (template)
+type DryRunRequest struct {
+	TemplateID string                      `json:"templateId"`
+	Template   *EmailParserTemplateRequest `json:"template"`
+	From       string                      `json:"from"`
+	Subject    string                      `json:"subject"`
+	Body       string                      `json:"body"`
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /api/v1/email-parsers
+func (h *EmailParserTemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req EmailParserTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	template := req.toEntity()
+	if err := h.Service.Create(r.Context(), template); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(template)
+}
+
+// GET /api/v1/email-parsers
+func (h *EmailParserTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.Service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(templates)
+}
+
+// GET /api/v1/email-parsers/{id}
+func (h *EmailParserTemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.Service.GetByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(template)
+}
+
+// PUT /api/v1/email-parsers/{id}
+func (h *EmailParserTemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req EmailParserTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	template := req.toEntity()
+	template.ID = r.PathValue("id")
+	if err := h.Service.Update(r.Context(), template); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(template)
+}
+
+// DELETE /api/v1/email-parsers/{id}
+func (h *EmailParserTemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/v1/email-parsers/dry-run
+func (h *EmailParserTemplateHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	var req DryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	var template *entity.EmailParserTemplate
+	switch {
+	case req.Template != nil:
+		template = req.Template.toEntity()
+	case req.TemplateID != "":
+		saved, err := h.Service.GetByID(r.Context(), req.TemplateID)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+		template = saved
+	default:
+		http.Error(w, "templateId or template is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Service.DryRun(template, req.Subject, req.From, req.Body)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+type FailedEmailHandler struct {
+	Service *service.FailedEmailService
+}
+
+func NewFailedEmailHandler(s *service.FailedEmailService) *FailedEmailHandler {
+	return &FailedEmailHandler{Service: s}
+}
+
+func writeFailedEmailError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "already resolved"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GET /api/v1/failed-emails?category=unknown_reference&includeResolved=true
+func (h *FailedEmailHandler) ListFailedEmails(w http.ResponseWriter, r *http.Request) {
+	filter := repository.FailedEmailFilter{
+		Category:        entity.EmailFailureCategory(r.URL.Query().Get("category")),
+		IncludeResolved: r.URL.Query().Get("includeResolved") == "true",
+	}
+
+	failed, err := h.Service.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(failed)
+}
+
+// GET /api/v1/failed-emails/{id}
+func (h *FailedEmailHandler) GetFailedEmail(w http.ResponseWriter, r *http.Request) {
+	failed, err := h.Service.GetByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeFailedEmailError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(failed)
+}
+
+// POST /api/v1/failed-emails/{id}/reprocess answers with the updated record:
+// resolvedAt is set when a lead was created, otherwise error has the new cause
+func (h *FailedEmailHandler) ReprocessFailedEmail(w http.ResponseWriter, r *http.Request) {
+	failed, err := h.Service.Reprocess(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeFailedEmailError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(failed)
+}
+
+// DELETE /api/v1/failed-emails/{id}
+func (h *FailedEmailHandler) DiscardFailedEmail(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.Discard(r.Context(), r.PathValue("id")); err != nil {
+		writeFailedEmailError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
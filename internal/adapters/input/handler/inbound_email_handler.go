@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+)
+
+type InboundEmailHandler struct {
+	Service *service.InboundEmailService
+}
+
+func NewInboundEmailHandler(s *service.InboundEmailService) *InboundEmailHandler {
+	return &InboundEmailHandler{Service: s}
+}
+
+// GET /api/v1/lead/{id}/emails/{emailId} returns the original email behind a
+// message of the lead's conversation (its inboundEmailId), with headers,
+// bodies, attachments and what was parsed out of it
+func (h *InboundEmailHandler) GetLeadEmail(w http.ResponseWriter, r *http.Request) {
+	inbound, err := h.Service.GetForLead(r.Context(), r.PathValue("id"), r.PathValue("emailId"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range inbound.Attachments {
+		if attachment := &inbound.Attachments[i]; attachment.StorageKey != "" {
+			attachment.URL = fmt.Sprintf("/api/v1/lead/%s/emails/%s/attachments/%s", r.PathValue("id"), inbound.ID, attachment.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(inbound)
+}
+
+// GET /api/v1/lead/{id}/emails/{emailId}/attachments/{attachmentId} downloads
+// an attachment of the original email. It is always served as a download of
+// opaque bytes: what the sender claimed it was, e.g. HTML, is not trusted.
+func (h *InboundEmailHandler) GetLeadEmailAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, content, err := h.Service.OpenAttachment(r.Context(), r.PathValue("id"), r.PathValue("emailId"), r.PathValue("attachmentId"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	filename := attachment.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Length", strconv.Itoa(attachment.Size))
+	_, _ = io.Copy(w, content)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+)
+
+type InvitationHandler struct {
+	Service          *service.InvitationService
+	SessionService   *service.SessionService
+	TwoFactorService *service.TwoFactorService
+}
+
+func NewInvitationHandler(s *service.InvitationService, sessionService *service.SessionService, twoFactorService *service.TwoFactorService) *InvitationHandler {
+	return &InvitationHandler{Service: s, SessionService: sessionService, TwoFactorService: twoFactorService}
+}
+
+type CreateInvitationRequest struct {
+	Email string           `json:"email"`
+	Role  entity.AgentRole `json:"role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+type InvitationInfoResponse struct {
+	Email       string           `json:"email"`
+	Role        entity.AgentRole `json:"role"`
+	CompanyName string           `json:"companyName"`
+}
+
+// POST /api/v1/invitations
+func (h *InvitationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	companyID, _ := r.Context().Value(middleware.CompanyIDKey).(string)
+	inviterID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+	inviterRole, _ := r.Context().Value(middleware.RoleKey).(string)
+
+	invitation, err := h.Service.Invite(r.Context(), companyID, req.Email, req.Role, inviterID, entity.AgentRole(inviterRole))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "required"), strings.Contains(err.Error(), "invalid role"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "unauthorized"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(invitation)
+}
+
+// GET /api/v1/invitations
+func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.Service.ListPending(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(invitations)
+}
+
+// DELETE /api/v1/invitations/{id}
+func (h *InvitationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Revoke(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/v1/auth/invitations/{token}
+func (h *InvitationHandler) ValidateInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, err := h.Service.Validate(r.Context(), r.PathValue("token"))
+	if err != nil {
+		http.Error(w, "Invalid or expired invitation", http.StatusBadRequest)
+		return
+	}
+
+	resp := InvitationInfoResponse{Email: invitation.Email, Role: invitation.Role}
+	if invitation.Company != nil {
+		resp.CompanyName = invitation.Company.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/v1/auth/accept-invitation creates the agent and logs them in
+func (h *InvitationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agent, err := h.Service.Accept(r.Context(), req.Token, req.Name, req.Phone, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidInvitation):
+			http.Error(w, "Invalid or expired invitation", http.StatusBadRequest)
+		case strings.Contains(err.Error(), "required"), strings.Contains(err.Error(), "password"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to accept invitation: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Companies that require 2FA get the new agent enrolled before the first session
+	step, err := h.TwoFactorService.NextLoginStep(r.Context(), agent)
+	if err != nil {
+		http.Error(w, "Failed to check two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if step == service.LoginStepTwoFactorSetup {
+		challenge, err := security.GenerateChallengeToken(agent.ID, security.ChallengeTwoFactorSetup)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(TwoFactorChallengeResponse{TwoFactorRequired: true, TwoFactorSetupRequired: true, ChallengeToken: challenge})
+		return
+	}
+
+	tokens, err := h.SessionService.Start(r.Context(), agent, r.UserAgent(), clientIP(r))
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AuthResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, Agent: *agent})
+}
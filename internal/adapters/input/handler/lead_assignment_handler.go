@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+type LeadAssignmentHandler struct {
+	Service *service.LeadAssignmentService
+}
+
+func NewLeadAssignmentHandler(s *service.LeadAssignmentService) *LeadAssignmentHandler {
+	return &LeadAssignmentHandler{Service: s}
+}
+
+type LeadAssignmentRuleRequest struct {
+	Name         string                    `json:"name"`
+	Strategy     entity.AssignmentStrategy `json:"strategy"`
+	AgentID      *string                   `json:"agentId"` // For the owner strategy
+	Zone         string                    `json:"zone"`
+	PropertyType string                    `json:"propertyType"`
+	Priority     int                       `json:"priority"`
+	IsEnabled    *bool                     `json:"isEnabled"` // Defaults to true
+}
+
+func (req LeadAssignmentRuleRequest) toEntity() *entity.LeadAssignmentRule {
+	rule := &entity.LeadAssignmentRule{
+		Name:         req.Name,
+		Strategy:     req.Strategy,
+		AgentID:      req.AgentID,
+		Zone:         req.Zone,
+		PropertyType: req.PropertyType,
+		Priority:     req.Priority,
+		IsEnabled:    true,
+	}
+	if req.IsEnabled != nil {
+		rule.IsEnabled = *req.IsEnabled
+	}
+	return rule
+}
+
+func writeAssignmentError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /api/v1/leads/{id}/assign assigns the lead to agentId, or unassigns it
+// when agentId is empty
+func (h *LeadAssignmentHandler) AssignLead(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AgentID string `json:"agentId"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	byAgentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+	assignment, err := h.Service.Reassign(r.Context(), r.PathValue("id"), req.AgentID, req.Note, byAgentID)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(assignment)
+}
+
+// GET /api/v1/lead/{id}/assignments lists who the lead was assigned to, oldest first
+func (h *LeadAssignmentHandler) GetLeadAssignments(w http.ResponseWriter, r *http.Request) {
+	assignments, err := h.Service.History(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(assignments)
+}
+
+// POST /api/v1/assignment-rules
+func (h *LeadAssignmentHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req LeadAssignmentRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	rule := req.toEntity()
+	if err := h.Service.CreateRule(r.Context(), rule); err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// GET /api/v1/assignment-rules lists the rules in the order they are tried
+func (h *LeadAssignmentHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Service.ListRules(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules)
+}
+
+// GET /api/v1/assignment-rules/{id}
+func (h *LeadAssignmentHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.Service.GetRule(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// PUT /api/v1/assignment-rules/{id}
+func (h *LeadAssignmentHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	var req LeadAssignmentRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	rule := req.toEntity()
+	rule.ID = r.PathValue("id")
+	if err := h.Service.UpdateRule(r.Context(), rule); err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// DELETE /api/v1/assignment-rules/{id}
+func (h *LeadAssignmentHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeleteRule(r.Context(), r.PathValue("id")); err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
 	"github.com/myestatia/myestatia-go/internal/application/service"
@@ -12,11 +14,12 @@ import (
 )
 
 type LeadHandler struct {
-	Service *service.LeadService
+	Service  *service.LeadService
+	Statuses *service.LeadStatusService
 }
 
-func NewLeadHandler(s *service.LeadService) *LeadHandler {
-	return &LeadHandler{Service: s}
+func NewLeadHandler(s *service.LeadService, statuses *service.LeadStatusService) *LeadHandler {
+	return &LeadHandler{Service: s, Statuses: statuses}
 }
 
 // POST /api/v1/leads
@@ -44,17 +47,20 @@ func (h *LeadHandler) CreateLead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var propertyID *string
-	if req.PropertyID != "" {
-		propertyID = &req.PropertyID
-	}
-
 	companyID, ok := r.Context().Value(middleware.CompanyIDKey).(string)
 	if !ok || companyID == "" {
 		http.Error(w, "Unauthorized: invalid company context", http.StatusUnauthorized)
 		return
 	}
 
+	var propertyID *string
+	if req.PropertyID != "" {
+		if !h.checkProperty(w, r, companyID, req.PropertyID) {
+			return
+		}
+		propertyID = &req.PropertyID
+	}
+
 	lead := &entity.Lead{
 		Name:         req.Name,
 		Email:        req.Email,
@@ -68,7 +74,7 @@ func (h *LeadHandler) CreateLead(w http.ResponseWriter, r *http.Request) {
 		CompanyID:    companyID,
 	}
 
-	createdLead, created, err := h.Service.Create(context.Background(), lead)
+	createdLead, created, err := h.Service.Create(r.Context(), lead)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -83,6 +89,20 @@ func (h *LeadHandler) CreateLead(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(createdLead)
 }
 
+// checkProperty writes a 404 unless propertyID is a property of companyID,
+// and tells whether it is
+func (h *LeadHandler) checkProperty(w http.ResponseWriter, r *http.Request, companyID, propertyID string) bool {
+	if err := h.Service.CheckProperty(r.Context(), companyID, propertyID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return false
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
 // GET /api/v1/leads
 func (h *LeadHandler) GetAllLeads(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -90,14 +110,83 @@ func (h *LeadHandler) GetAllLeads(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	leads, err := h.Service.FindAll(context.Background())
+	filter, err := parseLeadFilter(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeLeadPage(w, r, filter)
+}
+
+// parseLeadFilter reads a LeadFilter from the query, e.g.
+// ?q=garcia&status=new,contacted&agent=none&minBudget=200000&createdFrom=2026-01-01&sort=-score,createdAt&limit=20&cursor=...
+// Date ranges exclude their end, as in the funnel.
+func parseLeadFilter(r *http.Request) (entity.LeadFilter, error) {
+	q := r.URL.Query()
+
+	filter := entity.LeadFilter{
+		SearchTerm:      getQueryString(q, "q"),
+		Sources:         getQueryList(q, "source"),
+		Channels:        getQueryList(q, "channel"),
+		AssignedAgentID: getQueryString(q, "agent"),
+		Zone:            getQueryString(q, "zone"),
+		Language:        getQueryString(q, "language"),
+		MinBudget:       getQueryFloat(q, "minBudget"),
+		MaxBudget:       getQueryFloat(q, "maxBudget"),
+		Cursor:          q.Get("cursor"),
+	}
+	for _, status := range getQueryList(q, "status") {
+		filter.Statuses = append(filter.Statuses, entity.LeadStatus(status))
+	}
+	if limit := getQueryInt(q, "limit"); limit != nil {
+		filter.Limit = *limit
+	}
+
+	sort, err := entity.ParseLeadSort(q.Get("sort"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Sort = sort
+
+	for param, t := range map[string]**time.Time{
+		"createdFrom":         &filter.CreatedFrom,
+		"createdTo":           &filter.CreatedTo,
+		"lastInteractionFrom": &filter.LastInteractionFrom,
+		"lastInteractionTo":   &filter.LastInteractionTo,
+	} {
+		value := q.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: expected YYYY-MM-DD", param)
+		}
+		*t = &parsed
+	}
+	return filter, nil
+}
+
+// writeLeadPage writes the leads of the page matching filter as before
+// pagination, a JSON array, with the total in X-Total-Count and the cursor of
+// the next page in X-Next-Cursor
+func (h *LeadHandler) writeLeadPage(w http.ResponseWriter, r *http.Request, filter entity.LeadFilter) {
+	page, err := h.Service.Search(r.Context(), filter)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(leads)
+	_ = json.NewEncoder(w).Encode(page.Leads)
 }
 
 // GET /api/v1/leads/{id}
@@ -113,7 +202,7 @@ func (h *LeadHandler) GetLeadByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lead, err := h.Service.FindByID(context.Background(), id)
+	lead, err := h.Service.FindByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -141,8 +230,12 @@ func (h *LeadHandler) UpdateLead(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch existing lead first to avoid overwriting with zero values
-	existingLead, err := h.Service.FindByID(context.Background(), id)
+	existingLead, err := h.Service.FindByID(r.Context(), id)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -152,15 +245,17 @@ func (h *LeadHandler) UpdateLead(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name         *string  `json:"name"`
-		Email        *string  `json:"email"`
-		Phone        *string  `json:"phone"`
-		Language     *string  `json:"language"`
-		Budget       *float64 `json:"budget"`
-		Zone         *string  `json:"zone"`
-		PropertyType *string  `json:"propertyType"`
-		Status       *string  `json:"status"`     // Added status
-		PropertyID   *string  `json:"propertyId"` // Added propertyId
+		Name            *string  `json:"name"`
+		Email           *string  `json:"email"`
+		Phone           *string  `json:"phone"`
+		Language        *string  `json:"language"`
+		Budget          *float64 `json:"budget"`
+		Zone            *string  `json:"zone"`
+		PropertyType    *string  `json:"propertyType"`
+		Status          *string  `json:"status"`          // Goes through the status transitions
+		ReasonCode      string   `json:"reasonCode"`      // Required to dismiss or reject
+		PropertyID      *string  `json:"propertyId"`      // Added propertyId
+		AssignedAgentID *string  `json:"assignedAgentId"` // Requires lead:assign
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
@@ -192,11 +287,11 @@ func (h *LeadHandler) UpdateLead(w http.ResponseWriter, r *http.Request) {
 	if req.PropertyType != nil {
 		existingLead.PropertyType = *req.PropertyType
 	}
-	if req.Status != nil {
-		existingLead.Status = entity.LeadStatus(*req.Status)
-	}
 	if req.PropertyID != nil {
 		if *req.PropertyID != "" {
+			if !h.checkProperty(w, r, existingLead.CompanyID, *req.PropertyID) {
+				return
+			}
 			existingLead.PropertyID = req.PropertyID
 		} else {
 			// Handle empty string as unset if needed, or ignore.
@@ -206,7 +301,53 @@ func (h *LeadHandler) UpdateLead(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := h.Service.Update(context.Background(), existingLead); err != nil {
+	var change *entity.LeadStatusChange
+	var assignment *entity.LeadAssignment
+	if req.AssignedAgentID != nil {
+		if !middleware.GrantsOf(r.Context()).Can(entity.PermissionLeadAssign) {
+			http.Error(w, "Forbidden: missing permission "+string(entity.PermissionLeadAssign), http.StatusForbidden)
+			return
+		}
+		current := ""
+		if existingLead.AssignedAgentID != nil {
+			current = *existingLead.AssignedAgentID
+		}
+		switch {
+		case *req.AssignedAgentID == current:
+		case h.Service.Assignments != nil:
+			// Recorded in the assignment history along with the rest
+			agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+			assignment, err = h.Service.Assignments.NewReassignment(r.Context(), existingLead, *req.AssignedAgentID, "", agentID)
+			if err != nil {
+				writeAssignmentError(w, err)
+				return
+			}
+		case *req.AssignedAgentID != "":
+			existingLead.AssignedAgentID = req.AssignedAgentID
+		default:
+			existingLead.AssignedAgentID = nil
+		}
+	}
+
+	// The status is validated here and recorded in the history along with the rest
+	if req.Status != nil && entity.LeadStatus(*req.Status) != existingLead.Status {
+		agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+		change, err = h.Statuses.NewTransition(existingLead, entity.LeadStatus(*req.Status), entity.LeadStatusReason(req.ReasonCode), "", agentID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.Service.UpdateWithHistory(r.Context(), existingLead, change, assignment); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -227,7 +368,11 @@ func (h *LeadHandler) DeleteLead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.Delete(context.Background(), id); err != nil {
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -247,19 +392,17 @@ func (h *LeadHandler) GetLeadByCompanyId(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "missing company id", http.StatusBadRequest)
 		return
 	}
-
-	leads, err := h.Service.FindByCompanyId(context.Background(), id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if !requireSameCompany(w, r, id) {
 		return
 	}
-	if leads == nil {
-		http.Error(w, "Leads not found", http.StatusNotFound)
+
+	filter, err := parseLeadFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(leads)
+	filter.CompanyID = &id
+	h.writeLeadPage(w, r, filter)
 }
 
 // GET /api/leads/byproperty/{propertyId}:
@@ -276,7 +419,7 @@ func (h *LeadHandler) GetLeadByPropertyId(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	leads, err := h.Service.FindByPropertyId(context.Background(), id)
+	leads, err := h.Service.FindByPropertyId(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -290,3 +433,137 @@ func (h *LeadHandler) GetLeadByPropertyId(w http.ResponseWriter, r *http.Request
 	_ = json.NewEncoder(w).Encode(leads)
 
 }
+
+// GET /api/v1/lead/{id}/duplicates lists the leads of the company that share
+// the email or phone of the lead, candidates to merge into it
+func (h *LeadHandler) GetLeadDuplicates(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := h.Service.FindDuplicates(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(duplicates)
+}
+
+// POST /api/v1/leads/{id}/merge folds the lead duplicateId into {id} and
+// returns the merged lead
+func (h *LeadHandler) MergeLead(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DuplicateID string `json:"duplicateId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	lead, err := h.Service.Merge(r.Context(), r.PathValue("id"), req.DuplicateID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lead)
+}
+
+// POST /api/v1/leads/{id}/transition moves the lead to another status. A
+// reasonCode is required to dismiss or reject it, and a note for reason "other".
+func (h *LeadHandler) TransitionLead(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status     entity.LeadStatus       `json:"status"`
+		ReasonCode entity.LeadStatusReason `json:"reasonCode"`
+		Note       string                  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+	change, err := h.Statuses.Transition(r.Context(), r.PathValue("id"), req.Status, req.ReasonCode, req.Note, agentID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "invalid"), strings.Contains(err.Error(), "required"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(change)
+}
+
+// GET /api/v1/leads/{id}/{subresource} serves the history of the lead, the
+// only subresource read this way
+func (h *LeadHandler) GetLeadSubresource(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("subresource") != "history" {
+		http.NotFound(w, r)
+		return
+	}
+	h.GetLeadHistory(w, r)
+}
+
+// GET /api/v1/leads/{id}/history lists the status changes of the lead, oldest first
+func (h *LeadHandler) GetLeadHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.Statuses.History(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
+
+// GET /api/v1/leads/funnel?since=2026-01-01&until=2026-02-01 tells how far
+// the leads created in the period (until excluded) got, by default the last 30 days
+func (h *LeadHandler) GetLeadFunnel(w http.ResponseWriter, r *http.Request) {
+	until := time.Now()
+	since := until.AddDate(0, 0, -30)
+	for param, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			http.Error(w, "invalid "+param+": expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		*t = parsed
+	}
+
+	funnel, err := h.Statuses.Funnel(r.Context(), since, until)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(funnel)
+}
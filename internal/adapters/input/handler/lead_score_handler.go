@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/datatypes"
+)
+
+type LeadScoreHandler struct {
+	Service *service.LeadScoreService
+}
+
+func NewLeadScoreHandler(s *service.LeadScoreService) *LeadScoreHandler {
+	return &LeadScoreHandler{Service: s}
+}
+
+// GET /api/v1/lead/{id}/score scores the lead again and tells why it scores so
+func (h *LeadScoreHandler) GetLeadScore(w http.ResponseWriter, r *http.Request) {
+	score, err := h.Service.Recompute(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(score)
+}
+
+// GET /api/v1/lead-score/weights
+func (h *LeadScoreHandler) GetWeights(w http.ResponseWriter, r *http.Request) {
+	weights, err := h.Service.Weights(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(weights)
+}
+
+// PUT /api/v1/lead-score/weights replaces the weights of the company; its
+// leads are rescored in the background
+func (h *LeadScoreHandler) UpdateWeights(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Completeness  int                `json:"completeness"`
+		Recency       int                `json:"recency"`
+		Frequency     int                `json:"frequency"`
+		Presentations int                `json:"presentations"`
+		BudgetFit     int                `json:"budgetFit"`
+		Source        int                `json:"source"`
+		SourceQuality map[string]float64 `json:"sourceQuality"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	sourceQuality := make(map[string]float64, len(req.SourceQuality))
+	for source, rate := range req.SourceQuality {
+		sourceQuality[strings.ToLower(strings.TrimSpace(source))] = rate
+	}
+	weights := &entity.LeadScoreWeights{
+		Completeness:  req.Completeness,
+		Recency:       req.Recency,
+		Frequency:     req.Frequency,
+		Presentations: req.Presentations,
+		BudgetFit:     req.BudgetFit,
+		Source:        req.Source,
+		SourceQuality: datatypes.NewJSONType(sourceQuality),
+	}
+	if err := h.Service.UpdateWeights(r.Context(), weights); err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(weights)
+}
@@ -1,9 +1,9 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/myestatia/myestatia-go/internal/application/service"
@@ -24,6 +24,9 @@ type MessageDTO struct {
 	Content    string `json:"content"`
 	Timestamp  string `json:"timestamp"`
 	Channel    string `json:"channel,omitempty"`
+
+	// InboundEmailID links to the original email, see GET /api/v1/lead/{id}/emails/{emailId}
+	InboundEmailID string `json:"inboundEmailId,omitempty"`
 }
 
 type ConversationDTO struct {
@@ -42,7 +45,7 @@ func (h *MessageHandler) GetConversations(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	messages, err := h.Service.GetMessagesByLeadID(context.Background(), id)
+	messages, err := h.Service.GetMessagesByLeadID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -56,6 +59,10 @@ func (h *MessageHandler) GetConversations(w http.ResponseWriter, r *http.Request
 			SenderType: string(m.SenderType),
 			Content:    m.Content,
 			Timestamp:  m.Timestamp.Format(time.RFC3339),
+			Channel:    m.Channel,
+		}
+		if m.InboundEmailID != nil {
+			msgsDTO[i].InboundEmailID = *m.InboundEmailID
 		}
 	}
 
@@ -92,8 +99,12 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	msg, err := h.Service.CreateMessage(context.Background(), leadId, req.SenderType, req.Content)
+	msg, err := h.Service.CreateMessage(r.Context(), leadId, req.SenderType, req.Content)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "lead not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
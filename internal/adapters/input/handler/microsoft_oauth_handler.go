@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	oauthconfig "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
+)
+
+// MicrosoftOAuthHandler connects Microsoft 365 / Outlook mailboxes through the
+// Microsoft identity platform, for tenants where basic IMAP auth is disabled
+type MicrosoftOAuthHandler struct {
+	oauth2Config       *oauthconfig.OAuth2Config
+	emailConfigService *service.CompanyEmailConfigService
+	encryptionKey      string
+}
+
+func NewMicrosoftOAuthHandler(oauth2Config *oauthconfig.OAuth2Config,
+	emailConfigService *service.CompanyEmailConfigService, encryptionKey string) *MicrosoftOAuthHandler {
+	return &MicrosoftOAuthHandler{
+		oauth2Config:       oauth2Config,
+		emailConfigService: emailConfigService,
+		encryptionKey:      encryptionKey,
+	}
+}
+
+// InitiateOAuth starts the OAuth2 flow for the caller's company
+// GET /api/v1/auth/microsoft/connect[?config_id={configId}] -> {"authUrl": "..."}
+// With config_id the tokens replace those of that inbox instead of adding one.
+func (h *MicrosoftOAuthHandler) InitiateOAuth(w http.ResponseWriter, r *http.Request) {
+	startOAuthFlow(w, r, h.oauth2Config, h.emailConfigService, h.encryptionKey, "MicrosoftOAuth")
+}
+
+// HandleCallback handles the OAuth2 callback from Microsoft
+// GET /api/v1/auth/microsoft/callback?state=...&code=...
+func (h *MicrosoftOAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	// Microsoft reports a denied consent as error=...&error_description=...
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		log.Printf("[MicrosoftOAuth] Authorization failed: %s: %s", errCode, r.URL.Query().Get("error_description"))
+		http.Error(w, "authorization failed: "+errCode, http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	encodedState := r.URL.Query().Get("state")
+	if code == "" || encodedState == "" {
+		log.Println("[MicrosoftOAuth] Missing code or state in callback")
+		http.Error(w, "missing code or state", http.StatusBadRequest)
+		return
+	}
+
+	companyID, configID, err := inboxFromState(h.encryptionKey, encodedState)
+	if err != nil {
+		log.Printf("[MicrosoftOAuth] Error decoding state: %v", err)
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+
+	ctx := tenant.WithCompanyID(r.Context(), companyID)
+	token, err := h.oauth2Config.ExchangeCode(ctx, code)
+	if err != nil {
+		log.Printf("[MicrosoftOAuth] Error exchanging code: %v", err)
+		http.Error(w, "failed to exchange code", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[MicrosoftOAuth] Successfully obtained tokens for company: %s", companyID)
+	h.oauth2Config.LogTokenInfo(token)
+
+	if err := saveOAuth2Tokens(ctx, h.emailConfigService, h.encryptionKey, companyID, configID, "microsoft", token); err != nil {
+		log.Printf("[MicrosoftOAuth] Error saving OAuth2 config: %v", err)
+		http.Error(w, "failed to save configuration", http.StatusInternalServerError)
+		return
+	}
+
+	writeOAuthSuccess(w, "Outlook", "MICROSOFT_AUTH_SUCCESS")
+}
+
+// DisconnectMicrosoft disconnects the mailbox by deleting its configuration
+// POST /api/v1/auth/microsoft/disconnect
+func (h *MicrosoftOAuthHandler) DisconnectMicrosoft(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CompanyID string `json:"companyId"`
+		ConfigID  string `json:"configId"` // optional when the company has a single such inbox
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if req.CompanyID == "" {
+		http.Error(w, "companyId is required", http.StatusBadRequest)
+		return
+	}
+
+	if !requireSameCompany(w, r, req.CompanyID) {
+		return
+	}
+
+	ctx := r.Context()
+
+	config, err := oauthInboxToDisconnect(ctx, h.emailConfigService, req.CompanyID, req.ConfigID, entity.EmailAuthMicrosoft)
+	if err != nil {
+		writeDisconnectError(w, err)
+		return
+	}
+
+	if err := h.emailConfigService.DeleteConfig(ctx, config.ID); err != nil {
+		log.Printf("[MicrosoftOAuth] Error disconnecting mailbox: %v", err)
+		http.Error(w, "failed to disconnect", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[MicrosoftOAuth] Successfully disconnected mailbox for company: %s", req.CompanyID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Microsoft mailbox disconnected successfully",
+	})
+}
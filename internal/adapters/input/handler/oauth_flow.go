@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	oauthconfig "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"golang.org/x/oauth2"
+)
+
+// oauthStateTTL is how long the caller has to grant access once the flow starts
+const oauthStateTTL = 10 * time.Minute
+
+// oauthState is what the OAuth2 state parameter carries through the provider:
+// a random value, the company the mailbox is being connected for, the inbox
+// being reconnected if any, and when the flow expires
+type oauthState struct {
+	Nonce     string `json:"n"`
+	CompanyID string `json:"c"`
+	ConfigID  string `json:"i,omitempty"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// encodeOAuthState builds the OAuth2 state parameter for the company and,
+// when an existing inbox is being reconnected, its config ID. It is signed
+// with key, so the callback only trusts states this server issued.
+func encodeOAuthState(key, companyID, configID string) (string, error) {
+	nonce, err := generateState()
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(oauthState{
+		Nonce:     nonce,
+		CompanyID: companyID,
+		ConfigID:  configID,
+		ExpiresAt: time.Now().Add(oauthStateTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signOAuthState(key, encoded), nil
+}
+
+// inboxFromState returns the company and config ID encoded by encodeOAuthState,
+// once its signature and expiry check out. configID is empty when a new inbox
+// is being connected.
+func inboxFromState(key, encodedState string) (companyID, configID string, err error) {
+	encoded, signature, ok := strings.Cut(encodedState, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signOAuthState(key, encoded))) {
+		return "", "", errors.New("invalid state signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid state: %w", err)
+	}
+	var state oauthState
+	if err := json.Unmarshal(payload, &state); err != nil || state.CompanyID == "" {
+		return "", "", errors.New("invalid state format")
+	}
+	if time.Now().Unix() > state.ExpiresAt {
+		return "", "", errors.New("invalid state: expired")
+	}
+	return state.CompanyID, state.ConfigID, nil
+}
+
+func signOAuthState(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte("oauth-state:"+key))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// startOAuthFlow answers with the consent page of the provider, to open in a
+// popup, for a mailbox of the caller's company. The company comes from the
+// session; a config_id must be one of its inboxes, whose tokens are replaced.
+// The popup cannot send the bearer token, hence a URL instead of a redirect.
+func startOAuthFlow(w http.ResponseWriter, r *http.Request, oauth2Config *oauthconfig.OAuth2Config,
+	emailConfigService *service.CompanyEmailConfigService, encryptionKey, logPrefix string) {
+	companyID, _ := r.Context().Value(middleware.CompanyIDKey).(string)
+	if requested := r.URL.Query().Get("company_id"); requested != "" && !requireSameCompany(w, r, requested) {
+		return
+	}
+
+	configID := r.URL.Query().Get("config_id")
+	if configID != "" {
+		if _, err := emailConfigService.GetConfig(r.Context(), companyID, configID); err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "configuration not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	encodedState, err := encodeOAuthState(encryptionKey, companyID, configID)
+	if err != nil {
+		log.Printf("[%s] Error generating state: %v", logPrefix, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[%s] Starting OAuth flow for company: %s", logPrefix, companyID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"authUrl": oauth2Config.GetAuthURL(encodedState)})
+}
+
+// saveOAuth2Tokens encrypts token and stores it as a mailbox connection of the
+// company through provider. With a configID that inbox is switched over to the
+// new tokens; otherwise a new inbox is added.
+func saveOAuth2Tokens(ctx context.Context, emailConfigService *service.CompanyEmailConfigService, encryptionKey, companyID, configID, provider string, token *oauth2.Token) error {
+	encryptedAccessToken, err := security.Encrypt(token.AccessToken, encryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+
+	encryptedRefreshToken, err := security.Encrypt(token.RefreshToken, encryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+
+	if configID != "" {
+		existingConfig, err := emailConfigService.GetConfig(ctx, companyID, configID)
+		if err != nil {
+			return err
+		}
+		return emailConfigService.UpdateToOAuth2(ctx, existingConfig.ID, provider, encryptedAccessToken, encryptedRefreshToken, token.Expiry)
+	}
+
+	_, err = emailConfigService.CreateOAuth2Config(ctx, companyID, provider, encryptedAccessToken, encryptedRefreshToken, token.Expiry)
+	return err
+}
+
+// oauthInboxToDisconnect finds the inbox of companyID using authMethod that a
+// disconnect request is about. Without a configID the company must have exactly
+// one such inbox.
+func oauthInboxToDisconnect(ctx context.Context, emailConfigService *service.CompanyEmailConfigService, companyID, configID, authMethod string) (*entity.CompanyEmailConfig, error) {
+	if configID != "" {
+		config, err := emailConfigService.GetConfig(ctx, companyID, configID)
+		if err != nil {
+			return nil, err
+		}
+		if config.AuthMethod != authMethod {
+			return nil, fmt.Errorf("configuration not found")
+		}
+		return config, nil
+	}
+
+	configs, err := emailConfigService.ListConfigs(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	var found []*entity.CompanyEmailConfig
+	for _, config := range configs {
+		if config.AuthMethod == authMethod {
+			found = append(found, config)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("configuration not found")
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("configId is required when several inboxes are connected")
+	}
+}
+
+// writeDisconnectError answers a failed oauthInboxToDisconnect
+func writeDisconnectError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, "configuration not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "required"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to disconnect", http.StatusInternalServerError)
+	}
+}
+
+// writeOAuthSuccess answers the OAuth2 callback with a page that notifies the
+// window that opened the popup with messageType and then closes itself
+func writeOAuthSuccess(w http.ResponseWriter, mailbox, messageType string) {
+	successHTML := `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Authorization Successful</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            display: flex;
+            justify-content: center;
+            align-items: center;
+            height: 100vh;
+            margin: 0;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: white;
+        }
+        .container {
+            text-align: center;
+        }
+        h1 { font-size: 2em; margin-bottom: 20px; }
+        p { font-size: 1.2em; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>✓ Authorization Successful!</h1>
+        <p>` + mailbox + ` connected successfully. This window will close automatically...</p>
+    </div>
+    <script>
+        // Notify parent window
+        if (window.opener) {
+            window.opener.postMessage({ type: '` + messageType + `' }, '*');
+        }
+        // Close popup after 2 seconds
+        setTimeout(() => window.close(), 2000);
+    </script>
+</body>
+</html>
+`
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(successHTML))
+}
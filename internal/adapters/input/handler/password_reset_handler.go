@@ -23,17 +23,23 @@ type EmailSender interface {
 
 type PasswordResetHandler struct {
 	agentService      *service.AgentService
+	sessionService    *service.SessionService
+	throttleService   *service.LoginThrottleService
 	passwordResetRepo repository.PasswordResetRepository
 	emailSender       EmailSender
 }
 
 func NewPasswordResetHandler(
 	agentService *service.AgentService,
+	sessionService *service.SessionService,
+	throttleService *service.LoginThrottleService,
 	passwordResetRepo repository.PasswordResetRepository,
 	emailSender EmailSender,
 ) *PasswordResetHandler {
 	return &PasswordResetHandler{
 		agentService:      agentService,
+		sessionService:    sessionService,
+		throttleService:   throttleService,
 		passwordResetRepo: passwordResetRepo,
 		emailSender:       emailSender,
 	}
@@ -66,6 +72,17 @@ func (h *PasswordResetHandler) ForgotPassword(w http.ResponseWriter, r *http.Req
 	// Always return the same message to prevent user enumeration
 	genericMessage := "Si el usuario existe, recibirá un email para recuperar su contraseña. Recuerde revisar la carpeta de spam."
 
+	// Limit requests per email, whether it exists or not
+	retryAfter, err := h.throttleService.AllowPasswordReset(r.Context(), req.Email, clientIP(r))
+	if err != nil {
+		http.Error(w, "Failed to check password reset requests", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
+
 	// Find agent by email
 	agent, err := h.agentService.GetByEmail(r.Context(), req.Email)
 	if err != nil || agent == nil {
@@ -188,6 +205,16 @@ func (h *PasswordResetHandler) ResetPassword(w http.ResponseWriter, r *http.Requ
 		// Log error but don't fail the request
 	}
 
+	// Whoever knew the old password must not stay logged in
+	if err := h.sessionService.RevokeAll(r.Context(), agent.ID); err != nil {
+		log.Printf("[PasswordReset] ERROR: Failed to revoke sessions of %s: %v", agent.ID, err)
+	}
+
+	// The owner proved access to the mailbox, so the account is no longer locked
+	if err := h.throttleService.ClearAccount(r.Context(), agent.Email); err != nil {
+		log.Printf("[PasswordReset] ERROR: Failed to clear lockout of %s: %v", agent.Email, err)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(GenericResponse{Message: "Password reset successfully"})
 }
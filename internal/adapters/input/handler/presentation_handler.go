@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"os"
@@ -48,8 +47,12 @@ func (h *PresentationHandler) CreatePresentation(w http.ResponseWriter, r *http.
 		return
 	}
 
-	token, err := h.Service.GenerateToken(req.LeadId, req.PropertyIds)
+	token, err := h.Service.GenerateToken(r.Context(), req.LeadId, req.PropertyIds)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, "failed to generate presentation: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -122,7 +125,7 @@ func (h *PresentationHandler) GetMatchingProperties(w http.ResponseWriter, r *ht
 		return
 	}
 
-	matches, err := h.Service.GetMatchingProperties(context.Background(), leadID)
+	matches, err := h.Service.GetMatchingProperties(r.Context(), leadID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "lead not found", http.StatusNotFound)
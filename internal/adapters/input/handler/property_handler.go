@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -137,9 +136,12 @@ func (h *PropertyHandler) DeleteProperty(w http.ResponseWriter, r *http.Request)
 	}
 
 	executorID, _ := r.Context().Value(middleware.AgentIDKey).(string)
-	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
 
-	if err := h.Service.DeleteProperty(r.Context(), id, executorID, executorRole); err != nil {
+	if err := h.Service.DeleteProperty(r.Context(), id, executorID, middleware.GrantsOf(r.Context())); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		if strings.Contains(err.Error(), "unauthorized") {
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
@@ -227,6 +229,12 @@ func (h *PropertyHandler) processCreateProperty(w http.ResponseWriter, r *http.R
 	}
 	req.Origin = entity.OriginManual
 
+	// A property created in any status but draft is published right away
+	if req.Status != "" && req.Status != "draft" && !middleware.GrantsOf(r.Context()).Can(entity.PermissionPropertyPublish) {
+		http.Error(w, "unauthorized: creating a property that is not a draft requires "+string(entity.PermissionPropertyPublish), http.StatusForbidden)
+		return
+	}
+
 	createdProperty, created, err := h.Service.CreateProperty(r.Context(), &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -269,9 +277,8 @@ func (h *PropertyHandler) UpdateProperty(w http.ResponseWriter, r *http.Request)
 	}
 
 	executorID, _ := r.Context().Value(middleware.AgentIDKey).(string)
-	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
 
-	if err := h.Service.UpdateProperty(r.Context(), &req, executorID, executorRole); err != nil {
+	if err := h.Service.UpdateProperty(r.Context(), &req, executorID, middleware.GrantsOf(r.Context())); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -302,8 +309,11 @@ func (h *PropertyHandler) GetPropertiesByCompany(w http.ResponseWriter, r *http.
 		http.Error(w, "missing company_id", http.StatusBadRequest)
 		return
 	}
+	if !requireSameCompany(w, r, companyID) {
+		return
+	}
 
-	properties, err := h.Service.FindAllByCompanyID(context.Background(), companyID)
+	properties, err := h.Service.FindAllByCompanyID(r.Context(), companyID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+)
+
+type SecurityHandler struct {
+	ThrottleService *service.LoginThrottleService
+}
+
+func NewSecurityHandler(throttleService *service.LoginThrottleService) *SecurityHandler {
+	return &SecurityHandler{ThrottleService: throttleService}
+}
+
+// GET /api/v1/security/lockouts lists recent lockouts of the caller's company agents
+func (h *SecurityHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	events, err := h.ThrottleService.ListLockouts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
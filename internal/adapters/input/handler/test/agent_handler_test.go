@@ -33,7 +33,7 @@ func TestCreateAgent_Handler(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	mockAgentRepo.On("FindByEmail", mock.Anything, "agent@handler.com").Return(nil, nil)
-	mockAgentRepo.On("Create", mock.Anything).Return(nil)
+	mockAgentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 
 	// WHEN
 	h.CreateAgent(rr, req)
@@ -62,7 +62,7 @@ func TestGetAgentByID_Handler_Success(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// AgentRepository.FindByID only takes 1 argument: id
-	mockAgentRepo.On("FindByID", agentID).Return(agent, nil)
+	mockAgentRepo.On("FindByID", mock.Anything, agentID).Return(agent, nil)
 
 	// WHEN
 	h.GetAgentByID(rr, req)
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testAPIKey = entity.APIKeyPrefix + "0123456789abcdef"
+
+func serveWithAPIKey(permission entity.Permission, key *entity.APIKey, next http.HandlerFunc) *httptest.ResponseRecorder {
+	repo := new(mocks.APIKeyRepositoryMock)
+	repo.On("FindByHash", mock.Anything, security.HashOpaqueToken(testAPIKey)).Return(key, nil)
+	repo.On("TouchLastUsed", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	h := middleware.NewAuthMiddleware(activeSessions{}, service.NewAPIKeyService(repo))(middleware.RequirePermission(permission)(next))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads", nil)
+	req.Header.Set(middleware.APIKeyHeader, testAPIKey)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAuthMiddleware_APIKeySetsCompany(t *testing.T) {
+	// GIVEN
+	key := &entity.APIKey{ID: "K1", CompanyID: companyA, Scopes: []entity.APIKeyScope{entity.ScopeLeadsRead}}
+	var companyID, scoped string
+
+	// WHEN
+	rr := serveWithAPIKey(entity.PermissionLeadRead, key, func(w http.ResponseWriter, r *http.Request) {
+		companyID, _ = r.Context().Value(middleware.CompanyIDKey).(string)
+		scoped, _ = tenant.CompanyID(r.Context())
+	})
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, companyA, companyID)
+	assert.Equal(t, companyA, scoped)
+}
+
+func TestAuthMiddleware_APIKeyWithoutScopeIs403(t *testing.T) {
+	// GIVEN
+	key := &entity.APIKey{ID: "K1", CompanyID: companyA, Scopes: []entity.APIKeyScope{entity.ScopeLeadsRead}}
+	called := false
+
+	// WHEN
+	rr := serveWithAPIKey(entity.PermissionLeadDelete, key, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	// THEN
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.False(t, called)
+}
+
+func TestAuthMiddleware_UnknownAPIKeyIs401(t *testing.T) {
+	// WHEN
+	rr := serveWithAPIKey(entity.PermissionLeadRead, nil, func(w http.ResponseWriter, r *http.Request) {})
+
+	// THEN
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthMiddleware_APIKeyNotAcceptedOnAgentOnlyRoutes(t *testing.T) {
+	// GIVEN
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+	req.Header.Set(middleware.APIKeyHeader, testAPIKey)
+	h := middleware.NewAuthMiddleware(activeSessions{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
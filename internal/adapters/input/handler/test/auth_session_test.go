@@ -0,0 +1,176 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// activeSessions treats every session as active
+type activeSessions struct{}
+
+func (activeSessions) IsActive(ctx context.Context, sessionID, agentID string) (bool, error) {
+	return true, nil
+}
+
+func newAuthHandlerWithSessions(repo *mocks.SessionRepositoryMock) (*handler.AuthHandler, *service.SessionService) {
+	sessionSvc := service.NewSessionService(repo, new(mocks.AgentRepositoryMock))
+	return handler.NewAuthHandler(nil, nil, sessionSvc, nil, nil), sessionSvc
+}
+
+func TestAuthMiddleware_RevokedSessionIs401(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	_, sessionSvc := newAuthHandlerWithSessions(repo)
+	revokedAt := time.Now()
+	repo.On("FindByID", mock.Anything, "S1").
+		Return(&entity.Session{ID: "S1", AgentID: "A1", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil)
+
+	token, _ := security.GenerateToken("A1", "C1", string(entity.RoleAdmin), "S1")
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	called := false
+	h := middleware.NewAuthMiddleware(sessionSvc, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.False(t, called)
+}
+
+func TestAuthMiddleware_TokenWithoutSessionIs401(t *testing.T) {
+	// GIVEN
+	token, _ := security.GenerateToken("A1", "C1", string(entity.RoleAdmin), "")
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	h := middleware.NewAuthMiddleware(activeSessions{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestLogout_RevokesCurrentSession(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	h, sessionSvc := newAuthHandlerWithSessions(repo)
+	session := &entity.Session{ID: "S1", AgentID: "A1", ExpiresAt: time.Now().Add(time.Hour)}
+	repo.On("FindByID", mock.Anything, "S1").Return(session, nil)
+	repo.On("Revoke", mock.Anything, "S1").Return(nil)
+
+	token, _ := security.GenerateToken("A1", "C1", string(entity.RoleAgent), "S1")
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	middleware.NewAuthMiddleware(sessionSvc, nil)(http.HandlerFunc(h.Logout)).ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	repo.AssertCalled(t, "Revoke", mock.Anything, "S1")
+}
+
+func TestRefresh_UnknownTokenIs401(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	h, _ := newAuthHandlerWithSessions(repo)
+	repo.On("FindByTokenHash", mock.Anything, security.HashOpaqueToken("unknown")).Return(nil, nil)
+
+	body, _ := json.Marshal(map[string]string{"refreshToken": "unknown"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/refresh", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.Refresh(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestLogin_TwoFactorEnabledReturnsChallenge(t *testing.T) {
+	// GIVEN
+	agentRepo := new(mocks.AgentRepositoryMock)
+	sessionRepo := new(mocks.SessionRepositoryMock)
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	agent := &entity.Agent{ID: "A1", CompanyID: "C1", Email: "a@acme.com", Password: string(hashed), TwoFactorEnabled: true}
+	agentRepo.On("FindByEmail", mock.Anything, "a@acme.com").Return(agent, nil)
+	throttleRepo := new(mocks.LoginThrottleRepositoryMock)
+	throttleRepo.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	h := handler.NewAuthHandler(
+		service.NewAgentService(agentRepo, nil),
+		nil,
+		service.NewSessionService(sessionRepo, agentRepo),
+		service.NewTwoFactorService(agentRepo, new(mocks.CompanyRepositoryMock), new(mocks.RecoveryCodeRepositoryMock), ""),
+		service.NewLoginThrottleService(throttleRepo, agentRepo),
+	)
+
+	body, _ := json.Marshal(map[string]string{"email": "a@acme.com", "password": "password123"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp handler.TwoFactorChallengeResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.True(t, resp.TwoFactorRequired)
+	assert.NotContains(t, rr.Body.String(), "refreshToken")
+	agentID, audience, err := security.ValidateChallengeToken(resp.ChallengeToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "A1", agentID)
+	assert.Equal(t, security.ChallengeTwoFactor, audience)
+	sessionRepo.AssertNotCalled(t, "Create")
+}
+
+func TestLogin_LockedAccountIs429WithoutCheckingPassword(t *testing.T) {
+	// GIVEN
+	agentRepo := new(mocks.AgentRepositoryMock)
+	throttleRepo := new(mocks.LoginThrottleRepositoryMock)
+	lockedUntil := time.Now().Add(90 * time.Second)
+	throttleRepo.On("Find", mock.Anything, entity.ThrottleAccount, "a@acme.com").
+		Return(&entity.LoginThrottle{Kind: entity.ThrottleAccount, Key: "a@acme.com", Attempts: 6, LockedUntil: &lockedUntil}, nil)
+	throttleRepo.On("Find", mock.Anything, entity.ThrottleIP, "10.0.0.1").Return(nil, nil)
+
+	h := handler.NewAuthHandler(service.NewAgentService(agentRepo, nil), nil, nil, nil, service.NewLoginThrottleService(throttleRepo, agentRepo))
+
+	body, _ := json.Marshal(map[string]string{"email": "A@acme.com", "password": "password123"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(body))
+	req.RemoteAddr = "10.0.0.1:5555"
+
+	// WHEN
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+	agentRepo.AssertNotCalled(t, "FindByEmail")
+}
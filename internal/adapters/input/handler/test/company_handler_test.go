@@ -33,7 +33,7 @@ func TestCreateCompany_Handler(t *testing.T) {
 
 	// Mocking service dependency (the repo)
 	mockRepo.On("FindByName", mock.Anything, "Handler Corp").Return(nil, nil)
-	mockRepo.On("Create", mock.Anything).Return(nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 
 	// WHEN
 	h.CreateCompany(rr, req)
@@ -58,7 +58,7 @@ func TestGetCompanyByID_Handler_NotFound(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/companies/non-existent", nil)
 	rr := httptest.NewRecorder()
 
-	mockRepo.On("FindByID", "non-existent").Return(nil, nil)
+	mockRepo.On("FindByID", mock.Anything, "non-existent").Return(nil, nil)
 
 	// WHEN
 	h.GetCompanyByID(rr, req)
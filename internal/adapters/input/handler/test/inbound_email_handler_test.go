@@ -0,0 +1,71 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetLeadEmailAttachment_IsServedAsDownload(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InboundEmailRepositoryMock)
+	storage := new(mocks.PrivateStorageServiceMock)
+	h := handler.NewInboundEmailHandler(service.NewInboundEmailService(repo, storage))
+	leadID := "L1"
+	body := "<script>alert(1)</script>"
+
+	repo.On("FindByID", mock.Anything, "IE1").Return(&entity.InboundEmail{ID: "IE1", LeadID: &leadID, Attachments: []entity.InboundEmailAttachment{
+		{ID: "A1", Filename: "offer.html", ContentType: "text/html", Size: len(body), StorageKey: "key-1"},
+	}}, nil)
+	storage.On("Open", mock.Anything, "key-1").Return(io.NopCloser(strings.NewReader(body)), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lead/L1/emails/IE1/attachments/A1", nil)
+	req.SetPathValue("id", "L1")
+	req.SetPathValue("emailId", "IE1")
+	req.SetPathValue("attachmentId", "A1")
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.GetLeadEmailAttachment(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=offer.html`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, body, rr.Body.String())
+}
+
+func TestGetLeadEmailAttachment_OtherLeadIsNotFound(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InboundEmailRepositoryMock)
+	storage := new(mocks.PrivateStorageServiceMock)
+	h := handler.NewInboundEmailHandler(service.NewInboundEmailService(repo, storage))
+	leadID := "L1"
+
+	repo.On("FindByID", mock.Anything, "IE1").Return(&entity.InboundEmail{ID: "IE1", LeadID: &leadID, Attachments: []entity.InboundEmailAttachment{
+		{ID: "A1", StorageKey: "key-1"},
+	}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lead/L2/emails/IE1/attachments/A1", nil)
+	req.SetPathValue("id", "L2")
+	req.SetPathValue("emailId", "IE1")
+	req.SetPathValue("attachmentId", "A1")
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.GetLeadEmailAttachment(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	storage.AssertNotCalled(t, "Open", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAssignLead_Handler(t *testing.T) {
+	// GIVEN
+	leadRepo := new(mocks.LeadRepositoryMock)
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	h := handler.NewLeadAssignmentHandler(service.NewLeadAssignmentService(repo, leadRepo, nil))
+
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", CompanyID: "C1"}, nil)
+	repo.On("Workloads", mock.Anything).Return([]entity.AgentWorkload{{AgentID: "A2"}}, nil)
+	repo.On("Assign", mock.Anything, mock.MatchedBy(func(a *entity.LeadAssignment) bool {
+		return *a.ToAgentID == "A2" && *a.ByAgentID == "M1" && a.Note == "Holidays"
+	})).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/leads/L1/assign", bytes.NewBufferString(`{"agentId":"A2","note":"Holidays"}`))
+	req.SetPathValue("id", "L1")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.AgentIDKey, "M1"))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.AssignLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestCreateAssignmentRule_Handler_InvalidStrategyIsBadRequest(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	h := handler.NewLeadAssignmentHandler(service.NewLeadAssignmentService(repo, nil, nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/assignment-rules", bytes.NewBufferString(`{"name":"Everyone","strategy":"random"}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.CreateRule(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+}
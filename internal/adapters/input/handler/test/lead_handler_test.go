@@ -2,15 +2,19 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
 	"github.com/myestatia/myestatia-go/internal/application/service"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -18,8 +22,8 @@ import (
 func TestCreateLead_Handler(t *testing.T) {
 	// GIVEN
 	mockRepo := new(mocks.LeadRepositoryMock)
-	svc := service.NewLeadService(mockRepo)
-	h := handler.NewLeadHandler(svc)
+	svc := service.NewLeadService(mockRepo, nil)
+	h := handler.NewLeadHandler(svc, nil)
 
 	leadReq := entity.Lead{
 		Name:  "Test Lead",
@@ -29,10 +33,13 @@ func TestCreateLead_Handler(t *testing.T) {
 	body, _ := json.Marshal(leadReq)
 
 	req, _ := http.NewRequest(http.MethodPost, "/api/v1/leads", bytes.NewBuffer(body))
+	// Add CompanyID to context
+	ctx := context.WithValue(req.Context(), middleware.CompanyIDKey, "C1")
+	req = req.WithContext(ctx)
 	rr := httptest.NewRecorder()
 
-	mockRepo.On("FindByEmail", mock.Anything, "lead@handler.com").Return(nil, nil)
-	mockRepo.On("Create", mock.Anything).Return(nil)
+	mockRepo.On("FindByEmail", mock.Anything, mock.Anything, "lead@handler.com").Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 
 	// WHEN
 	h.CreateLead(rr, req)
@@ -47,11 +54,38 @@ func TestCreateLead_Handler(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateLead_Handler_PropertyOfAnotherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	properties := new(mocks.PropertyRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+	svc.Properties = properties
+	h := handler.NewLeadHandler(svc, nil)
+
+	// Scoped to C1, the property of another company is not found
+	properties.On("FindByID", mock.MatchedBy(func(ctx context.Context) bool {
+		companyID, _ := tenant.CompanyID(ctx)
+		return companyID == "C1"
+	}), "P2").Return(nil, nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/leads",
+		bytes.NewBufferString(`{"name":"Ana","email":"ana@example.com","propertyId":"P2"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.CompanyIDKey, "C1"))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.CreateLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	properties.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
 func TestGetLeadByID_Handler_Success(t *testing.T) {
 	// GIVEN
 	mockRepo := new(mocks.LeadRepositoryMock)
-	svc := service.NewLeadService(mockRepo)
-	h := handler.NewLeadHandler(svc)
+	svc := service.NewLeadService(mockRepo, nil)
+	h := handler.NewLeadHandler(svc, nil)
 
 	leadID := "L1"
 	lead := &entity.Lead{ID: leadID, Name: "Lead One"}
@@ -59,8 +93,8 @@ func TestGetLeadByID_Handler_Success(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads/"+leadID, nil)
 	rr := httptest.NewRecorder()
 
-	// LeadRepository.FindByID takes 1 argument
-	mockRepo.On("FindByID", leadID).Return(lead, nil)
+	// LeadRepository.FindByID takes the request context and the ID
+	mockRepo.On("FindByID", mock.Anything, leadID).Return(lead, nil)
 
 	// WHEN
 	h.GetLeadByID(rr, req)
@@ -72,3 +106,185 @@ func TestGetLeadByID_Handler_Success(t *testing.T) {
 	json.Unmarshal(rr.Body.Bytes(), &response)
 	assert.Equal(t, leadID, response.ID)
 }
+
+func TestMergeLead_Handler_MissingDuplicateIsBadRequest(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/leads/L1/merge", bytes.NewBufferString(`{}`))
+	req.SetPathValue("id", "L1")
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.MergeLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateLead_Handler_RejectWithoutReasonIsBadRequest(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	changes := new(mocks.LeadStatusChangeRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), service.NewLeadStatusService(mockRepo, changes))
+
+	mockRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", Status: entity.LeadStatusContacted}, nil)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/leads/L1", bytes.NewBufferString(`{"status":"rejected"}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.UpdateLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "reason code is required")
+	changes.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateWithHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateLead_Handler_SavesStatusChangeWithTheLead(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	changes := new(mocks.LeadStatusChangeRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), service.NewLeadStatusService(mockRepo, changes))
+
+	mockRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", Name: "Ana", Status: entity.LeadStatusNew}, nil)
+	mockRepo.On("UpdateWithHistory", mock.Anything,
+		mock.MatchedBy(func(l *entity.Lead) bool { return l.Name == "Ana Pérez" }),
+		mock.MatchedBy(func(c *entity.LeadStatusChange) bool {
+			return c.FromStatus == entity.LeadStatusNew && c.ToStatus == entity.LeadStatusContacted
+		}),
+		(*entity.LeadAssignment)(nil)).Return(nil)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/leads/L1", bytes.NewBufferString(`{"name":"Ana Pérez","status":"contacted"}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.UpdateLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+	changes.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+}
+
+func TestUpdateLead_Handler_PropertyOfAnotherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	properties := new(mocks.PropertyRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+	svc.Properties = properties
+	h := handler.NewLeadHandler(svc, nil)
+
+	mockRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", CompanyID: "C1"}, nil)
+	properties.On("FindByID", mock.Anything, "P2").Return(nil, nil)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/leads/L1", bytes.NewBufferString(`{"propertyId":"P2"}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.UpdateLead(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "UpdateWithHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateLead_Handler_APIKeyAssignsWithTheAssignScope(t *testing.T) {
+	cases := map[string]struct {
+		scopes   []entity.APIKeyScope
+		expected int
+	}{
+		"with leads:assign":    {[]entity.APIKeyScope{entity.ScopeLeadsWrite, entity.ScopeLeadsAssign}, http.StatusOK},
+		"without leads:assign": {[]entity.APIKeyScope{entity.ScopeLeadsWrite}, http.StatusForbidden},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN
+			mockRepo := new(mocks.LeadRepositoryMock)
+			h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+
+			mockRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", CompanyID: "C1"}, nil)
+			mockRepo.On("UpdateWithHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			key := &entity.APIKey{ID: "K1", CompanyID: "C1", Scopes: c.scopes}
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/leads/L1", bytes.NewBufferString(`{"assignedAgentId":"A2"}`))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.APIKeyKey, key))
+			rr := httptest.NewRecorder()
+
+			// WHEN
+			h.UpdateLead(rr, req)
+
+			// THEN
+			assert.Equal(t, c.expected, rr.Code)
+		})
+	}
+}
+
+func TestGetLeadSubresource_Handler_UnknownIsNotFound(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	changes := new(mocks.LeadStatusChangeRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), service.NewLeadStatusService(mockRepo, changes))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/leads/L1/notes", nil)
+	req.SetPathValue("id", "L1")
+	req.SetPathValue("subresource", "notes")
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.GetLeadSubresource(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+}
+
+func TestGetAllLeads_Handler_FiltersAndPaginates(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+
+	mockRepo.On("Search", mock.Anything, mock.MatchedBy(func(f entity.LeadFilter) bool {
+		return *f.SearchTerm == "garcia" &&
+			assert.ObjectsAreEqual([]entity.LeadStatus{entity.LeadStatusNew, entity.LeadStatusContacted}, f.Statuses) &&
+			*f.AssignedAgentID == entity.LeadFilterUnassigned &&
+			*f.MinBudget == 200000 &&
+			f.CreatedFrom.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+			assert.ObjectsAreEqual([]entity.LeadSort{{Field: entity.LeadSortScore, Desc: true}, {Field: entity.LeadSortCreatedAt}}, f.Sort) &&
+			f.Limit == 20 && f.Cursor == "abc"
+	})).Return(&entity.LeadPage{Leads: []entity.Lead{{ID: "L1"}}, Total: 41, NextCursor: "def"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/leads?q=garcia&status=new,contacted&agent=none&minBudget=200000&createdFrom=2026-01-01&sort=-score,createdAt&limit=20&cursor=abc", nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.GetAllLeads(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "41", rr.Header().Get("X-Total-Count"))
+	assert.Equal(t, "def", rr.Header().Get("X-Next-Cursor"))
+	var leads []entity.Lead
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &leads))
+	assert.Len(t, leads, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllLeads_Handler_InvalidSortIsBadRequest(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/leads?sort=-password", nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.GetAllLeads(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
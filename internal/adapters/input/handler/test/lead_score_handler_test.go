@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateLeadScoreWeights_Handler_RescoresLeadsInTheBackground(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadScoreRepositoryMock)
+	h := handler.NewLeadScoreHandler(service.NewLeadScoreService(repo, new(mocks.LeadRepositoryMock)))
+	lead := entity.Lead{ID: "L1", CompanyID: "C1", Source: "web"}
+	rescored := make(chan []entity.LeadScore, 1)
+
+	repo.On("SaveWeights", mock.Anything, mock.MatchedBy(func(w *entity.LeadScoreWeights) bool {
+		return w.Source == 10 && w.SourceQuality.Data()["web"] == 0.2
+	})).Return(nil)
+	repo.On("LeadsAfter", mock.Anything, "", mock.Anything).Return([]entity.Lead{lead}, nil)
+	repo.On("LeadsAfter", mock.Anything, "L1", mock.Anything).Return([]entity.Lead{}, nil)
+	repo.On("FindWeights", mock.Anything).Return(&entity.LeadScoreWeights{Source: 10}, nil)
+	repo.On("SignalsByLead", mock.Anything, mock.Anything, mock.Anything).Return(map[string]entity.LeadScoreSignals{}, nil)
+	repo.On("SaveScores", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { rescored <- args.Get(1).([]entity.LeadScore) }).
+		Return(nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/lead-score/weights", bytes.NewBufferString(`{"source":10,"sourceQuality":{"Web ":0.2}}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.UpdateWeights(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	select {
+	case scores := <-rescored:
+		if assert.Len(t, scores, 1) {
+			assert.Equal(t, "L1", scores[0].LeadID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("leads were not rescored")
+	}
+}
+
+func TestUpdateLeadScoreWeights_Handler_AllZeroIsBadRequest(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadScoreRepositoryMock)
+	h := handler.NewLeadScoreHandler(service.NewLeadScoreService(repo, new(mocks.LeadRepositoryMock)))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/lead-score/weights", bytes.NewBufferString(`{}`))
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.UpdateWeights(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "SaveWeights", mock.Anything, mock.Anything)
+}
@@ -33,7 +33,7 @@ func TestSendMessage_Handler(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/api/v1/conversations/L1/messages", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
 
-	mockRepo.On("Create", mock.Anything).Return(nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 
 	// WHEN - Call mux instead of handler directly
 	mux.ServeHTTP(rr, req)
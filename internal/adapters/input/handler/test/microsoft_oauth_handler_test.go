@@ -0,0 +1,178 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	oauthconfig "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const oauthEncryptionKey = "0123456789abcdef0123456789abcdef"
+
+// newMicrosoftOAuthHandler points the token endpoint at a local stand-in
+// that accepts the code "good-code"
+func newMicrosoftOAuthHandler(t *testing.T, repo *mocks.CompanyEmailConfigRepositoryMock) *handler.MicrosoftOAuthHandler {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "ms-access",
+			"refresh_token": "ms-refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	cfg := oauthconfig.NewMicrosoftOAuth2Config("client", "secret", "http://localhost/callback", "")
+	cfg.Config.Endpoint.TokenURL = tokenServer.URL
+
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), oauthEncryptionKey)
+	return handler.NewMicrosoftOAuthHandler(cfg, svc, oauthEncryptionKey)
+}
+
+// connectMicrosoftAs starts the flow as an agent of companyID and returns the
+// consent page URL it answers with
+func connectMicrosoftAs(t *testing.T, h *handler.MicrosoftOAuthHandler, companyID, query string) (*httptest.ResponseRecorder, *url.URL) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/microsoft/connect"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.CompanyIDKey, companyID))
+	rr := httptest.NewRecorder()
+	h.InitiateOAuth(rr, req)
+
+	var body struct {
+		AuthURL string `json:"authUrl"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	authURL, err := url.Parse(body.AuthURL)
+	assert.NoError(t, err)
+	return rr, authURL
+}
+
+func TestMicrosoftOAuth_ConnectAnswersMicrosoftConsentURL(t *testing.T) {
+	// GIVEN
+	h := newMicrosoftOAuthHandler(t, new(mocks.CompanyEmailConfigRepositoryMock))
+
+	// WHEN
+	rr, location := connectMicrosoftAs(t, h, "C1", "")
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "login.microsoftonline.com", location.Host)
+	assert.Contains(t, location.Query().Get("scope"), oauthconfig.MicrosoftMailReadScope)
+	assert.Contains(t, location.Query().Get("scope"), "offline_access")
+	assert.NotEmpty(t, location.Query().Get("state"))
+}
+
+func TestMicrosoftOAuth_ConnectForOtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	h := newMicrosoftOAuthHandler(t, new(mocks.CompanyEmailConfigRepositoryMock))
+
+	// WHEN
+	rr, _ := connectMicrosoftAs(t, h, "C1", "?company_id=C2")
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestMicrosoftOAuth_ConnectWithOtherCompanyInboxIs404(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	h := newMicrosoftOAuthHandler(t, repo)
+	repo.On("FindByID", mock.Anything, "cfg-of-c2").Return(&entity.CompanyEmailConfig{ID: "cfg-of-c2", CompanyID: "C2"}, nil)
+
+	// WHEN
+	rr, _ := connectMicrosoftAs(t, h, "C1", "?config_id=cfg-of-c2")
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestMicrosoftOAuth_CallbackStoresEncryptedTokens(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	h := newMicrosoftOAuthHandler(t, repo)
+
+	var created *entity.CompanyEmailConfig
+	repo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*entity.CompanyEmailConfig) }).
+		Return(nil)
+
+	_, location := connectMicrosoftAs(t, h, "C1", "")
+	state := location.Query().Get("state")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/microsoft/callback?code=good-code&state="+url.QueryEscape(state), nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.HandleCallback(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "MICROSOFT_AUTH_SUCCESS")
+	if assert.NotNil(t, created) {
+		assert.Equal(t, "C1", created.CompanyID)
+		assert.Equal(t, entity.EmailAuthMicrosoft, created.AuthMethod)
+		assert.Equal(t, "microsoft", created.OAuth2Provider)
+		access, err := security.Decrypt(created.AccessToken, oauthEncryptionKey)
+		assert.NoError(t, err)
+		assert.Equal(t, "ms-access", access)
+		refresh, err := security.Decrypt(created.RefreshToken, oauthEncryptionKey)
+		assert.NoError(t, err)
+		assert.Equal(t, "ms-refresh", refresh)
+	}
+}
+
+func TestMicrosoftOAuth_CallbackWithForgedStateIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	h := newMicrosoftOAuthHandler(t, repo)
+
+	// A state naming another company and inbox, without the server's signature
+	_, location := connectMicrosoftAs(t, h, "C1", "")
+	signature := strings.SplitN(location.Query().Get("state"), ".", 2)[1]
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"n":"x","c":"C2","i":"cfg-of-c2","e":9999999999}`)) + "." + signature
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/microsoft/callback?code=good-code&state="+url.QueryEscape(forged), nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.HandleCallback(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+}
+
+func TestMicrosoftOAuth_CallbackWithConsentDenied(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	h := newMicrosoftOAuthHandler(t, repo)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/microsoft/callback?error=access_denied&error_description=denied", nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.HandleCallback(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "access_denied")
+	repo.AssertNotCalled(t, "Create")
+}
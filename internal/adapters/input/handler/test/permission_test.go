@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRequirePermission_ReadOnlyCannotDeleteLead(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+	protected := middleware.RequirePermission(entity.PermissionLeadDelete)(http.HandlerFunc(h.DeleteLead))
+
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/leads/L1", nil)
+
+	// WHEN
+	rr := serveWithRole(t, "C1", entity.RoleReadOnly, "DELETE /api/v1/leads/{id}", protected, req)
+
+	// THEN
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "Delete")
+}
+
+func TestRequirePermission_ManagerCanDeleteLead(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+	protected := middleware.RequirePermission(entity.PermissionLeadDelete)(http.HandlerFunc(h.DeleteLead))
+
+	mockRepo.On("Delete", mock.Anything, "L1").Return(nil)
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/leads/L1", nil)
+
+	// WHEN
+	rr := serveWithRole(t, "C1", entity.RoleManager, "DELETE /api/v1/leads/{id}", protected, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateLead_AssignRequiresPermission(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	h := handler.NewLeadHandler(service.NewLeadService(mockRepo, nil), nil)
+
+	mockRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1"}, nil)
+	body, _ := json.Marshal(map[string]string{"assignedAgentId": "A2"})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/leads/L1", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveWithRole(t, "C1", entity.RoleAgent, "PUT /api/v1/leads/{id}", http.HandlerFunc(h.UpdateLead), req)
+
+	// THEN
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "Update")
+}
+
+func TestChangeAgentRole_Handler(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.AgentRepositoryMock)
+	h := handler.NewAgentHandler(service.NewAgentService(mockRepo, nil))
+	protected := middleware.RequirePermission(entity.PermissionAgentRole)(http.HandlerFunc(h.ChangeAgentRole))
+
+	mockRepo.On("FindByID", mock.Anything, "A2").Return(&entity.Agent{ID: "A2", Role: entity.RoleAgent}, nil)
+	mockRepo.On("UpdatePartial", mock.Anything, "A2", mock.Anything).Return(nil)
+	body, _ := json.Marshal(map[string]string{"role": "manager"})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/agents/A2/role", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveWithRole(t, "C1", entity.RoleAdmin, "PUT /api/v1/agents/{id}/role", protected, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangeAgentRole_ManagerIsForbidden(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.AgentRepositoryMock)
+	h := handler.NewAgentHandler(service.NewAgentService(mockRepo, nil))
+	protected := middleware.RequirePermission(entity.PermissionAgentRole)(http.HandlerFunc(h.ChangeAgentRole))
+
+	body, _ := json.Marshal(map[string]string{"role": "admin"})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/agents/A2/role", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveWithRole(t, "C1", entity.RoleManager, "PUT /api/v1/agents/{id}/role", protected, req)
+
+	// THEN
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "UpdatePartial")
+}
@@ -51,7 +51,7 @@ func TestCreateProperty_Handler(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	mockRepo.On("FindByReference", mock.Anything, "REF-HTTP").Return(nil, nil)
-	mockRepo.On("Create", mock.Anything).Return(nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 
 	// WHEN
 	h.CreateProperty(rr, req)
@@ -68,6 +68,28 @@ func TestCreateProperty_Handler(t *testing.T) {
 	assert.True(t, response["created"].(bool))
 }
 
+func TestCreateProperty_Handler_PublishedNeedsPublishPermission(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewPropertyService(mockRepo)
+	h := handler.NewPropertyHandler(svc, nil, nil, nil)
+
+	jsonData, _ := json.Marshal(entity.Property{Reference: "REF-PUB", Status: "published"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/properties", bytes.NewReader(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := context.WithValue(req.Context(), middleware.CompanyIDKey, "C1")
+	ctx = context.WithValue(ctx, middleware.RoleKey, string(entity.RoleAgent))
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	h.CreateProperty(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "Create")
+}
+
 func TestGetPropertyByID_Handler_Success(t *testing.T) {
 	// GIVEN
 	mockRepo := new(mocks.PropertyRepositoryMock)
@@ -81,7 +103,7 @@ func TestGetPropertyByID_Handler_Success(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/properties/"+propID, nil)
 	rr := httptest.NewRecorder()
 
-	mockRepo.On("FindByID", propID).Return(prop, nil)
+	mockRepo.On("FindByID", mock.Anything, propID).Return(prop, nil)
 
 	// WHEN
 	h.GetPropertyByID(rr, req)
@@ -0,0 +1,299 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// These tests run two companies through the real middleware, services and
+// repositories (over sqlmock) and check that company B never reaches the data
+// of company A: the SQL sent to the database is always filtered by company B,
+// and the API answers 404 as if the row did not exist.
+
+const (
+	companyA = "company-a"
+	companyB = "company-b"
+)
+
+func setupTenantDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	return gormDB, mock
+}
+
+// serveAs sends req through the auth middleware with a token for an admin of companyID
+func serveAs(t *testing.T, companyID, pattern string, h http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	return serveWithRole(t, companyID, entity.RoleAdmin, pattern, h, req)
+}
+
+// serveWithRole sends req through the auth middleware with a token for an agent of companyID with the given role
+func serveWithRole(t *testing.T, companyID string, role entity.AgentRole, pattern string, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	token, err := security.GenerateToken("agent-"+companyID, companyID, string(role), "session-"+companyID)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, middleware.NewAuthMiddleware(activeSessions{}, nil)(h))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func newLeadHandler(db *gorm.DB) *handler.LeadHandler {
+	return handler.NewLeadHandler(service.NewLeadService(repository.NewLeadRepository(db), nil), nil)
+}
+
+func TestTenant_GetLeadByID_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := newLeadHandler(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("lead-of-a", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads/lead-of-a", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/leads/{id}", h.GetLeadByID, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_UpdateLead_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := newLeadHandler(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("lead-of-a", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	body, _ := json.Marshal(map[string]string{"name": "Hijacked"})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/leads/lead-of-a", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveAs(t, companyB, "PUT /api/v1/leads/{id}", h.UpdateLead, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_DeleteLead_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := newLeadHandler(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM \"leads\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("lead-of-a", companyB).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/leads/lead-of-a", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "DELETE /api/v1/leads/{id}", h.DeleteLead, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_GetAllLeads_OnlyOwnCompany(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := newLeadHandler(db)
+
+	rows := sqlmock.NewRows([]string{"id", "company_id", "total_count"}).AddRow("lead-of-a", companyA, 1)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM (SELECT leads.*, COUNT(*) OVER () AS total_count FROM \"leads\" WHERE company_id = $1")).
+		WithArgs(companyA, entity.LeadPageDefaultLimit+1).
+		WillReturnRows(rows)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads", nil)
+
+	// WHEN
+	rr := serveAs(t, companyA, "GET /api/v1/leads", h.GetAllLeads, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var leads []entity.Lead
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &leads))
+	assert.Len(t, leads, 1)
+	assert.Equal(t, companyA, leads[0].CompanyID)
+	assert.Equal(t, "1", rr.Header().Get("X-Total-Count"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_GetLeadsByCompany_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := newLeadHandler(db)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/leads/bycompany/"+companyA, nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/leads/bycompany/{companyId}", h.GetLeadByCompanyId, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet()) // no query reached the database
+}
+
+func TestTenant_GetPropertyByID_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	svc := service.NewPropertyService(repository.NewPropertyRepository(db))
+	h := handler.NewPropertyHandler(svc, nil, nil, nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"properties\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("property-of-a", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/properties/property-of-a", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/properties/{id}", h.GetPropertyByID, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_GetPropertiesByCompany_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	svc := service.NewPropertyService(repository.NewPropertyRepository(db))
+	h := handler.NewPropertyHandler(svc, nil, nil, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/properties/company/"+companyA, nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/properties/company/{company_id}", h.GetPropertiesByCompany, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_GetAgentByID_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	svc := service.NewAgentService(repository.NewAgentRepository(db), repository.NewPropertyRepository(db))
+	h := handler.NewAgentHandler(svc)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"agents\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("agent-of-a", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/agents/agent-of-a", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/agents/{id}", h.GetAgentByID, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_UpdateCompany_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := handler.NewCompanyHandler(service.NewCompanyService(repository.NewCompanyRepository(db)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"companies\" SET .* WHERE id = \\$\\d+ AND id = \\$\\d+").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(map[string]string{"name": "Hijacked"})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/companies/"+companyA, bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveAs(t, companyB, "PUT /api/v1/companies/{id}", h.UpdateCompany, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_SendMessage_OtherCompanyLeadIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	h := handler.NewMessageHandler(service.NewMessageService(repository.NewMessageRepository(db)))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM \"leads\" WHERE (id = $1 AND company_id = $2)")).
+		WithArgs("lead-of-a", companyB).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	body, _ := json.Marshal(map[string]string{"senderType": "agent", "content": "Hello"})
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/conversations/lead-of-a/messages", bytes.NewBuffer(body))
+
+	// WHEN
+	rr := serveAs(t, companyB, "POST /api/v1/conversations/{leadId}/messages", h.SendMessage, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_ListEmailConfigs_OtherCompanyIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	svc := service.NewCompanyEmailConfigService(repository.NewCompanyEmailConfigRepository(db), repository.NewAgentRepository(db), repository.NewEmailSyncRunRepository(db), "test-key")
+	h := handler.NewCompanyEmailConfigHandler(svc)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/companies/"+companyA+"/email-config", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/companies/{id}/email-config", h.ListEmailConfigs, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_GetEmailConfig_OtherCompanyConfigIs404(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantDB(t)
+	svc := service.NewCompanyEmailConfigService(repository.NewCompanyEmailConfigRepository(db), repository.NewAgentRepository(db), repository.NewEmailSyncRunRepository(db), "test-key")
+	h := handler.NewCompanyEmailConfigHandler(svc)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"company_email_configs\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("config-of-a", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/companies/"+companyB+"/email-config/config-of-a", nil)
+
+	// WHEN
+	rr := serveAs(t, companyB, "GET /api/v1/companies/{id}/email-config/{configId}", h.GetEmailConfig, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+)
+
+type TwoFactorHandler struct {
+	Service         *service.TwoFactorService
+	SessionService  *service.SessionService
+	ThrottleService *service.LoginThrottleService
+}
+
+func NewTwoFactorHandler(s *service.TwoFactorService, sessionService *service.SessionService, throttleService *service.LoginThrottleService) *TwoFactorHandler {
+	return &TwoFactorHandler{Service: s, SessionService: sessionService, ThrottleService: throttleService}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// writeTwoFactorError maps service errors to status codes
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case strings.Contains(err.Error(), "unauthorized"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "already enabled"), strings.Contains(err.Error(), "not enabled"), strings.Contains(err.Error(), "not been started"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /api/v1/auth/2fa/enroll
+func (h *TwoFactorHandler) BeginEnrollment(w http.ResponseWriter, r *http.Request) {
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	enrollment, err := h.Service.BeginEnrollment(r.Context(), agentID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enrollment)
+}
+
+// POST /api/v1/auth/2fa/enable
+func (h *TwoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	codes, err := h.Service.Enable(r.Context(), agentID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// POST /api/v1/auth/2fa/disable
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	if err := h.Service.Disable(r.Context(), agentID, req.Code); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/v1/auth/2fa/recovery-codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	agentID, _ := r.Context().Value(middleware.AgentIDKey).(string)
+
+	codes, err := h.Service.RegenerateRecoveryCodes(r.Context(), agentID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// POST /api/v1/auth/login/2fa/enroll lets an agent whose company requires 2FA
+// enroll during login, with the challenge token of the password step
+func (h *TwoFactorHandler) LoginEnrollment(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	agentID, audience, err := security.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil || audience != security.ChallengeTwoFactorSetup {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.Service.BeginEnrollment(r.Context(), agentID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enrollment)
+}
+
+// POST /api/v1/auth/login/2fa is the second login step: it checks the TOTP or
+// recovery code and starts the session
+func (h *TwoFactorHandler) LoginVerify(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	agentID, _, err := security.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	// Wrong codes count as failed logins of the account, like wrong passwords
+	challenged, err := h.Service.FindAgent(r.Context(), agentID)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+	ip := clientIP(r)
+	retryAfter, err := h.ThrottleService.CheckLogin(r.Context(), challenged.Email, ip)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
+
+	agent, recoveryCodes, err := h.Service.VerifyLogin(r.Context(), agentID, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			if err := h.ThrottleService.RecordLoginFailure(r.Context(), challenged.Email, ip); err != nil {
+				log.Printf("[TwoFactor] ERROR: Failed to record failed login: %v", err)
+			}
+		}
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	tokens, err := h.SessionService.Start(r.Context(), agent, r.UserAgent(), ip)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.ThrottleService.RecordLoginSuccess(r.Context(), agent.Email); err != nil {
+		log.Printf("[TwoFactor] ERROR: Failed to reset login attempts of %s: %v", agent.Email, err)
+	}
+
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		Agent:         *agent,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// DELETE /api/v1/agents/{id}/two-factor
+func (h *TwoFactorHandler) ResetAgentTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	executorRole, _ := r.Context().Value(middleware.RoleKey).(string)
+
+	if err := h.Service.Reset(r.Context(), id, entity.AgentRole(executorRole)); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/v1/companies/{id}/two-factor
+func (h *TwoFactorHandler) SetCompanyRequirement(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !requireSameCompany(w, r, id) {
+		return
+	}
+
+	var req struct {
+		Required bool `json:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.SetCompanyRequirement(r.Context(), id, req.Required); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
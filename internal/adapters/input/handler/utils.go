@@ -1,10 +1,49 @@
 package handler
 
 import (
+	"math"
+	"net"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
 )
 
+// requireSameCompany writes a 404 unless companyID is the caller's company.
+// Other companies' resources are reported as missing so their existence is not leaked.
+func requireSameCompany(w http.ResponseWriter, r *http.Request, companyID string) bool {
+	callerCompanyID, _ := r.Context().Value(middleware.CompanyIDKey).(string)
+	if callerCompanyID == "" || callerCompanyID != companyID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// clientIP returns the address of the caller. Behind a proxy, middleware.RealIP
+// has already replaced RemoteAddr with the address the trusted proxies saw.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// writeTooManyAttempts answers 429 with the seconds to wait in Retry-After
+func writeTooManyAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many attempts, try again in "+strconv.Itoa(seconds)+" seconds", http.StatusTooManyRequests)
+}
+
 //Functions to search queryParams and transform
 
 func getQueryInt(q url.Values, key string) *int {
@@ -50,3 +89,14 @@ func getQueryString(q url.Values, key string) *string {
 	}
 	return &valStr
 }
+
+// getQueryList splits a comma-separated param, nil when it is missing
+func getQueryList(q url.Values, key string) []string {
+	var values []string
+	for _, value := range strings.Split(q.Get(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
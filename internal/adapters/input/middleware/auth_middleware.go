@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
 )
 
@@ -14,32 +16,84 @@ const (
 	AgentIDKey   contextKey = "agent_id"
 	CompanyIDKey contextKey = "company_id"
 	RoleKey      contextKey = "role"
+	SessionIDKey contextKey = "session_id"
+	APIKeyKey    contextKey = "api_key"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
-
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
-			return
-		}
-
-		claims, err := security.ValidateToken(bearerToken[1])
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), AgentIDKey, claims.AgentID)
-		ctx = context.WithValue(ctx, CompanyIDKey, claims.CompanyID)
-		ctx = context.WithValue(ctx, RoleKey, claims.Role)
-
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// APIKeyHeader carries an API key, as an alternative to a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// SessionChecker tells whether the session an access token was issued for is still active
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID, agentID string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves an API key to the active key it belongs to, or nil
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*entity.APIKey, error)
+}
+
+// NewAuthMiddleware validates the bearer token and rejects it once its
+// session has been revoked (logout, password reset, deleted agent).
+// When apiKeys is not nil, a company API key is accepted instead.
+func NewAuthMiddleware(sessions SessionChecker, apiKeys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if plain := r.Header.Get(APIKeyHeader); plain != "" && apiKeys != nil {
+				key, err := apiKeys.Authenticate(r.Context(), plain)
+				if err != nil {
+					http.Error(w, "Failed to validate API key", http.StatusInternalServerError)
+					return
+				}
+				if key == nil {
+					http.Error(w, "Invalid API key", http.StatusUnauthorized)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), CompanyIDKey, key.CompanyID)
+				ctx = context.WithValue(ctx, APIKeyKey, key)
+				ctx = tenant.WithCompanyID(ctx, key.CompanyID)
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				return
+			}
+
+			bearerToken := strings.Split(authHeader, " ")
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+				http.Error(w, "Invalid token format", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := security.ValidateToken(bearerToken[1])
+			if err != nil || claims.SessionID == "" {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			active, err := sessions.IsActive(r.Context(), claims.SessionID, claims.AgentID)
+			if err != nil {
+				http.Error(w, "Failed to validate session", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				http.Error(w, "Session expired or revoked", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), AgentIDKey, claims.AgentID)
+			ctx = context.WithValue(ctx, CompanyIDKey, claims.CompanyID)
+			ctx = context.WithValue(ctx, RoleKey, claims.Role)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
+			// Every repository call made while serving this request is restricted to the caller's company
+			ctx = tenant.WithCompanyID(ctx, claims.CompanyID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+// GrantsOf returns what the caller may do: the scopes of its API key, or else
+// the role set by the auth middleware
+func GrantsOf(ctx context.Context) entity.Grants {
+	if key, ok := ctx.Value(APIKeyKey).(*entity.APIKey); ok {
+		return key
+	}
+	role, _ := ctx.Value(RoleKey).(string)
+	return entity.AgentRole(role)
+}
+
+// RequirePermission only lets the request through when the role set by
+// the auth middleware, or the scopes of the API key, grant the given
+// permission. It must run after it.
+func RequirePermission(permission entity.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := r.Context().Value(APIKeyKey).(*entity.APIKey); ok {
+				if !key.Can(permission) {
+					http.Error(w, "Forbidden: API key is missing scope for "+string(permission), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			role, _ := r.Context().Value(RoleKey).(string)
+			if !entity.AgentRole(role).Can(permission) {
+				http.Error(w, "Forbidden: missing permission "+string(permission), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCors_PreflightAllowsPatch(t *testing.T) {
+	// GIVEN
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { t.Fatal("preflight reached the handler") })
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/companies/C1/email-config/E1/toggle", nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	middleware.CorsMiddleware(next).ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), "PATCH")
+}
+
+func TestCors_ExposesThePagination(t *testing.T) {
+	// GIVEN
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "120")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/leads", nil)
+	rr := httptest.NewRecorder()
+
+	// WHEN
+	middleware.CorsMiddleware(next).ServeHTTP(rr, req)
+
+	// THEN
+	assert.Equal(t, "X-Total-Count, X-Next-Cursor", rr.Header().Get("Access-Control-Expose-Headers"))
+}
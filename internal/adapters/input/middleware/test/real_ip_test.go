@@ -0,0 +1,62 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteAddrAfterRealIP returns the RemoteAddr the next handler sees
+func remoteAddrAfterRealIP(t *testing.T, trusted, remoteAddr string, forwardedFor ...string) string {
+	proxies, err := middleware.ParseTrustedProxies(trusted)
+	require.NoError(t, err)
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r.RemoteAddr })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for _, value := range forwardedFor {
+		req.Header.Add("X-Forwarded-For", value)
+	}
+	middleware.RealIP(proxies)(next).ServeHTTP(httptest.NewRecorder(), req)
+	return seen
+}
+
+func TestRealIP_IgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	// GIVEN a client talking to the API directly
+	// WHEN it sends its own X-Forwarded-For
+	seen := remoteAddrAfterRealIP(t, "10.0.0.0/8", "203.0.113.7:5555", "1.2.3.4")
+
+	// THEN
+	assert.Equal(t, "203.0.113.7:5555", seen)
+}
+
+func TestRealIP_TakesTheRightMostUntrustedHop(t *testing.T) {
+	// GIVEN two trusted proxies, the outer one appending the client address
+	// WHEN the client forged the left-most entry
+	seen := remoteAddrAfterRealIP(t, "10.0.0.1, 10.0.0.2", "10.0.0.2:4444", "1.2.3.4, 198.51.100.9", "10.0.0.1")
+
+	// THEN
+	assert.Equal(t, "198.51.100.9:0", seen)
+}
+
+func TestRealIP_StopsAtMalformedHops(t *testing.T) {
+	// GIVEN
+	// WHEN the hop next to the proxy is not an address
+	seen := remoteAddrAfterRealIP(t, "10.0.0.1", "10.0.0.1:4444", "1.2.3.4, "+string(make([]byte, 100)))
+
+	// THEN the proxy itself is the caller
+	assert.Equal(t, "10.0.0.1:0", seen)
+}
+
+func TestParseTrustedProxies_RejectsGarbage(t *testing.T) {
+	// WHEN
+	_, err := middleware.ParseTrustedProxies("10.0.0.1, proxy.internal")
+
+	// THEN
+	assert.Error(t, err)
+}
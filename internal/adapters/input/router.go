@@ -5,6 +5,7 @@ import (
 
 	"github.com/myestatia/myestatia-go/internal/adapters/input/handler"
 	"github.com/myestatia/myestatia-go/internal/adapters/input/middleware"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
 )
 
 func NewRouter(
@@ -16,84 +17,183 @@ func NewRouter(
 	authHandler *handler.AuthHandler,
 	emailConfigHandler *handler.CompanyEmailConfigHandler,
 	googleOAuthHandler *handler.GoogleOAuthHandler,
+	microsoftOAuthHandler *handler.MicrosoftOAuthHandler,
 	passwordResetHandler *handler.PasswordResetHandler,
 	presentationHandler *handler.PresentationHandler,
+	invitationHandler *handler.InvitationHandler,
+	twoFactorHandler *handler.TwoFactorHandler,
+	securityHandler *handler.SecurityHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+	parserTemplateHandler *handler.EmailParserTemplateHandler,
+	failedEmailHandler *handler.FailedEmailHandler,
+	inboundEmailHandler *handler.InboundEmailHandler,
+	leadAssignmentHandler *handler.LeadAssignmentHandler,
+	leadScoreHandler *handler.LeadScoreHandler,
+	sessions middleware.SessionChecker,
+	apiKeys middleware.APIKeyAuthenticator,
 ) http.Handler {
 	mux := http.NewServeMux()
 
 	// Auth
 	mux.HandleFunc("POST /api/v1/auth/register", authHandler.Register)
 	mux.HandleFunc("POST /api/v1/auth/login", authHandler.Login)
+	mux.HandleFunc("POST /api/v1/auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("POST /api/v1/auth/login/2fa", twoFactorHandler.LoginVerify)
+	mux.HandleFunc("POST /api/v1/auth/login/2fa/enroll", twoFactorHandler.LoginEnrollment)
 
 	// Password Reset (Public endpoints)
 	mux.HandleFunc("POST /api/v1/auth/forgot-password", passwordResetHandler.ForgotPassword)
 	mux.HandleFunc("POST /api/v1/auth/reset-password", passwordResetHandler.ResetPassword)
 	mux.HandleFunc("GET /api/v1/auth/validate-reset-token/{token}", passwordResetHandler.ValidateResetToken)
 
-	// Helper to protect routes
-	protected := func(h http.HandlerFunc) http.Handler {
-		return middleware.AuthMiddleware(h)
+	// Invitations (Public endpoints used by the invitee)
+	mux.HandleFunc("GET /api/v1/auth/invitations/{token}", invitationHandler.ValidateInvitation)
+	mux.HandleFunc("POST /api/v1/auth/accept-invitation", invitationHandler.AcceptInvitation)
+
+	// Helper to protect routes: authenticated caller whose role (or API key scopes) grants the permission.
+	// Routes wrapped only in auth are about the agent itself, so they do not accept API keys.
+	auth := middleware.NewAuthMiddleware(sessions, nil)
+	authOrAPIKey := middleware.NewAuthMiddleware(sessions, apiKeys)
+	protected := func(permission entity.Permission, h http.HandlerFunc) http.Handler {
+		return authOrAPIKey(middleware.RequirePermission(permission)(h))
 	}
 
+	// Sessions (any authenticated agent)
+	mux.Handle("POST /api/v1/auth/logout", auth(http.HandlerFunc(authHandler.Logout)))
+	mux.Handle("POST /api/v1/auth/logout-all", auth(http.HandlerFunc(authHandler.LogoutAll)))
+
+	// Two-factor authentication of the caller
+	mux.Handle("POST /api/v1/auth/2fa/enroll", auth(http.HandlerFunc(twoFactorHandler.BeginEnrollment)))
+	mux.Handle("POST /api/v1/auth/2fa/enable", auth(http.HandlerFunc(twoFactorHandler.Enable)))
+	mux.Handle("POST /api/v1/auth/2fa/disable", auth(http.HandlerFunc(twoFactorHandler.Disable)))
+	mux.Handle("POST /api/v1/auth/2fa/recovery-codes", auth(http.HandlerFunc(twoFactorHandler.RegenerateRecoveryCodes)))
+
 	// CRUD Leads
-	mux.Handle("POST /api/v1/leads", protected(leadHandler.CreateLead))
-	mux.Handle("GET /api/v1/leads", protected(leadHandler.GetAllLeads))
-	mux.Handle("GET /api/v1/leads/{id}", protected(leadHandler.GetLeadByID))
-	mux.Handle("PUT /api/v1/leads/{id}", protected(leadHandler.UpdateLead))
-	mux.Handle("DELETE /api/v1/leads/{id}", protected(leadHandler.DeleteLead))
-	mux.Handle("GET /api/v1/leads/bycompany/{companyId}", protected(leadHandler.GetLeadByCompanyId))
-	mux.Handle("GET /api/v1/leads/byproperty/{propertyId}", protected(leadHandler.GetLeadByPropertyId))
+	mux.Handle("POST /api/v1/leads", protected(entity.PermissionLeadWrite, leadHandler.CreateLead))
+	mux.Handle("GET /api/v1/leads", protected(entity.PermissionLeadRead, leadHandler.GetAllLeads))
+	mux.Handle("GET /api/v1/leads/funnel", protected(entity.PermissionLeadRead, leadHandler.GetLeadFunnel))
+	mux.Handle("GET /api/v1/leads/{id}", protected(entity.PermissionLeadRead, leadHandler.GetLeadByID))
+	mux.Handle("PUT /api/v1/leads/{id}", protected(entity.PermissionLeadWrite, leadHandler.UpdateLead))
+	mux.Handle("DELETE /api/v1/leads/{id}", protected(entity.PermissionLeadDelete, leadHandler.DeleteLead))
+	mux.Handle("GET /api/v1/leads/bycompany/{companyId}", protected(entity.PermissionLeadRead, leadHandler.GetLeadByCompanyId))
+	mux.Handle("GET /api/v1/leads/byproperty/{propertyId}", protected(entity.PermissionLeadRead, leadHandler.GetLeadByPropertyId))
+	// GET /leads/{id}/history on its own would overlap /leads/bycompany/{companyId}
+	mux.Handle("GET /api/v1/leads/{id}/{subresource}", protected(entity.PermissionLeadRead, leadHandler.GetLeadSubresource))
+	mux.Handle("POST /api/v1/leads/{id}/transition", protected(entity.PermissionLeadWrite, leadHandler.TransitionLead))
+	// Merging deletes the duplicate lead
+	mux.Handle("POST /api/v1/leads/{id}/merge", protected(entity.PermissionLeadDelete, leadHandler.MergeLead))
+	mux.Handle("POST /api/v1/leads/{id}/assign", protected(entity.PermissionLeadAssign, leadAssignmentHandler.AssignLead))
+
+	// Rules assigning new leads to agents
+	mux.Handle("POST /api/v1/assignment-rules", protected(entity.PermissionLeadAssign, leadAssignmentHandler.CreateRule))
+	mux.Handle("GET /api/v1/assignment-rules", protected(entity.PermissionLeadAssign, leadAssignmentHandler.ListRules))
+	mux.Handle("GET /api/v1/assignment-rules/{id}", protected(entity.PermissionLeadAssign, leadAssignmentHandler.GetRule))
+	mux.Handle("PUT /api/v1/assignment-rules/{id}", protected(entity.PermissionLeadAssign, leadAssignmentHandler.UpdateRule))
+	mux.Handle("DELETE /api/v1/assignment-rules/{id}", protected(entity.PermissionLeadAssign, leadAssignmentHandler.DeleteRule))
+
+	// How lead scores weigh each factor
+	mux.Handle("GET /api/v1/lead-score/weights", protected(entity.PermissionLeadRead, leadScoreHandler.GetWeights))
+	mux.Handle("PUT /api/v1/lead-score/weights", protected(entity.PermissionCompanyManage, leadScoreHandler.UpdateWeights))
 
 	//Property search filters (Public? Or Protected? Let's protect for now to enforce users)
-	mux.Handle("GET /api/v1/properties/search", protected(propertyHandler.SearchProperties))
+	mux.Handle("GET /api/v1/properties/search", protected(entity.PermissionPropertyRead, propertyHandler.SearchProperties))
 
 	// Public Property access
 	mux.HandleFunc("GET /api/v1/public/properties/", propertyHandler.GetPublicPropertyByID)
 
 	// CRUD Property
-	mux.Handle("POST /api/v1/properties", protected(propertyHandler.CreateProperty))
-	mux.Handle("GET /api/v1/properties", protected(propertyHandler.GetAllProperties))
-	mux.Handle("GET /api/v1/properties/{id}", protected(propertyHandler.GetPropertyByID))
-	mux.Handle("PUT /api/v1/properties/{id}", protected(propertyHandler.UpdateProperty))
-	mux.Handle("DELETE /api/v1/properties/{id}", protected(propertyHandler.DeleteProperty))
-	mux.Handle("GET /api/v1/properties/company/{company_id}", protected(propertyHandler.GetPropertiesByCompany))
-	mux.Handle("GET /api/v1/property-subtypes", protected(propertyHandler.ListSubtypes))
+	mux.Handle("POST /api/v1/properties", protected(entity.PermissionPropertyWrite, propertyHandler.CreateProperty))
+	mux.Handle("GET /api/v1/properties", protected(entity.PermissionPropertyRead, propertyHandler.GetAllProperties))
+	mux.Handle("GET /api/v1/properties/{id}", protected(entity.PermissionPropertyRead, propertyHandler.GetPropertyByID))
+	mux.Handle("PUT /api/v1/properties/{id}", protected(entity.PermissionPropertyWrite, propertyHandler.UpdateProperty))
+	mux.Handle("DELETE /api/v1/properties/{id}", protected(entity.PermissionPropertyWrite, propertyHandler.DeleteProperty))
+	mux.Handle("GET /api/v1/properties/company/{company_id}", protected(entity.PermissionPropertyRead, propertyHandler.GetPropertiesByCompany))
+	mux.Handle("GET /api/v1/property-subtypes", protected(entity.PermissionPropertyRead, propertyHandler.ListSubtypes))
 
 	//CRUD Company
-	mux.Handle("POST /api/v1/companies", protected(companyHandler.CreateCompany))
-	mux.Handle("GET /api/v1/companies", protected(companyHandler.GetAllCompanies))
-	mux.Handle("GET /api/v1/companies/{id}", protected(companyHandler.GetCompanyByID))
-	mux.Handle("PUT /api/v1/companies/{id}", protected(companyHandler.UpdateCompany))
-	mux.Handle("DELETE /api/v1/companies/{id}", protected(companyHandler.DeleteCompany))
+	mux.Handle("POST /api/v1/companies", protected(entity.PermissionCompanyManage, companyHandler.CreateCompany))
+	mux.Handle("GET /api/v1/companies", protected(entity.PermissionCompanyRead, companyHandler.GetAllCompanies))
+	mux.Handle("GET /api/v1/companies/{id}", protected(entity.PermissionCompanyRead, companyHandler.GetCompanyByID))
+	mux.Handle("PUT /api/v1/companies/{id}", protected(entity.PermissionCompanyManage, companyHandler.UpdateCompany))
+	mux.Handle("DELETE /api/v1/companies/{id}", protected(entity.PermissionCompanyManage, companyHandler.DeleteCompany))
+	mux.Handle("PUT /api/v1/companies/{id}/two-factor", protected(entity.PermissionCompanyManage, twoFactorHandler.SetCompanyRequirement))
 
 	//CRUD Agent
-	mux.Handle("POST /api/v1/agents", protected(agentHandler.CreateAgent))
-	mux.Handle("GET /api/v1/agents", protected(agentHandler.GetAllAgents))
-	mux.Handle("GET /api/v1/agents/{id}", protected(agentHandler.GetAgentByID))
-	mux.Handle("PUT /api/v1/agents/{id}", protected(agentHandler.UpdateAgent))
-	mux.Handle("DELETE /api/v1/agents/{id}", protected(agentHandler.DeleteAgent))
+	mux.Handle("POST /api/v1/agents", protected(entity.PermissionAgentManage, agentHandler.CreateAgent))
+	mux.Handle("GET /api/v1/agents", protected(entity.PermissionAgentRead, agentHandler.GetAllAgents))
+	mux.Handle("GET /api/v1/agents/{id}", protected(entity.PermissionAgentRead, agentHandler.GetAgentByID))
+	mux.Handle("PUT /api/v1/agents/{id}", protected(entity.PermissionAgentManage, agentHandler.UpdateAgent))
+	mux.Handle("DELETE /api/v1/agents/{id}", protected(entity.PermissionAgentManage, agentHandler.DeleteAgent))
+	mux.Handle("PUT /api/v1/agents/{id}/role", protected(entity.PermissionAgentRole, agentHandler.ChangeAgentRole))
+	mux.Handle("DELETE /api/v1/agents/{id}/two-factor", protected(entity.PermissionAgentManage, twoFactorHandler.ResetAgentTwoFactor))
+	mux.Handle("GET /api/v1/security/lockouts", protected(entity.PermissionAgentManage, securityHandler.ListLockouts))
+	mux.Handle("GET /api/v1/roles", protected(entity.PermissionAgentRead, agentHandler.ListRoles))
+
+	// Invitations
+	mux.Handle("POST /api/v1/invitations", protected(entity.PermissionAgentManage, invitationHandler.CreateInvitation))
+	mux.Handle("GET /api/v1/invitations", protected(entity.PermissionAgentManage, invitationHandler.ListInvitations))
+	mux.Handle("DELETE /api/v1/invitations/{id}", protected(entity.PermissionAgentManage, invitationHandler.RevokeInvitation))
+
+	// API keys for integrations
+	mux.Handle("POST /api/v1/api-keys", protected(entity.PermissionAPIKeyManage, apiKeyHandler.CreateAPIKey))
+	mux.Handle("GET /api/v1/api-keys", protected(entity.PermissionAPIKeyManage, apiKeyHandler.ListAPIKeys))
+	mux.Handle("DELETE /api/v1/api-keys/{id}", protected(entity.PermissionAPIKeyManage, apiKeyHandler.RevokeAPIKey))
 
 	// Conversations
-	mux.Handle("GET /api/v1/lead/{id}/conversations", protected(messageHandler.GetConversations))
-	mux.Handle("POST /api/v1/conversations/{leadId}/messages", protected(messageHandler.SendMessage))
+	mux.Handle("GET /api/v1/lead/{id}/conversations", protected(entity.PermissionLeadRead, messageHandler.GetConversations))
+	// Alias of /api/v1/leads/{id}/history
+	mux.Handle("GET /api/v1/lead/{id}/history", protected(entity.PermissionLeadRead, leadHandler.GetLeadHistory))
+	mux.Handle("GET /api/v1/lead/{id}/duplicates", protected(entity.PermissionLeadRead, leadHandler.GetLeadDuplicates))
+	mux.Handle("GET /api/v1/lead/{id}/assignments", protected(entity.PermissionLeadRead, leadAssignmentHandler.GetLeadAssignments))
+	mux.Handle("GET /api/v1/lead/{id}/score", protected(entity.PermissionLeadRead, leadScoreHandler.GetLeadScore))
+	mux.Handle("GET /api/v1/lead/{id}/emails/{emailId}", protected(entity.PermissionLeadRead, inboundEmailHandler.GetLeadEmail))
+	mux.Handle("GET /api/v1/lead/{id}/emails/{emailId}/attachments/{attachmentId}", protected(entity.PermissionLeadRead, inboundEmailHandler.GetLeadEmailAttachment))
+	mux.Handle("POST /api/v1/conversations/{leadId}/messages", protected(entity.PermissionLeadWrite, messageHandler.SendMessage))
 
 	// Company Email Configuration (for MyAccount integration)
-	mux.Handle("POST /api/v1/companies/{id}/email-config", protected(emailConfigHandler.CreateEmailConfig))
-	mux.Handle("GET /api/v1/companies/{id}/email-config", protected(emailConfigHandler.GetEmailConfig))
-	mux.Handle("PUT /api/v1/companies/{id}/email-config", protected(emailConfigHandler.UpdateEmailConfig))
-	mux.Handle("DELETE /api/v1/companies/{id}/email-config", protected(emailConfigHandler.DeleteEmailConfig))
-	mux.Handle("POST /api/v1/companies/{id}/email-config/test", protected(emailConfigHandler.TestConnection))
-	mux.Handle("PATCH /api/v1/companies/{id}/email-config/toggle", protected(emailConfigHandler.ToggleEnabled))
-
-	// Google OAuth2 for Gmail (Public endpoints - no auth required for OAuth flow)
-	mux.HandleFunc("GET /api/v1/auth/google/connect", googleOAuthHandler.InitiateOAuth)
+	mux.Handle("POST /api/v1/companies/{id}/email-config", protected(entity.PermissionEmailConfigManage, emailConfigHandler.CreateEmailConfig))
+	mux.Handle("GET /api/v1/companies/{id}/email-config", protected(entity.PermissionEmailConfigManage, emailConfigHandler.ListEmailConfigs))
+	mux.Handle("GET /api/v1/companies/{id}/email-config/status", protected(entity.PermissionEmailConfigManage, emailConfigHandler.GetIngestionStatus))
+	mux.Handle("GET /api/v1/companies/{id}/email-config/{configId}", protected(entity.PermissionEmailConfigManage, emailConfigHandler.GetEmailConfig))
+	mux.Handle("PUT /api/v1/companies/{id}/email-config/{configId}", protected(entity.PermissionEmailConfigManage, emailConfigHandler.UpdateEmailConfig))
+	mux.Handle("DELETE /api/v1/companies/{id}/email-config/{configId}", protected(entity.PermissionEmailConfigManage, emailConfigHandler.DeleteEmailConfig))
+	mux.Handle("POST /api/v1/companies/{id}/email-config/{configId}/test", protected(entity.PermissionEmailConfigManage, emailConfigHandler.TestConnection))
+	mux.Handle("PATCH /api/v1/companies/{id}/email-config/{configId}/toggle", protected(entity.PermissionEmailConfigManage, emailConfigHandler.ToggleEnabled))
+
+	// Company-defined email parsers
+	mux.Handle("POST /api/v1/email-parsers", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.CreateTemplate))
+	mux.Handle("GET /api/v1/email-parsers", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.ListTemplates))
+	mux.Handle("POST /api/v1/email-parsers/dry-run", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.DryRun))
+	mux.Handle("GET /api/v1/email-parsers/{id}", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.GetTemplate))
+	mux.Handle("PUT /api/v1/email-parsers/{id}", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.UpdateTemplate))
+	mux.Handle("DELETE /api/v1/email-parsers/{id}", protected(entity.PermissionEmailConfigManage, parserTemplateHandler.DeleteTemplate))
+
+	// Inbound emails that did not become leads (dead-letter queue)
+	mux.Handle("GET /api/v1/failed-emails", protected(entity.PermissionEmailConfigManage, failedEmailHandler.ListFailedEmails))
+	mux.Handle("GET /api/v1/failed-emails/{id}", protected(entity.PermissionEmailConfigManage, failedEmailHandler.GetFailedEmail))
+	mux.Handle("POST /api/v1/failed-emails/{id}/reprocess", protected(entity.PermissionEmailConfigManage, failedEmailHandler.ReprocessFailedEmail))
+	mux.Handle("DELETE /api/v1/failed-emails/{id}", protected(entity.PermissionEmailConfigManage, failedEmailHandler.DiscardFailedEmail))
+
+	// OAuth2 mailboxes: connecting needs a signed-in agent, since a person has to
+	// consent at the provider; the callback is public and trusts only the signed state
+	connectMailbox := func(h http.HandlerFunc) http.Handler {
+		return auth(middleware.RequirePermission(entity.PermissionEmailConfigManage)(h))
+	}
+
+	// Google OAuth2 for Gmail
+	mux.Handle("GET /api/v1/auth/google/connect", connectMailbox(googleOAuthHandler.InitiateOAuth))
 	mux.HandleFunc("GET /api/v1/auth/google/callback", googleOAuthHandler.HandleCallback)
-	mux.Handle("POST /api/v1/auth/google/disconnect", protected(googleOAuthHandler.DisconnectGmail))
+	mux.Handle("POST /api/v1/auth/google/disconnect", protected(entity.PermissionEmailConfigManage, googleOAuthHandler.DisconnectGmail))
+
+	// Microsoft OAuth2 for Microsoft 365 / Outlook
+	mux.Handle("GET /api/v1/auth/microsoft/connect", connectMailbox(microsoftOAuthHandler.InitiateOAuth))
+	mux.HandleFunc("GET /api/v1/auth/microsoft/callback", microsoftOAuthHandler.HandleCallback)
+	mux.Handle("POST /api/v1/auth/microsoft/disconnect", protected(entity.PermissionEmailConfigManage, microsoftOAuthHandler.DisconnectMicrosoft))
 
 	// Presentations
-	mux.Handle("POST /api/v1/presentations", protected(presentationHandler.CreatePresentation))
+	mux.Handle("POST /api/v1/presentations", protected(entity.PermissionLeadRead, presentationHandler.CreatePresentation))
 	mux.HandleFunc("GET /api/v1/public/presentations/", presentationHandler.GetPresentation)
-	mux.Handle("GET /api/v1/presentations/matching-properties/{leadId}", protected(presentationHandler.GetMatchingProperties))
+	mux.Handle("GET /api/v1/presentations/matching-properties/{leadId}", protected(entity.PermissionLeadRead, presentationHandler.GetMatchingProperties))
 
 	return mux
 
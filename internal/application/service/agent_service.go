@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
@@ -34,11 +36,11 @@ func (s *AgentService) Create(ctx context.Context, c *entity.Agent) (*entity.Age
 
 	c.ID = uuid.New().String()
 
-	if err := s.AssociateProperties(c); err != nil {
+	if err := s.AssociateProperties(ctx, c); err != nil {
 		return nil, false, err
 	}
 
-	if err := s.Repo.Create(c); err != nil {
+	if err := s.Repo.Create(ctx, c); err != nil {
 		return nil, false, err
 	}
 
@@ -46,11 +48,11 @@ func (s *AgentService) Create(ctx context.Context, c *entity.Agent) (*entity.Age
 }
 
 func (s *AgentService) FindByID(ctx context.Context, id string) (*entity.Agent, error) {
-	return s.Repo.FindByID(id)
+	return s.Repo.FindByID(ctx, id)
 }
 
 func (s *AgentService) FindAll(ctx context.Context) ([]entity.Agent, error) {
-	return s.Repo.FindAll()
+	return s.Repo.FindAll(ctx)
 }
 
 // Update parcial
@@ -61,23 +63,90 @@ func (s *AgentService) UpdatePartial(ctx context.Context, id string, fields map[
 	return s.Repo.UpdatePartial(ctx, id, fields)
 }
 
+// AgentProfile is what an agent may have changed through UpdateProfile; nil
+// fields are kept. Email, password, role and 2FA have their own flows.
+type AgentProfile struct {
+	Name  *string
+	Phone *string
+}
+
+// UpdateProfile changes the profile of an agent. Only owners can edit owners.
+func (s *AgentService) UpdateProfile(ctx context.Context, id string, profile AgentProfile, executorRole entity.AgentRole) error {
+	agent, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if agent.Role == entity.RoleOwner && executorRole != entity.RoleOwner {
+		return errors.New("unauthorized: only an owner can edit an owner")
+	}
+
+	fields := map[string]interface{}{"updated_at": time.Now()}
+	if profile.Name != nil {
+		if *profile.Name == "" {
+			return errors.New("name is required")
+		}
+		fields["name"] = *profile.Name
+	}
+	if profile.Phone != nil {
+		fields["phone"] = *profile.Phone
+	}
+	return s.Repo.UpdatePartial(ctx, id, fields)
+}
+
 func (s *AgentService) GetByEmail(ctx context.Context, email string) (*entity.Agent, error) {
 	return s.Repo.FindByEmail(ctx, email)
 }
 
-func (s *AgentService) Delete(ctx context.Context, id string) error {
-	return s.Repo.Delete(id)
+// Delete removes an agent. Only owners can remove owners, and nobody can
+// remove themselves.
+func (s *AgentService) Delete(ctx context.Context, id string, executorID string, executorRole entity.AgentRole) error {
+	if id == executorID {
+		return errors.New("unauthorized: agents cannot delete themselves")
+	}
+	agent, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if agent.Role == entity.RoleOwner && executorRole != entity.RoleOwner {
+		return errors.New("unauthorized: only an owner can delete an owner")
+	}
+	return s.Repo.Delete(ctx, id)
+}
+
+// ChangeRole sets the role of an agent. Only owners can grant or take away
+// the owner role, and nobody can change their own role.
+func (s *AgentService) ChangeRole(ctx context.Context, id string, role entity.AgentRole, executorID string, executorRole entity.AgentRole) error {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role: %q", role)
+	}
+	if id == executorID {
+		return errors.New("unauthorized: agents cannot change their own role")
+	}
+
+	agent, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if (agent.Role == entity.RoleOwner || role == entity.RoleOwner) && executorRole != entity.RoleOwner {
+		return errors.New("unauthorized: only an owner can grant or revoke the owner role")
+	}
+
+	return s.Repo.UpdatePartial(ctx, id, map[string]interface{}{
+		"role":       role,
+		"updated_at": time.Now(),
+	})
 }
 
 // AssociateProperties filtra las propiedades que existen en la base de datos
-func (s *AgentService) AssociateProperties(agent *entity.Agent) error {
+func (s *AgentService) AssociateProperties(ctx context.Context, agent *entity.Agent) error {
 	if len(agent.Properties) == 0 {
 		return nil
 	}
 
 	var existing []*entity.Property
 	for _, p := range agent.Properties {
-		prop, err := s.PropertyRepository.FindByID(p.ID)
+		prop, err := s.PropertyRepository.FindByID(ctx, p.ID)
 		if err != nil {
 			return err // errores de DB reales se siguen propagando
 		}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+)
+
+// apiKeyLastUsedResolution limits how often a busy key writes its last-used timestamp
+const apiKeyLastUsedResolution = time.Minute
+
+type APIKeyService struct {
+	Repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{Repo: repo}
+}
+
+// Create issues a new key for the caller's company. The plain key is only
+// returned here; afterwards only its prefix can be shown.
+func (s *APIKeyService) Create(ctx context.Context, companyID, name string, scopes []entity.APIKeyScope, createdByID string) (*entity.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, "", fmt.Errorf("invalid scope: %q", scope)
+		}
+	}
+
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plain := entity.APIKeyPrefix + token
+
+	key := &entity.APIKey{
+		ID:        uuid.New().String(),
+		CompanyID: companyID,
+		Name:      name,
+		Prefix:    plain[:len(entity.APIKeyPrefix)+8],
+		KeyHash:   security.HashOpaqueToken(plain),
+		Scopes:    scopes,
+	}
+	if createdByID != "" {
+		key.CreatedByID = &createdByID
+	}
+
+	if err := s.Repo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// List returns every key of the caller's company, revoked ones included
+func (s *APIKeyService) List(ctx context.Context) ([]entity.APIKey, error) {
+	return s.Repo.FindAll(ctx)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	if err := s.Repo.Revoke(ctx, id); err != nil {
+		return errors.New("api key not found or already revoked")
+	}
+	return nil
+}
+
+// Authenticate returns the active key matching plain, or nil if there is none
+func (s *APIKeyService) Authenticate(ctx context.Context, plain string) (*entity.APIKey, error) {
+	if !strings.HasPrefix(plain, entity.APIKeyPrefix) {
+		return nil, nil
+	}
+
+	key, err := s.Repo.FindByHash(ctx, security.HashOpaqueToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || !key.IsActive() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution {
+		// A failed write must not block the request
+		if err := s.Repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("Failed to update last use of API key %s: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+	return key, nil
+}
@@ -14,110 +14,220 @@ import (
 
 type CompanyEmailConfigService struct {
 	repo          repository.CompanyEmailConfigRepository
+	agentRepo     repository.AgentRepository
+	runRepo       repository.EmailSyncRunRepository
 	encryptionKey string
+	changes       chan EmailConfigChange
 }
 
-func NewCompanyEmailConfigService(repo repository.CompanyEmailConfigRepository, encryptionKey string) *CompanyEmailConfigService {
+// EmailConfigChange tells that an inbox was created, changed or deleted, so
+// its worker has to be (re)started or stopped
+type EmailConfigChange struct {
+	ConfigID  string
+	CompanyID string
+}
+
+// emailConfigChangeBuffer is how many changes may wait for the worker manager.
+// Changes beyond it are dropped; the manager still picks them up on its next
+// periodic reload.
+const emailConfigChangeBuffer = 64
+
+func NewCompanyEmailConfigService(repo repository.CompanyEmailConfigRepository, agentRepo repository.AgentRepository, runRepo repository.EmailSyncRunRepository, encryptionKey string) *CompanyEmailConfigService {
 	return &CompanyEmailConfigService{
 		repo:          repo,
+		agentRepo:     agentRepo,
+		runRepo:       runRepo,
 		encryptionKey: encryptionKey,
+		changes:       make(chan EmailConfigChange, emailConfigChangeBuffer),
 	}
 }
 
+// Changes returns the inbox changes made through this service
+func (s *CompanyEmailConfigService) Changes() <-chan EmailConfigChange {
+	return s.changes
+}
+
+// notifyChange publishes a change of config without ever blocking the caller
+func (s *CompanyEmailConfigService) notifyChange(config *entity.CompanyEmailConfig) {
+	select {
+	case s.changes <- EmailConfigChange{ConfigID: config.ID, CompanyID: config.CompanyID}:
+	default:
+		log.Printf("[CompanyEmailConfigService] Change queue full, config %s will be picked up on the next reload", config.ID)
+	}
+}
+
+// EmailConfigInput holds the editable settings of an inbox. The IMAP fields
+// only apply to inboxes using password auth.
+type EmailConfigInput struct {
+	Label            string
+	DefaultAgentID   *string // agent that new leads from this inbox are assigned to
+	IMAPHost         string
+	IMAPPort         int
+	IMAPUsername     string
+	IMAPPassword     string // empty on update keeps the current password
+	InboxFolder      string
+	PollIntervalSecs int
+	SyncMode         string
+}
+
 // GetEncryptionKey returns the encryption key (needed by worker manager)
 func (s *CompanyEmailConfigService) GetEncryptionKey() string {
 	return s.encryptionKey
 }
 
-func (s *CompanyEmailConfigService) CreateConfig(ctx context.Context, companyID, imapHost, imapUsername, imapPassword string, imapPort, pollIntervalSecs int, inboxFolder string) (*entity.CompanyEmailConfig, error) {
-	// Check if config already exists
-	existing, err := s.repo.FindByCompanyID(ctx, companyID)
-	if err != nil {
-		return nil, fmt.Errorf("error checking existing config: %w", err)
+// CreateConfig connects a new IMAP inbox. A company may have several.
+func (s *CompanyEmailConfigService) CreateConfig(ctx context.Context, companyID string, input EmailConfigInput) (*entity.CompanyEmailConfig, error) {
+	syncMode := input.SyncMode
+	if syncMode == "" {
+		syncMode = entity.EmailSyncModePoll
+	}
+	if !isValidSyncMode(syncMode) {
+		return nil, fmt.Errorf("invalid sync mode: %s", syncMode)
 	}
-	if existing != nil {
-		return nil, fmt.Errorf("email configuration already exists for this company")
+	if err := s.checkDefaultAgent(ctx, companyID, input.DefaultAgentID); err != nil {
+		return nil, err
 	}
 
 	// Encrypt password
-	encryptedPassword, err := security.Encrypt(imapPassword, s.encryptionKey)
+	encryptedPassword, err := security.Encrypt(input.IMAPPassword, s.encryptionKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt password: %w", err)
 	}
 
 	// Set defaults
-	if inboxFolder == "" {
-		inboxFolder = "INBOX"
-	}
-	if pollIntervalSecs <= 0 {
-		pollIntervalSecs = 300 // 5 minutes default
-	}
-	if imapPort <= 0 {
-		imapPort = 993
-	}
-
 	config := &entity.CompanyEmailConfig{
 		CompanyID:        companyID,
-		IMAPHost:         imapHost,
-		IMAPPort:         imapPort,
-		IMAPUsername:     imapUsername,
+		Label:            input.Label,
+		DefaultAgentID:   input.DefaultAgentID,
+		AuthMethod:       entity.EmailAuthPassword,
+		IMAPHost:         input.IMAPHost,
+		IMAPPort:         input.IMAPPort,
+		IMAPUsername:     input.IMAPUsername,
 		IMAPPassword:     encryptedPassword,
-		InboxFolder:      inboxFolder,
-		PollIntervalSecs: pollIntervalSecs,
+		InboxFolder:      input.InboxFolder,
+		PollIntervalSecs: input.PollIntervalSecs,
+		SyncMode:         syncMode,
 		IsEnabled:        true,
 	}
+	if config.Label == "" {
+		config.Label = input.IMAPUsername
+	}
+	if config.InboxFolder == "" {
+		config.InboxFolder = "INBOX"
+	}
+	if config.PollIntervalSecs <= 0 {
+		config.PollIntervalSecs = 300 // 5 minutes default
+	}
+	if config.IMAPPort <= 0 {
+		config.IMAPPort = 993
+	}
 
-	if err := s.repo.Create(config); err != nil {
+	if err := s.repo.Create(ctx, config); err != nil {
 		return nil, fmt.Errorf("failed to create config: %w", err)
 	}
+	s.notifyChange(config)
 
-	log.Printf("[CompanyEmailConfigService] Created email config for company %s", companyID)
+	log.Printf("[CompanyEmailConfigService] Created email config %s for company %s", config.ID, companyID)
 	return config, nil
 }
 
-func (s *CompanyEmailConfigService) UpdateConfig(ctx context.Context, id, imapHost, imapUsername, imapPassword string, imapPort, pollIntervalSecs int, inboxFolder string) (*entity.CompanyEmailConfig, error) {
-	config, err := s.repo.FindByID(id)
+// UpdateConfig changes the settings of inbox id
+func (s *CompanyEmailConfigService) UpdateConfig(ctx context.Context, id string, input EmailConfigInput) (*entity.CompanyEmailConfig, error) {
+	if input.SyncMode != "" && !isValidSyncMode(input.SyncMode) {
+		return nil, fmt.Errorf("invalid sync mode: %s", input.SyncMode)
+	}
+
+	config, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error finding config: %w", err)
 	}
 	if config == nil {
 		return nil, fmt.Errorf("config not found")
 	}
+	if err := s.checkDefaultAgent(ctx, config.CompanyID, input.DefaultAgentID); err != nil {
+		return nil, err
+	}
+
+	// New settings deserve a fresh start
+	clearSyncFailures(config)
 
 	// Update fields
-	config.IMAPHost = imapHost
-	config.IMAPPort = imapPort
-	config.IMAPUsername = imapUsername
-	config.InboxFolder = inboxFolder
-	config.PollIntervalSecs = pollIntervalSecs
-
-	// Only encrypt and update password if a new one is provided
-	if imapPassword != "" {
-		encryptedPassword, err := security.Encrypt(imapPassword, s.encryptionKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt password: %w", err)
+	if input.Label != "" {
+		config.Label = input.Label
+	}
+	config.DefaultAgentID = input.DefaultAgentID
+	if input.PollIntervalSecs > 0 {
+		config.PollIntervalSecs = input.PollIntervalSecs
+	}
+	if input.SyncMode != "" {
+		config.SyncMode = input.SyncMode
+	}
+
+	if config.AuthMethod == entity.EmailAuthPassword {
+		config.IMAPHost = input.IMAPHost
+		config.IMAPPort = input.IMAPPort
+		config.IMAPUsername = input.IMAPUsername
+		config.InboxFolder = input.InboxFolder
+
+		// Only encrypt and update password if a new one is provided
+		if input.IMAPPassword != "" {
+			encryptedPassword, err := security.Encrypt(input.IMAPPassword, s.encryptionKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt password: %w", err)
+			}
+			config.IMAPPassword = encryptedPassword
 		}
-		config.IMAPPassword = encryptedPassword
 	}
 
-	if err := s.repo.Update(config); err != nil {
+	if err := s.repo.Update(ctx, config); err != nil {
 		return nil, fmt.Errorf("failed to update config: %w", err)
 	}
+	s.notifyChange(config)
 
 	log.Printf("[CompanyEmailConfigService] Updated email config %s for company %s", id, config.CompanyID)
 	return config, nil
 }
 
-func (s *CompanyEmailConfigService) GetConfigByCompanyID(ctx context.Context, companyID string) (*entity.CompanyEmailConfig, error) {
+// checkDefaultAgent makes sure agentID, when set, is an agent of companyID
+func (s *CompanyEmailConfigService) checkDefaultAgent(ctx context.Context, companyID string, agentID *string) error {
+	if agentID == nil || *agentID == "" {
+		return nil
+	}
+	agent, err := s.agentRepo.FindByID(ctx, *agentID)
+	if err != nil || agent == nil || agent.CompanyID != companyID {
+		return fmt.Errorf("invalid default agent: %s", *agentID)
+	}
+	return nil
+}
+
+// isValidSyncMode reports whether mode is one of the supported inbox sync modes
+func isValidSyncMode(mode string) bool {
+	return mode == entity.EmailSyncModePoll || mode == entity.EmailSyncModeIdle
+}
+
+// ListConfigs returns every inbox connected by companyID
+func (s *CompanyEmailConfigService) ListConfigs(ctx context.Context, companyID string) ([]*entity.CompanyEmailConfig, error) {
 	return s.repo.FindByCompanyID(ctx, companyID)
 }
 
+// GetConfig returns inbox id of companyID
+func (s *CompanyEmailConfigService) GetConfig(ctx context.Context, companyID, id string) (*entity.CompanyEmailConfig, error) {
+	config, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error finding config: %w", err)
+	}
+	if config == nil || config.CompanyID != companyID {
+		return nil, fmt.Errorf("config not found")
+	}
+	return config, nil
+}
+
 func (s *CompanyEmailConfigService) GetAllEnabledConfigs(ctx context.Context) ([]*entity.CompanyEmailConfig, error) {
 	return s.repo.FindAllEnabled(ctx)
 }
 
 func (s *CompanyEmailConfigService) DeleteConfig(ctx context.Context, id string) error {
-	config, err := s.repo.FindByID(id)
+	config, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("error finding config: %w", err)
 	}
@@ -125,16 +235,17 @@ func (s *CompanyEmailConfigService) DeleteConfig(ctx context.Context, id string)
 		return fmt.Errorf("config not found")
 	}
 
-	if err := s.repo.Delete(id); err != nil {
+	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete config: %w", err)
 	}
+	s.notifyChange(config)
 
 	log.Printf("[CompanyEmailConfigService] Deleted email config %s for company %s", id, config.CompanyID)
 	return nil
 }
 
 func (s *CompanyEmailConfigService) ToggleEnabled(ctx context.Context, id string, enabled bool) error {
-	config, err := s.repo.FindByID(id)
+	config, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("error finding config: %w", err)
 	}
@@ -143,9 +254,13 @@ func (s *CompanyEmailConfigService) ToggleEnabled(ctx context.Context, id string
 	}
 
 	config.IsEnabled = enabled
-	if err := s.repo.Update(config); err != nil {
+	if enabled {
+		clearSyncFailures(config)
+	}
+	if err := s.repo.Update(ctx, config); err != nil {
 		return fmt.Errorf("failed to toggle config: %w", err)
 	}
+	s.notifyChange(config)
 
 	status := "disabled"
 	if enabled {
@@ -189,6 +304,99 @@ func (s *CompanyEmailConfigService) UpdateLastSync(ctx context.Context, id strin
 	return s.repo.UpdateLastSync(ctx, id, time.Now())
 }
 
+const (
+	// EmailAuthFailureLimit is how many polls in a row may be rejected for bad
+	// credentials before the inbox is disabled
+	EmailAuthFailureLimit = 5
+	// emailSyncRunHistory is how many recent runs the status of an inbox shows
+	emailSyncRunHistory = 20
+	// emailSyncRunRetention is how long sync runs are kept
+	emailSyncRunRetention = 7 * 24 * time.Hour
+)
+
+// EmailInboxStatus is the ingestion health of one inbox
+type EmailInboxStatus struct {
+	Config     *entity.CompanyEmailConfig
+	RecentRuns []entity.EmailSyncRun // newest first
+}
+
+// RecordSyncRun stores the outcome of a poll of inbox run.ConfigID and updates
+// its health. After EmailAuthFailureLimit auth failures in a row the inbox is
+// disabled until an admin fixes its credentials.
+func (s *CompanyEmailConfigService) RecordSyncRun(ctx context.Context, run *entity.EmailSyncRun) error {
+	config, err := s.repo.FindByID(ctx, run.ConfigID)
+	if err != nil {
+		return fmt.Errorf("error finding config: %w", err)
+	}
+	if config == nil {
+		return fmt.Errorf("config not found")
+	}
+
+	if err := s.runRepo.Create(ctx, run); err != nil {
+		return fmt.Errorf("failed to save sync run: %w", err)
+	}
+	if err := s.runRepo.DeleteBefore(ctx, run.ConfigID, time.Now().Add(-emailSyncRunRetention)); err != nil {
+		log.Printf("[CompanyEmailConfigService] Error pruning sync runs of config %s: %v", run.ConfigID, err)
+	}
+
+	if run.Succeeded() {
+		if err := s.repo.UpdateLastSync(ctx, config.ID, run.FinishedAt); err != nil {
+			return fmt.Errorf("failed to update last sync: %w", err)
+		}
+		if config.ConsecutiveFailures == 0 && config.LastError == "" {
+			return nil
+		}
+		return s.repo.UpdateSyncStatus(ctx, config.ID, 0, "")
+	}
+
+	failures := config.ConsecutiveFailures + 1
+	if err := s.repo.UpdateSyncStatus(ctx, config.ID, failures, run.Error); err != nil {
+		return fmt.Errorf("failed to update sync status: %w", err)
+	}
+
+	if !run.AuthFailure {
+		return nil
+	}
+	runs, err := s.runRepo.FindRecentByConfigID(ctx, config.ID, EmailAuthFailureLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load sync runs: %w", err)
+	}
+	if len(runs) < EmailAuthFailureLimit {
+		return nil
+	}
+	for _, recent := range runs {
+		if !recent.AuthFailure {
+			return nil
+		}
+	}
+
+	reason := fmt.Sprintf("disabled after %d consecutive authentication failures: %s", EmailAuthFailureLimit, run.Error)
+	if err := s.repo.Disable(ctx, config.ID, reason); err != nil {
+		return fmt.Errorf("failed to disable config: %w", err)
+	}
+	log.Printf("[CompanyEmailConfigService] Disabled email config %s of company %s: %s", config.ID, config.CompanyID, reason)
+	s.notifyChange(config)
+	return nil
+}
+
+// GetStatus returns the ingestion health of every inbox of companyID
+func (s *CompanyEmailConfigService) GetStatus(ctx context.Context, companyID string) ([]EmailInboxStatus, error) {
+	configs, err := s.repo.FindByCompanyID(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding configs: %w", err)
+	}
+
+	statuses := make([]EmailInboxStatus, 0, len(configs))
+	for _, config := range configs {
+		runs, err := s.runRepo.FindRecentByConfigID(ctx, config.ID, emailSyncRunHistory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sync runs: %w", err)
+		}
+		statuses = append(statuses, EmailInboxStatus{Config: config, RecentRuns: runs})
+	}
+	return statuses, nil
+}
+
 func (s *CompanyEmailConfigService) DecryptPassword(encryptedPassword string) (string, error) {
 	return security.Decrypt(encryptedPassword, s.encryptionKey)
 }
@@ -202,15 +410,10 @@ func (s *CompanyEmailConfigService) CreateOAuth2Config(
 	encryptedRefreshToken string,
 	tokenExpiry time.Time,
 ) (*entity.CompanyEmailConfig, error) {
-	// Check if config already exists
-	existing, err := s.repo.FindByCompanyID(ctx, companyID)
-	if err == nil && existing != nil {
-		return nil, fmt.Errorf("email configuration already exists for this company")
-	}
-
 	config := &entity.CompanyEmailConfig{
 		CompanyID:        companyID,
-		AuthMethod:       "oauth2",
+		Label:            oauth2Label(provider),
+		AuthMethod:       oauth2AuthMethod(provider),
 		OAuth2Provider:   provider,
 		AccessToken:      encryptedAccessToken,
 		RefreshToken:     encryptedRefreshToken,
@@ -220,10 +423,11 @@ func (s *CompanyEmailConfigService) CreateOAuth2Config(
 		IsEnabled:        true,
 	}
 
-	err = s.repo.Create(config)
+	err := s.repo.Create(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OAuth2 config: %w", err)
 	}
+	s.notifyChange(config)
 
 	return config, nil
 }
@@ -237,13 +441,16 @@ func (s *CompanyEmailConfigService) UpdateToOAuth2(
 	encryptedRefreshToken string,
 	tokenExpiry time.Time,
 ) error {
-	config, err := s.repo.FindByID(id)
+	config, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("configuration not found: %w", err)
 	}
+	if config == nil {
+		return fmt.Errorf("configuration not found")
+	}
 
 	// Update to OAuth2
-	config.AuthMethod = "oauth2"
+	config.AuthMethod = oauth2AuthMethod(provider)
 	config.OAuth2Provider = provider
 	config.AccessToken = encryptedAccessToken
 	config.RefreshToken = encryptedRefreshToken
@@ -252,15 +459,21 @@ func (s *CompanyEmailConfigService) UpdateToOAuth2(
 	// Clear IMAP password (no longer needed)
 	config.IMAPPassword = ""
 
-	err = s.repo.Update(config)
+	// A reconnected inbox starts over, and is read again if it was disabled
+	clearSyncFailures(config)
+	config.IsEnabled = true
+
+	err = s.repo.Update(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to update to OAuth2: %w", err)
 	}
+	s.notifyChange(config)
 
 	return nil
 }
 
-// RefreshOAuth2Token refreshes an expired OAuth2 token
+// RefreshOAuth2Token stores the tokens refreshed by the worker of inbox id.
+// It leaves UpdatedAt alone so the worker is not restarted for it.
 func (s *CompanyEmailConfigService) RefreshOAuth2Token(
 	ctx context.Context,
 	id string,
@@ -268,20 +481,19 @@ func (s *CompanyEmailConfigService) RefreshOAuth2Token(
 	newEncryptedRefreshToken string,
 	newTokenExpiry time.Time,
 ) error {
-	config, err := s.repo.FindByID(id)
+	config, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("configuration not found: %w", err)
 	}
+	if config == nil {
+		return fmt.Errorf("configuration not found")
+	}
 
-	if config.AuthMethod != "oauth2" {
+	if !usesOAuth2(config) {
 		return fmt.Errorf("configuration is not using OAuth2")
 	}
 
-	config.AccessToken = newEncryptedAccessToken
-	config.RefreshToken = newEncryptedRefreshToken
-	config.TokenExpiry = &newTokenExpiry
-
-	err = s.repo.Update(config)
+	err = s.repo.UpdateTokens(ctx, id, newEncryptedAccessToken, newEncryptedRefreshToken, newTokenExpiry)
 	if err != nil {
 		return fmt.Errorf("failed to refresh token: %w", err)
 	}
@@ -291,7 +503,7 @@ func (s *CompanyEmailConfigService) RefreshOAuth2Token(
 
 // DecryptOAuth2Tokens decrypts OAuth2 tokens for use by workers
 func (s *CompanyEmailConfigService) DecryptOAuth2Tokens(config *entity.CompanyEmailConfig) (accessToken string, refreshToken string, err error) {
-	if config.AuthMethod != "oauth2" {
+	if !usesOAuth2(config) {
 		return "", "", fmt.Errorf("configuration is not using OAuth2")
 	}
 
@@ -307,3 +519,32 @@ func (s *CompanyEmailConfigService) DecryptOAuth2Tokens(config *entity.CompanyEm
 
 	return accessToken, refreshToken, nil
 }
+
+// oauth2AuthMethod returns the auth method of an inbox connected through provider
+func oauth2AuthMethod(provider string) string {
+	if provider == "microsoft" {
+		return entity.EmailAuthMicrosoft
+	}
+	return entity.EmailAuthGoogle
+}
+
+// oauth2Label is the label of a new inbox connected through provider
+func oauth2Label(provider string) string {
+	if provider == "microsoft" {
+		return "Outlook"
+	}
+	return "Gmail"
+}
+
+// usesOAuth2 reports whether config holds OAuth2 tokens rather than a password
+func usesOAuth2(config *entity.CompanyEmailConfig) bool {
+	return config.AuthMethod == entity.EmailAuthGoogle || config.AuthMethod == entity.EmailAuthMicrosoft
+}
+
+// clearSyncFailures resets the ingestion health of config, once its settings
+// or credentials changed
+func clearSyncFailures(config *entity.CompanyEmailConfig) {
+	config.ConsecutiveFailures = 0
+	config.LastError = ""
+	config.DisabledReason = ""
+}
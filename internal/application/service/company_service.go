@@ -32,7 +32,7 @@ func (s *CompanyService) Create(ctx context.Context, c *entity.Company) (*entity
 	}
 	c.ID = uuid.New().String()
 
-	if err := s.Repo.Create(c); err != nil {
+	if err := s.Repo.Create(ctx, c); err != nil {
 		return nil, false, err
 	}
 
@@ -40,11 +40,11 @@ func (s *CompanyService) Create(ctx context.Context, c *entity.Company) (*entity
 }
 
 func (s *CompanyService) FindByID(ctx context.Context, id string) (*entity.Company, error) {
-	return s.Repo.FindByID(id)
+	return s.Repo.FindByID(ctx, id)
 }
 
 func (s *CompanyService) FindAll(ctx context.Context) ([]entity.Company, error) {
-	return s.Repo.FindAll()
+	return s.Repo.FindAll(ctx)
 }
 
 // Update parcial
@@ -56,5 +56,5 @@ func (s *CompanyService) UpdatePartial(ctx context.Context, id string, fields ma
 }
 
 func (s *CompanyService) Delete(ctx context.Context, id string) error {
-	return s.Repo.Delete(id)
+	return s.Repo.Delete(ctx, id)
 }
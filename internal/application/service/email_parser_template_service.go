@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/adapters/email/parser"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+type EmailParserTemplateService struct {
+	Repo repository.EmailParserTemplateRepository
+}
+
+func NewEmailParserTemplateService(repo repository.EmailParserTemplateRepository) *EmailParserTemplateService {
+	return &EmailParserTemplateService{Repo: repo}
+}
+
+// DryRunResult is what a template extracts from a sample email
+type DryRunResult struct {
+	Matches bool               `json:"matches"` // Whether the sender and subject match the template
+	Lead    *entity.ParsedLead `json:"lead,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+func (s *EmailParserTemplateService) Create(ctx context.Context, template *entity.EmailParserTemplate) error {
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	template.ID = uuid.New().String()
+	return s.Repo.Create(ctx, template)
+}
+
+func (s *EmailParserTemplateService) Update(ctx context.Context, template *entity.EmailParserTemplate) error {
+	existing, err := s.Repo.FindByID(ctx, template.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return errors.New("template not found")
+	}
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	template.CreatedAt = existing.CreatedAt
+	return s.Repo.Update(ctx, template)
+}
+
+func (s *EmailParserTemplateService) GetByID(ctx context.Context, id string) (*entity.EmailParserTemplate, error) {
+	template, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, errors.New("template not found")
+	}
+	return template, nil
+}
+
+func (s *EmailParserTemplateService) List(ctx context.Context) ([]entity.EmailParserTemplate, error) {
+	return s.Repo.FindAll(ctx)
+}
+
+func (s *EmailParserTemplateService) Delete(ctx context.Context, id string) error {
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		return errors.New("template not found")
+	}
+	return nil
+}
+
+// DryRun runs a sample email through template without saving anything.
+// The email is parsed even if it does not match, to help fixing the rules.
+func (s *EmailParserTemplateService) DryRun(template *entity.EmailParserTemplate, subject, from, body string) (*DryRunResult, error) {
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+	p, _ := parser.NewTemplateParser(template)
+
+	result := &DryRunResult{Matches: p.CanParse(subject, from)}
+	lead, err := p.Extract(subject, body)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Lead = lead
+	return result, nil
+}
+
+// validateTemplate checks the template and compiles its rules
+func validateTemplate(template *entity.EmailParserTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return errors.New("name is required")
+	}
+	_, err := parser.NewTemplateParser(template)
+	return err
+}
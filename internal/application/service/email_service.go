@@ -2,51 +2,213 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/myestatia/myestatia-go/internal/adapters/email/parser"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/port"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 )
 
+var (
+	ErrUnsupportedEmailSource = errors.New("unsupported email source")
+	ErrEmailParse             = errors.New("failed to parse email")
+)
+
+// UnknownReferenceError is returned when a parsed lead points to a property
+// reference that does not exist, possibly because it has not been created yet
+type UnknownReferenceError struct {
+	Reference string
+}
+
+func (e *UnknownReferenceError) Error() string {
+	return fmt.Sprintf("property reference %s does not exist - email ignored", e.Reference)
+}
+
+// ClassifyEmailFailure maps an error of ProcessEmail to its dead-letter category,
+// with the missing property reference for unknown_reference failures
+func ClassifyEmailFailure(err error) (entity.EmailFailureCategory, string) {
+	var unknown *UnknownReferenceError
+	switch {
+	case errors.As(err, &unknown):
+		return entity.EmailFailureUnknownReference, unknown.Reference
+	case errors.Is(err, ErrUnsupportedEmailSource):
+		return entity.EmailFailureUnsupportedSource, ""
+	case errors.Is(err, ErrEmailParse):
+		return entity.EmailFailureParse, ""
+	default:
+		return entity.EmailFailureInternal, ""
+	}
+}
+
+// LeadOutcome tells what a processed email did to the leads of the company
+type LeadOutcome string
+
+const (
+	LeadOutcomeCreated LeadOutcome = "created"
+	LeadOutcomeUpdated LeadOutcome = "updated"
+)
+
 type EmailLeadService struct {
 	parserFactory *parser.ParserFactory
 	propertyRepo  repository.PropertyRepository
 	leadRepo      repository.LeadRepository
+	templateRepo  repository.EmailParserTemplateRepository
+	messageRepo   repository.MessageRepository
+	inboundEmails *InboundEmailService   // keeps the original emails; nil to skip
+	statuses      *LeadStatusService     // moves repeat contacts out of new; nil to skip
+	assignments   *LeadAssignmentService // assigns new leads by company rules; nil for DefaultAgentID
 	emailConfig   email.Config
+
+	onLeadActivity []func(ctx context.Context, leadID string)
 }
 
 func NewEmailLeadService(
 	propertyRepo repository.PropertyRepository,
 	leadRepo repository.LeadRepository,
+	templateRepo repository.EmailParserTemplateRepository,
+	messageRepo repository.MessageRepository,
+	inboundEmails *InboundEmailService,
+	statuses *LeadStatusService,
+	assignments *LeadAssignmentService,
 	emailConfig email.Config,
 ) *EmailLeadService {
 	return &EmailLeadService{
 		parserFactory: parser.NewParserFactory(),
 		propertyRepo:  propertyRepo,
 		leadRepo:      leadRepo,
+		templateRepo:  templateRepo,
+		messageRepo:   messageRepo,
+		inboundEmails: inboundEmails,
+		statuses:      statuses,
+		assignments:   assignments,
 		emailConfig:   emailConfig,
 	}
 }
 
+// EmailLeadConfig is the email.Config of the EmailLeadService for inbox config
+func EmailLeadConfig(config *entity.CompanyEmailConfig) email.Config {
+	leadConfig := email.Config{DefaultCompanyID: config.CompanyID, ConfigID: config.ID}
+	if config.DefaultAgentID != nil {
+		leadConfig.DefaultAgentID = *config.DefaultAgentID
+	}
+	return leadConfig
+}
+
+// OnLeadActivity registers fn to be called after an email creates or updates a lead
+func (s *EmailLeadService) OnLeadActivity(fn func(ctx context.Context, leadID string)) {
+	s.onLeadActivity = append(s.onLeadActivity, fn)
+}
+
 func (s *EmailLeadService) ProcessEmail(ctx context.Context, emailMsg email.ParsedEmail) error {
+	_, err := s.ProcessEmailOutcome(ctx, emailMsg)
+	return err
+}
+
+// ProcessEmailOutcome is ProcessEmail, also telling whether a lead was created
+// or updated. The original email is archived and linked to the lead, whose
+// conversation gets the message of the contact.
+func (s *EmailLeadService) ProcessEmailOutcome(ctx context.Context, emailMsg email.ParsedEmail) (LeadOutcome, error) {
+	inbound := s.archive(ctx, emailMsg)
+
+	outcome, parsedLead, lead, err := s.processEmail(ctx, emailMsg)
+	if err == nil {
+		s.addToConversation(ctx, lead, parsedLead, emailMsg, inbound)
+		for _, fn := range s.onLeadActivity {
+			fn(ctx, lead.ID)
+		}
+	}
+
+	if inbound != nil {
+		if recordErr := s.inboundEmails.RecordResult(ctx, inbound, parsedLead, lead, err); recordErr != nil {
+			log.Printf("[EmailLeadService] Error recording result of email %s: %v", emailMsg.MessageID, recordErr)
+		}
+	}
+	return outcome, err
+}
+
+// archive keeps the original of emailMsg. Failing to do so is logged, the
+// lead is what matters.
+func (s *EmailLeadService) archive(ctx context.Context, emailMsg email.ParsedEmail) *entity.InboundEmail {
+	if s.inboundEmails == nil {
+		return nil
+	}
+
+	inbound, err := s.inboundEmails.Archive(ctx, s.companyID(ctx), s.emailConfig.ConfigID, emailMsg)
+	if err != nil {
+		log.Printf("[EmailLeadService] Error archiving email %s: %v", emailMsg.MessageID, err)
+		return nil
+	}
+	return inbound
+}
+
+// companyID is the company the inbox being read belongs to
+func (s *EmailLeadService) companyID(ctx context.Context) string {
+	if id, ok := tenant.CompanyID(ctx); ok {
+		return id
+	}
+	return s.emailConfig.DefaultCompanyID
+}
+
+// addToConversation adds the message of the contact to the conversation of
+// lead, sent through the portal about the lead's property at the date of the
+// email. It points to inbound, its original email, when there is one.
+func (s *EmailLeadService) addToConversation(ctx context.Context, lead *entity.Lead, parsedLead *entity.ParsedLead, emailMsg email.ParsedEmail, inbound *entity.InboundEmail) {
+	if s.messageRepo == nil {
+		return
+	}
+
+	msg := &entity.Message{
+		ID:         uuid.New().String(),
+		LeadID:     lead.ID,
+		SenderType: entity.SenderLead,
+		Channel:    string(parsedLead.Source),
+		PropertyID: lead.PropertyID,
+		Content:    parsedLead.Message,
+		Timestamp:  emailMsg.Date,
+	}
+	if msg.Content == "" {
+		msg.Content = emailMsg.Subject
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	if inbound != nil {
+		msg.InboundEmailID = &inbound.ID
+	}
+
+	if err := s.messageRepo.Create(ctx, msg); err != nil {
+		log.Printf("[EmailLeadService] Error adding email %s to the conversation of lead %s: %v", emailMsg.MessageID, lead.ID, err)
+		return
+	}
+	if inbound != nil {
+		inbound.ConversationMessageID = &msg.ID
+	}
+}
+
+// processEmail turns emailMsg into a new or updated lead. parsedLead is set
+// once a parser read the email, even if it failed later.
+func (s *EmailLeadService) processEmail(ctx context.Context, emailMsg email.ParsedEmail) (LeadOutcome, *entity.ParsedLead, *entity.Lead, error) {
 	subject := emailMsg.Subject
 	from := emailMsg.From
 	body := emailMsg.Body
 
-	// Step 1: Find appropriate parser
-	emailParser, err := s.parserFactory.GetParser(subject, from)
+	// Step 1: Find appropriate parser (company templates first, then built-in portals)
+	emailParser, err := s.parserFactory.With(s.templateParsers(ctx)...).GetParser(subject, from)
 	if err != nil {
-		return fmt.Errorf("unsupported email source: %w", err)
+		return "", nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedEmailSource, err)
 	}
 
 	// Step 2: Parse email to extract lead data
 	parsedLead, err := emailParser.Parse(subject, body)
 	if err != nil {
-		return fmt.Errorf("failed to parse email: %w", err)
+		return "", nil, nil, fmt.Errorf("%w: %v", ErrEmailParse, err)
 	}
 
 	log.Printf("[EmailLeadService] Parsed lead from %s: email=%s, ref=%s",
@@ -55,88 +217,110 @@ func (s *EmailLeadService) ProcessEmail(ctx context.Context, emailMsg email.Pars
 	// Step 3: CRITICAL VALIDATION - Check if property exists
 	property, err := s.propertyRepo.FindByReference(ctx, parsedLead.PropertyReference)
 	if err != nil {
-		return fmt.Errorf("error checking property reference: %w", err)
+		return "", parsedLead, nil, fmt.Errorf("error checking property reference: %w", err)
 	}
 	if property == nil {
 		log.Printf("[EmailLeadService] SKIPPED: Property reference %s not found in database",
 			parsedLead.PropertyReference)
-		return fmt.Errorf("property reference %s does not exist - email ignored",
-			parsedLead.PropertyReference)
+		return "", parsedLead, nil, &UnknownReferenceError{Reference: parsedLead.PropertyReference}
 	}
 
 	log.Printf("[EmailLeadService] Property %s found (ID: %s)",
 		parsedLead.PropertyReference, property.ID)
 
-	// Step 4: Check if lead already exists (duplicate detection)
-	existingLead, err := s.leadRepo.FindByEmail(ctx, parsedLead.Email)
+	// Step 4: Check if lead already exists in the company (duplicate detection).
+	// Portals may relay the email through their own address, so the phone counts too.
+	existingLead, err := findExistingLead(ctx, s.leadRepo, s.companyID(ctx), parsedLead.Email, parsedLead.Phone)
 	if err != nil {
-		return fmt.Errorf("error checking existing lead: %w", err)
+		return "", parsedLead, nil, fmt.Errorf("error checking existing lead: %w", err)
 	}
 
 	if existingLead != nil {
 		// UPDATE existing lead
-		return s.updateExistingLead(ctx, existingLead, parsedLead, property)
+		if err := s.updateExistingLead(ctx, existingLead, parsedLead, property); err != nil {
+			return "", parsedLead, nil, err
+		}
+		return LeadOutcomeUpdated, parsedLead, existingLead, nil
 	}
 
 	// CREATE new lead
-	return s.createNewLead(ctx, parsedLead, property)
+	lead, err := s.createNewLead(ctx, parsedLead, property)
+	if err != nil {
+		return "", parsedLead, nil, err
+	}
+	return LeadOutcomeCreated, parsedLead, lead, nil
 }
 
-func (s *EmailLeadService) createNewLead(ctx context.Context, parsedLead *entity.ParsedLead, property *entity.Property) error {
-	now := time.Now()
+// templateParsers returns the enabled parser templates of the company in ctx.
+// A template that no longer compiles is skipped rather than blocking the inbox.
+func (s *EmailLeadService) templateParsers(ctx context.Context) []port.EmailParser {
+	if s.templateRepo == nil {
+		return nil
+	}
+
+	templates, err := s.templateRepo.FindEnabled(ctx)
+	if err != nil {
+		log.Printf("[EmailLeadService] Error loading parser templates: %v", err)
+		return nil
+	}
+
+	parsers := make([]port.EmailParser, 0, len(templates))
+	for i := range templates {
+		p, err := parser.NewTemplateParser(&templates[i])
+		if err != nil {
+			log.Printf("[EmailLeadService] Skipping parser template %s: %v", templates[i].ID, err)
+			continue
+		}
+		parsers = append(parsers, p)
+	}
+	return parsers
+}
 
+// createNewLead creates the lead of a first contact. Its message goes to the
+// conversation, which also sets LastInteraction.
+func (s *EmailLeadService) createNewLead(ctx context.Context, parsedLead *entity.ParsedLead, property *entity.Property) (*entity.Lead, error) {
 	lead := &entity.Lead{
-		Name:            parsedLead.Name,
-		Email:           parsedLead.Email,
-		Phone:           parsedLead.Phone,
-		Status:          entity.LeadStatusNew,
-		PropertyID:      &property.ID,
-		CompanyID:       s.emailConfig.DefaultCompanyID,
-		Source:          string(parsedLead.Source),
-		Channel:         "email",
-		Notes:           fmt.Sprintf("Mensaje inicial: %s", parsedLead.Message),
-		LastInteraction: &now,
+		Name:       parsedLead.Name,
+		Email:      entity.NormalizeEmail(parsedLead.Email),
+		Phone:      parsedLead.Phone,
+		Status:     entity.LeadStatusNew,
+		PropertyID: &property.ID,
+		CompanyID:  s.companyID(ctx),
+		Source:     string(parsedLead.Source),
+		Channel:    "email",
+	}
+	if s.assignments == nil && s.emailConfig.DefaultAgentID != "" {
+		agentID := s.emailConfig.DefaultAgentID
+		lead.AssignedAgentID = &agentID
+	}
+
+	if err := s.leadRepo.Create(ctx, lead); err != nil {
+		return nil, fmt.Errorf("failed to create lead: %w", err)
 	}
 
-	if err := s.leadRepo.Create(lead); err != nil {
-		return fmt.Errorf("failed to create lead: %w", err)
+	// The rules of the company pick the agent, DefaultAgentID when none does.
+	// A lead left unassigned is still a lead.
+	if s.assignments != nil {
+		lead.Property = property
+		if err := s.assignments.AutoAssign(ctx, lead, s.emailConfig.DefaultAgentID); err != nil {
+			log.Printf("[EmailLeadService] Error assigning lead %s: %v", lead.ID, err)
+		}
 	}
 
 	log.Printf("[EmailLeadService] ✓ Created new lead: ID=%s, Email=%s, Property=%s",
 		lead.ID, lead.Email, property.Reference)
 
-	return nil
+	return lead, nil
 }
 
-// updateExistingLead updates an existing lead with new contact information
+// updateExistingLead updates an existing lead with new contact information.
+// The message itself goes to the conversation, which keeps the history of the
+// properties asked about and sets LastInteraction.
 func (s *EmailLeadService) updateExistingLead(ctx context.Context, existingLead *entity.Lead, parsedLead *entity.ParsedLead, property *entity.Property) error {
-	now := time.Now()
-
 	log.Printf("[EmailLeadService] Lead already exists (ID: %s), updating...", existingLead.ID)
 
-	// Track property history if it changed
-	propertyChanged := false
-	if existingLead.PropertyID == nil || *existingLead.PropertyID != property.ID {
-		propertyChanged = true
-		oldPropertyRef := "none"
-		if existingLead.PropertyID != nil {
-			oldPropertyRef = *existingLead.PropertyID
-		}
-
-		historyNote := fmt.Sprintf("\n[%s] Nuevo contacto sobre propiedad %s (anterior: %s). Mensaje: %s",
-			now.Format("2006-01-02 15:04"),
-			property.Reference,
-			oldPropertyRef,
-			parsedLead.Message)
-		existingLead.Notes += historyNote
-		existingLead.PropertyID = &property.ID
-	} else {
-		// Same property, just update with new message
-		messageNote := fmt.Sprintf("\n[%s] Nuevo contacto. Mensaje: %s",
-			now.Format("2006-01-02 15:04"),
-			parsedLead.Message)
-		existingLead.Notes += messageNote
-	}
+	propertyChanged := existingLead.PropertyID == nil || *existingLead.PropertyID != property.ID
+	existingLead.PropertyID = &property.ID
 
 	// Update contact information (in case it changed)
 	if parsedLead.Name != "" {
@@ -146,26 +330,24 @@ func (s *EmailLeadService) updateExistingLead(ctx context.Context, existingLead
 		existingLead.Phone = parsedLead.Phone
 	}
 
-	// Update last interaction timestamp
-	existingLead.LastInteraction = &now
-
-	// Update status if it was closed, set it back to contacted
-	if existingLead.Status == entity.LeadStatusClosed {
-		existingLead.Status = entity.LeadStatusContacted
-		log.Printf("[EmailLeadService] Lead status changed from 'closed' to 'contacted'")
-	} else if existingLead.Status == entity.LeadStatusNew {
-		existingLead.Status = entity.LeadStatusContacted
-	}
-
 	// Update source if it changed
 	if string(parsedLead.Source) != existingLead.Source {
 		existingLead.Source = string(parsedLead.Source)
 	}
 
-	if err := s.leadRepo.Update(existingLead); err != nil {
+	if err := s.leadRepo.Update(ctx, existingLead); err != nil {
 		return fmt.Errorf("failed to update lead: %w", err)
 	}
 
+	// A lead that writes again is contacted. Leads closed, dismissed or
+	// rejected stay so; agents see the new message in the conversation.
+	if s.statuses != nil && existingLead.Status == entity.LeadStatusNew {
+		note := "New contact through " + string(parsedLead.Source)
+		if _, err := s.statuses.TransitionLead(ctx, existingLead, entity.LeadStatusContacted, "", note, ""); err != nil {
+			log.Printf("[EmailLeadService] Error moving lead %s to contacted: %v", existingLead.ID, err)
+		}
+	}
+
 	if propertyChanged {
 		log.Printf("[EmailLeadService] ✓ Updated lead with property change: ID=%s, New Property=%s",
 			existingLead.ID, property.Reference)
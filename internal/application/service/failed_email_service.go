@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// EmailProcessor turns an inbound email into a lead; implemented by EmailLeadService
+type EmailProcessor interface {
+	ProcessEmail(ctx context.Context, emailMsg email.ParsedEmail) error
+}
+
+// EmailProcessors returns the EmailProcessor of inbox configID of companyID,
+// set up like the one that read it (default agent, ...). configID is empty for
+// emails recorded before the inbox was.
+type EmailProcessors func(ctx context.Context, companyID, configID string) (EmailProcessor, error)
+
+// FailedEmailService keeps the dead-letter queue of inbound emails that
+// could not be turned into leads, and reprocesses them
+type FailedEmailService struct {
+	Repo       repository.FailedEmailRepository
+	Processors EmailProcessors
+}
+
+func NewFailedEmailService(repo repository.FailedEmailRepository, processors EmailProcessors) *FailedEmailService {
+	return &FailedEmailService{Repo: repo, Processors: processors}
+}
+
+// Record stores an email read from inbox configID that failed with cause. An
+// email that fails again keeps a single record with its attempts counted.
+func (s *FailedEmailService) Record(ctx context.Context, companyID, configID string, msg email.ParsedEmail, cause error) error {
+	existing, err := s.Repo.FindByMessageID(ctx, companyID, msg.MessageID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		existing.Attempts++
+		if configID != "" {
+			existing.ConfigID = &configID
+		}
+		setFailure(existing, cause)
+		return s.Repo.Update(ctx, existing)
+	}
+
+	failed := &entity.FailedEmail{
+		CompanyID:  companyID,
+		MessageID:  msg.MessageID,
+		From:       msg.From,
+		Subject:    msg.Subject,
+		Body:       msg.Body,
+		ReceivedAt: msg.Date,
+		Attempts:   1,
+	}
+	if configID != "" {
+		failed.ConfigID = &configID
+	}
+	setFailure(failed, cause)
+	return s.Repo.Create(ctx, failed)
+}
+
+func setFailure(failed *entity.FailedEmail, cause error) {
+	failed.Category, failed.PropertyReference = ClassifyEmailFailure(cause)
+	failed.Error = cause.Error()
+	failed.LastAttemptAt = time.Now()
+	failed.ResolvedAt = nil
+}
+
+func (s *FailedEmailService) List(ctx context.Context, filter repository.FailedEmailFilter) ([]entity.FailedEmail, error) {
+	return s.Repo.FindAll(ctx, filter)
+}
+
+func (s *FailedEmailService) GetByID(ctx context.Context, id string) (*entity.FailedEmail, error) {
+	failed, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if failed == nil {
+		return nil, errors.New("failed email not found")
+	}
+	return failed, nil
+}
+
+// Reprocess runs a failed email through the parsers again. The outcome is
+// stored on the record: resolved, or the new error.
+func (s *FailedEmailService) Reprocess(ctx context.Context, id string) (*entity.FailedEmail, error) {
+	failed, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if failed.ResolvedAt != nil {
+		return nil, errors.New("failed email already resolved")
+	}
+	if err := s.retry(ctx, failed); err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// Discard removes a failed email that will never become a lead (spam, newsletters, ...)
+func (s *FailedEmailService) Discard(ctx context.Context, id string) error {
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		return errors.New("failed email not found")
+	}
+	return nil
+}
+
+// RetryForProperty retries, in the background, the emails that failed because
+// the reference of a just created property did not exist yet
+func (s *FailedEmailService) RetryForProperty(_ context.Context, property *entity.Property) {
+	// The request that created the property may be over before the retries are
+	ctx := tenant.WithCompanyID(context.Background(), property.CompanyID)
+	go func() {
+		resolved, err := s.RetryUnknownReference(ctx, property.Reference)
+		if err != nil {
+			log.Printf("[FailedEmailService] Error retrying emails for reference %s: %v", property.Reference, err)
+			return
+		}
+		if resolved > 0 {
+			log.Printf("[FailedEmailService] Resolved %d email(s) for new property %s", resolved, property.Reference)
+		}
+	}()
+}
+
+// RetryUnknownReference reprocesses the unresolved unknown_reference failures
+// of reference and returns how many became leads
+func (s *FailedEmailService) RetryUnknownReference(ctx context.Context, reference string) (int, error) {
+	failures, err := s.Repo.FindUnresolvedByReference(ctx, reference)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range failures {
+		if err := s.retry(ctx, &failures[i]); err != nil {
+			return resolved, err
+		}
+		if failures[i].ResolvedAt != nil {
+			resolved++
+		}
+	}
+	return resolved, nil
+}
+
+// retry processes failed again and saves the outcome on it
+func (s *FailedEmailService) retry(ctx context.Context, failed *entity.FailedEmail) error {
+	msg := email.ParsedEmail{
+		MessageID: failed.MessageID,
+		From:      failed.From,
+		Subject:   failed.Subject,
+		Body:      failed.Body,
+		Date:      failed.ReceivedAt,
+	}
+
+	ctx = tenant.WithCompanyID(ctx, failed.CompanyID)
+	configID := ""
+	if failed.ConfigID != nil {
+		configID = *failed.ConfigID
+	}
+	processor, err := s.Processors(ctx, failed.CompanyID, configID)
+	if err != nil {
+		return err
+	}
+
+	failed.Attempts++
+	if err := processor.ProcessEmail(ctx, msg); err != nil {
+		setFailure(failed, err)
+	} else {
+		now := time.Now()
+		failed.LastAttemptAt = now
+		failed.ResolvedAt = &now
+	}
+	return s.Repo.Update(ctx, failed)
+}
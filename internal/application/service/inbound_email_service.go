@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"gorm.io/datatypes"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// InboundEmailService keeps the originals of the emails read from company
+// inboxes, with their attachments in private storage
+type InboundEmailService struct {
+	Repo    repository.InboundEmailRepository
+	Storage PrivateStorageService
+}
+
+func NewInboundEmailService(repo repository.InboundEmailRepository, storage PrivateStorageService) *InboundEmailService {
+	return &InboundEmailService{Repo: repo, Storage: storage}
+}
+
+// Archive stores msg as read from the inbox configID (empty when unknown) of
+// companyID. An email archived before, e.g. one reprocessed from the
+// dead-letter queue, is returned as it was stored.
+func (s *InboundEmailService) Archive(ctx context.Context, companyID, configID string, msg email.ParsedEmail) (*entity.InboundEmail, error) {
+	existing, err := s.Repo.FindByMessageID(ctx, companyID, msg.MessageID)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	inbound := &entity.InboundEmail{
+		CompanyID:  companyID,
+		MessageID:  msg.MessageID,
+		From:       msg.From,
+		To:         msg.To,
+		Subject:    msg.Subject,
+		Headers:    datatypes.NewJSONType(msg.Headers),
+		TextBody:   msg.TextBody,
+		HTMLBody:   msg.HTMLBody,
+		ReceivedAt: msg.Date,
+	}
+	if configID != "" {
+		inbound.ConfigID = &configID
+	}
+	if inbound.TextBody == "" && inbound.HTMLBody == "" {
+		// Only the body the parsers read is known, e.g. for older dead-letter records
+		inbound.TextBody = msg.Body
+	}
+	if inbound.ReceivedAt.IsZero() {
+		inbound.ReceivedAt = time.Now()
+	}
+
+	for _, a := range msg.Attachments {
+		key, err := s.Storage.Save(ctx, bytes.NewReader(a.Data))
+		if err != nil {
+			// The email itself matters more than its attachments
+			log.Printf("[InboundEmailService] Error saving attachment %q of %s: %v", a.Filename, msg.MessageID, err)
+			continue
+		}
+		inbound.Attachments = append(inbound.Attachments, entity.InboundEmailAttachment{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        len(a.Data),
+			StorageKey:  key,
+		})
+	}
+
+	if err := s.Repo.Create(ctx, inbound); err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+// RecordResult stores on inbound how processing it ended: cause is nil when
+// it created or updated lead, parsedLead is nil when no parser got that far
+func (s *InboundEmailService) RecordResult(ctx context.Context, inbound *entity.InboundEmail, parsedLead *entity.ParsedLead, lead *entity.Lead, cause error) error {
+	inbound.ParseStatus = entity.InboundEmailParsed
+	inbound.ParseError = ""
+	if cause != nil {
+		category, _ := ClassifyEmailFailure(cause)
+		inbound.ParseStatus = string(category)
+		inbound.ParseError = cause.Error()
+	}
+	if parsedLead != nil {
+		parsed := datatypes.NewJSONType(*parsedLead)
+		inbound.ParsedLead = &parsed
+		inbound.Source = string(parsedLead.Source)
+	}
+	if lead != nil {
+		inbound.LeadID = &lead.ID
+	}
+	return s.Repo.UpdateResult(ctx, inbound)
+}
+
+// GetForLead returns the original email id of a message of leadID
+func (s *InboundEmailService) GetForLead(ctx context.Context, leadID, id string) (*entity.InboundEmail, error) {
+	inbound, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if inbound == nil || inbound.LeadID == nil || *inbound.LeadID != leadID {
+		return nil, errors.New("inbound email not found")
+	}
+	return inbound, nil
+}
+
+// OpenAttachment returns attachment attachmentID of the original email id of a
+// message of leadID, and its content
+func (s *InboundEmailService) OpenAttachment(ctx context.Context, leadID, id, attachmentID string) (*entity.InboundEmailAttachment, io.ReadCloser, error) {
+	inbound, err := s.GetForLead(ctx, leadID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range inbound.Attachments {
+		attachment := &inbound.Attachments[i]
+		if attachment.ID != attachmentID || attachment.StorageKey == "" {
+			continue
+		}
+		content, err := s.Storage.Open(ctx, attachment.StorageKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return attachment, content, nil
+	}
+	return nil, nil, errors.New("attachment not found")
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidInvitation = errors.New("invalid or expired invitation")
+
+// InvitationEmailSender sends the invitation link; implemented by the Resend and SMTP senders
+type InvitationEmailSender interface {
+	SendInvitationEmail(to, companyName, token string) error
+}
+
+type InvitationService struct {
+	Repo        repository.InvitationRepository
+	AgentRepo   repository.AgentRepository
+	CompanyRepo repository.CompanyRepository
+	EmailSender InvitationEmailSender
+}
+
+func NewInvitationService(
+	repo repository.InvitationRepository,
+	agentRepo repository.AgentRepository,
+	companyRepo repository.CompanyRepository,
+	emailSender InvitationEmailSender,
+) *InvitationService {
+	return &InvitationService{Repo: repo, AgentRepo: agentRepo, CompanyRepo: companyRepo, EmailSender: emailSender}
+}
+
+// Invite creates an invitation to the inviter's company and emails it.
+// A previous pending invitation to the same address is revoked.
+func (s *InvitationService) Invite(ctx context.Context, companyID, email string, role entity.AgentRole, inviterID string, inviterRole entity.AgentRole) (*entity.Invitation, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	if role == "" {
+		role = entity.RoleAgent
+	}
+	if !role.IsValid() {
+		return nil, fmt.Errorf("invalid role: %q", role)
+	}
+	if role == entity.RoleOwner && inviterRole != entity.RoleOwner {
+		return nil, errors.New("unauthorized: only an owner can invite another owner")
+	}
+
+	// Agent emails are unique across companies, not only within this one
+	inUse, err := s.AgentRepo.EmailInUse(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, errors.New("an agent with this email already exists")
+	}
+
+	company, err := s.CompanyRepo.FindByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.RevokePendingForEmail(ctx, companyID, email); err != nil {
+		return nil, err
+	}
+
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &entity.Invitation{
+		ID:        uuid.New().String(),
+		CompanyID: companyID,
+		Email:     email,
+		Role:      role,
+		TokenHash: security.HashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(entity.InvitationTTL),
+	}
+	if inviterID != "" {
+		invitation.InvitedByID = &inviterID
+	}
+	if err := s.Repo.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	if err := s.EmailSender.SendInvitationEmail(email, company.Name, token); err != nil {
+		// The invitation stays pending so it can be sent again
+		log.Printf("[Invitation] ERROR: Failed to send email to %s: %v", email, err)
+		return nil, fmt.Errorf("failed to send invitation email: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// ListPending returns the invitations of the caller's company that can still be accepted
+func (s *InvitationService) ListPending(ctx context.Context) ([]entity.Invitation, error) {
+	return s.Repo.FindPending(ctx)
+}
+
+// Revoke cancels a pending invitation of the caller's company
+func (s *InvitationService) Revoke(ctx context.Context, id string) error {
+	if _, err := s.Repo.FindByID(ctx, id); err != nil {
+		return err
+	}
+	if err := s.Repo.Revoke(ctx, id); err != nil {
+		return errors.New("invitation not found or no longer pending")
+	}
+	return nil
+}
+
+// Validate returns the pending invitation behind token
+func (s *InvitationService) Validate(ctx context.Context, token string) (*entity.Invitation, error) {
+	if token == "" {
+		return nil, ErrInvalidInvitation
+	}
+	invitation, err := s.Repo.FindByTokenHash(ctx, security.HashOpaqueToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || !invitation.IsPending() {
+		return nil, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
+
+// Accept creates the invited agent in the inviting company with the password
+// they chose, and uses up the invitation.
+func (s *InvitationService) Accept(ctx context.Context, token, name, phone, password string) (*entity.Agent, error) {
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(password) < 8 {
+		return nil, errors.New("password must be at least 8 characters long")
+	}
+
+	invitation, err := s.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	inUse, err := s.AgentRepo.EmailInUse(ctx, invitation.Email)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, errors.New("an agent with this email already exists")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := &entity.Agent{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Email:     invitation.Email,
+		Phone:     phone,
+		Password:  string(hashedPassword),
+		Role:      invitation.Role,
+		CompanyID: invitation.CompanyID,
+	}
+	// The unique email on agents stops the same invitation from being accepted twice
+	if err := s.AgentRepo.Create(tenant.WithCompanyID(ctx, invitation.CompanyID), agent); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.MarkAsAccepted(ctx, invitation.ID); err != nil {
+		log.Printf("[Invitation] ERROR: Failed to mark invitation %s as accepted: %v", invitation.ID, err)
+	}
+
+	return agent, nil
+}
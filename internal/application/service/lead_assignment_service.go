@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// LeadAssignmentService assigns new leads to agents following the rules of
+// their company, and keeps the history of who was assigned each lead
+type LeadAssignmentService struct {
+	Repo       repository.LeadAssignmentRepository
+	Leads      repository.LeadRepository
+	Properties repository.PropertyRepository
+}
+
+func NewLeadAssignmentService(repo repository.LeadAssignmentRepository, leads repository.LeadRepository, properties repository.PropertyRepository) *LeadAssignmentService {
+	return &LeadAssignmentService{Repo: repo, Leads: leads, Properties: properties}
+}
+
+// AutoAssign assigns a newly created lead with the first rule of its company
+// that finds an agent, or to fallbackAgentID (may be empty) when none does
+func (s *LeadAssignmentService) AutoAssign(ctx context.Context, lead *entity.Lead, fallbackAgentID string) error {
+	ctx = tenant.WithCompanyID(ctx, lead.CompanyID)
+
+	agentID, rule, err := s.choose(ctx, lead)
+	if err != nil {
+		return err
+	}
+	if agentID == "" {
+		agentID = fallbackAgentID
+	}
+	if agentID == "" {
+		return nil
+	}
+
+	assignment := &entity.LeadAssignment{
+		LeadID:      lead.ID,
+		CompanyID:   lead.CompanyID,
+		FromAgentID: lead.AssignedAgentID,
+		ToAgentID:   &agentID,
+	}
+	if rule != nil {
+		assignment.RuleID = &rule.ID
+		assignment.Note = "Rule " + rule.Name
+	} else {
+		assignment.Note = "Default agent"
+	}
+	if err := s.Repo.Assign(ctx, assignment); err != nil {
+		return err
+	}
+	lead.AssignedAgentID = &agentID
+	return nil
+}
+
+// choose returns the agent the first matching rule gives lead, and that rule
+func (s *LeadAssignmentService) choose(ctx context.Context, lead *entity.Lead) (string, *entity.LeadAssignmentRule, error) {
+	rules, err := s.Repo.FindEnabledRules(ctx)
+	if err != nil || len(rules) == 0 {
+		return "", nil, err
+	}
+
+	workloads, err := s.Repo.Workloads(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	eligible := make(map[string]bool, len(workloads))
+	for _, w := range workloads {
+		eligible[w.AgentID] = true
+	}
+
+	property := lead.Property
+	if property == nil && lead.PropertyID != nil && s.Properties != nil {
+		if property, err = s.Properties.FindByID(ctx, *lead.PropertyID); err != nil {
+			return "", nil, err
+		}
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(lead, property) {
+			continue
+		}
+
+		var agentID string
+		switch rule.Strategy {
+		case entity.AssignPropertyCreator:
+			if property != nil && property.CreatedByAgentID != nil {
+				agentID = *property.CreatedByAgentID
+			}
+		case entity.AssignOwner:
+			if rule.AgentID != nil {
+				agentID = *rule.AgentID
+			}
+		case entity.AssignRoundRobin:
+			agentID = leastRecentlyAssigned(workloads)
+		case entity.AssignLeastLoaded:
+			agentID = leastLoaded(workloads)
+		}
+		if agentID != "" && eligible[agentID] {
+			return agentID, rule, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// leastRecentlyAssigned is the next agent of a round-robin: the one never
+// assigned a lead or assigned one longest ago
+func leastRecentlyAssigned(workloads []entity.AgentWorkload) string {
+	var next *entity.AgentWorkload
+	for i := range workloads {
+		if next == nil || assignedBefore(&workloads[i], next) {
+			next = &workloads[i]
+		}
+	}
+	if next == nil {
+		return ""
+	}
+	return next.AgentID
+}
+
+// leastLoaded is the agent with the fewest open leads, round-robin among equals
+func leastLoaded(workloads []entity.AgentWorkload) string {
+	var next *entity.AgentWorkload
+	for i := range workloads {
+		w := &workloads[i]
+		if next == nil || w.OpenLeads < next.OpenLeads || (w.OpenLeads == next.OpenLeads && assignedBefore(w, next)) {
+			next = w
+		}
+	}
+	if next == nil {
+		return ""
+	}
+	return next.AgentID
+}
+
+func assignedBefore(a, b *entity.AgentWorkload) bool {
+	if a.LastAssignedAt == nil || b.LastAssignedAt == nil {
+		return a.LastAssignedAt == nil && b.LastAssignedAt != nil
+	}
+	return a.LastAssignedAt.Before(*b.LastAssignedAt)
+}
+
+// Reassign assigns the lead id to agentID by hand, or unassigns it when
+// agentID is empty. byAgentID is who did it.
+func (s *LeadAssignmentService) Reassign(ctx context.Context, id, agentID, note, byAgentID string) (*entity.LeadAssignment, error) {
+	lead, err := s.Leads.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReassignLead(ctx, lead, agentID, note, byAgentID)
+}
+
+// ReassignLead is Reassign on a loaded lead, whose AssignedAgentID it updates
+func (s *LeadAssignmentService) ReassignLead(ctx context.Context, lead *entity.Lead, agentID, note, byAgentID string) (*entity.LeadAssignment, error) {
+	assignment, err := s.NewReassignment(ctx, lead, agentID, note, byAgentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.Assign(tenant.WithCompanyID(ctx, lead.CompanyID), assignment); err != nil {
+		return nil, err
+	}
+	lead.AssignedAgentID = assignment.ToAgentID
+	return assignment, nil
+}
+
+// NewReassignment validates the reassignment of lead to agentID and returns
+// the assignment to record, without recording it
+func (s *LeadAssignmentService) NewReassignment(ctx context.Context, lead *entity.Lead, agentID, note, byAgentID string) (*entity.LeadAssignment, error) {
+	ctx = tenant.WithCompanyID(ctx, lead.CompanyID)
+
+	if agentID != "" {
+		workloads, err := s.Repo.Workloads(ctx)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, w := range workloads {
+			found = found || w.AgentID == agentID
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid agent %s: not an agent of the company who can take leads", agentID)
+		}
+	}
+
+	assignment := &entity.LeadAssignment{
+		LeadID:      lead.ID,
+		CompanyID:   lead.CompanyID,
+		FromAgentID: lead.AssignedAgentID,
+		Note:        note,
+	}
+	if agentID != "" {
+		assignment.ToAgentID = &agentID
+	}
+	if byAgentID != "" {
+		assignment.ByAgentID = &byAgentID
+	}
+	return assignment, nil
+}
+
+// History returns the assignments of the lead id, oldest first
+func (s *LeadAssignmentService) History(ctx context.Context, id string) ([]entity.LeadAssignment, error) {
+	if _, err := s.Leads.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.Repo.FindByLead(ctx, id)
+}
+
+func (s *LeadAssignmentService) CreateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	if err := validateAssignmentRule(rule); err != nil {
+		return err
+	}
+	rule.ID = uuid.New().String()
+	return s.Repo.CreateRule(ctx, rule)
+}
+
+func (s *LeadAssignmentService) UpdateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	existing, err := s.Repo.FindRuleByID(ctx, rule.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return errors.New("assignment rule not found")
+	}
+	if err := validateAssignmentRule(rule); err != nil {
+		return err
+	}
+	rule.CreatedAt = existing.CreatedAt
+	return s.Repo.UpdateRule(ctx, rule)
+}
+
+func (s *LeadAssignmentService) GetRule(ctx context.Context, id string) (*entity.LeadAssignmentRule, error) {
+	rule, err := s.Repo.FindRuleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, errors.New("assignment rule not found")
+	}
+	return rule, nil
+}
+
+func (s *LeadAssignmentService) ListRules(ctx context.Context) ([]entity.LeadAssignmentRule, error) {
+	return s.Repo.FindRules(ctx)
+}
+
+func (s *LeadAssignmentService) DeleteRule(ctx context.Context, id string) error {
+	if err := s.Repo.DeleteRule(ctx, id); err != nil {
+		return errors.New("assignment rule not found")
+	}
+	return nil
+}
+
+// validateAssignmentRule checks the rule has what its strategy needs
+func validateAssignmentRule(rule *entity.LeadAssignmentRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Zone = strings.TrimSpace(rule.Zone)
+	rule.PropertyType = strings.TrimSpace(rule.PropertyType)
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	if !rule.Strategy.IsValid() {
+		return fmt.Errorf("invalid strategy %q", rule.Strategy)
+	}
+	if rule.Strategy == entity.AssignOwner && (rule.AgentID == nil || *rule.AgentID == "") {
+		return errors.New("agentId is required for the owner strategy")
+	}
+	if rule.Strategy != entity.AssignOwner {
+		rule.AgentID = nil
+	}
+	return nil
+}
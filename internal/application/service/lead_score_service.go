@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// LeadScoreService scores how promising leads are, with the weights of their company
+type LeadScoreService struct {
+	Repo  repository.LeadScoreRepository
+	Leads repository.LeadRepository
+}
+
+func NewLeadScoreService(repo repository.LeadScoreRepository, leads repository.LeadRepository) *LeadScoreService {
+	return &LeadScoreService{Repo: repo, Leads: leads}
+}
+
+// Weights returns the weights of the company in ctx, the defaults when it set none
+func (s *LeadScoreService) Weights(ctx context.Context) (*entity.LeadScoreWeights, error) {
+	weights, err := s.Repo.FindWeights(ctx)
+	if err != nil || weights != nil {
+		return weights, err
+	}
+	defaults := entity.DefaultLeadScoreWeights()
+	defaults.CompanyID, _ = tenant.CompanyID(ctx)
+	return &defaults, nil
+}
+
+// UpdateWeights saves the weights of the company in ctx. Its leads are
+// rescored with them in the background.
+func (s *LeadScoreService) UpdateWeights(ctx context.Context, weights *entity.LeadScoreWeights) error {
+	if err := weights.Validate(); err != nil {
+		return err
+	}
+	if err := s.Repo.SaveWeights(ctx, weights); err != nil {
+		return err
+	}
+
+	// The request that saved the weights may be over before the rescoring is
+	rescoreCtx := context.Background()
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		rescoreCtx = tenant.WithCompanyID(rescoreCtx, companyID)
+	}
+	go func() {
+		scored, err := s.RecomputeAll(rescoreCtx)
+		if err != nil {
+			log.Printf("[LeadScoreService] Error rescoring leads with new weights: %v", err)
+			return
+		}
+		log.Printf("[LeadScoreService] Rescored %d leads with new weights", scored)
+	}()
+	return nil
+}
+
+// Recompute scores the lead id again
+func (s *LeadScoreService) Recompute(ctx context.Context, id string) (*entity.LeadScore, error) {
+	lead, err := s.Leads.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ctx = tenant.WithCompanyID(ctx, lead.CompanyID)
+	weights, err := s.Weights(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.score(ctx, lead, weights)
+}
+
+// Refresh is Recompute for events that leave the lead scored differently,
+// e.g. a new message. Errors are only logged: the event already happened.
+func (s *LeadScoreService) Refresh(ctx context.Context, leadID string) {
+	if _, err := s.Recompute(ctx, leadID); err != nil {
+		log.Printf("[LeadScoreService] Error scoring lead %s: %v", leadID, err)
+	}
+}
+
+// leadScoreBatchSize is how many leads RecomputeAll reads, and scores, at once
+const leadScoreBatchSize = 500
+
+// RecomputeAll scores again every lead in ctx, of every company when ctx has
+// none, since recency fades with time. Leads are scored in batches of a few
+// queries each. It returns how many leads it scored.
+func (s *LeadScoreService) RecomputeAll(ctx context.Context) (int, error) {
+	weightsByCompany := make(map[string]*entity.LeadScoreWeights)
+	scored := 0
+	afterID := ""
+	for {
+		leads, err := s.Repo.LeadsAfter(ctx, afterID, leadScoreBatchSize)
+		if err != nil || len(leads) == 0 {
+			return scored, err
+		}
+		afterID = leads[len(leads)-1].ID
+
+		now := time.Now()
+		signals, err := s.Repo.SignalsByLead(ctx, leads, now.Add(-entity.LeadScoreWindow))
+		if err != nil {
+			return scored, err
+		}
+
+		scores := make([]entity.LeadScore, 0, len(leads))
+		for i := range leads {
+			lead := &leads[i]
+			weights, ok := weightsByCompany[lead.CompanyID]
+			if !ok {
+				if weights, err = s.Weights(tenant.WithCompanyID(ctx, lead.CompanyID)); err != nil {
+					return scored, err
+				}
+				weightsByCompany[lead.CompanyID] = weights
+			}
+			scores = append(scores, weights.Score(lead, signals[lead.ID], now))
+		}
+		if err := s.Repo.SaveScores(ctx, scores); err != nil {
+			return scored, err
+		}
+		scored += len(scores)
+	}
+}
+
+func (s *LeadScoreService) score(ctx context.Context, lead *entity.Lead, weights *entity.LeadScoreWeights) (*entity.LeadScore, error) {
+	now := time.Now()
+	signals, err := s.Repo.Signals(ctx, lead, now.Add(-entity.LeadScoreWindow))
+	if err != nil {
+		return nil, err
+	}
+	score := weights.Score(lead, *signals, now)
+	if err := s.Repo.SaveScore(ctx, &score); err != nil {
+		return nil, err
+	}
+	lead.Score, lead.ScoredAt = score.Score, &score.ScoredAt
+	return &score, nil
+}
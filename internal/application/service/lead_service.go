@@ -3,18 +3,37 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 	"github.com/google/uuid"
 )
 
 type LeadService struct {
-	Repo repository.LeadRepository
+	Repo           repository.LeadRepository
+	Properties     repository.PropertyRepository // looks up the properties leads are linked to
+	Assignments    *LeadAssignmentService        // assigns new leads by company rules; nil to skip
+	onLeadActivity []func(ctx context.Context, leadID string)
 }
 
-func NewLeadService(repo repository.LeadRepository) *LeadService {
-	return &LeadService{Repo: repo}
+func NewLeadService(repo repository.LeadRepository, assignments *LeadAssignmentService) *LeadService {
+	return &LeadService{Repo: repo, Assignments: assignments}
+}
+
+// OnLeadActivity registers fn to be called after a lead is created, updated
+// or merged with a duplicate
+func (s *LeadService) OnLeadActivity(fn func(ctx context.Context, leadID string)) {
+	s.onLeadActivity = append(s.onLeadActivity, fn)
+}
+
+func (s *LeadService) leadActivity(ctx context.Context, leadID string) {
+	for _, fn := range s.onLeadActivity {
+		fn(ctx, leadID)
+	}
 }
 
 // Create crea un nuevo lead (valida duplicados)
@@ -23,7 +42,8 @@ func (s *LeadService) Create(ctx context.Context, l *entity.Lead) (*entity.Lead,
 		return nil, false, errors.New("email is required")
 	}
 
-	existing, err := s.Repo.FindByEmail(ctx, l.Email)
+	l.Email = entity.NormalizeEmail(l.Email)
+	existing, err := findExistingLead(ctx, s.Repo, l.CompanyID, l.Email, l.Phone)
 	if err != nil {
 		return nil, false, err
 	}
@@ -35,36 +55,219 @@ func (s *LeadService) Create(ctx context.Context, l *entity.Lead) (*entity.Lead,
 		l.ID = uuid.New().String()
 	}
 
-	if err := s.Repo.Create(l); err != nil {
+	if err := s.Repo.Create(ctx, l); err != nil {
 		return nil, false, err
 	}
 
+	// A lead created already assigned keeps its agent
+	if s.Assignments != nil && l.AssignedAgentID == nil {
+		if err := s.Assignments.AutoAssign(ctx, l, ""); err != nil {
+			log.Printf("[LeadService] Error assigning lead %s: %v", l.ID, err)
+		}
+	}
+	s.leadActivity(ctx, l.ID)
+
 	return l, true, nil
 }
 
+// CheckProperty fails with a not found error unless propertyID is a property
+// of companyID: the foreign key alone lets a lead link another company's
+func (s *LeadService) CheckProperty(ctx context.Context, companyID, propertyID string) error {
+	property, err := s.Properties.FindByID(tenant.WithCompanyID(ctx, companyID), propertyID)
+	if err != nil {
+		return err
+	}
+	if property == nil {
+		return fmt.Errorf("property %s not found", propertyID)
+	}
+	return nil
+}
+
 func (s *LeadService) FindByID(ctx context.Context, id string) (*entity.Lead, error) {
-	return s.Repo.FindByID(id)
+	return s.Repo.FindByID(ctx, id)
 }
 
 func (s *LeadService) FindAll(ctx context.Context) ([]entity.Lead, error) {
-	return s.Repo.FindAll()
+	return s.Repo.FindAll(ctx)
 }
 
 func (s *LeadService) Update(ctx context.Context, l *entity.Lead) error {
 	if l.ID == "" {
 		return errors.New("missing ID")
 	}
-	return s.Repo.Update(l)
+	if err := s.Repo.Update(ctx, l); err != nil {
+		return err
+	}
+	s.leadActivity(ctx, l.ID)
+	return nil
+}
+
+// UpdateWithHistory is Update plus the status change and the reassignment of
+// l, each nil when it does not happen, saved together
+func (s *LeadService) UpdateWithHistory(ctx context.Context, l *entity.Lead, change *entity.LeadStatusChange, assignment *entity.LeadAssignment) error {
+	if l.ID == "" {
+		return errors.New("missing ID")
+	}
+	if err := s.Repo.UpdateWithHistory(ctx, l, change, assignment); err != nil {
+		return err
+	}
+	if change != nil {
+		l.Status = change.ToStatus
+	}
+	s.leadActivity(ctx, l.ID)
+	return nil
 }
 
 func (s *LeadService) Delete(ctx context.Context, id string) error {
-	return s.Repo.Delete(id)
+	return s.Repo.Delete(ctx, id)
 }
 
 func (s *LeadService) FindByCompanyId(ctx context.Context, id string) ([]entity.Lead, error) {
 	return s.Repo.FindByCompanyId(ctx, id)
 }
 
+// Search returns a page of the leads matching filter, of LeadPageDefaultLimit
+// leads unless it sets a limit, of LeadPageMaxLimit at most
+func (s *LeadService) Search(ctx context.Context, filter entity.LeadFilter) (*entity.LeadPage, error) {
+	for _, sort := range filter.Sort {
+		if !sort.Field.IsValid() {
+			return nil, fmt.Errorf("invalid sort field %q", sort.Field)
+		}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = entity.LeadPageDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, entity.LeadPageMaxLimit)
+	return s.Repo.Search(ctx, filter)
+}
+
 func (s *LeadService) FindByPropertyId(ctx context.Context, id string) ([]entity.Lead, error) {
 	return s.Repo.FindByPropertyId(ctx, id)
 }
+
+// findExistingLead returns the lead of companyID a contact belongs to: the one
+// with its email or, failing that, with its phone
+func findExistingLead(ctx context.Context, repo repository.LeadRepository, companyID, email, phone string) (*entity.Lead, error) {
+	existing, err := repo.FindByEmail(ctx, companyID, email)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	if entity.NormalizePhone(phone) == "" {
+		return nil, nil
+	}
+	return repo.FindByPhone(ctx, companyID, phone)
+}
+
+// LeadDuplicate is a lead that looks like the same person as another one
+type LeadDuplicate struct {
+	Lead      entity.Lead `json:"lead"`
+	MatchedOn []string    `json:"matchedOn"` // "email" and/or "phone"
+}
+
+// FindDuplicates returns the leads of the company of id that share its email or phone
+func (s *LeadService) FindDuplicates(ctx context.Context, id string) ([]LeadDuplicate, error) {
+	lead, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.Repo.FindDuplicates(ctx, lead)
+	if err != nil {
+		return nil, err
+	}
+
+	email := entity.NormalizeEmail(lead.Email)
+	phone := entity.NormalizePhone(lead.Phone)
+	duplicates := make([]LeadDuplicate, 0, len(candidates))
+	for _, c := range candidates {
+		d := LeadDuplicate{Lead: c}
+		if entity.NormalizeEmail(c.Email) == email {
+			d.MatchedOn = append(d.MatchedOn, "email")
+		}
+		if phone != "" && entity.NormalizePhone(c.Phone) == phone {
+			d.MatchedOn = append(d.MatchedOn, "phone")
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, nil
+}
+
+// Merge folds the lead duplicateID into survivorID, which keeps its own data
+// and takes from the duplicate what it lacks. The conversation, original
+// emails and summary of the duplicate move to the survivor, so do the
+// properties it asked about through its messages. The duplicate is deleted.
+func (s *LeadService) Merge(ctx context.Context, survivorID, duplicateID string) (*entity.Lead, error) {
+	if duplicateID == "" {
+		return nil, errors.New("duplicateId is required")
+	}
+	if duplicateID == survivorID {
+		return nil, errors.New("invalid merge: a lead cannot be merged into itself")
+	}
+
+	survivor, err := s.Repo.FindByID(ctx, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	duplicate, err := s.Repo.FindByID(ctx, duplicateID)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeLeadFields(survivor, duplicate)
+	if err := s.Repo.Merge(ctx, survivor, duplicate.ID); err != nil {
+		return nil, err
+	}
+	s.leadActivity(ctx, survivor.ID)
+	return survivor, nil
+}
+
+// mergeLeadFields fills what survivor lacks from duplicate and keeps the
+// notes of both, along with the contact details only the duplicate had
+func mergeLeadFields(survivor, duplicate *entity.Lead) {
+	if survivor.Name == "" {
+		survivor.Name = duplicate.Name
+	}
+	if survivor.Phone == "" {
+		survivor.Phone = duplicate.Phone
+	}
+	if survivor.Source == "" {
+		survivor.Source = duplicate.Source
+	}
+	if survivor.Budget == 0 {
+		survivor.Budget = duplicate.Budget
+	}
+	if survivor.Zone == "" {
+		survivor.Zone = duplicate.Zone
+	}
+	if survivor.PropertyType == "" {
+		survivor.PropertyType = duplicate.PropertyType
+	}
+	if survivor.PropertyID == nil {
+		survivor.PropertyID = duplicate.PropertyID
+	}
+	if survivor.AssignedAgentID == nil {
+		survivor.AssignedAgentID = duplicate.AssignedAgentID
+	}
+	if duplicate.LastInteraction != nil && (survivor.LastInteraction == nil || duplicate.LastInteraction.After(*survivor.LastInteraction)) {
+		survivor.LastInteraction = duplicate.LastInteraction
+	}
+
+	var notes []string
+	if survivor.Notes != "" {
+		notes = append(notes, survivor.Notes)
+	}
+	if duplicate.Notes != "" {
+		notes = append(notes, duplicate.Notes)
+	}
+	var contacts []string
+	if entity.NormalizeEmail(duplicate.Email) != entity.NormalizeEmail(survivor.Email) {
+		contacts = append(contacts, duplicate.Email)
+	}
+	if duplicate.Phone != "" && entity.NormalizePhone(duplicate.Phone) != entity.NormalizePhone(survivor.Phone) {
+		contacts = append(contacts, duplicate.Phone)
+	}
+	if len(contacts) > 0 {
+		notes = append(notes, "Also reachable at "+strings.Join(contacts, ", "))
+	}
+	survivor.Notes = strings.Join(notes, "\n\n")
+}
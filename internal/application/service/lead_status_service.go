@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// LeadStatusService moves leads through the allowed status transitions,
+// keeping the history that funnel reporting reads
+type LeadStatusService struct {
+	Leads   repository.LeadRepository
+	Changes repository.LeadStatusChangeRepository
+}
+
+func NewLeadStatusService(leads repository.LeadRepository, changes repository.LeadStatusChangeRepository) *LeadStatusService {
+	return &LeadStatusService{Leads: leads, Changes: changes}
+}
+
+// Transition moves the lead id to status to on behalf of agentID
+func (s *LeadStatusService) Transition(ctx context.Context, id string, to entity.LeadStatus, reason entity.LeadStatusReason, note, agentID string) (*entity.LeadStatusChange, error) {
+	lead, err := s.Leads.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.TransitionLead(ctx, lead, to, reason, note, agentID)
+}
+
+// TransitionLead is Transition on a loaded lead, whose Status it updates.
+// agentID is empty when the system moves the lead.
+func (s *LeadStatusService) TransitionLead(ctx context.Context, lead *entity.Lead, to entity.LeadStatus, reason entity.LeadStatusReason, note, agentID string) (*entity.LeadStatusChange, error) {
+	change, err := s.NewTransition(lead, to, reason, note, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Changes.Record(ctx, change); err != nil {
+		return nil, err
+	}
+	lead.Status = to
+	return change, nil
+}
+
+// NewTransition validates the move of lead to status to and returns the
+// change to record, without recording it
+func (s *LeadStatusService) NewTransition(lead *entity.Lead, to entity.LeadStatus, reason entity.LeadStatusReason, note, agentID string) (*entity.LeadStatusChange, error) {
+	from := lead.Status
+	if from == "" {
+		from = entity.LeadStatusNew
+	}
+	if err := entity.ValidateLeadTransition(from, to, reason, note); err != nil {
+		return nil, err
+	}
+
+	change := &entity.LeadStatusChange{
+		LeadID:     lead.ID,
+		CompanyID:  lead.CompanyID,
+		FromStatus: from,
+		ToStatus:   to,
+		ReasonCode: reason,
+		Note:       note,
+	}
+	if agentID != "" {
+		change.AgentID = &agentID
+	}
+	return change, nil
+}
+
+// History returns the status changes of the lead id, oldest first
+func (s *LeadStatusService) History(ctx context.Context, id string) ([]entity.LeadStatusChange, error) {
+	if _, err := s.Leads.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.Changes.FindByLead(ctx, id)
+}
+
+// Funnel tells how far the leads created between since and until got
+func (s *LeadStatusService) Funnel(ctx context.Context, since, until time.Time) (*entity.LeadFunnel, error) {
+	if !since.Before(until) {
+		return nil, errors.New("invalid period: since must be before until")
+	}
+
+	created, counts, err := s.Changes.Funnel(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make(map[entity.LeadStatus]*entity.LeadFunnelStage, len(entity.LeadStatuses))
+	funnel := &entity.LeadFunnel{Since: since, Until: until, Created: created}
+	for _, status := range entity.LeadStatuses {
+		funnel.Stages = append(funnel.Stages, entity.LeadFunnelStage{Status: status})
+	}
+	for i := range funnel.Stages {
+		stages[funnel.Stages[i].Status] = &funnel.Stages[i]
+	}
+
+	// Every lead starts as new, even those created before the history was kept
+	stages[entity.LeadStatusNew].Leads = created
+	for _, c := range counts {
+		stage, ok := stages[c.Status]
+		if !ok || c.Status == entity.LeadStatusNew {
+			continue
+		}
+		switch {
+		case c.ReasonCode == nil:
+			stage.Leads = c.Leads
+		case *c.ReasonCode != "":
+			if stage.Reasons == nil {
+				stage.Reasons = make(map[entity.LeadStatusReason]int64)
+			}
+			stage.Reasons[*c.ReasonCode] = c.Leads
+		}
+	}
+
+	if created > 0 {
+		for i := range funnel.Stages {
+			funnel.Stages[i].Rate = float64(funnel.Stages[i].Leads) / float64(created)
+		}
+	}
+	return funnel, nil
+}
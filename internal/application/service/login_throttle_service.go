@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+)
+
+// throttlePolicy says how many attempts are free inside the window and how
+// long the lock lasts once they are used up. The lock doubles with every
+// further attempt, up to maxLock.
+type throttlePolicy struct {
+	freeAttempts int
+	window       time.Duration
+	baseLock     time.Duration
+	maxLock      time.Duration
+}
+
+var throttlePolicies = map[string]throttlePolicy{
+	entity.ThrottleAccount:       {freeAttempts: 5, window: 24 * time.Hour, baseLock: time.Minute, maxLock: time.Hour},
+	entity.ThrottleIP:            {freeAttempts: 20, window: 24 * time.Hour, baseLock: time.Minute, maxLock: time.Hour},
+	entity.ThrottlePasswordReset: {freeAttempts: 3, window: time.Hour, baseLock: time.Hour, maxLock: time.Hour},
+}
+
+const lockoutEventsLimit = 200
+
+type LoginThrottleService struct {
+	Repo      repository.LoginThrottleRepository
+	AgentRepo repository.AgentRepository
+}
+
+func NewLoginThrottleService(repo repository.LoginThrottleRepository, agentRepo repository.AgentRepository) *LoginThrottleService {
+	return &LoginThrottleService{Repo: repo, AgentRepo: agentRepo}
+}
+
+// CheckLogin returns how long the caller must wait before trying to log in
+// with email from ip. Zero means the attempt may go ahead.
+func (s *LoginThrottleService) CheckLogin(ctx context.Context, email, ip string) (time.Duration, error) {
+	account, err := s.Repo.Find(ctx, entity.ThrottleAccount, normalizeEmail(email))
+	if err != nil {
+		return 0, err
+	}
+	byIP, err := s.Repo.Find(ctx, entity.ThrottleIP, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	return maxDuration(account.RetryAfter(now), byIP.RetryAfter(now)), nil
+}
+
+// RecordLoginFailure counts a wrong password or second factor against both the
+// account and the IP. Emails that do not exist are counted too, so the answer
+// never tells whether an account exists.
+func (s *LoginThrottleService) RecordLoginFailure(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	if err := s.hit(ctx, entity.ThrottleAccount, email, email, ip); err != nil {
+		return err
+	}
+	return s.hit(ctx, entity.ThrottleIP, ip, email, ip)
+}
+
+// RecordLoginSuccess forgets the failed attempts of the account. The IP
+// counter is kept, since one IP may be trying many accounts.
+func (s *LoginThrottleService) RecordLoginSuccess(ctx context.Context, email string) error {
+	return s.Repo.Delete(ctx, entity.ThrottleAccount, normalizeEmail(email))
+}
+
+// ClearAccount lifts the lockout of an account, e.g. after a successful password reset
+func (s *LoginThrottleService) ClearAccount(ctx context.Context, email string) error {
+	return s.Repo.Delete(ctx, entity.ThrottleAccount, normalizeEmail(email))
+}
+
+// AllowPasswordReset counts a password reset request for email and returns how
+// long the caller must wait when there were too many of them.
+func (s *LoginThrottleService) AllowPasswordReset(ctx context.Context, email, ip string) (time.Duration, error) {
+	email = normalizeEmail(email)
+	throttle, err := s.Repo.Find(ctx, entity.ThrottlePasswordReset, email)
+	if err != nil {
+		return 0, err
+	}
+	if retry := throttle.RetryAfter(time.Now()); retry > 0 {
+		return retry, nil
+	}
+	if err := s.hit(ctx, entity.ThrottlePasswordReset, email, email, ip); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// ListLockouts returns the latest lockouts of agents of the caller's company
+func (s *LoginThrottleService) ListLockouts(ctx context.Context) ([]entity.LockoutEvent, error) {
+	return s.Repo.FindEvents(ctx, lockoutEventsLimit)
+}
+
+// hit adds one attempt to a throttle and locks it when the free attempts are used up
+func (s *LoginThrottleService) hit(ctx context.Context, kind, key, email, ip string) error {
+	if key == "" {
+		return nil
+	}
+	policy := throttlePolicies[kind]
+
+	throttle, err := s.Repo.Hit(ctx, kind, key, policy.window)
+	if err != nil {
+		return err
+	}
+	over := throttle.Attempts - policy.freeAttempts
+	if over <= 0 {
+		return nil
+	}
+
+	lock := policy.baseLock
+	for i := 1; i < over && lock < policy.maxLock; i++ {
+		lock *= 2
+	}
+	if lock > policy.maxLock {
+		lock = policy.maxLock
+	}
+	until := time.Now().Add(lock)
+	if err := s.Repo.Lock(ctx, throttle.ID, until); err != nil {
+		return err
+	}
+	throttle.LockedUntil = &until
+
+	s.recordLockout(ctx, throttle, email, ip)
+	return nil
+}
+
+// recordLockout stores the lockout for admins; failing to do so never blocks the login flow
+func (s *LoginThrottleService) recordLockout(ctx context.Context, throttle *entity.LoginThrottle, email, ip string) {
+	log.Printf("[LoginThrottle] %s %s locked until %s after %d attempts", throttle.Kind, throttle.Key, throttle.LockedUntil.Format(time.RFC3339), throttle.Attempts)
+
+	event := &entity.LockoutEvent{
+		ID:          uuid.New().String(),
+		Kind:        throttle.Kind,
+		Email:       email,
+		IPAddress:   ip,
+		Attempts:    throttle.Attempts,
+		LockedUntil: *throttle.LockedUntil,
+	}
+	if agent, err := s.AgentRepo.FindByEmail(ctx, email); err == nil && agent != nil {
+		event.CompanyID = &agent.CompanyID
+		event.AgentID = &agent.ID
+	}
+	if err := s.Repo.CreateEvent(ctx, event); err != nil {
+		log.Printf("[LoginThrottle] ERROR: Failed to record lockout event: %v", err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -11,15 +11,21 @@ import (
 )
 
 type MessageService struct {
-	Repo repository.MessageRepository
+	Repo           repository.MessageRepository
+	onLeadActivity []func(ctx context.Context, leadID string)
 }
 
 func NewMessageService(repo repository.MessageRepository) *MessageService {
 	return &MessageService{Repo: repo}
 }
 
+// OnLeadActivity registers fn to be called after a message of a lead is created
+func (s *MessageService) OnLeadActivity(fn func(ctx context.Context, leadID string)) {
+	s.onLeadActivity = append(s.onLeadActivity, fn)
+}
+
 func (s *MessageService) GetMessagesByLeadID(ctx context.Context, leadID string) ([]entity.Message, error) {
-	return s.Repo.FindByLeadID(leadID)
+	return s.Repo.FindByLeadID(ctx, leadID)
 }
 
 func (s *MessageService) CreateMessage(ctx context.Context, leadID string, senderType string, content string) (*entity.Message, error) {
@@ -39,9 +45,12 @@ func (s *MessageService) CreateMessage(ctx context.Context, leadID string, sende
 		CreatedAt:  time.Now(),
 	}
 
-	if err := s.Repo.Create(msg); err != nil {
+	if err := s.Repo.Create(ctx, msg); err != nil {
 		return nil, err
 	}
+	for _, fn := range s.onLeadActivity {
+		fn(ctx, leadID)
+	}
 
 	return msg, nil
 }
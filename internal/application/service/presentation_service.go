@@ -9,6 +9,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 )
 
@@ -17,7 +18,10 @@ type PresentationService struct {
 	propertyRepo repository.PropertyRepository
 	agentRepo    repository.AgentRepository
 	companyRepo  repository.CompanyRepository
+	viewRepo     repository.PresentationViewRepository
 	jwtSecret    string
+
+	onLeadActivity []func(ctx context.Context, leadID string)
 }
 
 func NewPresentationService(
@@ -25,6 +29,7 @@ func NewPresentationService(
 	propertyRepo repository.PropertyRepository,
 	agentRepo repository.AgentRepository,
 	companyRepo repository.CompanyRepository,
+	viewRepo repository.PresentationViewRepository,
 	jwtSecret string,
 ) *PresentationService {
 	return &PresentationService{
@@ -32,12 +37,32 @@ func NewPresentationService(
 		propertyRepo: propertyRepo,
 		agentRepo:    agentRepo,
 		companyRepo:  companyRepo,
+		viewRepo:     viewRepo,
 		jwtSecret:    jwtSecret,
 	}
 }
 
-// GenerateToken for a presentation
-func (s *PresentationService) GenerateToken(leadID string, propertyIDs []string) (string, error) {
+// OnLeadActivity registers fn to be called after a lead opens a presentation
+func (s *PresentationService) OnLeadActivity(fn func(ctx context.Context, leadID string)) {
+	s.onLeadActivity = append(s.onLeadActivity, fn)
+}
+
+// GenerateToken for a presentation. The lead and every property must be
+// visible to the caller's company, otherwise the token would expose them publicly.
+func (s *PresentationService) GenerateToken(ctx context.Context, leadID string, propertyIDs []string) (string, error) {
+	if _, err := s.leadRepo.FindByID(ctx, leadID); err != nil {
+		return "", fmt.Errorf("lead not found: %w", err)
+	}
+	for _, propID := range propertyIDs {
+		prop, err := s.propertyRepo.FindByID(ctx, propID)
+		if err != nil {
+			return "", err
+		}
+		if prop == nil {
+			return "", fmt.Errorf("property %s not found", propID)
+		}
+	}
+
 	expiresAt := time.Now().Add(7 * 24 * time.Hour).Unix()
 
 	claims := jwt.MapClaims{
@@ -111,13 +136,16 @@ func (s *PresentationService) GetPresentation(ctx context.Context, tokenString s
 		return nil, errors.New("presentation has expired")
 	}
 
-	lead, err := s.leadRepo.FindByID(tokenData.LeadID)
+	lead, err := s.leadRepo.FindByID(ctx, tokenData.LeadID)
 	if err != nil {
 		return nil, fmt.Errorf("lead not found: %w", err)
 	}
+	// Public endpoint: everything else is looked up within the lead's company
+	ctx = tenant.WithCompanyID(ctx, lead.CompanyID)
+
 	properties := make([]entity.Property, 0, len(tokenData.PropertyIDs))
 	for _, propID := range tokenData.PropertyIDs {
-		prop, err := s.propertyRepo.FindByID(propID)
+		prop, err := s.propertyRepo.FindByID(ctx, propID)
 		if err != nil {
 			continue
 		}
@@ -128,19 +156,28 @@ func (s *PresentationService) GetPresentation(ctx context.Context, tokenString s
 
 	contactPhone := ""
 	if lead.AssignedAgentID != nil && *lead.AssignedAgentID != "" {
-		agent, err := s.agentRepo.FindByID(*lead.AssignedAgentID)
+		agent, err := s.agentRepo.FindByID(ctx, *lead.AssignedAgentID)
 		if err == nil && agent != nil && agent.Phone != "" {
 			contactPhone = agent.Phone
 		}
 	}
 
 	if contactPhone == "" && lead.CompanyID != "" {
-		company, err := s.companyRepo.FindByID(lead.CompanyID)
+		company, err := s.companyRepo.FindByID(ctx, lead.CompanyID)
 		if err == nil && company != nil {
 			contactPhone = company.Phone1
 		}
 	}
 
+	// Opening the presentation tells the lead is interested
+	if err := s.viewRepo.Create(ctx, &entity.PresentationView{LeadID: lead.ID, CompanyID: lead.CompanyID}); err != nil {
+		slog.Error("failed to record presentation view", "lead_id", lead.ID, "error", err)
+	} else {
+		for _, fn := range s.onLeadActivity {
+			fn(ctx, lead.ID)
+		}
+	}
+
 	return &entity.Presentation{
 		Lead:         lead,
 		Properties:   properties,
@@ -149,7 +186,7 @@ func (s *PresentationService) GetPresentation(ctx context.Context, tokenString s
 }
 
 func (s *PresentationService) GetMatchingProperties(ctx context.Context, leadID string) ([]entity.PropertyMatch, error) {
-	lead, err := s.leadRepo.FindByID(leadID)
+	lead, err := s.leadRepo.FindByID(ctx, leadID)
 	if err != nil {
 		return nil, fmt.Errorf("lead not found: %w", err)
 	}
@@ -11,13 +11,19 @@ import (
 )
 
 type PropertyService struct {
-	repo repository.PropertyRepository
+	repo      repository.PropertyRepository
+	onCreated []func(ctx context.Context, p *entity.Property)
 }
 
 func NewPropertyService(repo repository.PropertyRepository) *PropertyService {
 	return &PropertyService{repo: repo}
 }
 
+// OnCreated registers fn to be called after a property is created
+func (s *PropertyService) OnCreated(fn func(ctx context.Context, p *entity.Property)) {
+	s.onCreated = append(s.onCreated, fn)
+}
+
 func (s *PropertyService) CreateProperty(ctx context.Context, p *entity.Property) (*entity.Property, bool, error) {
 	if p.Reference == "" || p.CompanyID == "" {
 		return nil, false, errors.New("reference and company_id are required")
@@ -35,23 +41,29 @@ func (s *PropertyService) CreateProperty(ctx context.Context, p *entity.Property
 		p.ID = uuid.New().String()
 	}
 
-	if err := s.repo.Create(p); err != nil {
+	if err := s.repo.Create(ctx, p); err != nil {
 		return nil, false, err
 	}
 
+	for _, fn := range s.onCreated {
+		fn(ctx, p)
+	}
+
 	return p, true, nil
 }
 
 func (s *PropertyService) GetPropertyByID(ctx context.Context, id string) (*entity.Property, error) {
-	return s.repo.FindByID(id)
+	return s.repo.FindByID(ctx, id)
 }
 
 func (s *PropertyService) GetAllProperties(ctx context.Context) ([]entity.Property, error) {
-	return s.repo.FindAll()
+	return s.repo.FindAll(ctx)
 }
 
-func (s *PropertyService) UpdateProperty(ctx context.Context, p *entity.Property, executorID, executorRole string) error {
-	existing, err := s.repo.FindByID(p.ID)
+// UpdateProperty changes p for executorID, an agent, or nobody for an API key.
+// Only its creator or who can manage every property may do so.
+func (s *PropertyService) UpdateProperty(ctx context.Context, p *entity.Property, executorID string, executor entity.Grants) error {
+	existing, err := s.repo.FindByID(ctx, p.ID)
 	if err != nil {
 		return err
 	}
@@ -59,12 +71,17 @@ func (s *PropertyService) UpdateProperty(ctx context.Context, p *entity.Property
 		return errors.New("property not found")
 	}
 
-	// Permission check: Admin or Creator
+	// Permission check: Creator or a role that manages every property
 	isCreator := existing.CreatedByAgentID != nil && *existing.CreatedByAgentID == executorID
-	isAdmin := executorRole == "admin"
+	canManage := executor.Can(entity.PermissionPropertyManage)
+
+	if !isCreator && !canManage {
+		return errors.New("unauthorized: only the creator or a manager can update this property")
+	}
 
-	if !isCreator && !isAdmin {
-		return errors.New("unauthorized: only admin or creator can update this property")
+	// Changing the status is what publishes or withdraws a property
+	if p.Status != "" && p.Status != existing.Status && !executor.Can(entity.PermissionPropertyPublish) {
+		return errors.New("unauthorized: changing the status of a property requires " + string(entity.PermissionPropertyPublish))
 	}
 
 	// Tenemos que adaptar esto para que funcione con el update parcial correctamente
@@ -84,11 +101,12 @@ func (s *PropertyService) UpdateProperty(ctx context.Context, p *entity.Property
 	// CompanyID, Reference, CreatedByAgentID -> already in 'existing'
 
 	// Use existing as the object to save
-	return s.repo.Update(existing)
+	return s.repo.Update(ctx, existing)
 }
 
-func (s *PropertyService) DeleteProperty(ctx context.Context, id string, executorID, executorRole string) error {
-	existing, err := s.repo.FindByID(id)
+// DeleteProperty removes property id, with the same rule as UpdateProperty
+func (s *PropertyService) DeleteProperty(ctx context.Context, id string, executorID string, executor entity.Grants) error {
+	existing, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -96,15 +114,15 @@ func (s *PropertyService) DeleteProperty(ctx context.Context, id string, executo
 		return errors.New("property not found")
 	}
 
-	// Permission check: Admin or Creator
+	// Permission check: Creator or a role that manages every property
 	isCreator := existing.CreatedByAgentID != nil && *existing.CreatedByAgentID == executorID
-	isAdmin := executorRole == "admin"
+	canManage := executor.Can(entity.PermissionPropertyManage)
 
-	if !isCreator && !isAdmin {
-		return errors.New("unauthorized: only admin or creator can delete this property")
+	if !isCreator && !canManage {
+		return errors.New("unauthorized: only the creator or a manager can delete this property")
 	}
 
-	return s.repo.Delete(id)
+	return s.repo.Delete(ctx, id)
 }
 
 func (s *PropertyService) FindAllByCompanyID(ctx context.Context, companyID string) ([]entity.Property, error) {
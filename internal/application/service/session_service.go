@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+)
+
+// RefreshTokenTTL is how long a session survives without being refreshed
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// AuthTokens is what a client gets after logging in or refreshing
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type SessionService struct {
+	Repo      repository.SessionRepository
+	AgentRepo repository.AgentRepository
+}
+
+func NewSessionService(repo repository.SessionRepository, agentRepo repository.AgentRepository) *SessionService {
+	return &SessionService{Repo: repo, AgentRepo: agentRepo}
+}
+
+// Start opens a new session for an agent that has just authenticated
+func (s *SessionService) Start(ctx context.Context, agent *entity.Agent, userAgent, ipAddress string) (*AuthTokens, error) {
+	refreshToken, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &entity.Session{
+		ID:               uuid.New().String(),
+		AgentID:          agent.ID,
+		RefreshTokenHash: security.HashOpaqueToken(refreshToken),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := s.Repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := security.GenerateToken(agent.ID, agent.CompanyID, string(agent.Role), session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Presenting a token that was already rotated means it leaked, so the
+// whole session is revoked.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*AuthTokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	hash := security.HashOpaqueToken(refreshToken)
+
+	session, err := s.Repo.FindByTokenHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || !session.IsActive() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if session.RefreshTokenHash != hash {
+		log.Printf("[Session] Reused refresh token for session %s, revoking it", session.ID)
+		if err := s.Repo.Revoke(ctx, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Role and company are read again so that changes apply from the next refresh
+	agent, err := s.AgentRepo.FindByID(ctx, session.AgentID)
+	if err != nil || agent == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newRefreshToken, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.Rotate(ctx, session.ID, hash, security.HashOpaqueToken(newRefreshToken), time.Now().Add(RefreshTokenTTL)); err != nil {
+		// Someone else rotated the same token first
+		return nil, ErrInvalidRefreshToken
+	}
+
+	accessToken, err := security.GenerateToken(agent.ID, agent.CompanyID, string(agent.Role), session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{AccessToken: accessToken, RefreshToken: newRefreshToken}, nil
+}
+
+// Revoke ends a single session of the agent
+func (s *SessionService) Revoke(ctx context.Context, sessionID, agentID string) error {
+	session, err := s.Repo.FindByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.AgentID != agentID {
+		return errors.New("session not found")
+	}
+	return s.Repo.Revoke(ctx, sessionID)
+}
+
+// RevokeAll ends every session of the agent, e.g. on "log out everywhere" or after a password reset
+func (s *SessionService) RevokeAll(ctx context.Context, agentID string) error {
+	return s.Repo.RevokeAllForAgent(ctx, agentID)
+}
+
+// IsActive reports whether an access token issued for sessionID may still be used
+func (s *SessionService) IsActive(ctx context.Context, sessionID, agentID string) (bool, error) {
+	session, err := s.Repo.FindByID(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return session != nil && session.AgentID == agentID && session.IsActive(), nil
+}
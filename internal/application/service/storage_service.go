@@ -2,9 +2,22 @@ package service
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 )
 
 type StorageService interface {
 	UploadFile(ctx context.Context, file multipart.File, header *multipart.FileHeader) (string, error)
+	// SaveFile stores content under a unique name keeping the extension of
+	// filename, and returns its public URL
+	SaveFile(ctx context.Context, filename string, content io.Reader) (string, error)
+}
+
+// PrivateStorageService keeps files that must never be reachable from a public
+// URL, such as the attachments of inbound emails; only the API serves them
+type PrivateStorageService interface {
+	// Save stores content under a new random key, which it returns
+	Save(ctx context.Context, content io.Reader) (string, error)
+	// Open reads the file stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
 }
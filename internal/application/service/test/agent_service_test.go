@@ -25,7 +25,7 @@ func TestCreateAgent(t *testing.T) {
 
 
 	mockAgentRepo.On("FindByEmail", ctx, "agent@test.com").Return(nil, nil)
-	mockAgentRepo.On("Create", mock.MatchedBy(func(a *entity.Agent) bool {
+	mockAgentRepo.On("Create", mock.Anything, mock.MatchedBy(func(a *entity.Agent) bool {
 		return a.Email == "agent@test.com" && a.ID != ""
 	})).Return(nil)
 
@@ -80,12 +80,12 @@ func TestAssociateProperties(t *testing.T) {
 		},
 	}
 
-	mockPropRepo.On("FindByID", "p1").Return(prop1, nil)
-	mockPropRepo.On("FindByID", "p2").Return(prop2, nil)
-	mockPropRepo.On("FindByID", "p3").Return(nil, nil)
+	mockPropRepo.On("FindByID", mock.Anything, "p1").Return(prop1, nil)
+	mockPropRepo.On("FindByID", mock.Anything, "p2").Return(prop2, nil)
+	mockPropRepo.On("FindByID", mock.Anything, "p3").Return(nil, nil)
 
 	// WHEN
-	err := svc.AssociateProperties(agent)
+	err := svc.AssociateProperties(context.Background(), agent)
 
 	// THEN
 	assert.NoError(t, err)
@@ -93,3 +93,151 @@ func TestAssociateProperties(t *testing.T) {
 	assert.Equal(t, "p1", agent.Properties[0].ID)
 	assert.Equal(t, "p2", agent.Properties[1].ID)
 }
+
+func TestChangeRole(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+
+	mockAgentRepo.On("FindByID", ctx, "a2").Return(&entity.Agent{ID: "a2", Role: entity.RoleAgent}, nil)
+	mockAgentRepo.On("UpdatePartial", ctx, "a2", mock.MatchedBy(func(fields map[string]interface{}) bool {
+		return fields["role"] == entity.RoleManager
+	})).Return(nil)
+
+	// WHEN
+	err := svc.ChangeRole(ctx, "a2", entity.RoleManager, "a1", entity.RoleAdmin)
+
+	// THEN
+	assert.NoError(t, err)
+	mockAgentRepo.AssertExpectations(t)
+}
+
+func TestChangeRole_InvalidRole(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+
+	// WHEN
+	err := svc.ChangeRole(context.TODO(), "a2", entity.AgentRole("superuser"), "a1", entity.RoleOwner)
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid role")
+	mockAgentRepo.AssertNotCalled(t, "UpdatePartial")
+}
+
+func TestChangeRole_OwnRole(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+
+	// WHEN
+	err := svc.ChangeRole(context.TODO(), "a1", entity.RoleReadOnly, "a1", entity.RoleOwner)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	mockAgentRepo.AssertNotCalled(t, "UpdatePartial")
+}
+
+func TestChangeRole_AdminCannotTouchOwners(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+
+	mockAgentRepo.On("FindByID", ctx, "owner").Return(&entity.Agent{ID: "owner", Role: entity.RoleOwner}, nil)
+	mockAgentRepo.On("FindByID", ctx, "a2").Return(&entity.Agent{ID: "a2", Role: entity.RoleAgent}, nil)
+
+	// WHEN
+	demoteErr := svc.ChangeRole(ctx, "owner", entity.RoleAgent, "a1", entity.RoleAdmin)
+	promoteErr := svc.ChangeRole(ctx, "a2", entity.RoleOwner, "a1", entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, demoteErr, "unauthorized")
+	assert.ErrorContains(t, promoteErr, "unauthorized")
+	mockAgentRepo.AssertNotCalled(t, "UpdatePartial")
+}
+
+func TestUpdateProfile_OnlyWritesProfileFields(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+	name := "New Name"
+
+	mockAgentRepo.On("FindByID", ctx, "a2").Return(&entity.Agent{ID: "a2", Role: entity.RoleAgent}, nil)
+	mockAgentRepo.On("UpdatePartial", ctx, "a2", mock.MatchedBy(func(fields map[string]interface{}) bool {
+		_, hasPhone := fields["phone"]
+		return fields["name"] == "New Name" && !hasPhone && len(fields) == 2
+	})).Return(nil)
+
+	// WHEN
+	err := svc.UpdateProfile(ctx, "a2", service.AgentProfile{Name: &name}, entity.RoleAdmin)
+
+	// THEN
+	assert.NoError(t, err)
+	mockAgentRepo.AssertExpectations(t)
+}
+
+func TestUpdateProfile_AdminCannotEditOwner(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+	name := "Taken Over"
+
+	mockAgentRepo.On("FindByID", ctx, "owner").Return(&entity.Agent{ID: "owner", Role: entity.RoleOwner}, nil)
+
+	// WHEN
+	err := svc.UpdateProfile(ctx, "owner", service.AgentProfile{Name: &name}, entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	mockAgentRepo.AssertNotCalled(t, "UpdatePartial")
+}
+
+func TestDeleteAgent_Self(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+
+	// WHEN
+	err := svc.Delete(context.TODO(), "a1", "a1", entity.RoleOwner)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	mockAgentRepo.AssertNotCalled(t, "Delete")
+}
+
+func TestDeleteAgent_AdminCannotDeleteOwner(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+
+	mockAgentRepo.On("FindByID", ctx, "owner").Return(&entity.Agent{ID: "owner", Role: entity.RoleOwner}, nil)
+
+	// WHEN
+	err := svc.Delete(ctx, "owner", "a1", entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	mockAgentRepo.AssertNotCalled(t, "Delete")
+}
+
+func TestDeleteAgent_OwnerCanDeleteOwner(t *testing.T) {
+	// GIVEN
+	mockAgentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewAgentService(mockAgentRepo, nil)
+	ctx := context.TODO()
+
+	mockAgentRepo.On("FindByID", ctx, "owner2").Return(&entity.Agent{ID: "owner2", Role: entity.RoleOwner}, nil)
+	mockAgentRepo.On("Delete", ctx, "owner2").Return(nil)
+
+	// WHEN
+	err := svc.Delete(ctx, "owner2", "owner1", entity.RoleOwner)
+
+	// THEN
+	assert.NoError(t, err)
+	mockAgentRepo.AssertExpectations(t)
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKey_StoresOnlyTheHash(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.APIKeyRepositoryMock)
+	svc := service.NewAPIKeyService(repo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*entity.APIKey")).Return(nil)
+
+	// WHEN
+	key, plain, err := svc.Create(context.TODO(), "C1", "Website", []entity.APIKeyScope{entity.ScopeLeadsWrite}, "A1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(plain, entity.APIKeyPrefix))
+	assert.Equal(t, security.HashOpaqueToken(plain), key.KeyHash)
+	assert.True(t, strings.HasPrefix(plain, key.Prefix))
+	assert.NotEqual(t, plain, key.Prefix)
+	assert.Equal(t, "A1", *key.CreatedByID)
+}
+
+func TestCreateAPIKey_UnknownScopeIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.APIKeyRepositoryMock)
+	svc := service.NewAPIKeyService(repo)
+
+	// WHEN
+	_, _, err := svc.Create(context.TODO(), "C1", "Website", []entity.APIKeyScope{"agents:manage"}, "A1")
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid scope")
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestAuthenticateAPIKey_RecordsLastUse(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.APIKeyRepositoryMock)
+	svc := service.NewAPIKeyService(repo)
+	plain := entity.APIKeyPrefix + "secret"
+	stored := &entity.APIKey{ID: "K1", CompanyID: "C1", Scopes: []entity.APIKeyScope{entity.ScopeLeadsRead}}
+
+	repo.On("FindByHash", mock.Anything, security.HashOpaqueToken(plain)).Return(stored, nil)
+	repo.On("TouchLastUsed", mock.Anything, "K1", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// WHEN
+	key, err := svc.Authenticate(context.TODO(), plain)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, stored, key)
+	assert.NotNil(t, key.LastUsedAt)
+	repo.AssertExpectations(t)
+}
+
+func TestAuthenticateAPIKey_RecentlyUsedIsNotWrittenAgain(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.APIKeyRepositoryMock)
+	svc := service.NewAPIKeyService(repo)
+	plain := entity.APIKeyPrefix + "secret"
+	lastUsed := time.Now().Add(-10 * time.Second)
+
+	repo.On("FindByHash", mock.Anything, mock.Anything).Return(&entity.APIKey{ID: "K1", LastUsedAt: &lastUsed}, nil)
+
+	// WHEN
+	key, err := svc.Authenticate(context.TODO(), plain)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+	repo.AssertNotCalled(t, "TouchLastUsed")
+}
+
+func TestAuthenticateAPIKey_RevokedIsNil(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.APIKeyRepositoryMock)
+	svc := service.NewAPIKeyService(repo)
+	revokedAt := time.Now()
+
+	repo.On("FindByHash", mock.Anything, mock.Anything).Return(&entity.APIKey{ID: "K1", RevokedAt: &revokedAt}, nil)
+
+	// WHEN
+	key, err := svc.Authenticate(context.TODO(), entity.APIKeyPrefix+"secret")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+}
@@ -0,0 +1,266 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func imapInput(syncMode string) service.EmailConfigInput {
+	return service.EmailConfigInput{
+		IMAPHost:     "imap.example.com",
+		IMAPUsername: "leads@example.com",
+		IMAPPassword: "secret",
+		SyncMode:     syncMode,
+	}
+}
+
+func TestCreateEmailConfig_DefaultsToPolling(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+
+	repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	config, err := svc.CreateConfig(context.TODO(), "C1", imapInput(""))
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, entity.EmailSyncModePoll, config.SyncMode)
+	assert.Equal(t, "leads@example.com", config.Label)
+	assert.NotEqual(t, "secret", config.IMAPPassword)
+}
+
+func TestCreateEmailConfig_InvalidSyncMode(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+
+	// WHEN
+	_, err := svc.CreateConfig(context.TODO(), "C1", imapInput("push"))
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid sync mode")
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestCreateEmailConfig_SecondInboxWithDefaultAgent(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, agentRepo, new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	agentID := "A1"
+
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(&entity.Agent{ID: "A1", CompanyID: "C1"}, nil)
+	repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	input := imapInput("")
+	input.Label = "Rentals"
+	input.DefaultAgentID = &agentID
+
+	// WHEN
+	config, err := svc.CreateConfig(context.TODO(), "C1", input)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "Rentals", config.Label)
+	assert.Equal(t, &agentID, config.DefaultAgentID)
+	repo.AssertNotCalled(t, "FindByCompanyID")
+}
+
+func TestCreateEmailConfig_DefaultAgentOfOtherCompany(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, agentRepo, new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	agentID := "A2"
+
+	agentRepo.On("FindByID", mock.Anything, "A2").Return(&entity.Agent{ID: "A2", CompanyID: "C2"}, nil)
+
+	input := imapInput("")
+	input.DefaultAgentID = &agentID
+
+	// WHEN
+	_, err := svc.CreateConfig(context.TODO(), "C1", input)
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid default agent")
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestUpdateEmailConfig_SwitchesToIdle(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	existing := &entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", AuthMethod: entity.EmailAuthPassword, SyncMode: entity.EmailSyncModePoll, IMAPPassword: "encrypted"}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(existing, nil)
+	repo.On("Update", mock.Anything, existing).Return(nil)
+
+	input := imapInput(entity.EmailSyncModeIdle)
+	input.IMAPPassword = ""
+
+	// WHEN
+	config, err := svc.UpdateConfig(context.TODO(), "CFG1", input)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, entity.EmailSyncModeIdle, config.SyncMode)
+	assert.Equal(t, "encrypted", config.IMAPPassword)
+}
+
+func TestGetEmailConfig_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(&entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C2"}, nil)
+
+	// WHEN
+	_, err := svc.GetConfig(context.TODO(), "C1", "CFG1")
+
+	// THEN
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestToggleEmailConfig_PublishesChange(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	existing := &entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", IsEnabled: true}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(existing, nil)
+	repo.On("Update", mock.Anything, existing).Return(nil)
+
+	// WHEN
+	err := svc.ToggleEnabled(context.TODO(), "CFG1", false)
+
+	// THEN
+	assert.NoError(t, err)
+	select {
+	case change := <-svc.Changes():
+		assert.Equal(t, service.EmailConfigChange{ConfigID: "CFG1", CompanyID: "C1"}, change)
+	default:
+		t.Fatal("expected a change to be published")
+	}
+}
+
+func TestRefreshOAuth2Token_DoesNotRestartWorker(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	existing := &entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", AuthMethod: entity.EmailAuthGoogle}
+	expiry := time.Now().Add(time.Hour)
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(existing, nil)
+	repo.On("UpdateTokens", mock.Anything, "CFG1", "enc-access", "enc-refresh", expiry).Return(nil)
+
+	// WHEN
+	err := svc.RefreshOAuth2Token(context.TODO(), "CFG1", "enc-access", "enc-refresh", expiry)
+
+	// THEN
+	assert.NoError(t, err)
+	repo.AssertNotCalled(t, "Update")
+	assert.Empty(t, svc.Changes())
+}
+
+func TestRecordSyncRun_SuccessClearsFailures(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	runRepo := new(mocks.EmailSyncRunRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), runRepo, testEncryptionKey)
+	finished := time.Now()
+	run := &entity.EmailSyncRun{ConfigID: "CFG1", EmailsSeen: 3, LeadsCreated: 2, FinishedAt: finished}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(&entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", ConsecutiveFailures: 2, LastError: "timeout"}, nil)
+	runRepo.On("Create", mock.Anything, run).Return(nil)
+	runRepo.On("DeleteBefore", mock.Anything, "CFG1", mock.Anything).Return(nil)
+	repo.On("UpdateLastSync", mock.Anything, "CFG1", finished).Return(nil)
+	repo.On("UpdateSyncStatus", mock.Anything, "CFG1", 0, "").Return(nil)
+
+	// WHEN
+	err := svc.RecordSyncRun(context.TODO(), run)
+
+	// THEN
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Disable")
+}
+
+func TestRecordSyncRun_DisablesAfterRepeatedAuthFailures(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	runRepo := new(mocks.EmailSyncRunRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), runRepo, testEncryptionKey)
+	run := &entity.EmailSyncRun{ConfigID: "CFG1", Error: "authentication failed", AuthFailure: true}
+
+	recent := make([]entity.EmailSyncRun, service.EmailAuthFailureLimit)
+	for i := range recent {
+		recent[i] = entity.EmailSyncRun{ConfigID: "CFG1", AuthFailure: true}
+	}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(&entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", IsEnabled: true, ConsecutiveFailures: service.EmailAuthFailureLimit - 1}, nil)
+	runRepo.On("Create", mock.Anything, run).Return(nil)
+	runRepo.On("DeleteBefore", mock.Anything, "CFG1", mock.Anything).Return(nil)
+	repo.On("UpdateSyncStatus", mock.Anything, "CFG1", service.EmailAuthFailureLimit, "authentication failed").Return(nil)
+	runRepo.On("FindRecentByConfigID", mock.Anything, "CFG1", service.EmailAuthFailureLimit).Return(recent, nil)
+	repo.On("Disable", mock.Anything, "CFG1", mock.MatchedBy(func(reason string) bool {
+		return strings.Contains(reason, "authentication failed")
+	})).Return(nil)
+
+	// WHEN
+	err := svc.RecordSyncRun(context.TODO(), run)
+
+	// THEN
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	assert.Len(t, svc.Changes(), 1)
+}
+
+func TestRecordSyncRun_NetworkErrorsDoNotDisable(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	runRepo := new(mocks.EmailSyncRunRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), runRepo, testEncryptionKey)
+	run := &entity.EmailSyncRun{ConfigID: "CFG1", Error: "connection refused"}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(&entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", ConsecutiveFailures: 10}, nil)
+	runRepo.On("Create", mock.Anything, run).Return(nil)
+	runRepo.On("DeleteBefore", mock.Anything, "CFG1", mock.Anything).Return(nil)
+	repo.On("UpdateSyncStatus", mock.Anything, "CFG1", 11, "connection refused").Return(nil)
+
+	// WHEN
+	err := svc.RecordSyncRun(context.TODO(), run)
+
+	// THEN
+	assert.NoError(t, err)
+	repo.AssertNotCalled(t, "Disable")
+	runRepo.AssertNotCalled(t, "FindRecentByConfigID")
+}
+
+func TestToggleEmailConfig_EnableClearsDisabledReason(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.CompanyEmailConfigRepositoryMock)
+	svc := service.NewCompanyEmailConfigService(repo, new(mocks.AgentRepositoryMock), new(mocks.EmailSyncRunRepositoryMock), testEncryptionKey)
+	existing := &entity.CompanyEmailConfig{ID: "CFG1", CompanyID: "C1", ConsecutiveFailures: 5, DisabledReason: "bad password"}
+
+	repo.On("FindByID", mock.Anything, "CFG1").Return(existing, nil)
+	repo.On("Update", mock.Anything, existing).Return(nil)
+
+	// WHEN
+	err := svc.ToggleEnabled(context.TODO(), "CFG1", true)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, existing.IsEnabled)
+	assert.Zero(t, existing.ConsecutiveFailures)
+	assert.Empty(t, existing.DisabledReason)
+}
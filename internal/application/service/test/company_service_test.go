@@ -24,7 +24,7 @@ func TestCreateCompany(t *testing.T) {
 
 	// Mocking behavior
 	mockRepo.On("FindByName", ctx, "Test Company").Return(nil, nil)
-	mockRepo.On("Create", mock.MatchedBy(func(c *entity.Company) bool {
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(c *entity.Company) bool {
 		return c.Name == "Test Company" && c.ID != ""
 	})).Return(nil)
 
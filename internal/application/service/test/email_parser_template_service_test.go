@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/datatypes"
+)
+
+func contactFormTemplate() *entity.EmailParserTemplate {
+	return &entity.EmailParserTemplate{
+		Name:        "Web",
+		FromPattern: "@example.com",
+		Rules: datatypes.NewJSONType(entity.ParserTemplateRules{
+			Email:             entity.TemplateFieldRule{Regex: `Email:\s*(\S+)`},
+			PropertyReference: entity.TemplateFieldRule{Regex: `Ref:\s*(\S+)`},
+		}),
+	}
+}
+
+func TestCreateParserTemplate_InvalidRegexIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.EmailParserTemplateRepositoryMock)
+	svc := service.NewEmailParserTemplateService(repo)
+	template := contactFormTemplate()
+	template.SubjectPattern = "[unclosed"
+
+	// WHEN
+	err := svc.Create(context.TODO(), template)
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid subject pattern")
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestCreateParserTemplate(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.EmailParserTemplateRepositoryMock)
+	svc := service.NewEmailParserTemplateService(repo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*entity.EmailParserTemplate")).Return(nil)
+
+	// WHEN
+	template := contactFormTemplate()
+	err := svc.Create(context.TODO(), template)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotEmpty(t, template.ID)
+	repo.AssertExpectations(t)
+}
+
+func TestDryRunParserTemplate(t *testing.T) {
+	svc := service.NewEmailParserTemplateService(new(mocks.EmailParserTemplateRepositoryMock))
+
+	// WHEN
+	result, err := svc.DryRun(contactFormTemplate(), "Contacto", "web@example.com", "Email: ana@example.com\nRef: P-1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, result.Matches)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "ana@example.com", result.Lead.Email)
+	assert.Equal(t, "P-1", result.Lead.PropertyReference)
+}
+
+func TestDryRunParserTemplate_ShowsPartialLead(t *testing.T) {
+	svc := service.NewEmailParserTemplateService(new(mocks.EmailParserTemplateRepositoryMock))
+
+	// WHEN
+	result, err := svc.DryRun(contactFormTemplate(), "Contacto", "other@portal.com", "Email: ana@example.com")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.False(t, result.Matches)
+	assert.Contains(t, result.Error, "property reference")
+	assert.Equal(t, "ana@example.com", result.Lead.Email)
+}
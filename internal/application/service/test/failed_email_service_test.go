@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// processorFunc lets a test decide the outcome of processing an email
+type processorFunc func(ctx context.Context, msg email.ParsedEmail) error
+
+func (f processorFunc) ProcessEmail(ctx context.Context, msg email.ParsedEmail) error {
+	return f(ctx, msg)
+}
+
+// everyInbox processes the emails of every inbox with f
+func everyInbox(f processorFunc) service.EmailProcessors {
+	return func(ctx context.Context, companyID, configID string) (service.EmailProcessor, error) {
+		return f, nil
+	}
+}
+
+func TestProcessEmail_UnknownReferenceIsClassified(t *testing.T) {
+	// GIVEN
+	propertyRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewEmailLeadService(propertyRepo, new(mocks.LeadRepositoryMock), nil, nil, nil, nil, nil, email.Config{})
+	propertyRepo.On("FindByReference", mock.Anything, "V-1020").Return(nil, nil)
+
+	msg := email.ParsedEmail{
+		From:    "enquiries@kyero.com",
+		Subject: "New enquiry",
+		Body:    "<p>Email: ana@example.com</p><p>Agent ref: V-1020</p>",
+	}
+
+	// WHEN
+	err := svc.ProcessEmail(context.TODO(), msg)
+
+	// THEN
+	category, reference := service.ClassifyEmailFailure(err)
+	assert.Equal(t, entity.EmailFailureUnknownReference, category)
+	assert.Equal(t, "V-1020", reference)
+}
+
+func TestProcessEmail_UnsupportedSourceIsClassified(t *testing.T) {
+	svc := service.NewEmailLeadService(new(mocks.PropertyRepositoryMock), new(mocks.LeadRepositoryMock), nil, nil, nil, nil, nil, email.Config{})
+
+	err := svc.ProcessEmail(context.TODO(), email.ParsedEmail{From: "newsletter@shop.com", Subject: "Ofertas"})
+
+	category, _ := service.ClassifyEmailFailure(err)
+	assert.Equal(t, entity.EmailFailureUnsupportedSource, category)
+	assert.Contains(t, err.Error(), "unsupported email source")
+}
+
+func TestRecordFailedEmail_New(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	svc := service.NewFailedEmailService(repo, nil)
+	msg := email.ParsedEmail{MessageID: "m1", From: "a@kyero.com", Subject: "Enquiry", Body: "raw", Date: time.Now()}
+
+	repo.On("FindByMessageID", mock.Anything, "C1", "m1").Return(nil, nil)
+	var saved *entity.FailedEmail
+	repo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(1).(*entity.FailedEmail) }).
+		Return(nil)
+
+	// WHEN
+	err := svc.Record(context.TODO(), "C1", "IN1", msg, &service.UnknownReferenceError{Reference: "V-1"})
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, entity.EmailFailureUnknownReference, saved.Category)
+	assert.Equal(t, "V-1", saved.PropertyReference)
+	assert.Equal(t, "raw", saved.Body)
+	assert.Equal(t, 1, saved.Attempts)
+	if assert.NotNil(t, saved.ConfigID) {
+		assert.Equal(t, "IN1", *saved.ConfigID)
+	}
+}
+
+func TestRecordFailedEmail_AgainCountsAttempts(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	svc := service.NewFailedEmailService(repo, nil)
+	existing := &entity.FailedEmail{ID: "F1", CompanyID: "C1", MessageID: "m1", Attempts: 2, Category: entity.EmailFailureUnsupportedSource}
+
+	repo.On("FindByMessageID", mock.Anything, "C1", "m1").Return(existing, nil)
+	repo.On("Update", mock.Anything, existing).Return(nil)
+
+	// WHEN
+	err := svc.Record(context.TODO(), "C1", "IN1", email.ParsedEmail{MessageID: "m1"}, errors.New("connection reset"))
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, 3, existing.Attempts)
+	assert.Equal(t, entity.EmailFailureInternal, existing.Category)
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestReprocessFailedEmail_ResolvesOnSuccess(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	var processed email.ParsedEmail
+	svc := service.NewFailedEmailService(repo, everyInbox(func(ctx context.Context, msg email.ParsedEmail) error {
+		processed = msg
+		return nil
+	}))
+	failed := &entity.FailedEmail{ID: "F1", CompanyID: "C1", MessageID: "m1", Subject: "Enquiry", Body: "raw", Attempts: 1}
+
+	repo.On("FindByID", mock.Anything, "F1").Return(failed, nil)
+	repo.On("Update", mock.Anything, failed).Return(nil)
+
+	// WHEN
+	result, err := svc.Reprocess(context.TODO(), "F1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotNil(t, result.ResolvedAt)
+	assert.Equal(t, 2, result.Attempts)
+	assert.Equal(t, "raw", processed.Body)
+}
+
+func TestReprocessFailedEmail_UsesTheInboxItCameIn(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	configID := "IN1"
+	var inbox string
+	svc := service.NewFailedEmailService(repo, func(ctx context.Context, companyID, configID string) (service.EmailProcessor, error) {
+		inbox = companyID + "/" + configID
+		return processorFunc(func(ctx context.Context, msg email.ParsedEmail) error { return nil }), nil
+	})
+	failed := &entity.FailedEmail{ID: "F1", CompanyID: "C1", ConfigID: &configID, MessageID: "m1", Attempts: 1}
+
+	repo.On("FindByID", mock.Anything, "F1").Return(failed, nil)
+	repo.On("Update", mock.Anything, failed).Return(nil)
+
+	// WHEN
+	_, err := svc.Reprocess(context.TODO(), "F1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "C1/IN1", inbox)
+}
+
+func TestReprocessFailedEmail_AlreadyResolved(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	svc := service.NewFailedEmailService(repo, nil)
+	resolvedAt := time.Now()
+	repo.On("FindByID", mock.Anything, "F1").Return(&entity.FailedEmail{ID: "F1", ResolvedAt: &resolvedAt}, nil)
+
+	// WHEN
+	_, err := svc.Reprocess(context.TODO(), "F1")
+
+	// THEN
+	assert.ErrorContains(t, err, "already resolved")
+}
+
+func TestRetryUnknownReference(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.FailedEmailRepositoryMock)
+	svc := service.NewFailedEmailService(repo, everyInbox(func(ctx context.Context, msg email.ParsedEmail) error {
+		if msg.MessageID == "broken" {
+			return errors.New("failed to parse email: could not extract email")
+		}
+		return nil
+	}))
+
+	repo.On("FindUnresolvedByReference", mock.Anything, "V-1").Return([]entity.FailedEmail{
+		{ID: "F1", CompanyID: "C1", MessageID: "ok", Category: entity.EmailFailureUnknownReference},
+		{ID: "F2", CompanyID: "C1", MessageID: "broken", Category: entity.EmailFailureUnknownReference},
+	}, nil)
+	repo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	resolved, err := svc.RetryUnknownReference(context.TODO(), "V-1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	repo.AssertNumberOfCalls(t, "Update", 2)
+}
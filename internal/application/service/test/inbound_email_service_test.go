@@ -0,0 +1,264 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveInboundEmail_StoresAttachments(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InboundEmailRepositoryMock)
+	storage := new(mocks.PrivateStorageServiceMock)
+	svc := service.NewInboundEmailService(repo, storage)
+	msg := email.ParsedEmail{
+		MessageID:   "m1",
+		From:        "enquiries@kyero.com",
+		Subject:     "New enquiry",
+		TextBody:    "Hello",
+		HTMLBody:    "<p>Hello</p>",
+		Headers:     map[string][]string{"X-Portal": {"kyero"}},
+		Date:        time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+		Attachments: []email.Attachment{{Filename: "plan.pdf", ContentType: "application/pdf", Data: []byte("%PDF")}},
+	}
+
+	repo.On("FindByMessageID", mock.Anything, "C1", "m1").Return(nil, nil)
+	storage.On("Save", mock.Anything, mock.Anything).Return("0b8e1a64-5f0e-4d3c-9a47-3c1f5d2e7b10", nil)
+	repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	inbound, err := svc.Archive(context.TODO(), "C1", "CFG1", msg)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Hello</p>", inbound.HTMLBody)
+	assert.Equal(t, []string{"kyero"}, inbound.Headers.Data()["X-Portal"])
+	assert.Equal(t, "CFG1", *inbound.ConfigID)
+	require.Len(t, inbound.Attachments, 1)
+	assert.Equal(t, "0b8e1a64-5f0e-4d3c-9a47-3c1f5d2e7b10", inbound.Attachments[0].StorageKey)
+	assert.Empty(t, inbound.Attachments[0].URL, "no public URL")
+	assert.Equal(t, 4, inbound.Attachments[0].Size)
+}
+
+func TestArchiveInboundEmail_AlreadyArchivedIsReused(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InboundEmailRepositoryMock)
+	storage := new(mocks.PrivateStorageServiceMock)
+	svc := service.NewInboundEmailService(repo, storage)
+	existing := &entity.InboundEmail{ID: "IE1", CompanyID: "C1", MessageID: "m1"}
+
+	repo.On("FindByMessageID", mock.Anything, "C1", "m1").Return(existing, nil)
+
+	// WHEN
+	inbound, err := svc.Archive(context.TODO(), "C1", "", email.ParsedEmail{MessageID: "m1", Body: "raw"})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Same(t, existing, inbound)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	storage.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestProcessEmail_LinksOriginalEmailToLeadAndConversation(t *testing.T) {
+	// GIVEN
+	propertyRepo := new(mocks.PropertyRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	messageRepo := new(mocks.MessageRepositoryMock)
+	inboundRepo := new(mocks.InboundEmailRepositoryMock)
+	inboundEmails := service.NewInboundEmailService(inboundRepo, new(mocks.PrivateStorageServiceMock))
+	svc := service.NewEmailLeadService(propertyRepo, leadRepo, nil, messageRepo, inboundEmails, nil, nil, email.Config{DefaultCompanyID: "C1"})
+	date := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	msg := email.ParsedEmail{
+		MessageID: "m1",
+		From:      "enquiries@kyero.com",
+		Subject:   "New enquiry",
+		Body:      "<p>Email: ana@example.com</p><p>Agent ref: V-1020</p>",
+		Date:      date,
+	}
+
+	inboundRepo.On("FindByMessageID", mock.Anything, "C1", "m1").Return(nil, nil)
+	inboundRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(1).(*entity.InboundEmail).ID = "IE1" }).
+		Return(nil)
+	propertyRepo.On("FindByReference", mock.Anything, "V-1020").Return(&entity.Property{ID: "P1", Reference: "V-1020"}, nil)
+	leadRepo.On("FindByEmail", mock.Anything, "C1", "ana@example.com").Return(nil, nil)
+	leadRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(1).(*entity.Lead).ID = "L1" }).
+		Return(nil)
+	var message *entity.Message
+	messageRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { message = args.Get(1).(*entity.Message) }).
+		Return(nil)
+	var result *entity.InboundEmail
+	inboundRepo.On("UpdateResult", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { result = args.Get(1).(*entity.InboundEmail) }).
+		Return(nil)
+
+	// WHEN
+	err := svc.ProcessEmail(context.TODO(), msg)
+
+	// THEN
+	require.NoError(t, err)
+	require.NotNil(t, message)
+	assert.Equal(t, "L1", message.LeadID)
+	assert.Equal(t, entity.SenderLead, message.SenderType)
+	assert.Equal(t, string(entity.EmailSourceKyero), message.Channel)
+	assert.Equal(t, "P1", *message.PropertyID)
+	assert.Equal(t, date, message.Timestamp)
+	assert.Equal(t, "IE1", *message.InboundEmailID)
+
+	require.NotNil(t, result)
+	assert.Equal(t, entity.InboundEmailParsed, result.ParseStatus)
+	assert.Equal(t, string(entity.EmailSourceKyero), result.Source)
+	assert.Equal(t, "V-1020", result.ParsedLead.Data().PropertyReference)
+	assert.Equal(t, "L1", *result.LeadID)
+	assert.Equal(t, message.ID, *result.ConversationMessageID)
+}
+
+func TestProcessEmail_FailureIsRecordedOnOriginalEmail(t *testing.T) {
+	// GIVEN
+	inboundRepo := new(mocks.InboundEmailRepositoryMock)
+	inboundEmails := service.NewInboundEmailService(inboundRepo, new(mocks.PrivateStorageServiceMock))
+	svc := service.NewEmailLeadService(new(mocks.PropertyRepositoryMock), new(mocks.LeadRepositoryMock), nil, nil, inboundEmails, nil, nil, email.Config{DefaultCompanyID: "C1"})
+
+	inboundRepo.On("FindByMessageID", mock.Anything, "C1", "m2").Return(nil, nil)
+	inboundRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	var result *entity.InboundEmail
+	inboundRepo.On("UpdateResult", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { result = args.Get(1).(*entity.InboundEmail) }).
+		Return(nil)
+
+	// WHEN
+	err := svc.ProcessEmail(context.TODO(), email.ParsedEmail{MessageID: "m2", From: "newsletter@shop.com", Subject: "Ofertas"})
+
+	// THEN
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, string(entity.EmailFailureUnsupportedSource), result.ParseStatus)
+	assert.Nil(t, result.LeadID)
+	assert.Nil(t, result.ParsedLead)
+}
+
+func TestGetLeadEmail_OtherLeadIsNotFound(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InboundEmailRepositoryMock)
+	svc := service.NewInboundEmailService(repo, nil)
+	leadID := "L1"
+	repo.On("FindByID", mock.Anything, "IE1").Return(&entity.InboundEmail{ID: "IE1", LeadID: &leadID}, nil)
+
+	// WHEN
+	_, err := svc.GetForLead(context.TODO(), "L2", "IE1")
+
+	// THEN
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestProcessEmail_RepeatContactGoesToConversationNotNotes(t *testing.T) {
+	// GIVEN
+	propertyRepo := new(mocks.PropertyRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	messageRepo := new(mocks.MessageRepositoryMock)
+	svc := service.NewEmailLeadService(propertyRepo, leadRepo, nil, messageRepo, nil, nil, nil, email.Config{DefaultCompanyID: "C1"})
+	oldProperty := "P0"
+	existing := &entity.Lead{ID: "L1", Email: "ana@example.com", Notes: "Prefers mornings", PropertyID: &oldProperty, Status: entity.LeadStatusContacted}
+
+	propertyRepo.On("FindByReference", mock.Anything, "V-1020").Return(&entity.Property{ID: "P1", Reference: "V-1020"}, nil)
+	leadRepo.On("FindByEmail", mock.Anything, "C1", "ana@example.com").Return(existing, nil)
+	leadRepo.On("Update", mock.Anything, existing).Return(nil)
+	var message *entity.Message
+	messageRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { message = args.Get(1).(*entity.Message) }).
+		Return(nil)
+
+	// WHEN
+	err := svc.ProcessEmail(context.TODO(), email.ParsedEmail{
+		MessageID: "m3",
+		From:      "enquiries@kyero.com",
+		Subject:   "New enquiry",
+		Body:      "<p>Email: ana@example.com</p><p>Agent ref: V-1020</p>",
+	})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "Prefers mornings", existing.Notes)
+	assert.Equal(t, "P1", *existing.PropertyID)
+	require.NotNil(t, message)
+	assert.Equal(t, "P1", *message.PropertyID)
+	assert.False(t, message.Timestamp.IsZero())
+}
+
+func TestProcessEmail_RelayedEmailMatchesLeadByPhone(t *testing.T) {
+	// GIVEN
+	propertyRepo := new(mocks.PropertyRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewEmailLeadService(propertyRepo, leadRepo, nil, nil, nil, nil, nil, email.Config{DefaultCompanyID: "C1"})
+	existing := &entity.Lead{ID: "L1", Email: "ana@example.com", Phone: "+34 600 123 456", Status: entity.LeadStatusContacted}
+
+	propertyRepo.On("FindByReference", mock.Anything, "V-1020").Return(&entity.Property{ID: "P1", Reference: "V-1020"}, nil)
+	leadRepo.On("FindByEmail", mock.Anything, "C1", "relay-981@kyero.com").Return(nil, nil)
+	leadRepo.On("FindByPhone", mock.Anything, "C1", "600123456").Return(existing, nil)
+	leadRepo.On("Update", mock.Anything, existing).Return(nil)
+
+	// WHEN
+	outcome, err := svc.ProcessEmailOutcome(context.TODO(), email.ParsedEmail{
+		MessageID: "m4",
+		From:      "enquiries@kyero.com",
+		Subject:   "New enquiry",
+		Body:      "<p>Email: relay-981@kyero.com</p><p>Phone: 600123456</p><p>Agent ref: V-1020</p>",
+	})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, service.LeadOutcomeUpdated, outcome)
+	assert.Equal(t, "ana@example.com", existing.Email)
+	leadRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestProcessEmail_RepeatContactMovesOnlyNewLeads(t *testing.T) {
+	for _, status := range []entity.LeadStatus{entity.LeadStatusNew, entity.LeadStatusClosed} {
+		t.Run(string(status), func(t *testing.T) {
+			// GIVEN
+			propertyRepo := new(mocks.PropertyRepositoryMock)
+			leadRepo := new(mocks.LeadRepositoryMock)
+			changes := new(mocks.LeadStatusChangeRepositoryMock)
+			statuses := service.NewLeadStatusService(leadRepo, changes)
+			svc := service.NewEmailLeadService(propertyRepo, leadRepo, nil, nil, nil, statuses, nil, email.Config{DefaultCompanyID: "C1"})
+			existing := &entity.Lead{ID: "L1", CompanyID: "C1", Email: "ana@example.com", Status: status}
+
+			propertyRepo.On("FindByReference", mock.Anything, "V-1020").Return(&entity.Property{ID: "P1", Reference: "V-1020"}, nil)
+			leadRepo.On("FindByEmail", mock.Anything, "C1", "ana@example.com").Return(existing, nil)
+			leadRepo.On("Update", mock.Anything, existing).Return(nil)
+			var change *entity.LeadStatusChange
+			changes.On("Record", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { change = args.Get(1).(*entity.LeadStatusChange) }).
+				Return(nil)
+
+			// WHEN
+			err := svc.ProcessEmail(context.TODO(), email.ParsedEmail{
+				MessageID: "m5",
+				From:      "enquiries@kyero.com",
+				Subject:   "New enquiry",
+				Body:      "<p>Email: ana@example.com</p><p>Agent ref: V-1020</p>",
+			})
+
+			// THEN
+			require.NoError(t, err)
+			if status == entity.LeadStatusClosed {
+				assert.Equal(t, entity.LeadStatusClosed, existing.Status)
+				assert.Nil(t, change)
+				return
+			}
+			assert.Equal(t, entity.LeadStatusContacted, existing.Status)
+			require.NotNil(t, change)
+			assert.Nil(t, change.AgentID)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type invitationSenderStub struct {
+	to, companyName, token string
+	err                    error
+}
+
+func (s *invitationSenderStub) SendInvitationEmail(to, companyName, token string) error {
+	s.to, s.companyName, s.token = to, companyName, token
+	return s.err
+}
+
+func TestInvite_StoresHashAndSendsToken(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	companyRepo := new(mocks.CompanyRepositoryMock)
+	sender := &invitationSenderStub{}
+	svc := service.NewInvitationService(repo, agentRepo, companyRepo, sender)
+	ctx := context.TODO()
+
+	agentRepo.On("EmailInUse", ctx, "new@acme.com").Return(false, nil)
+	companyRepo.On("FindByID", ctx, "C1").Return(&entity.Company{ID: "C1", Name: "Acme"}, nil)
+	repo.On("RevokePendingForEmail", ctx, "C1", "new@acme.com").Return(nil)
+	repo.On("Create", ctx, mock.AnythingOfType("*entity.Invitation")).Return(nil)
+
+	// WHEN
+	invitation, err := svc.Invite(ctx, "C1", " New@Acme.com ", entity.RoleManager, "A1", entity.RoleAdmin)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "new@acme.com", invitation.Email)
+	assert.Equal(t, entity.RoleManager, invitation.Role)
+	assert.Equal(t, "Acme", sender.companyName)
+	assert.Equal(t, security.HashOpaqueToken(sender.token), invitation.TokenHash)
+	assert.True(t, invitation.IsPending())
+	repo.AssertExpectations(t)
+}
+
+func TestInvite_OnlyOwnerCanInviteOwner(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	svc := service.NewInvitationService(repo, new(mocks.AgentRepositoryMock), new(mocks.CompanyRepositoryMock), &invitationSenderStub{})
+
+	// WHEN
+	_, err := svc.Invite(context.TODO(), "C1", "new@acme.com", entity.RoleOwner, "A1", entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestInvite_EmailOfAnotherCompanyIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewInvitationService(repo, agentRepo, new(mocks.CompanyRepositoryMock), &invitationSenderStub{})
+	agentRepo.On("EmailInUse", mock.Anything, "taken@other.com").Return(true, nil)
+
+	// WHEN
+	_, err := svc.Invite(context.TODO(), "C1", "taken@other.com", entity.RoleAgent, "A1", entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, err, "already exists")
+	agentRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "Create")
+}
+
+func TestInvite_EmailSendFailureIsReported(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	companyRepo := new(mocks.CompanyRepositoryMock)
+	svc := service.NewInvitationService(repo, agentRepo, companyRepo, &invitationSenderStub{err: errors.New("smtp down")})
+
+	agentRepo.On("EmailInUse", mock.Anything, "new@acme.com").Return(false, nil)
+	companyRepo.On("FindByID", mock.Anything, "C1").Return(&entity.Company{ID: "C1", Name: "Acme"}, nil)
+	repo.On("RevokePendingForEmail", mock.Anything, "C1", "new@acme.com").Return(nil)
+	repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	_, err := svc.Invite(context.TODO(), "C1", "new@acme.com", entity.RoleAgent, "A1", entity.RoleAdmin)
+
+	// THEN
+	assert.ErrorContains(t, err, "failed to send invitation email")
+}
+
+func TestAccept_CreatesAgentInInvitingCompany(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewInvitationService(repo, agentRepo, new(mocks.CompanyRepositoryMock), &invitationSenderStub{})
+	invitation := &entity.Invitation{ID: "I1", CompanyID: "C1", Email: "new@acme.com", Role: entity.RoleManager, ExpiresAt: time.Now().Add(time.Hour)}
+
+	repo.On("FindByTokenHash", mock.Anything, security.HashOpaqueToken("tok")).Return(invitation, nil)
+	agentRepo.On("EmailInUse", mock.Anything, "new@acme.com").Return(false, nil)
+	agentRepo.On("Create", mock.MatchedBy(func(ctx context.Context) bool {
+		companyID, _ := tenant.CompanyID(ctx)
+		return companyID == "C1"
+	}), mock.AnythingOfType("*entity.Agent")).Return(nil)
+	repo.On("MarkAsAccepted", mock.Anything, "I1").Return(nil)
+
+	// WHEN
+	agent, err := svc.Accept(context.TODO(), "tok", "New Agent", "", "secret-password")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "C1", agent.CompanyID)
+	assert.Equal(t, entity.RoleManager, agent.Role)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(agent.Password), []byte("secret-password")))
+	repo.AssertExpectations(t)
+	agentRepo.AssertExpectations(t)
+}
+
+func TestAccept_UsedInvitationIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.InvitationRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewInvitationService(repo, agentRepo, new(mocks.CompanyRepositoryMock), &invitationSenderStub{})
+	acceptedAt := time.Now()
+	invitation := &entity.Invitation{ID: "I1", CompanyID: "C1", Email: "new@acme.com", ExpiresAt: time.Now().Add(time.Hour), AcceptedAt: &acceptedAt}
+
+	repo.On("FindByTokenHash", mock.Anything, security.HashOpaqueToken("tok")).Return(invitation, nil)
+
+	// WHEN
+	_, err := svc.Accept(context.TODO(), "tok", "New Agent", "", "secret-password")
+
+	// THEN
+	assert.ErrorIs(t, err, service.ErrInvalidInvitation)
+	agentRepo.AssertNotCalled(t, "Create")
+}
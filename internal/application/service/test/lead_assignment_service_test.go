@@ -0,0 +1,147 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func assignedTo(agentID string) interface{} {
+	return mock.MatchedBy(func(a *entity.LeadAssignment) bool {
+		return a.ToAgentID != nil && *a.ToAgentID == agentID
+	})
+}
+
+func TestAutoAssign_Strategies(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour)
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	workloads := []entity.AgentWorkload{
+		{AgentID: "A1", OpenLeads: 5, LastAssignedAt: &lastWeek},
+		{AgentID: "A2", OpenLeads: 1, LastAssignedAt: &yesterday},
+		{AgentID: "A3", OpenLeads: 1, LastAssignedAt: &lastWeek},
+	}
+	owner, creator, gone := "A2", "A3", "A9"
+
+	cases := []struct {
+		name     string
+		rules    []entity.LeadAssignmentRule
+		property *entity.Property
+		want     string
+	}{
+		{"property creator", []entity.LeadAssignmentRule{{Strategy: entity.AssignPropertyCreator}}, &entity.Property{CreatedByAgentID: &creator}, "A3"},
+		{"owner of the zone", []entity.LeadAssignmentRule{{Strategy: entity.AssignOwner, AgentID: &owner, Zone: "marbella"}}, &entity.Property{Zone: "Marbella"}, "A2"},
+		{"round robin", []entity.LeadAssignmentRule{{Strategy: entity.AssignRoundRobin}}, nil, "A1"},
+		{"least loaded, ties by round robin", []entity.LeadAssignmentRule{{Strategy: entity.AssignLeastLoaded}}, nil, "A3"},
+		{"zone of another rule", []entity.LeadAssignmentRule{
+			{Strategy: entity.AssignOwner, AgentID: &owner, Zone: "Estepona"},
+			{Strategy: entity.AssignRoundRobin},
+		}, &entity.Property{Zone: "Marbella"}, "A1"},
+		{"creator no longer an agent", []entity.LeadAssignmentRule{
+			{Strategy: entity.AssignPropertyCreator},
+			{Strategy: entity.AssignLeastLoaded},
+		}, &entity.Property{CreatedByAgentID: &gone}, "A3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			repo := new(mocks.LeadAssignmentRepositoryMock)
+			svc := service.NewLeadAssignmentService(repo, new(mocks.LeadRepositoryMock), new(mocks.PropertyRepositoryMock))
+			lead := &entity.Lead{ID: "L1", CompanyID: "C1", Property: tc.property}
+
+			repo.On("FindEnabledRules", mock.Anything).Return(tc.rules, nil)
+			repo.On("Workloads", mock.Anything).Return(workloads, nil)
+			repo.On("Assign", mock.Anything, assignedTo(tc.want)).Return(nil)
+
+			// WHEN
+			err := svc.AutoAssign(context.TODO(), lead, "")
+
+			// THEN
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, *lead.AssignedAgentID)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAutoAssign_FallsBackToDefaultAgent(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	svc := service.NewLeadAssignmentService(repo, new(mocks.LeadRepositoryMock), new(mocks.PropertyRepositoryMock))
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Zone: "Madrid"}
+	owner := "A2"
+
+	repo.On("FindEnabledRules", mock.Anything).Return([]entity.LeadAssignmentRule{
+		{Strategy: entity.AssignOwner, AgentID: &owner, Zone: "Marbella"},
+	}, nil)
+	repo.On("Workloads", mock.Anything).Return([]entity.AgentWorkload{{AgentID: "A2"}}, nil)
+	repo.On("Assign", mock.Anything, mock.MatchedBy(func(a *entity.LeadAssignment) bool {
+		return *a.ToAgentID == "DEFAULT" && a.RuleID == nil
+	})).Return(nil)
+
+	// WHEN
+	err := svc.AutoAssign(context.TODO(), lead, "DEFAULT")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "DEFAULT", *lead.AssignedAgentID)
+	repo.AssertExpectations(t)
+}
+
+func TestReassign_RecordsWhoDidIt(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadAssignmentService(repo, leadRepo, nil)
+	previous := "A1"
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", AssignedAgentID: &previous}
+
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(lead, nil)
+	repo.On("Workloads", mock.Anything).Return([]entity.AgentWorkload{{AgentID: "A1"}, {AgentID: "A2"}}, nil)
+	repo.On("Assign", mock.Anything, assignedTo("A2")).Return(nil)
+
+	// WHEN
+	assignment, err := svc.Reassign(context.TODO(), "L1", "A2", "Speaks German", "M1")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "A1", *assignment.FromAgentID)
+	assert.Equal(t, "M1", *assignment.ByAgentID)
+	assert.Equal(t, "A2", *lead.AssignedAgentID)
+}
+
+func TestReassign_UnknownAgent(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadAssignmentService(repo, leadRepo, nil)
+
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(&entity.Lead{ID: "L1", CompanyID: "C1"}, nil)
+	repo.On("Workloads", mock.Anything).Return([]entity.AgentWorkload{{AgentID: "A1"}}, nil)
+
+	// WHEN
+	_, err := svc.Reassign(context.TODO(), "L1", "OTHER-COMPANY-AGENT", "", "M1")
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid agent")
+	repo.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything)
+}
+
+func TestCreateAssignmentRule_OwnerNeedsAgent(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadAssignmentRepositoryMock)
+	svc := service.NewLeadAssignmentService(repo, nil, nil)
+
+	// WHEN
+	err := svc.CreateRule(context.TODO(), &entity.LeadAssignmentRule{Name: "Marbella", Strategy: entity.AssignOwner, Zone: "Marbella"})
+
+	// THEN
+	assert.ErrorContains(t, err, "required")
+	repo.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestRecomputeLeadScore_EngagedLeadWithDefaultWeights(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadScoreRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadScoreService(repo, leadRepo)
+	yesterday := time.Now().Add(-24 * time.Hour)
+	lead := &entity.Lead{
+		ID: "L1", CompanyID: "C1", Name: "Ana", Email: "ana@example.com", Phone: "600123456",
+		Budget: 300000, Zone: "Marbella", PropertyType: "APARTMENT", Source: "Referral",
+	}
+
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(lead, nil)
+	repo.On("FindWeights", mock.Anything).Return(nil, nil)
+	repo.On("Signals", mock.Anything, lead, mock.Anything).Return(&entity.LeadScoreSignals{
+		LeadMessages: 5, LastLeadMessageAt: &yesterday, PresentationViews: 3, PropertiesInBudget: 4,
+	}, nil)
+	repo.On("SaveScore", mock.Anything, mock.MatchedBy(func(s *entity.LeadScore) bool {
+		return s.LeadID == "L1" && s.Score == 100
+	})).Return(nil)
+
+	// WHEN
+	score, err := svc.Recompute(context.TODO(), "L1")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, 100, score.Score)
+	assert.Equal(t, 100, lead.Score)
+	repo.AssertExpectations(t)
+}
+
+func TestRecomputeLeadScore_CompanyWeights(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadScoreRepositoryMock)
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadScoreService(repo, leadRepo)
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Email: "ana@example.com", Source: "Milanuncios"}
+
+	// Only the source counts, and this company rates Milanuncios highly
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(lead, nil)
+	repo.On("FindWeights", mock.Anything).Return(&entity.LeadScoreWeights{
+		Source:        10,
+		SourceQuality: datatypes.NewJSONType(map[string]float64{"milanuncios": 0.9}),
+	}, nil)
+	repo.On("Signals", mock.Anything, lead, mock.Anything).Return(&entity.LeadScoreSignals{}, nil)
+	repo.On("SaveScore", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	score, err := svc.Recompute(context.TODO(), "L1")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, 90, score.Score)
+	assert.Equal(t, 0.0, score.Factors.Recency)
+}
+
+func TestLeadScore_FactorsOfAColdLead(t *testing.T) {
+	// GIVEN
+	weights := entity.DefaultLeadScoreWeights()
+	now := time.Now()
+	twoMonthsAgo := now.AddDate(0, -2, 0)
+	lead := &entity.Lead{Name: "Ana", Email: "ana@example.com", Phone: "0", Budget: 150000, LastInteraction: &twoMonthsAgo}
+
+	// WHEN
+	score := weights.Score(lead, entity.LeadScoreSignals{LeadMessages: 1, PropertiesInBudget: 0}, now)
+
+	// THEN
+	assert.InDelta(t, 0.5, score.Factors.Completeness, 0.001)
+	assert.InDelta(t, 0.2, score.Factors.Frequency, 0.001)
+	assert.Greater(t, score.Factors.Recency, 0.0)
+	assert.Less(t, score.Factors.Recency, 0.5)
+	assert.Equal(t, 0.0, score.Factors.BudgetFit)
+	assert.Equal(t, 0.5, score.Factors.Source) // Unknown source
+	assert.Less(t, score.Score, 40)
+}
+
+func TestUpdateLeadScoreWeights_Invalid(t *testing.T) {
+	cases := map[string]entity.LeadScoreWeights{
+		"all zero":        {},
+		"negative":        {Completeness: -5, Source: 10},
+		"source above 1":  {Source: 10, SourceQuality: datatypes.NewJSONType(map[string]float64{"web": 2})},
+		"weight over 100": {Recency: 150},
+	}
+	for name, weights := range cases {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN
+			repo := new(mocks.LeadScoreRepositoryMock)
+			svc := service.NewLeadScoreService(repo, new(mocks.LeadRepositoryMock))
+
+			// WHEN
+			err := svc.UpdateWeights(context.TODO(), &weights)
+
+			// THEN
+			assert.ErrorContains(t, err, "invalid")
+			repo.AssertNotCalled(t, "SaveWeights", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateLead_NotifiesLeadActivity(t *testing.T) {
+	// GIVEN
+	leadRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(leadRepo, nil)
+	var notified []string
+	svc.OnLeadActivity(func(ctx context.Context, leadID string) { notified = append(notified, leadID) })
+
+	leadRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	err := svc.Update(context.TODO(), &entity.Lead{ID: "L1"})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, []string{"L1"}, notified)
+}
+
+func TestRecomputeAllLeadScores_InBatches(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LeadScoreRepositoryMock)
+	svc := service.NewLeadScoreService(repo, new(mocks.LeadRepositoryMock))
+	first := []entity.Lead{{ID: "L1", CompanyID: "C1", Source: "web"}, {ID: "L2", CompanyID: "C2", Source: "web"}}
+
+	repo.On("LeadsAfter", mock.Anything, "", mock.Anything).Return(first, nil)
+	repo.On("LeadsAfter", mock.Anything, "L2", mock.Anything).Return([]entity.Lead{}, nil)
+	repo.On("SignalsByLead", mock.Anything, first, mock.Anything).Return(map[string]entity.LeadScoreSignals{}, nil)
+	repo.On("FindWeights", mock.Anything).Return(&entity.LeadScoreWeights{Source: 10}, nil)
+	repo.On("SaveScores", mock.Anything, mock.MatchedBy(func(scores []entity.LeadScore) bool {
+		return len(scores) == 2 && scores[0].LeadID == "L1" && scores[1].LeadID == "L2"
+	})).Return(nil)
+
+	// WHEN
+	scored, err := svc.RecomputeAll(context.TODO())
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, 2, scored)
+	// Once per company, not per lead
+	repo.AssertNumberOfCalls(t, "FindWeights", 2)
+	repo.AssertNotCalled(t, "Signals", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+}
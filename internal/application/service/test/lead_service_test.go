@@ -14,17 +14,18 @@ import (
 func TestCreateLead(t *testing.T) {
 	// GIVEN
 	mockRepo := new(mocks.LeadRepositoryMock)
-	svc := service.NewLeadService(mockRepo)
+	svc := service.NewLeadService(mockRepo, nil)
 	ctx := context.TODO()
 
 	lead := &entity.Lead{
-		Email: "lead@test.com",
-		Name:  "Test Lead",
+		Email:     "lead@test.com",
+		Name:      "Test Lead",
+		CompanyID: "C1",
 	}
 
 	// Mocking behavior
-	mockRepo.On("FindByEmail", ctx, "lead@test.com").Return(nil, nil)
-	mockRepo.On("Create", mock.MatchedBy(func(l *entity.Lead) bool {
+	mockRepo.On("FindByEmail", ctx, "C1", "lead@test.com").Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(l *entity.Lead) bool {
 		return l.Email == "lead@test.com" && l.ID != ""
 	})).Return(nil)
 
@@ -44,13 +45,13 @@ func TestCreateLead(t *testing.T) {
 func TestCreateLead_AlreadyExists(t *testing.T) {
 	// GIVEN
 	mockRepo := new(mocks.LeadRepositoryMock)
-	svc := service.NewLeadService(mockRepo)
+	svc := service.NewLeadService(mockRepo, nil)
 	ctx := context.TODO()
 
-	lead := &entity.Lead{Email: "existing@lead.com"}
+	lead := &entity.Lead{Email: "existing@lead.com", CompanyID: "C1"}
 	existingLead := &entity.Lead{ID: "L1", Email: "existing@lead.com"}
 
-	mockRepo.On("FindByEmail", ctx, "existing@lead.com").Return(existingLead, nil)
+	mockRepo.On("FindByEmail", ctx, "C1", "existing@lead.com").Return(existingLead, nil)
 
 	// WHEN
 	result, created, err := svc.Create(ctx, lead)
@@ -61,3 +62,154 @@ func TestCreateLead_AlreadyExists(t *testing.T) {
 	assert.Equal(t, existingLead, result)
 	mockRepo.AssertNotCalled(t, "Create")
 }
+
+func TestCreateLead_SamePhoneIsExistingLead(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+	ctx := context.TODO()
+
+	lead := &entity.Lead{Email: "Ana.Relay@Portal.com", Phone: "600 12 34 56", CompanyID: "C1"}
+	existingLead := &entity.Lead{ID: "L1", Email: "ana@example.com", Phone: "+34600123456"}
+
+	mockRepo.On("FindByEmail", ctx, "C1", "ana.relay@portal.com").Return(nil, nil)
+	mockRepo.On("FindByPhone", ctx, "C1", "600 12 34 56").Return(existingLead, nil)
+
+	// WHEN
+	result, created, err := svc.Create(ctx, lead)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, existingLead, result)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"+34 600-12-34-56":   "+34600123456",
+		"0034 600123456":     "+34600123456",
+		"600 12 34 56":       "+34600123456",
+		"(+44) 20 7946 0958": "+442079460958",
+		"0":                  "",
+		"":                   "",
+	}
+	for phone, want := range cases {
+		assert.Equal(t, want, entity.NormalizePhone(phone), phone)
+	}
+}
+
+func TestFindLeadDuplicates_TellsWhatMatched(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+	ctx := context.TODO()
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Email: "ana@example.com", Phone: "600123456"}
+
+	mockRepo.On("FindByID", ctx, "L1").Return(lead, nil)
+	mockRepo.On("FindDuplicates", ctx, lead).Return([]entity.Lead{
+		{ID: "L2", Email: "ANA@example.com", Phone: "+34 600 123 456"},
+		{ID: "L3", Email: "relay-981@idealista.com", Phone: "0034600123456"},
+	}, nil)
+
+	// WHEN
+	duplicates, err := svc.FindDuplicates(ctx, "L1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Len(t, duplicates, 2)
+	assert.Equal(t, []string{"email", "phone"}, duplicates[0].MatchedOn)
+	assert.Equal(t, []string{"phone"}, duplicates[1].MatchedOn)
+}
+
+func TestMergeLeads_FoldsDuplicateIntoSurvivor(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+	ctx := context.TODO()
+	property := "P2"
+	survivor := &entity.Lead{ID: "L1", Email: "ana@example.com", Phone: "600123456", Notes: "Prefers mornings"}
+	duplicate := &entity.Lead{ID: "L2", Email: "relay-981@idealista.com", Phone: "+34 600 123 456", Zone: "Marbella", Budget: 450000, PropertyID: &property, Notes: "Has a dog"}
+
+	mockRepo.On("FindByID", ctx, "L1").Return(survivor, nil)
+	mockRepo.On("FindByID", ctx, "L2").Return(duplicate, nil)
+	mockRepo.On("Merge", ctx, survivor, "L2").Return(nil)
+
+	// WHEN
+	merged, err := svc.Merge(ctx, "L1", "L2")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "ana@example.com", merged.Email)
+	assert.Equal(t, "Marbella", merged.Zone)
+	assert.Equal(t, float64(450000), merged.Budget)
+	assert.Equal(t, "P2", *merged.PropertyID)
+	assert.Equal(t, "Prefers mornings\n\nHas a dog\n\nAlso reachable at relay-981@idealista.com", merged.Notes)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMergeLeads_IntoItselfIsInvalid(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+
+	// WHEN
+	_, err := svc.Merge(context.TODO(), "L1", "L1")
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid")
+	mockRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateLead_AssignsByCompanyRules(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	assignmentRepo := new(mocks.LeadAssignmentRepositoryMock)
+	svc := service.NewLeadService(mockRepo, service.NewLeadAssignmentService(assignmentRepo, mockRepo, nil))
+	lead := &entity.Lead{Email: "lead@test.com", CompanyID: "C1"}
+
+	mockRepo.On("FindByEmail", mock.Anything, "C1", "lead@test.com").Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, lead).Return(nil)
+	assignmentRepo.On("FindEnabledRules", mock.Anything).Return([]entity.LeadAssignmentRule{{Strategy: entity.AssignRoundRobin}}, nil)
+	assignmentRepo.On("Workloads", mock.Anything).Return([]entity.AgentWorkload{{AgentID: "A1"}}, nil)
+	assignmentRepo.On("Assign", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	createdLead, created, err := svc.Create(context.TODO(), lead)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "A1", *createdLead.AssignedAgentID)
+	assignmentRepo.AssertExpectations(t)
+}
+
+func TestSearchLeads_CapsTheLimit(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+
+	mockRepo.On("Search", mock.Anything, mock.MatchedBy(func(f entity.LeadFilter) bool {
+		return f.Limit == entity.LeadPageMaxLimit
+	})).Return(&entity.LeadPage{}, nil)
+
+	// WHEN
+	_, err := svc.Search(context.TODO(), entity.LeadFilter{Limit: 10000})
+
+	// THEN
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchLeads_UnknownSortFieldIsInvalid(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.LeadRepositoryMock)
+	svc := service.NewLeadService(mockRepo, nil)
+
+	// WHEN
+	_, err := svc.Search(context.TODO(), entity.LeadFilter{Sort: []entity.LeadSort{{Field: "password"}}})
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid sort field")
+	mockRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionLead_RecordsWhoMovedIt(t *testing.T) {
+	// GIVEN
+	leadRepo := new(mocks.LeadRepositoryMock)
+	changes := new(mocks.LeadStatusChangeRepositoryMock)
+	svc := service.NewLeadStatusService(leadRepo, changes)
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Status: entity.LeadStatusContacted}
+
+	leadRepo.On("FindByID", mock.Anything, "L1").Return(lead, nil)
+	changes.On("Record", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	change, err := svc.Transition(context.TODO(), "L1", entity.LeadStatusDismissed, entity.LeadReasonBudgetMismatch, "Looking below 100k", "A1")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, entity.LeadStatusContacted, change.FromStatus)
+	assert.Equal(t, entity.LeadStatusDismissed, change.ToStatus)
+	assert.Equal(t, entity.LeadReasonBudgetMismatch, change.ReasonCode)
+	assert.Equal(t, "A1", *change.AgentID)
+	assert.Equal(t, entity.LeadStatusDismissed, lead.Status)
+}
+
+func TestTransitionLead_InvalidTransitions(t *testing.T) {
+	cases := []struct {
+		name   string
+		from   entity.LeadStatus
+		to     entity.LeadStatus
+		reason entity.LeadStatusReason
+		note   string
+	}{
+		{"reject without reason", entity.LeadStatusNew, entity.LeadStatusRejected, "", ""},
+		{"unknown reason", entity.LeadStatusNew, entity.LeadStatusDismissed, "bored", ""},
+		{"other without note", entity.LeadStatusNew, entity.LeadStatusDismissed, entity.LeadReasonOther, ""},
+		{"closed back to new", entity.LeadStatusClosed, entity.LeadStatusNew, "", ""},
+		{"new straight to closed", entity.LeadStatusNew, entity.LeadStatusClosed, "", ""},
+		{"unknown status", entity.LeadStatusNew, "discarded", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			changes := new(mocks.LeadStatusChangeRepositoryMock)
+			svc := service.NewLeadStatusService(new(mocks.LeadRepositoryMock), changes)
+			lead := &entity.Lead{ID: "L1", Status: tc.from}
+
+			// WHEN
+			_, err := svc.TransitionLead(context.TODO(), lead, tc.to, tc.reason, tc.note, "A1")
+
+			// THEN
+			assert.Error(t, err)
+			assert.Equal(t, tc.from, lead.Status)
+			changes.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestLeadFunnel_RatesAndReasons(t *testing.T) {
+	// GIVEN
+	changes := new(mocks.LeadStatusChangeRepositoryMock)
+	svc := service.NewLeadStatusService(new(mocks.LeadRepositoryMock), changes)
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	spam, none := entity.LeadReasonSpam, entity.LeadStatusReason("")
+
+	changes.On("Funnel", mock.Anything, since, until).Return(int64(20), []entity.LeadStatusCount{
+		{Status: entity.LeadStatusContacted, Leads: 10},
+		{Status: entity.LeadStatusContacted, ReasonCode: &none, Leads: 10},
+		{Status: entity.LeadStatusClosed, Leads: 2},
+		{Status: entity.LeadStatusDismissed, Leads: 4},
+		{Status: entity.LeadStatusDismissed, ReasonCode: &spam, Leads: 4},
+	}, nil)
+
+	// WHEN
+	funnel, err := svc.Funnel(context.TODO(), since, until)
+
+	// THEN
+	require.NoError(t, err)
+	require.Len(t, funnel.Stages, len(entity.LeadStatuses))
+	assert.Equal(t, entity.LeadFunnelStage{Status: entity.LeadStatusNew, Leads: 20, Rate: 1}, funnel.Stages[0])
+	assert.Equal(t, entity.LeadFunnelStage{Status: entity.LeadStatusContacted, Leads: 10, Rate: 0.5}, funnel.Stages[1])
+	assert.Equal(t, entity.LeadFunnelStage{Status: entity.LeadStatusClosed, Leads: 2, Rate: 0.1}, funnel.Stages[3])
+	assert.Equal(t, map[entity.LeadStatusReason]int64{entity.LeadReasonSpam: 4}, funnel.Stages[4].Reasons)
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// hitReturns makes the Hit of kind and key add one attempt to throttle and return it
+func hitReturns(repo *mocks.LoginThrottleRepositoryMock, kind, key string, throttle *entity.LoginThrottle) {
+	repo.On("Hit", mock.Anything, kind, key, mock.Anything).
+		Run(func(mock.Arguments) { throttle.Attempts++ }).
+		Return(throttle, nil)
+}
+
+func TestRecordLoginFailure_LocksAccountAfterFreeAttempts(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LoginThrottleRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewLoginThrottleService(repo, agentRepo)
+	account := &entity.LoginThrottle{ID: "T1", Kind: entity.ThrottleAccount, Key: "a@acme.com", Attempts: 5, WindowStart: time.Now().Add(-time.Minute)}
+
+	hitReturns(repo, entity.ThrottleAccount, "a@acme.com", account)
+	hitReturns(repo, entity.ThrottleIP, "10.0.0.1", &entity.LoginThrottle{ID: "T2", Kind: entity.ThrottleIP, Key: "10.0.0.1"})
+	var lockedUntil time.Time
+	repo.On("Lock", mock.Anything, "T1", mock.Anything).
+		Run(func(args mock.Arguments) { lockedUntil = args.Get(2).(time.Time) }).
+		Return(nil)
+	agentRepo.On("FindByEmail", mock.Anything, "a@acme.com").Return(&entity.Agent{ID: "A1", CompanyID: "C1"}, nil)
+
+	var event *entity.LockoutEvent
+	repo.On("CreateEvent", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { event = args.Get(1).(*entity.LockoutEvent) }).
+		Return(nil)
+
+	// WHEN
+	err := svc.RecordLoginFailure(context.TODO(), "A@acme.com", "10.0.0.1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, 6, account.Attempts)
+	assert.InDelta(t, time.Minute.Seconds(), time.Until(lockedUntil).Seconds(), 2)
+	assert.NotNil(t, event)
+	assert.Equal(t, "C1", *event.CompanyID)
+	assert.Equal(t, entity.ThrottleAccount, event.Kind)
+	repo.AssertNumberOfCalls(t, "Lock", 1) // The IP is still within its free attempts
+}
+
+func TestRecordLoginFailure_LockDoublesUpToOneHour(t *testing.T) {
+	cases := map[int]time.Duration{
+		7:  4 * time.Minute,  // 8th attempt
+		9:  16 * time.Minute, // 10th attempt
+		20: time.Hour,
+	}
+
+	for attempts, expected := range cases {
+		repo := new(mocks.LoginThrottleRepositoryMock)
+		agentRepo := new(mocks.AgentRepositoryMock)
+		svc := service.NewLoginThrottleService(repo, agentRepo)
+		account := &entity.LoginThrottle{ID: "T1", Kind: entity.ThrottleAccount, Key: "a@acme.com", Attempts: attempts, WindowStart: time.Now()}
+
+		hitReturns(repo, entity.ThrottleAccount, "a@acme.com", account)
+		repo.On("Lock", mock.Anything, "T1", mock.Anything).Return(nil)
+		repo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+		agentRepo.On("FindByEmail", mock.Anything, mock.Anything).Return(nil, nil)
+
+		assert.NoError(t, svc.RecordLoginFailure(context.TODO(), "a@acme.com", ""))
+		assert.InDelta(t, expected.Seconds(), account.RetryAfter(time.Now()).Seconds(), 2, "after %d attempts", attempts)
+	}
+}
+
+func TestRecordLoginFailure_FreeAttemptsDoNotLock(t *testing.T) {
+	// GIVEN an account whose old attempts the repository forgot
+	repo := new(mocks.LoginThrottleRepositoryMock)
+	svc := service.NewLoginThrottleService(repo, new(mocks.AgentRepositoryMock))
+	account := &entity.LoginThrottle{ID: "T1", Kind: entity.ThrottleAccount, Key: "a@acme.com", WindowStart: time.Now()}
+
+	repo.On("Hit", mock.Anything, entity.ThrottleAccount, "a@acme.com", 24*time.Hour).
+		Run(func(mock.Arguments) { account.Attempts++ }).
+		Return(account, nil)
+
+	// WHEN
+	err := svc.RecordLoginFailure(context.TODO(), "a@acme.com", "")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, 1, account.Attempts)
+	repo.AssertNotCalled(t, "Lock", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "CreateEvent", mock.Anything, mock.Anything)
+}
+
+func TestCheckLogin_LockedIPBlocksEveryAccount(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LoginThrottleRepositoryMock)
+	svc := service.NewLoginThrottleService(repo, new(mocks.AgentRepositoryMock))
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	repo.On("Find", mock.Anything, entity.ThrottleAccount, "other@acme.com").Return(nil, nil)
+	repo.On("Find", mock.Anything, entity.ThrottleIP, "10.0.0.1").Return(&entity.LoginThrottle{LockedUntil: &lockedUntil}, nil)
+
+	// WHEN
+	retryAfter, err := svc.CheckLogin(context.TODO(), "other@acme.com", "10.0.0.1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.InDelta(t, (10 * time.Minute).Seconds(), retryAfter.Seconds(), 2)
+}
+
+func TestAllowPasswordReset_LimitedPerEmail(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.LoginThrottleRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewLoginThrottleService(repo, agentRepo)
+	resets := &entity.LoginThrottle{ID: "T1", Kind: entity.ThrottlePasswordReset, Key: "a@acme.com", Attempts: 3, WindowStart: time.Now().Add(-10 * time.Minute)}
+
+	repo.On("Find", mock.Anything, entity.ThrottlePasswordReset, "a@acme.com").Return(resets, nil)
+	hitReturns(repo, entity.ThrottlePasswordReset, "a@acme.com", resets)
+	repo.On("Lock", mock.Anything, "T1", mock.Anything).Return(nil)
+	repo.On("CreateEvent", mock.Anything, mock.Anything).Return(nil)
+	agentRepo.On("FindByEmail", mock.Anything, "a@acme.com").Return(nil, nil)
+
+	// WHEN
+	first, err1 := svc.AllowPasswordReset(context.TODO(), "a@acme.com", "10.0.0.1")
+	second, err2 := svc.AllowPasswordReset(context.TODO(), "a@acme.com", "10.0.0.1")
+
+	// THEN
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Zero(t, first) // The 4th request is still sent, and locks further ones
+	assert.Greater(t, second, 59*time.Minute)
+	repo.AssertNumberOfCalls(t, "Hit", 1)
+}
@@ -21,7 +21,7 @@ func TestCreateMessage(t *testing.T) {
 	content := "Hello, I am interested"
 	senderType := "user"
 
-	mockRepo.On("Create", mock.MatchedBy(func(m *entity.Message) bool {
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(m *entity.Message) bool {
 		return m.LeadID == leadID && m.Content == content
 	})).Return(nil)
 
@@ -45,7 +45,7 @@ func TestGetMessagesByLeadID(t *testing.T) {
 	leadID := "L1"
 	messages := []entity.Message{{ID: "M1", Content: "Hi"}}
 
-	mockRepo.On("FindByLeadID", leadID).Return(messages, nil)
+	mockRepo.On("FindByLeadID", mock.Anything, leadID).Return(messages, nil)
 
 	// WHEN
 	result, err := svc.GetMessagesByLeadID(ctx, leadID)
@@ -25,7 +25,7 @@ func TestCreateProperty(t *testing.T) {
 
 	// Mocking behavior
 	mockRepo.On("FindByReference", ctx, "REF123").Return(nil, nil)
-	mockRepo.On("Create", mock.MatchedBy(func(p *entity.Property) bool {
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *entity.Property) bool {
 		return p.Reference == "REF123" && p.ID != ""
 	})).Return(nil)
 
@@ -48,7 +48,7 @@ func TestGetPropertyByID(t *testing.T) {
 	ctx := context.TODO()
 	prop := &entity.Property{ID: "P1", Title: "Test Prop"}
 
-	mockRepo.On("FindByID", "P1").Return(prop, nil)
+	mockRepo.On("FindByID", mock.Anything, "P1").Return(prop, nil)
 
 	// WHEN
 	result, err := svc.GetPropertyByID(ctx, "P1")
@@ -57,3 +57,114 @@ func TestGetPropertyByID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, prop, result)
 }
+
+func TestUpdateProperty_AgentCannotEditOthersProperty(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewPropertyService(mockRepo)
+	ctx := context.TODO()
+	creator := "creator"
+	existing := &entity.Property{ID: "P1", Title: "Old", CreatedByAgentID: &creator}
+
+	mockRepo.On("FindByID", mock.Anything, "P1").Return(existing, nil)
+
+	// WHEN
+	err := svc.UpdateProperty(ctx, &entity.Property{ID: "P1", Title: "New"}, "someone-else", entity.RoleAgent)
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	mockRepo.AssertNotCalled(t, "Update")
+}
+
+func TestUpdateProperty_ManagerCanEditOthersProperty(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewPropertyService(mockRepo)
+	ctx := context.TODO()
+	creator := "creator"
+	existing := &entity.Property{ID: "P1", Title: "Old", CreatedByAgentID: &creator}
+
+	mockRepo.On("FindByID", mock.Anything, "P1").Return(existing, nil)
+	mockRepo.On("Update", mock.Anything, existing).Return(nil)
+
+	// WHEN
+	err := svc.UpdateProperty(ctx, &entity.Property{ID: "P1", Title: "New"}, "manager", entity.RoleManager)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "New", existing.Title)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateProperty_APIKeyScopes(t *testing.T) {
+	cases := []struct {
+		name   string
+		scopes []entity.APIKeyScope
+		wantOK bool
+	}{
+		{"write only", []entity.APIKeyScope{entity.ScopePropertiesWrite}, false},
+		{"manage", []entity.APIKeyScope{entity.ScopePropertiesWrite, entity.ScopePropertiesManage}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			mockRepo := new(mocks.PropertyRepositoryMock)
+			svc := service.NewPropertyService(mockRepo)
+			existing := &entity.Property{ID: "P1", Title: "Old"}
+			key := &entity.APIKey{Scopes: tc.scopes}
+
+			mockRepo.On("FindByID", mock.Anything, "P1").Return(existing, nil)
+			mockRepo.On("Update", mock.Anything, existing).Return(nil)
+
+			// WHEN an API key, which has no agent, updates a property
+			err := svc.UpdateProperty(context.TODO(), &entity.Property{ID: "P1", Title: "New"}, "", key)
+
+			// THEN
+			if tc.wantOK {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "unauthorized")
+				mockRepo.AssertNotCalled(t, "Update")
+			}
+		})
+	}
+}
+
+func TestUpdateProperty_StatusChangeRequiresPublish(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewPropertyService(mockRepo)
+	ctx := context.TODO()
+	creator := "creator"
+	existing := &entity.Property{ID: "P1", Status: "draft", CreatedByAgentID: &creator}
+
+	mockRepo.On("FindByID", mock.Anything, "P1").Return(existing, nil)
+
+	// WHEN
+	err := svc.UpdateProperty(ctx, &entity.Property{ID: "P1", Status: "published"}, creator, entity.RoleAgent)
+
+	// THEN
+	assert.ErrorContains(t, err, string(entity.PermissionPropertyPublish))
+	mockRepo.AssertNotCalled(t, "Update")
+}
+
+func TestCreateProperty_NotifiesOnCreated(t *testing.T) {
+	// GIVEN
+	mockRepo := new(mocks.PropertyRepositoryMock)
+	svc := service.NewPropertyService(mockRepo)
+	ctx := context.TODO()
+
+	var notified *entity.Property
+	svc.OnCreated(func(ctx context.Context, p *entity.Property) { notified = p })
+
+	mockRepo.On("FindByReference", ctx, "REF9").Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// WHEN
+	created, _, err := svc.CreateProperty(ctx, &entity.Property{Reference: "REF9", CompanyID: "C1"})
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Same(t, created, notified)
+}
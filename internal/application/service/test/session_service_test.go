@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessionStart_IssuesTokensBoundToSession(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	svc := service.NewSessionService(repo, new(mocks.AgentRepositoryMock))
+	agent := &entity.Agent{ID: "A1", CompanyID: "C1", Role: entity.RoleAgent}
+
+	var stored *entity.Session
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Session")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*entity.Session) }).
+		Return(nil)
+
+	// WHEN
+	tokens, err := svc.Start(context.TODO(), agent, "test-agent", "10.0.0.1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, security.HashOpaqueToken(tokens.RefreshToken), stored.RefreshTokenHash)
+	assert.NotEqual(t, tokens.RefreshToken, stored.RefreshTokenHash)
+
+	claims, err := security.ValidateToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, stored.ID, claims.SessionID)
+	assert.Equal(t, "C1", claims.CompanyID)
+}
+
+func TestSessionRefresh_RotatesToken(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	agentRepo := new(mocks.AgentRepositoryMock)
+	svc := service.NewSessionService(repo, agentRepo)
+	hash := security.HashOpaqueToken("old-token")
+	session := &entity.Session{ID: "S1", AgentID: "A1", RefreshTokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+
+	repo.On("FindByTokenHash", mock.Anything, hash).Return(session, nil)
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(&entity.Agent{ID: "A1", CompanyID: "C1", Role: entity.RoleManager}, nil)
+	repo.On("Rotate", mock.Anything, "S1", hash, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+	// WHEN
+	tokens, err := svc.Refresh(context.TODO(), "old-token")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old-token", tokens.RefreshToken)
+	claims, err := security.ValidateToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "S1", claims.SessionID)
+	assert.Equal(t, string(entity.RoleManager), claims.Role)
+	repo.AssertExpectations(t)
+}
+
+func TestSessionRefresh_ReusedTokenRevokesSession(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	svc := service.NewSessionService(repo, new(mocks.AgentRepositoryMock))
+	oldHash := security.HashOpaqueToken("rotated-token")
+	session := &entity.Session{
+		ID:                "S1",
+		AgentID:           "A1",
+		RefreshTokenHash:  security.HashOpaqueToken("current-token"),
+		PreviousTokenHash: oldHash,
+		ExpiresAt:         time.Now().Add(time.Hour),
+	}
+
+	repo.On("FindByTokenHash", mock.Anything, oldHash).Return(session, nil)
+	repo.On("Revoke", mock.Anything, "S1").Return(nil)
+
+	// WHEN
+	tokens, err := svc.Refresh(context.TODO(), "rotated-token")
+
+	// THEN
+	assert.ErrorIs(t, err, service.ErrInvalidRefreshToken)
+	assert.Nil(t, tokens)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Rotate")
+}
+
+func TestSessionRefresh_RevokedSessionIsRejected(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	svc := service.NewSessionService(repo, new(mocks.AgentRepositoryMock))
+	hash := security.HashOpaqueToken("token")
+	revokedAt := time.Now()
+	session := &entity.Session{ID: "S1", AgentID: "A1", RefreshTokenHash: hash, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+
+	repo.On("FindByTokenHash", mock.Anything, hash).Return(session, nil)
+
+	// WHEN
+	_, err := svc.Refresh(context.TODO(), "token")
+
+	// THEN
+	assert.ErrorIs(t, err, service.ErrInvalidRefreshToken)
+	repo.AssertNotCalled(t, "Rotate")
+}
+
+func TestSessionIsActive_OtherAgentsSession(t *testing.T) {
+	// GIVEN
+	repo := new(mocks.SessionRepositoryMock)
+	svc := service.NewSessionService(repo, new(mocks.AgentRepositoryMock))
+	session := &entity.Session{ID: "S1", AgentID: "A1", ExpiresAt: time.Now().Add(time.Hour)}
+
+	repo.On("FindByID", mock.Anything, "S1").Return(session, nil)
+
+	// WHEN
+	own, err1 := svc.IsActive(context.TODO(), "S1", "A1")
+	other, err2 := svc.IsActive(context.TODO(), "S1", "A2")
+
+	// THEN
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.True(t, own)
+	assert.False(t, other)
+}
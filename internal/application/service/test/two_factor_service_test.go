@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/mocks"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testEncryptionKey = "0123456789abcdef0123456789abcdef"
+
+func newTwoFactorService() (*service.TwoFactorService, *mocks.AgentRepositoryMock, *mocks.CompanyRepositoryMock, *mocks.RecoveryCodeRepositoryMock) {
+	agentRepo := new(mocks.AgentRepositoryMock)
+	companyRepo := new(mocks.CompanyRepositoryMock)
+	codeRepo := new(mocks.RecoveryCodeRepositoryMock)
+	return service.NewTwoFactorService(agentRepo, companyRepo, codeRepo, testEncryptionKey), agentRepo, companyRepo, codeRepo
+}
+
+// enrolledAgent returns an agent with an encrypted TOTP secret and the plain secret
+func enrolledAgent(t *testing.T, enabled bool) (*entity.Agent, string) {
+	secret, err := security.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encrypted, err := security.Encrypt(secret, testEncryptionKey)
+	assert.NoError(t, err)
+	return &entity.Agent{ID: "A1", CompanyID: "C1", Email: "a@acme.com", TOTPSecret: encrypted, TwoFactorEnabled: enabled}, secret
+}
+
+func currentCode(t *testing.T, secret string) string {
+	code, err := security.TOTPCode(secret, security.TOTPStep(time.Now()))
+	assert.NoError(t, err)
+	return code
+}
+
+func TestBeginEnrollment_ReturnsOtpauthURIAndStoresEncryptedSecret(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, _, _ := newTwoFactorService()
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(&entity.Agent{ID: "A1", Email: "a@acme.com"}, nil)
+
+	var stored map[string]interface{}
+	agentRepo.On("UpdatePartial", mock.Anything, "A1", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).(map[string]interface{}) }).
+		Return(nil)
+
+	// WHEN
+	enrollment, err := svc.BeginEnrollment(context.TODO(), "A1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.OtpauthURI, "otpauth://totp/MyEstatia:a@acme.com")
+	assert.Contains(t, enrollment.OtpauthURI, "secret="+enrollment.Secret)
+	decrypted, err := security.Decrypt(stored["totp_secret"].(string), testEncryptionKey)
+	assert.NoError(t, err)
+	assert.Equal(t, enrollment.Secret, decrypted)
+	assert.NotContains(t, stored, "totp_enabled")
+}
+
+func TestEnable_WrongCodeKeepsTwoFactorOff(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, _, codeRepo := newTwoFactorService()
+	agent, _ := enrolledAgent(t, false)
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(agent, nil)
+
+	// WHEN
+	codes, err := svc.Enable(context.TODO(), "A1", "000000")
+
+	// THEN
+	assert.ErrorIs(t, err, service.ErrInvalidTwoFactorCode)
+	assert.Nil(t, codes)
+	agentRepo.AssertNotCalled(t, "UpdatePartial")
+	codeRepo.AssertNotCalled(t, "ReplaceForAgent")
+}
+
+func TestEnable_ValidCodeReturnsRecoveryCodes(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, _, codeRepo := newTwoFactorService()
+	agent, secret := enrolledAgent(t, false)
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(agent, nil)
+	agentRepo.On("UpdatePartial", mock.Anything, "A1", mock.MatchedBy(func(f map[string]interface{}) bool {
+		return f["totp_enabled"] == true
+	})).Return(nil)
+
+	var stored []entity.RecoveryCode
+	codeRepo.On("ReplaceForAgent", mock.Anything, "A1", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]entity.RecoveryCode) }).
+		Return(nil)
+
+	// WHEN
+	codes, err := svc.Enable(context.TODO(), "A1", currentCode(t, secret))
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, stored, 10)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(stored[0].CodeHash), []byte(codes[0])))
+	agentRepo.AssertExpectations(t)
+}
+
+func TestVerifyLogin_ReplayedCodeIsRejected(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, _, _ := newTwoFactorService()
+	agent, secret := enrolledAgent(t, true)
+	agent.TOTPLastStep = security.TOTPStep(time.Now()) + 1 // Already used up to the next step
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(agent, nil)
+
+	// WHEN
+	_, _, err := svc.VerifyLogin(context.TODO(), "A1", currentCode(t, secret))
+
+	// THEN
+	assert.ErrorIs(t, err, service.ErrInvalidTwoFactorCode)
+}
+
+func TestVerifyLogin_RecoveryCodeIsSingleUse(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, _, codeRepo := newTwoFactorService()
+	agent, _ := enrolledAgent(t, true)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("abcde-12345"), bcrypt.MinCost)
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(agent, nil)
+	codeRepo.On("FindUnusedByAgent", mock.Anything, "A1").Return([]entity.RecoveryCode{{ID: "R1", AgentID: "A1", CodeHash: string(hash)}}, nil)
+	codeRepo.On("MarkAsUsed", mock.Anything, "R1").Return(nil)
+
+	// WHEN
+	result, _, err := svc.VerifyLogin(context.TODO(), "A1", "ABCDE-12345")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "A1", result.ID)
+	codeRepo.AssertCalled(t, "MarkAsUsed", mock.Anything, "R1")
+}
+
+func TestDisable_RefusedWhenCompanyRequiresTwoFactor(t *testing.T) {
+	// GIVEN
+	svc, agentRepo, companyRepo, codeRepo := newTwoFactorService()
+	agent, secret := enrolledAgent(t, true)
+	agentRepo.On("FindByID", mock.Anything, "A1").Return(agent, nil)
+	companyRepo.On("FindByID", mock.Anything, "C1").Return(&entity.Company{ID: "C1", RequireTwoFactor: true}, nil)
+
+	// WHEN
+	err := svc.Disable(context.TODO(), "A1", currentCode(t, secret))
+
+	// THEN
+	assert.ErrorContains(t, err, "unauthorized")
+	codeRepo.AssertNotCalled(t, "DeleteForAgent")
+}
+
+func TestNextLoginStep(t *testing.T) {
+	svc, _, companyRepo, _ := newTwoFactorService()
+	companyRepo.On("FindByID", mock.Anything, "open").Return(&entity.Company{ID: "open"}, nil)
+	companyRepo.On("FindByID", mock.Anything, "strict").Return(&entity.Company{ID: "strict", RequireTwoFactor: true}, nil)
+
+	step, _ := svc.NextLoginStep(context.TODO(), &entity.Agent{CompanyID: "open"})
+	assert.Equal(t, service.LoginStepDone, step)
+
+	step, _ = svc.NextLoginStep(context.TODO(), &entity.Agent{CompanyID: "strict"})
+	assert.Equal(t, service.LoginStepTwoFactorSetup, step)
+
+	step, _ = svc.NextLoginStep(context.TODO(), &entity.Agent{CompanyID: "open", TwoFactorEnabled: true})
+	assert.Equal(t, service.LoginStepTwoFactor, step)
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer        = "MyEstatia"
+	recoveryCodeCount = 10
+)
+
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// LoginStep tells what an agent must do after a correct password
+type LoginStep int
+
+const (
+	LoginStepDone           LoginStep = iota // No second factor, start the session
+	LoginStepTwoFactor                       // Ask for the TOTP or a recovery code
+	LoginStepTwoFactorSetup                  // The company requires 2FA and the agent has not enrolled yet
+)
+
+// TwoFactorEnrollment is what the agent needs to add the account to an authenticator app
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+type TwoFactorService struct {
+	AgentRepo        repository.AgentRepository
+	CompanyRepo      repository.CompanyRepository
+	RecoveryCodeRepo repository.RecoveryCodeRepository
+	EncryptionKey    string
+}
+
+func NewTwoFactorService(
+	agentRepo repository.AgentRepository,
+	companyRepo repository.CompanyRepository,
+	recoveryCodeRepo repository.RecoveryCodeRepository,
+	encryptionKey string,
+) *TwoFactorService {
+	return &TwoFactorService{
+		AgentRepo:        agentRepo,
+		CompanyRepo:      companyRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+		EncryptionKey:    encryptionKey,
+	}
+}
+
+// NextLoginStep decides whether a password login needs a second factor
+func (s *TwoFactorService) NextLoginStep(ctx context.Context, agent *entity.Agent) (LoginStep, error) {
+	if agent.TwoFactorEnabled {
+		return LoginStepTwoFactor, nil
+	}
+	company, err := s.CompanyRepo.FindByID(ctx, agent.CompanyID)
+	if err != nil {
+		return LoginStepDone, err
+	}
+	if company.RequireTwoFactor {
+		return LoginStepTwoFactorSetup, nil
+	}
+	return LoginStepDone, nil
+}
+
+// BeginEnrollment generates a new secret for the agent. It is not used at
+// login until Enable confirms that the authenticator produces valid codes.
+func (s *TwoFactorService) BeginEnrollment(ctx context.Context, agentID string) (*TwoFactorEnrollment, error) {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := security.Encrypt(secret, s.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.AgentRepo.UpdatePartial(ctx, agentID, map[string]interface{}{
+		"totp_secret":    encrypted,
+		"totp_last_step": 0,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:     secret,
+		OtpauthURI: security.TOTPURI(totpIssuer, agent.Email, secret),
+	}, nil
+}
+
+// Enable verifies a first code from the authenticator, turns 2FA on and
+// returns the recovery codes, which are shown only this once.
+func (s *TwoFactorService) Enable(ctx context.Context, agentID, code string) ([]string, error) {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if agent.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrollment has not been started")
+	}
+
+	step, err := s.checkTOTP(agent, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.newRecoveryCodes(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.AgentRepo.UpdatePartial(ctx, agentID, map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+	}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns 2FA off after checking a current code. Not allowed when the company requires 2FA.
+func (s *TwoFactorService) Disable(ctx context.Context, agentID, code string) error {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if !agent.TwoFactorEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	company, err := s.CompanyRepo.FindByID(ctx, agent.CompanyID)
+	if err != nil {
+		return err
+	}
+	if company.RequireTwoFactor {
+		return errors.New("unauthorized: the company requires two-factor authentication")
+	}
+
+	if err := s.verifyCode(ctx, agent, code); err != nil {
+		return err
+	}
+	return s.reset(ctx, agentID)
+}
+
+// Reset turns 2FA off for an agent without a code, for admins helping an
+// agent who lost both the authenticator and the recovery codes. Only owners
+// can reset the 2FA of another owner.
+func (s *TwoFactorService) Reset(ctx context.Context, agentID string, executorRole entity.AgentRole) error {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if agent.Role == entity.RoleOwner && executorRole != entity.RoleOwner {
+		return errors.New("unauthorized: only an owner can reset the two-factor authentication of an owner")
+	}
+	return s.reset(ctx, agentID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, agentID, code string) ([]string, error) {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if !agent.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := s.verifyCode(ctx, agent, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, agentID)
+}
+
+// FindAgent returns the agent a login challenge was issued for
+func (s *TwoFactorService) FindAgent(ctx context.Context, agentID string) (*entity.Agent, error) {
+	return s.AgentRepo.FindByID(ctx, agentID)
+}
+
+// VerifyLogin completes the second login step. For agents that are enrolling
+// because their company requires 2FA, the first valid code enables it and the
+// new recovery codes are returned.
+func (s *TwoFactorService) VerifyLogin(ctx context.Context, agentID, code string) (*entity.Agent, []string, error) {
+	agent, err := s.AgentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if agent.TwoFactorEnabled {
+		if err := s.verifyCode(ctx, agent, code); err != nil {
+			return nil, nil, err
+		}
+		return agent, nil, nil
+	}
+
+	codes, err := s.Enable(ctx, agentID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	agent.TwoFactorEnabled = true
+	return agent, codes, nil
+}
+
+// verifyCode accepts either a TOTP code or an unused recovery code
+func (s *TwoFactorService) verifyCode(ctx context.Context, agent *entity.Agent, code string) error {
+	code = strings.TrimSpace(code)
+	if strings.Contains(code, "-") {
+		return s.useRecoveryCode(ctx, agent.ID, code)
+	}
+
+	step, err := s.checkTOTP(agent, code)
+	if err != nil {
+		return err
+	}
+	return s.AgentRepo.UpdatePartial(ctx, agent.ID, map[string]interface{}{"totp_last_step": step})
+}
+
+func (s *TwoFactorService) checkTOTP(agent *entity.Agent, code string) (int64, error) {
+	secret, err := security.Decrypt(agent.TOTPSecret, s.EncryptionKey)
+	if err != nil {
+		return 0, err
+	}
+	step, ok := security.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= agent.TOTPLastStep {
+		return 0, ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+func (s *TwoFactorService) useRecoveryCode(ctx context.Context, agentID, code string) error {
+	codes, err := s.RecoveryCodeRepo.FindUnusedByAgent(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	code = strings.ToLower(code)
+	for _, rc := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(code)) == nil {
+			if err := s.RecoveryCodeRepo.MarkAsUsed(ctx, rc.ID); err != nil {
+				return ErrInvalidTwoFactorCode
+			}
+			return nil
+		}
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+func (s *TwoFactorService) newRecoveryCodes(ctx context.Context, agentID string) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	records := make([]entity.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		token, err := security.GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		code := token[:5] + "-" + token[5:10]
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, code)
+		records = append(records, entity.RecoveryCode{ID: uuid.New().String(), AgentID: agentID, CodeHash: string(hash)})
+	}
+
+	if err := s.RecoveryCodeRepo.ReplaceForAgent(ctx, agentID, records); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func (s *TwoFactorService) reset(ctx context.Context, agentID string) error {
+	if err := s.AgentRepo.UpdatePartial(ctx, agentID, map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}); err != nil {
+		return err
+	}
+	return s.RecoveryCodeRepo.DeleteForAgent(ctx, agentID)
+}
+
+// SetCompanyRequirement turns the company-wide 2FA requirement on or off
+func (s *TwoFactorService) SetCompanyRequirement(ctx context.Context, companyID string, required bool) error {
+	return s.CompanyRepo.UpdatePartial(ctx, companyID, map[string]interface{}{
+		"require_two_factor": required,
+		"updated_at":         time.Now(),
+	})
+}
@@ -12,8 +12,14 @@ type Agent struct {
 	Email string `gorm:"not null;uniqueIndex" json:"email"`
 	Phone string `json:"phone"`
 
-	Password string `gorm:"not null" json:"-"`
-	Role     string `gorm:"default:'agent'" json:"role"`
+	Password string    `gorm:"not null" json:"-"`
+	Role     AgentRole `gorm:"type:varchar(20);default:'agent'" json:"role"`
+
+	// TOTP two-factor authentication. The secret is encrypted and is set at
+	// enrollment, but only used at login once the first code was verified.
+	TOTPSecret       string `gorm:"column:totp_secret" json:"-"`
+	TwoFactorEnabled bool   `gorm:"column:totp_enabled;default:false" json:"two_factor_enabled"`
+	TOTPLastStep     int64  `gorm:"column:totp_last_step;default:0" json:"-"` // Last accepted time step, replays are refused
 
 	CompanyID string   `gorm:"type:uuid;not null;index" json:"company_id"`
 	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"company,omitempty"`
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// APIKeyPrefix starts every API key, so a leaked key is easy to recognise
+const APIKeyPrefix = "mye_"
+
+// APIKeyScope is something an API key is allowed to do. Each scope grants
+// exactly one permission of the role matrix.
+type APIKeyScope string
+
+const (
+	ScopeLeadsRead       APIKeyScope = "leads:read"
+	ScopeLeadsWrite      APIKeyScope = "leads:write"
+	ScopeLeadsAssign     APIKeyScope = "leads:assign"
+	ScopePropertiesRead  APIKeyScope = "properties:read"
+	ScopePropertiesWrite APIKeyScope = "properties:write"
+	// An API key is never the creator of a property, so it needs manage to update or delete one
+	ScopePropertiesManage  APIKeyScope = "properties:manage"
+	ScopePropertiesPublish APIKeyScope = "properties:publish"
+	ScopeAgentsRead        APIKeyScope = "agents:read"
+	ScopeCompanyRead       APIKeyScope = "company:read"
+)
+
+var scopePermissions = map[APIKeyScope]Permission{
+	ScopeLeadsRead:         PermissionLeadRead,
+	ScopeLeadsWrite:        PermissionLeadWrite,
+	ScopeLeadsAssign:       PermissionLeadAssign,
+	ScopePropertiesRead:    PermissionPropertyRead,
+	ScopePropertiesWrite:   PermissionPropertyWrite,
+	ScopePropertiesManage:  PermissionPropertyManage,
+	ScopePropertiesPublish: PermissionPropertyPublish,
+	ScopeAgentsRead:        PermissionAgentRead,
+	ScopeCompanyRead:       PermissionCompanyRead,
+}
+
+// IsValid reports whether s is one of the known scopes
+func (s APIKeyScope) IsValid() bool {
+	_, ok := scopePermissions[s]
+	return ok
+}
+
+// APIKey lets a website or script call the API on behalf of a company,
+// without an agent. Only the hash of the key is stored.
+type APIKey struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;index" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+
+	Name    string                           `gorm:"not null" json:"name"`
+	Prefix  string                           `gorm:"type:varchar(16);not null" json:"prefix"` // First characters of the key, to tell keys apart
+	KeyHash string                           `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	Scopes  datatypes.JSONSlice[APIKeyScope] `gorm:"type:jsonb;not null" json:"scopes"`
+
+	CreatedByID *string `gorm:"type:uuid" json:"createdById,omitempty"`
+	CreatedBy   *Agent  `gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// IsActive checks if the key has not been revoked
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil
+}
+
+// Can reports whether one of the key scopes grants permission p
+func (k *APIKey) Can(p Permission) bool {
+	for _, scope := range k.Scopes {
+		if scopePermissions[scope] == p {
+			return true
+		}
+	}
+	return false
+}
@@ -24,6 +24,8 @@ type Company struct {
 	PageLink       string `json:"page_link"`
 	WebDeveloper   string `json:"web_developer"`
 
+	RequireTwoFactor bool `gorm:"default:false" json:"require_two_factor"` // Every agent must use 2FA to log in
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -6,14 +6,35 @@ import (
 	"gorm.io/gorm"
 )
 
-// CompanyEmailConfig stores email configuration for a company's email inbox
-// Supports both IMAP password auth and OAuth2 (Google)
+// Auth methods of an inbox. "oauth2" is Google (Gmail API); Microsoft 365
+// mailboxes are read through Microsoft Graph.
+const (
+	EmailAuthPassword  = "password"
+	EmailAuthGoogle    = "oauth2"
+	EmailAuthMicrosoft = "microsoft"
+)
+
+// Sync modes of an inbox. "poll" checks it every PollIntervalSecs; "idle" keeps
+// an IMAP IDLE connection open and is told about new mail right away, falling
+// back to polling when the server does not support IDLE.
+const (
+	EmailSyncModePoll = "poll"
+	EmailSyncModeIdle = "idle"
+)
+
+// CompanyEmailConfig stores email configuration for one of a company's inboxes.
+// A company may connect several. Supports both IMAP password auth and OAuth2.
 type CompanyEmailConfig struct {
 	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	CompanyID string   `gorm:"not null;type:uuid;uniqueIndex;index" json:"companyId"`
+	CompanyID string   `gorm:"not null;type:uuid;index:idx_company_email_configs_company" json:"companyId"`
 	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"company,omitempty"`
 
-	// Auth method: "password" or "oauth2"
+	// Label tells the inboxes of a company apart, e.g. "Sales" or "Rentals"
+	Label string `gorm:"type:varchar(100)" json:"label"`
+	// DefaultAgentID is the agent that new leads from this inbox are assigned to
+	DefaultAgentID *string `gorm:"type:uuid" json:"defaultAgentId"`
+
+	// Auth method: "password", "oauth2" or "microsoft"
 	AuthMethod string `gorm:"type:varchar(20);default:'password'" json:"authMethod"`
 
 	// IMAP fields (for password auth)
@@ -22,18 +43,27 @@ type CompanyEmailConfig struct {
 	IMAPUsername string `gorm:"type:varchar(255)" json:"imapUsername"`
 	IMAPPassword string `gorm:"type:text" json:"-"` // Encrypted, never returned in JSON
 
-	// OAuth2 fields (for oauth2 auth)
-	OAuth2Provider string     `gorm:"type:varchar(50)" json:"oauth2Provider"` // "google"
+	// OAuth2 fields (for oauth2 and microsoft auth)
+	OAuth2Provider string     `gorm:"type:varchar(50)" json:"oauth2Provider"` // "google" or "microsoft"
 	AccessToken    string     `gorm:"type:text" json:"-"`                     // Encrypted
 	RefreshToken   string     `gorm:"type:text" json:"-"`                     // Encrypted
 	TokenExpiry    *time.Time `json:"tokenExpiry"`
 
+	// Sync mode: "poll" or "idle" (IMAP only)
+	SyncMode string `gorm:"type:varchar(20);default:'poll'" json:"syncMode"`
+
 	// Common fields
-	InboxFolder      string         `gorm:"type:varchar(100);default:'INBOX'" json:"inboxFolder"`
-	PollIntervalSecs int            `gorm:"default:300" json:"pollIntervalSecs"` // 5 minutes default
-	IsEnabled        bool           `gorm:"default:true;index" json:"isEnabled"`
-	LastSyncAt       *time.Time     `json:"lastSyncAt"`
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+	InboxFolder      string     `gorm:"type:varchar(100);default:'INBOX'" json:"inboxFolder"`
+	PollIntervalSecs int        `gorm:"default:300" json:"pollIntervalSecs"` // 5 minutes default
+	IsEnabled        bool       `gorm:"default:true;index" json:"isEnabled"`
+	LastSyncAt       *time.Time `json:"lastSyncAt"`
+
+	// Ingestion health, written after every poll (see EmailSyncRun)
+	ConsecutiveFailures int    `gorm:"not null;default:0" json:"consecutiveFailures"`
+	LastError           string `gorm:"type:text" json:"lastError"`
+	DisabledReason      string `gorm:"type:text" json:"disabledReason"` // Why the inbox was disabled automatically
+
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
 }
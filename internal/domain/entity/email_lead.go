@@ -5,17 +5,22 @@ import "time"
 type EmailSource string
 
 const (
-	EmailSourceFotocasa  EmailSource = "Fotocasa"
-	EmailSourceIdealista EmailSource = "Idealista"
+	EmailSourceFotocasa    EmailSource = "Fotocasa"
+	EmailSourceIdealista   EmailSource = "Idealista"
+	EmailSourceHabitaclia  EmailSource = "Habitaclia"
+	EmailSourcePisos       EmailSource = "Pisos.com"
+	EmailSourceKyero       EmailSource = "Kyero"
+	EmailSourceThinkSpain  EmailSource = "ThinkSpain"
+	EmailSourceMilanuncios EmailSource = "Milanuncios"
 )
 
 // ParsedLead represents the intermediate structure after parsing an email
 type ParsedLead struct {
-	Name              string
-	Email             string
-	Phone             string
-	Message           string
-	PropertyReference string      // e.g., "R4786633"
-	Source            EmailSource // Fotocasa or Idealista
-	ContactDate       time.Time
+	Name              string      `json:"name"`
+	Email             string      `json:"email"`
+	Phone             string      `json:"phone"`
+	Message           string      `json:"message"`
+	PropertyReference string      `json:"propertyReference"` // e.g., "R4786633"
+	Source            EmailSource `json:"source"`            // Portal the contact came from
+	ContactDate       time.Time   `json:"contactDate"`
 }
@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// EmailParserTemplate is an email parser a company configures without code,
+// for its own website form or a portal without a built-in parser. It is
+// tried before the built-in parsers, lowest Priority first.
+type EmailParserTemplate struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;index" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+
+	Name   string `gorm:"not null" json:"name"`
+	Source string `gorm:"type:varchar(100)" json:"source"` // Lead source, defaults to Name
+
+	// Match rules: case-insensitive regular expressions; an empty one matches anything
+	FromPattern    string `json:"fromPattern"`
+	SubjectPattern string `json:"subjectPattern"`
+
+	Rules     datatypes.JSONType[ParserTemplateRules] `gorm:"type:jsonb;not null" json:"rules"`
+	Priority  int                                     `gorm:"default:0" json:"priority"`
+	IsEnabled bool                                    `gorm:"not null;index" json:"isEnabled"`
+	CreatedAt time.Time                               `json:"createdAt"`
+	UpdatedAt time.Time                               `json:"updatedAt"`
+}
+
+// ParserTemplateRules tells where each lead field is in the email
+type ParserTemplateRules struct {
+	Name              TemplateFieldRule `json:"name"`
+	Email             TemplateFieldRule `json:"email"`
+	Phone             TemplateFieldRule `json:"phone"`
+	Message           TemplateFieldRule `json:"message"`
+	PropertyReference TemplateFieldRule `json:"propertyReference"`
+}
+
+// TemplateFieldRule extracts one field. The selector picks an element of the
+// HTML body and the regex then runs on its text (or on the whole body text,
+// or on the subject); the first capture group is the value, or the whole
+// match if there is none.
+type TemplateFieldRule struct {
+	Selector string `json:"selector,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	Subject  bool   `json:"subject,omitempty"`
+}
+
+// IsEmpty reports whether the rule extracts nothing
+func (r TemplateFieldRule) IsEmpty() bool {
+	return r.Selector == "" && r.Regex == ""
+}
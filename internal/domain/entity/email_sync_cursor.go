@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// EmailSyncCursor remembers how far an inbox has been read, so each poll only
+// fetches the messages that arrived after the previous one
+type EmailSyncCursor struct {
+	ConfigID  string `gorm:"type:uuid;primaryKey" json:"configId"`
+	CompanyID string `gorm:"type:uuid;not null;index" json:"companyId"`
+
+	// IMAP: UIDs are only meaningful while the mailbox keeps the same UIDVALIDITY
+	UIDValidity uint32 `json:"uidValidity"`
+	LastUID     uint32 `json:"lastUid"`
+
+	// Gmail: the mailbox historyId the next history.list starts from
+	HistoryID uint64 `json:"historyId"`
+
+	// Microsoft Graph: the deltaLink returned at the end of the last sync
+	DeltaLink string `gorm:"type:text" json:"-"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// EmailSyncRun is the outcome of one poll of an inbox, kept so admins can see
+// whether their connection works and what it brought in
+type EmailSyncRun struct {
+	ID        string `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ConfigID  string `gorm:"type:uuid;not null;index:idx_email_sync_runs_config_started" json:"configId"`
+	CompanyID string `gorm:"type:uuid;not null;index" json:"companyId"`
+
+	StartedAt  time.Time `gorm:"not null;index:idx_email_sync_runs_config_started" json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+
+	EmailsSeen    int `json:"emailsSeen"` // New emails fetched from the inbox
+	LeadsCreated  int `json:"leadsCreated"`
+	LeadsUpdated  int `json:"leadsUpdated"`
+	ParseFailures int `json:"parseFailures"` // Emails that did not become a lead, see FailedEmail
+
+	// Error is set when the inbox could not be read at all
+	Error       string `gorm:"type:text" json:"error,omitempty"`
+	AuthFailure bool   `json:"authFailure"` // The server rejected the stored credentials
+}
+
+// Succeeded reports whether the inbox could be read
+func (r *EmailSyncRun) Succeeded() bool {
+	return r.Error == ""
+}
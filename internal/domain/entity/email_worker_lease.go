@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// EmailWorkerLease gives one replica the right to poll an inbox until
+// ExpiresAt. The holder renews it while its worker runs; once it stops being
+// renewed, any other replica may take over.
+type EmailWorkerLease struct {
+	ConfigID   string    `gorm:"type:uuid;primaryKey" json:"configId"`
+	HolderID   string    `gorm:"type:varchar(255);not null" json:"holderId"`
+	AcquiredAt time.Time `gorm:"not null" json:"acquiredAt"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expiresAt"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// EmailFailureCategory tells why an inbound email did not become a lead
+type EmailFailureCategory string
+
+const (
+	EmailFailureUnsupportedSource EmailFailureCategory = "unsupported_source" // No parser recognised the email
+	EmailFailureParse             EmailFailureCategory = "parse_error"        // A parser matched but required fields were missing
+	EmailFailureUnknownReference  EmailFailureCategory = "unknown_reference"  // The property reference does not exist (yet)
+	EmailFailureInternal          EmailFailureCategory = "internal"           // Database or other unexpected errors
+)
+
+// FailedEmail is the dead-letter record of an inbound email that could not be
+// turned into a lead. It keeps the raw email so it can be reprocessed once the
+// cause is fixed (a new parser template, the property being created, ...).
+type FailedEmail struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;uniqueIndex:idx_failed_email_company_message" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	MessageID string   `gorm:"type:varchar(255);not null;uniqueIndex:idx_failed_email_company_message" json:"messageId"`
+	ConfigID  *string  `gorm:"type:uuid;index" json:"configId,omitempty"` // Inbox it came in through; nil for records that predate it
+
+	From       string    `gorm:"type:varchar(500)" json:"from"`
+	Subject    string    `gorm:"type:text" json:"subject"`
+	Body       string    `gorm:"type:text" json:"body"`
+	ReceivedAt time.Time `json:"receivedAt"`
+
+	Category          EmailFailureCategory `gorm:"type:varchar(30);not null;index" json:"category"`
+	Error             string               `gorm:"type:text" json:"error"`
+	PropertyReference string               `gorm:"type:varchar(100);index" json:"propertyReference,omitempty"` // Set for unknown_reference failures
+	Attempts          int                  `gorm:"not null;default:1" json:"attempts"`
+	LastAttemptAt     time.Time            `json:"lastAttemptAt"`
+	ResolvedAt        *time.Time           `gorm:"index" json:"resolvedAt,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
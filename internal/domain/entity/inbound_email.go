@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// InboundEmailParsed is the ParseStatus of an email that created or updated a
+// lead. Otherwise ParseStatus is the EmailFailureCategory of why it did not.
+const InboundEmailParsed = "parsed"
+
+// InboundEmail is the original of an email read from a company inbox, as it
+// arrived. It is kept so agents can check what the portal actually sent
+// next to what was parsed out of it.
+type InboundEmail struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;uniqueIndex:idx_inbound_email_company_message" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	ConfigID  *string  `gorm:"type:uuid;index" json:"configId,omitempty"` // Inbox it was read from
+	MessageID string   `gorm:"type:varchar(255);not null;uniqueIndex:idx_inbound_email_company_message" json:"messageId"`
+
+	From       string                                  `gorm:"type:varchar(500)" json:"from"`
+	To         string                                  `gorm:"type:text" json:"to"`
+	Subject    string                                  `gorm:"type:text" json:"subject"`
+	Headers    datatypes.JSONType[map[string][]string] `gorm:"type:jsonb" json:"headers"`
+	TextBody   string                                  `gorm:"type:text" json:"textBody"`
+	HTMLBody   string                                  `gorm:"type:text" json:"htmlBody"`
+	ReceivedAt time.Time                               `json:"receivedAt"`
+
+	Attachments []InboundEmailAttachment `gorm:"foreignKey:InboundEmailID;constraint:OnDelete:CASCADE" json:"attachments"`
+
+	Source      string                          `gorm:"type:varchar(50)" json:"source,omitempty"` // Portal, once a parser recognised it
+	ParseStatus string                          `gorm:"type:varchar(30);index" json:"parseStatus,omitempty"`
+	ParseError  string                          `gorm:"type:text" json:"parseError,omitempty"`
+	ParsedLead  *datatypes.JSONType[ParsedLead] `gorm:"type:jsonb" json:"parsedLead,omitempty"`
+
+	LeadID                *string `gorm:"type:uuid;index" json:"leadId,omitempty"`
+	ConversationMessageID *string `gorm:"type:uuid" json:"conversationMessageId,omitempty"` // Message it added to the lead's conversation
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// InboundEmailAttachment is a file attached to an inbound email, kept in
+// private storage since it may hold personal data of the buyer
+type InboundEmailAttachment struct {
+	ID             string `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	InboundEmailID string `gorm:"type:uuid;not null;index" json:"inboundEmailId"`
+	Filename       string `gorm:"type:varchar(255)" json:"filename"`
+	ContentType    string `gorm:"type:varchar(255)" json:"contentType"` // As sent, never used to serve it
+	Size           int    `json:"size"`
+	StorageKey     string `gorm:"type:varchar(64)" json:"-"` // Key in PrivateStorageService
+	// URL downloads the attachment through the API. Attachments archived before
+	// they were kept private keep the public URL they were stored under.
+	URL       string    `gorm:"type:text;not null" json:"url"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// InvitationTTL is how long an invitation link can be used
+const InvitationTTL = 7 * 24 * time.Hour
+
+// Invitation lets someone join an existing company as an agent with the
+// given role. The token sent by email is single-use and only its hash is stored.
+type Invitation struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;index" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"company,omitempty"`
+
+	Email     string    `gorm:"not null;index" json:"email"`
+	Role      AgentRole `gorm:"type:varchar(20);not null" json:"role"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+
+	InvitedByID *string `gorm:"type:uuid" json:"invitedById,omitempty"`
+	InvitedBy   *Agent  `gorm:"foreignKey:InvitedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// IsExpired checks if the invitation has expired
+func (i *Invitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// IsPending checks if the invitation can still be accepted
+func (i *Invitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && !i.IsExpired()
+}
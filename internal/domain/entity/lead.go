@@ -1,6 +1,8 @@
 package entity
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,15 +19,101 @@ const (
 	LeadStatusRejected  LeadStatus = "rejected"
 )
 
+// LeadFilter narrows and orders a list of leads; nil and empty fields mean "any"
+type LeadFilter struct {
+	CompanyID       *string
+	SearchTerm      *string // Name, email, phone, zone or notes containing it
+	Statuses        []LeadStatus
+	Sources         []string
+	Channels        []string
+	AssignedAgentID *string // LeadFilterUnassigned for leads with no agent
+	Zone            *string
+	Language        *string
+	MinBudget       *float64
+	MaxBudget       *float64
+
+	// Date ranges, From included and To excluded
+	CreatedFrom         *time.Time
+	CreatedTo           *time.Time
+	LastInteractionFrom *time.Time
+	LastInteractionTo   *time.Time
+
+	Sort   []LeadSort // By default newest first
+	Cursor string     // NextCursor of the previous LeadPage
+	Limit  int
+}
+
+const (
+	LeadPageDefaultLimit = 50  // Leads in a page when LeadFilter.Limit is not set
+	LeadPageMaxLimit     = 200 // Most leads in a page
+)
+
+// LeadFilterUnassigned as LeadFilter.AssignedAgentID matches the leads with no agent
+const LeadFilterUnassigned = "none"
+
+// LeadSortField is a field leads can be sorted by
+type LeadSortField string
+
+const (
+	LeadSortCreatedAt       LeadSortField = "createdAt"
+	LeadSortUpdatedAt       LeadSortField = "updatedAt"
+	LeadSortLastInteraction LeadSortField = "lastInteraction" // Leads never contacted count as the oldest
+	LeadSortScore           LeadSortField = "score"
+	LeadSortBudget          LeadSortField = "budget"
+	LeadSortName            LeadSortField = "name"
+	LeadSortStatus          LeadSortField = "status"
+)
+
+// IsValid reports whether f is one of the fields leads can be sorted by
+func (f LeadSortField) IsValid() bool {
+	switch f {
+	case LeadSortCreatedAt, LeadSortUpdatedAt, LeadSortLastInteraction, LeadSortScore, LeadSortBudget, LeadSortName, LeadSortStatus:
+		return true
+	}
+	return false
+}
+
+// LeadSort sorts leads by Field, descending when Desc
+type LeadSort struct {
+	Field LeadSortField
+	Desc  bool
+}
+
+// ParseLeadSort parses a comma-separated list of fields, each descending when
+// prefixed with "-", e.g. "-score,createdAt"
+func ParseLeadSort(value string) ([]LeadSort, error) {
+	var sorts []LeadSort
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sort := LeadSort{Field: LeadSortField(strings.TrimPrefix(part, "-")), Desc: strings.HasPrefix(part, "-")}
+		if !sort.Field.IsValid() {
+			return nil, fmt.Errorf("invalid sort field %q", sort.Field)
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+// LeadPage is a page of the leads matching a LeadFilter
+type LeadPage struct {
+	Leads      []Lead `json:"leads"`
+	Total      int64  `json:"total"`                // Leads matching the filter, in every page
+	NextCursor string `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
 type Lead struct {
 	ID              string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
 	Name            string     `gorm:"not null" json:"name"`
-	Email           string     `gorm:"uniqueIndex;not null" json:"email"`
+	Email           string     `gorm:"not null" json:"email"` // Unique per company lowercased, see migration.LeadEmailIndex
 	Phone           string     `gorm:"not null" json:"phone"`
+	NormalizedPhone string     `gorm:"type:varchar(20);index:idx_leads_company_phone" json:"-"` // NormalizePhone of Phone, to match leads by phone
 	Status          LeadStatus `gorm:"type:varchar(20);default:'new'" json:"status"`
 	PropertyID      *string    `gorm:"type:uuid;index" json:"propertyId"`
 	Property        *Property  `gorm:"foreignKey:PropertyID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"property,omitempty"`
-	CompanyID       string     `gorm:"not null;type:uuid;index" json:"companyId"`
+	CompanyID       string     `gorm:"not null;type:uuid;index;index:idx_leads_company_phone" json:"companyId"`
 	Company         *Company   `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"company,omitempty"`
 	AssignedAgentID *string    `json:"assignedAgentId"`
 	LastInteraction *time.Time `json:"lastInteraction"`
@@ -40,6 +128,10 @@ type Lead struct {
 	SuggestedPropertiesCount int     `json:"suggestedPropertiesCount"`
 	Notes                    string  `json:"notes"`
 
+	// Score is how promising the lead is, from 0 to 100 (see LeadScoreWeights)
+	Score    int        `gorm:"not null;default:0;index" json:"score"`
+	ScoredAt *time.Time `json:"scoredAt,omitempty"`
+
 	Messages  []Message `gorm:"foreignKey:LeadID" json:"messages,omitempty"`
 	Summaries []Summary `gorm:"foreignKey:LeadID" json:"summaries,omitempty"`
 
@@ -47,3 +139,44 @@ type Lead struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
 }
+
+// minPhoneDigits is the fewest digits a phone needs to tell leads apart;
+// shorter values are placeholders such as "0" or "-"
+const minPhoneDigits = 7
+
+// NormalizePhone reduces phone to its digits with the country code, so
+// "+34 600-12-34-56", "0034 600123456" and "600 12 34 56" are all
+// "+34600123456". Spanish numbers are often written without their code.
+// It returns "" when phone cannot identify a lead.
+func NormalizePhone(phone string) string {
+	international := strings.HasPrefix(strings.TrimLeft(phone, " ("), "+")
+
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+
+	if !international && strings.HasPrefix(number, "00") {
+		number = number[2:]
+		international = true
+	}
+	if len(number) < minPhoneDigits {
+		return ""
+	}
+	if !international && len(number) == 9 {
+		number = "34" + number
+		international = true
+	}
+	if international {
+		return "+" + number
+	}
+	return number
+}
+
+// NormalizeEmail is the form emails of leads are stored and matched in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
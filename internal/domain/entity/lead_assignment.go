@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// AssignmentStrategy is how a LeadAssignmentRule picks the agent of a new lead
+type AssignmentStrategy string
+
+const (
+	AssignPropertyCreator AssignmentStrategy = "property_creator" // Agent who created the lead's property
+	AssignOwner           AssignmentStrategy = "owner"            // AgentID of the rule, who owns its zone/property type
+	AssignRoundRobin      AssignmentStrategy = "round_robin"      // Agent assigned a lead longest ago
+	AssignLeastLoaded     AssignmentStrategy = "least_loaded"     // Agent with the fewest open leads
+)
+
+// IsValid reports whether s is a known strategy
+func (s AssignmentStrategy) IsValid() bool {
+	switch s {
+	case AssignPropertyCreator, AssignOwner, AssignRoundRobin, AssignLeastLoaded:
+		return true
+	}
+	return false
+}
+
+// LeadAssignmentRule routes the new leads of a company to an agent. Rules
+// are tried lowest Priority first; the first one that matches the lead and
+// finds an agent assigns it.
+type LeadAssignmentRule struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID string   `gorm:"type:uuid;not null;index" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+
+	Name     string             `gorm:"not null" json:"name"`
+	Strategy AssignmentStrategy `gorm:"type:varchar(30);not null" json:"strategy"`
+	AgentID  *string            `gorm:"type:uuid" json:"agentId,omitempty"` // For AssignOwner
+
+	// Conditions on the lead, or its property when the lead lacks them;
+	// case-insensitive, an empty one matches anything
+	Zone         string `gorm:"type:varchar(100)" json:"zone"`
+	PropertyType string `gorm:"type:varchar(50)" json:"propertyType"`
+
+	Priority  int       `gorm:"default:0" json:"priority"`
+	IsEnabled bool      `gorm:"not null;index" json:"isEnabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Matches reports whether the conditions of r hold for lead, whose property may be nil
+func (r *LeadAssignmentRule) Matches(lead *Lead, property *Property) bool {
+	zone, propertyType := lead.Zone, lead.PropertyType
+	if property != nil {
+		if zone == "" {
+			zone = property.Zone
+		}
+		if propertyType == "" {
+			propertyType = string(property.Type)
+		}
+	}
+	return (r.Zone == "" || strings.EqualFold(r.Zone, strings.TrimSpace(zone))) &&
+		(r.PropertyType == "" || strings.EqualFold(r.PropertyType, strings.TrimSpace(propertyType)))
+}
+
+// LeadAssignment records a lead being assigned to an agent, or unassigned
+type LeadAssignment struct {
+	ID          string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	LeadID      string    `gorm:"type:uuid;not null;index" json:"leadId"`
+	Lead        *Lead     `gorm:"foreignKey:LeadID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CompanyID   string    `gorm:"type:uuid;not null;index" json:"companyId"`
+	FromAgentID *string   `gorm:"type:uuid" json:"fromAgentId,omitempty"`
+	ToAgentID   *string   `gorm:"type:uuid;index" json:"toAgentId,omitempty"` // Nil when unassigned
+	RuleID      *string   `gorm:"type:uuid" json:"ruleId,omitempty"`          // Rule that chose the agent
+	ByAgentID   *string   `gorm:"type:uuid" json:"byAgentId,omitempty"`       // Agent who reassigned it by hand
+	Note        string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time `gorm:"index" json:"createdAt"`
+}
+
+// AgentWorkload is what assignment rules know of an agent who can take leads
+type AgentWorkload struct {
+	AgentID        string
+	OpenLeads      int64
+	LastAssignedAt *time.Time
+}
@@ -0,0 +1,191 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	// LeadScoreWindow is how far back messages count towards the frequency of a lead
+	LeadScoreWindow = 90 * 24 * time.Hour
+	// BudgetFitMargin is how far from the budget of a lead a price still fits it
+	BudgetFitMargin = 0.2
+
+	// A lead scores full marks with this many messages in the window,
+	// presentation views, or properties in its budget
+	leadScoreFullMessages      = 5
+	leadScoreFullViews         = 3
+	leadScoreFullInventory     = 3
+	leadScoreFreshDays         = 7 // Contacts this recent score full recency
+	leadScoreUnknownSourceRate = 0.5
+)
+
+// DefaultSourceQuality rates how likely leads from each source are to buy,
+// by lowercase source. Companies may override it.
+var DefaultSourceQuality = map[string]float64{
+	"referral":    1,
+	"web":         0.8,
+	"phone":       0.8,
+	"manual":      0.7,
+	"idealista":   0.7,
+	"fotocasa":    0.7,
+	"kyero":       0.7,
+	"thinkspain":  0.7,
+	"habitaclia":  0.6,
+	"pisos.com":   0.6,
+	"milanuncios": 0.4,
+}
+
+// LeadScoreWeights is how much each factor counts in the score of the leads
+// of a company. Weights are relative to their sum.
+type LeadScoreWeights struct {
+	ID        string   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id,omitempty"`
+	CompanyID string   `gorm:"type:uuid;not null;uniqueIndex" json:"companyId"`
+	Company   *Company `gorm:"foreignKey:CompanyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+
+	Completeness  int `gorm:"not null" json:"completeness"`  // Contact details and preferences filled in
+	Recency       int `gorm:"not null" json:"recency"`       // How recently the lead wrote
+	Frequency     int `gorm:"not null" json:"frequency"`     // How often the lead wrote in LeadScoreWindow
+	Presentations int `gorm:"not null" json:"presentations"` // Views of the presentations sent to the lead
+	BudgetFit     int `gorm:"not null" json:"budgetFit"`     // Properties of the company in the lead's budget
+	Source        int `gorm:"not null" json:"source"`        // Quality of the source of the lead
+
+	// SourceQuality overrides DefaultSourceQuality, rates from 0 to 1
+	SourceQuality datatypes.JSONType[map[string]float64] `gorm:"type:jsonb" json:"sourceQuality"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DefaultLeadScoreWeights are the weights of companies that set none
+func DefaultLeadScoreWeights() LeadScoreWeights {
+	return LeadScoreWeights{
+		Completeness:  20,
+		Recency:       15,
+		Frequency:     15,
+		Presentations: 15,
+		BudgetFit:     20,
+		Source:        15,
+		SourceQuality: datatypes.NewJSONType(map[string]float64{}),
+	}
+}
+
+// Validate checks that weights are between 0 and 100, with at least one
+// above 0, and source rates between 0 and 1
+func (w *LeadScoreWeights) Validate() error {
+	total := 0
+	for _, weight := range []int{w.Completeness, w.Recency, w.Frequency, w.Presentations, w.BudgetFit, w.Source} {
+		if weight < 0 || weight > 100 {
+			return fmt.Errorf("invalid weight %d: weights go from 0 to 100", weight)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("invalid weights: at least one is required to be above 0")
+	}
+	for source, rate := range w.SourceQuality.Data() {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid quality %v of source %s: rates go from 0 to 1", rate, source)
+		}
+	}
+	return nil
+}
+
+// LeadScoreSignals is what a lead did, as far as its score goes
+type LeadScoreSignals struct {
+	LeadMessages       int64      // Messages from the lead in LeadScoreWindow
+	LastLeadMessageAt  *time.Time // Latest message from the lead
+	PresentationViews  int64
+	PropertiesInBudget int64 // Properties of the company within BudgetFitMargin of the budget
+}
+
+// LeadScoreFactors are the factors of a LeadScore, from 0 to 1
+type LeadScoreFactors struct {
+	Completeness  float64 `json:"completeness"`
+	Recency       float64 `json:"recency"`
+	Frequency     float64 `json:"frequency"`
+	Presentations float64 `json:"presentations"`
+	BudgetFit     float64 `json:"budgetFit"`
+	Source        float64 `json:"source"`
+}
+
+// LeadScore is how promising a lead is, from 0 to 100, and why
+type LeadScore struct {
+	LeadID   string           `json:"leadId"`
+	Score    int              `json:"score"`
+	Factors  LeadScoreFactors `json:"factors"`
+	ScoredAt time.Time        `json:"scoredAt"`
+}
+
+// Score scores lead from its signals at now
+func (w *LeadScoreWeights) Score(lead *Lead, signals LeadScoreSignals, now time.Time) LeadScore {
+	factors := LeadScoreFactors{
+		Completeness:  completeness(lead),
+		Frequency:     ratio(signals.LeadMessages, leadScoreFullMessages),
+		Presentations: ratio(signals.PresentationViews, leadScoreFullViews),
+		Source:        w.sourceQuality(lead.Source),
+	}
+	if lead.Budget > 0 {
+		factors.BudgetFit = ratio(signals.PropertiesInBudget, leadScoreFullInventory)
+	}
+
+	last := signals.LastLeadMessageAt
+	if last == nil {
+		last = lead.LastInteraction
+	}
+	if last != nil {
+		days := now.Sub(*last).Hours() / 24
+		window := LeadScoreWindow.Hours() / 24
+		factors.Recency = math.Max(0, math.Min(1, 1-(days-leadScoreFreshDays)/(window-leadScoreFreshDays)))
+	}
+
+	total := w.Completeness + w.Recency + w.Frequency + w.Presentations + w.BudgetFit + w.Source
+	score := 0.0
+	if total > 0 {
+		score = (factors.Completeness*float64(w.Completeness) +
+			factors.Recency*float64(w.Recency) +
+			factors.Frequency*float64(w.Frequency) +
+			factors.Presentations*float64(w.Presentations) +
+			factors.BudgetFit*float64(w.BudgetFit) +
+			factors.Source*float64(w.Source)) * 100 / float64(total)
+	}
+
+	return LeadScore{LeadID: lead.ID, Score: int(math.Round(score)), Factors: factors, ScoredAt: now}
+}
+
+func (w *LeadScoreWeights) sourceQuality(source string) float64 {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if rate, ok := w.SourceQuality.Data()[source]; ok {
+		return rate
+	}
+	if rate, ok := DefaultSourceQuality[source]; ok {
+		return rate
+	}
+	return leadScoreUnknownSourceRate
+}
+
+// completeness is the share of the contact details and preferences of lead filled in
+func completeness(lead *Lead) float64 {
+	filled := 0
+	for _, ok := range []bool{
+		lead.Name != "",
+		lead.Email != "",
+		NormalizePhone(lead.Phone) != "",
+		lead.Budget > 0,
+		lead.Zone != "",
+		lead.PropertyType != "",
+	} {
+		if ok {
+			filled++
+		}
+	}
+	return float64(filled) / 6
+}
+
+func ratio(n, full int64) float64 {
+	return math.Min(1, float64(n)/float64(full))
+}
@@ -0,0 +1,140 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// LeadStatuses are the statuses of a lead in funnel order
+var LeadStatuses = []LeadStatus{
+	LeadStatusNew,
+	LeadStatusContacted,
+	LeadStatusQualified,
+	LeadStatusClosed,
+	LeadStatusDismissed,
+	LeadStatusRejected,
+}
+
+// leadTransitions are the statuses a lead may move to from each status.
+// Leads that left the funnel can only come back as contacted.
+var leadTransitions = map[LeadStatus][]LeadStatus{
+	LeadStatusNew:       {LeadStatusContacted, LeadStatusQualified, LeadStatusDismissed, LeadStatusRejected},
+	LeadStatusContacted: {LeadStatusQualified, LeadStatusClosed, LeadStatusDismissed, LeadStatusRejected},
+	LeadStatusQualified: {LeadStatusContacted, LeadStatusClosed, LeadStatusDismissed, LeadStatusRejected},
+	LeadStatusClosed:    {LeadStatusContacted},
+	LeadStatusDismissed: {LeadStatusContacted},
+	LeadStatusRejected:  {LeadStatusContacted},
+}
+
+// IsValid reports whether s is a known status
+func (s LeadStatus) IsValid() bool {
+	_, ok := leadTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a lead in status s may move to to
+func (s LeadStatus) CanTransitionTo(to LeadStatus) bool {
+	for _, allowed := range leadTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether a lead in status s is still being worked on
+func (s LeadStatus) IsOpen() bool {
+	return s == LeadStatusNew || s == LeadStatusContacted || s == LeadStatusQualified
+}
+
+// RequiresReason reports whether moving a lead to s needs a LeadStatusReason
+func (s LeadStatus) RequiresReason() bool {
+	return s == LeadStatusDismissed || s == LeadStatusRejected
+}
+
+// LeadStatusReason tells why a lead was dismissed or rejected
+type LeadStatusReason string
+
+const (
+	LeadReasonNotInterested   LeadStatusReason = "not_interested"
+	LeadReasonUnreachable     LeadStatusReason = "unreachable"
+	LeadReasonBudgetMismatch  LeadStatusReason = "budget_mismatch"
+	LeadReasonBoughtElsewhere LeadStatusReason = "bought_elsewhere"
+	LeadReasonDuplicate       LeadStatusReason = "duplicate"
+	LeadReasonSpam            LeadStatusReason = "spam"
+	LeadReasonOther           LeadStatusReason = "other" // Needs a note
+)
+
+// IsValid reports whether r is a known reason code
+func (r LeadStatusReason) IsValid() bool {
+	switch r {
+	case LeadReasonNotInterested, LeadReasonUnreachable, LeadReasonBudgetMismatch,
+		LeadReasonBoughtElsewhere, LeadReasonDuplicate, LeadReasonSpam, LeadReasonOther:
+		return true
+	}
+	return false
+}
+
+// ValidateLeadTransition checks that a lead may move from from to to for
+// reason, explained by note
+func ValidateLeadTransition(from, to LeadStatus, reason LeadStatusReason, note string) error {
+	if !to.IsValid() {
+		return fmt.Errorf("invalid status %q", to)
+	}
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("invalid transition from %s to %s", from, to)
+	}
+	if !to.RequiresReason() {
+		if reason != "" {
+			return fmt.Errorf("invalid reason code: %s takes no reason", to)
+		}
+		return nil
+	}
+	if reason == "" {
+		return fmt.Errorf("reason code is required to move a lead to %s", to)
+	}
+	if !reason.IsValid() {
+		return fmt.Errorf("invalid reason code %q", reason)
+	}
+	if reason == LeadReasonOther && note == "" {
+		return fmt.Errorf("note is required for reason code %s", reason)
+	}
+	return nil
+}
+
+// LeadStatusChange records a lead moving from one status to another
+type LeadStatusChange struct {
+	ID         string           `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	LeadID     string           `gorm:"type:uuid;not null;index" json:"leadId"`
+	Lead       *Lead            `gorm:"foreignKey:LeadID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CompanyID  string           `gorm:"type:uuid;not null;index" json:"companyId"`
+	FromStatus LeadStatus       `gorm:"type:varchar(20);not null" json:"fromStatus"`
+	ToStatus   LeadStatus       `gorm:"type:varchar(20);not null;index" json:"toStatus"`
+	ReasonCode LeadStatusReason `gorm:"type:varchar(30)" json:"reasonCode,omitempty"`
+	Note       string           `gorm:"type:text" json:"note,omitempty"`
+	AgentID    *string          `gorm:"type:uuid" json:"agentId,omitempty"` // Nil when the system made it, e.g. on an inbound email
+	CreatedAt  time.Time        `gorm:"index" json:"createdAt"`
+}
+
+// LeadStatusCount is how many leads reached Status, for ReasonCode when set
+type LeadStatusCount struct {
+	Status     LeadStatus
+	ReasonCode *LeadStatusReason
+	Leads      int64
+}
+
+// LeadFunnel tells how far the leads created in a period got
+type LeadFunnel struct {
+	Since   time.Time         `json:"since"`
+	Until   time.Time         `json:"until"`
+	Created int64             `json:"created"`
+	Stages  []LeadFunnelStage `json:"stages"`
+}
+
+// LeadFunnelStage is how many of the leads of a LeadFunnel reached Status
+type LeadFunnelStage struct {
+	Status  LeadStatus                 `json:"status"`
+	Leads   int64                      `json:"leads"`
+	Rate    float64                    `json:"rate"` // Share of the leads created
+	Reasons map[LeadStatusReason]int64 `json:"reasons,omitempty"`
+}
@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// Kinds of login throttles
+const (
+	ThrottleAccount       = "account"        // Failed logins per email
+	ThrottleIP            = "ip"             // Failed logins per client IP
+	ThrottlePasswordReset = "password-reset" // Password reset requests per email
+)
+
+// LoginThrottle counts attempts for one email or IP inside a time window
+// and holds the lock applied once there are too many.
+type LoginThrottle struct {
+	ID          string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Kind        string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_login_throttle_kind_key" json:"kind"`
+	Key         string     `gorm:"not null;uniqueIndex:idx_login_throttle_kind_key" json:"key"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	WindowStart time.Time  `gorm:"not null" json:"windowStart"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// RetryAfter returns how long the throttle is still locked at now
+func (t *LoginThrottle) RetryAfter(now time.Time) time.Duration {
+	if t == nil || t.LockedUntil == nil || !now.Before(*t.LockedUntil) {
+		return 0
+	}
+	return t.LockedUntil.Sub(now)
+}
+
+// LockoutEvent records that an account or IP was locked, so company admins can review it
+type LockoutEvent struct {
+	ID          string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CompanyID   *string   `gorm:"type:uuid;index" json:"companyId,omitempty"` // Company of the targeted agent, if the email exists
+	AgentID     *string   `gorm:"type:uuid" json:"agentId,omitempty"`
+	Kind        string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Email       string    `json:"email"`
+	IPAddress   string    `gorm:"type:varchar(45)" json:"ipAddress"`
+	Attempts    int       `json:"attempts"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	CreatedAt   time.Time `gorm:"index" json:"createdAt"`
+}
@@ -19,7 +19,12 @@ type Message struct {
 	LeadID     string     `gorm:"index;not null"`
 	SenderType SenderType `gorm:"type:varchar(10);not null"`
 	Content    string     `gorm:"type:text;not null"`
-	Timestamp  time.Time  `gorm:"not null"`
+	Timestamp  time.Time  `gorm:"not null"`         // When it was sent, e.g. the date of the email it came in
+	Channel    string     `gorm:"type:varchar(50)"` // How it was sent: the portal of inbound contacts
+	PropertyID *string    `gorm:"type:uuid;index"`  // Property it was about, if any
+
+	// InboundEmailID is the original email of a message received by email
+	InboundEmailID *string `gorm:"type:uuid;index"`
 
 	Lead Lead `gorm:"foreignKey:LeadID"`
 
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type PresentationToken struct {
 	LeadID      string   `json:"leadId"`
 	PropertyIDs []string `json:"propertyIds"`
@@ -18,3 +20,12 @@ type PropertyMatch struct {
 	IsInquired   bool      `json:"isInquired"`
 	IsDismissed  bool      `json:"isDismissed"`
 }
+
+// PresentationView records a lead opening a presentation sent to them
+type PresentationView struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	LeadID    string    `gorm:"type:uuid;not null;index" json:"leadId"`
+	Lead      *Lead     `gorm:"foreignKey:LeadID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CompanyID string    `gorm:"type:uuid;not null;index" json:"companyId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -9,14 +9,23 @@ import (
 
 // ProcessedEmail represents an email that has already been processed by the worker.
 // This prevents re-processing the same email multiple times without modifying the source inbox state.
+// A message is processed at most once per company, whichever replica or inbox sees it first.
+// It is claimed while being processed and only marked processed once its lead,
+// or its failed email record, has been saved.
 type ProcessedEmail struct {
 	ID          string    `gorm:"type:uuid;primary_key;" json:"id"`
-	MessageID   string    `gorm:"type:varchar(255);index;not null" json:"messageId"` // Unique ID from email provider
-	CompanyID   string    `gorm:"type:uuid;index;not null" json:"companyId"`
+	MessageID   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_processed_email_company_message" json:"messageId"` // Unique ID from email provider
+	CompanyID   string    `gorm:"type:uuid;index;not null;uniqueIndex:idx_processed_email_company_message" json:"companyId"`
 	Company     Company   `gorm:"foreignKey:CompanyID" json:"-"`
-	ProcessedAt time.Time `json:"processedAt"`
+	Status      string    `gorm:"type:varchar(20);not null;default:processed" json:"status"`
+	ProcessedAt time.Time `json:"processedAt"` // when it was claimed, while it is still processing
 }
 
+const (
+	ProcessedEmailProcessing = "processing"
+	ProcessedEmailProcessed  = "processed"
+)
+
 func (pe *ProcessedEmail) BeforeCreate(tx *gorm.DB) (err error) {
 	if pe.ID == "" {
 		pe.ID = uuid.New().String()
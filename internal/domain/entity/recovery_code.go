@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// RecoveryCode is a one-time code that replaces the TOTP code when the agent
+// lost their authenticator. Only a bcrypt hash is stored.
+type RecoveryCode struct {
+	ID        string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AgentID   string     `gorm:"type:uuid;not null;index" json:"agentId"`
+	Agent     *Agent     `gorm:"foreignKey:AgentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
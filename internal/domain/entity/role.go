@@ -0,0 +1,107 @@
+package entity
+
+// AgentRole is the role of an agent inside its company
+type AgentRole string
+
+const (
+	RoleOwner    AgentRole = "owner"
+	RoleAdmin    AgentRole = "admin"
+	RoleManager  AgentRole = "manager"
+	RoleAgent    AgentRole = "agent"
+	RoleReadOnly AgentRole = "read-only"
+)
+
+// Permission is a named action that a role may or may not perform
+type Permission string
+
+const (
+	PermissionLeadRead   Permission = "lead:read"
+	PermissionLeadWrite  Permission = "lead:write"
+	PermissionLeadDelete Permission = "lead:delete"
+	PermissionLeadAssign Permission = "lead:assign"
+
+	PermissionPropertyRead    Permission = "property:read"
+	PermissionPropertyWrite   Permission = "property:write"
+	PermissionPropertyManage  Permission = "property:manage" // update or delete properties created by someone else
+	PermissionPropertyPublish Permission = "property:publish"
+
+	PermissionAgentRead   Permission = "agent:read"
+	PermissionAgentManage Permission = "agent:manage"
+	PermissionAgentRole   Permission = "agent:role"
+
+	PermissionCompanyRead   Permission = "company:read"
+	PermissionCompanyManage Permission = "company:manage"
+
+	PermissionEmailConfigManage Permission = "email-config:manage"
+
+	PermissionAPIKeyManage Permission = "api-key:manage"
+)
+
+// rolePermissions is the permission matrix. Owners and admins only differ in
+// what they can do to other owners, which is checked where roles are changed.
+var rolePermissions = map[AgentRole][]Permission{
+	RoleOwner: {
+		PermissionLeadRead, PermissionLeadWrite, PermissionLeadDelete, PermissionLeadAssign,
+		PermissionPropertyRead, PermissionPropertyWrite, PermissionPropertyManage, PermissionPropertyPublish,
+		PermissionAgentRead, PermissionAgentManage, PermissionAgentRole,
+		PermissionCompanyRead, PermissionCompanyManage,
+		PermissionEmailConfigManage,
+		PermissionAPIKeyManage,
+	},
+	RoleAdmin: {
+		PermissionLeadRead, PermissionLeadWrite, PermissionLeadDelete, PermissionLeadAssign,
+		PermissionPropertyRead, PermissionPropertyWrite, PermissionPropertyManage, PermissionPropertyPublish,
+		PermissionAgentRead, PermissionAgentManage, PermissionAgentRole,
+		PermissionCompanyRead, PermissionCompanyManage,
+		PermissionEmailConfigManage,
+		PermissionAPIKeyManage,
+	},
+	RoleManager: {
+		PermissionLeadRead, PermissionLeadWrite, PermissionLeadDelete, PermissionLeadAssign,
+		PermissionPropertyRead, PermissionPropertyWrite, PermissionPropertyManage, PermissionPropertyPublish,
+		PermissionAgentRead,
+		PermissionCompanyRead,
+	},
+	RoleAgent: {
+		PermissionLeadRead, PermissionLeadWrite,
+		PermissionPropertyRead, PermissionPropertyWrite,
+		PermissionAgentRead,
+		PermissionCompanyRead,
+	},
+	RoleReadOnly: {
+		PermissionLeadRead,
+		PermissionPropertyRead,
+		PermissionAgentRead,
+		PermissionCompanyRead,
+	},
+}
+
+// Roles lists every role, from most to least privileged
+var Roles = []AgentRole{RoleOwner, RoleAdmin, RoleManager, RoleAgent, RoleReadOnly}
+
+// IsValid reports whether r is one of the known roles
+func (r AgentRole) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Grants is what the executor of an action may do: the role of an agent, or
+// the scopes of an API key
+type Grants interface {
+	Can(p Permission) bool
+}
+
+// Can reports whether r grants permission p. Unknown roles grant nothing.
+func (r AgentRole) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the permissions granted to r
+func (r AgentRole) Permissions() []Permission {
+	return append([]Permission(nil), rolePermissions[r]...)
+}
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// Session is one login of an agent. Access tokens carry its ID and stop
+// working as soon as it is revoked, and its refresh token rotates on every
+// use. Sessions are deleted along with their agent.
+type Session struct {
+	ID      string `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AgentID string `gorm:"type:uuid;not null;index" json:"agentId"`
+	Agent   *Agent `gorm:"foreignKey:AgentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+
+	RefreshTokenHash  string `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the current refresh token
+	PreviousTokenHash string `gorm:"type:varchar(64);index" json:"-"`                // Already rotated token, kept to detect reuse
+
+	UserAgent string `json:"userAgent"`
+	IPAddress string `gorm:"type:varchar(45)" json:"ipAddress"`
+
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	RevokedAt  *time.Time `gorm:"index" json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// IsActive checks if the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
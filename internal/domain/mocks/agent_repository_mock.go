@@ -11,21 +11,21 @@ type AgentRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *AgentRepositoryMock) Create(agent *entity.Agent) error {
-	args := m.Called(agent)
+func (m *AgentRepositoryMock) Create(ctx context.Context, agent *entity.Agent) error {
+	args := m.Called(ctx, agent)
 	return args.Error(0)
 }
 
-func (m *AgentRepositoryMock) FindByID(id string) (*entity.Agent, error) {
-	args := m.Called(id)
+func (m *AgentRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Agent, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Agent), args.Error(1)
 }
 
-func (m *AgentRepositoryMock) FindAll() ([]entity.Agent, error) {
-	args := m.Called()
+func (m *AgentRepositoryMock) FindAll(ctx context.Context) ([]entity.Agent, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]entity.Agent), args.Error(1)
 }
 
@@ -34,8 +34,8 @@ func (m *AgentRepositoryMock) UpdatePartial(ctx context.Context, id string, fiel
 	return args.Error(0)
 }
 
-func (m *AgentRepositoryMock) Delete(id string) error {
-	args := m.Called(id)
+func (m *AgentRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
@@ -46,3 +46,8 @@ func (m *AgentRepositoryMock) FindByEmail(ctx context.Context, email string) (*e
 	}
 	return args.Get(0).(*entity.Agent), args.Error(1)
 }
+
+func (m *AgentRepositoryMock) EmailInUse(ctx context.Context, email string) (bool, error) {
+	args := m.Called(ctx, email)
+	return args.Bool(0), args.Error(1)
+}
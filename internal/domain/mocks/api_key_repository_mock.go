@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type APIKeyRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *APIKeyRepositoryMock) Create(ctx context.Context, key *entity.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *APIKeyRepositoryMock) FindAll(ctx context.Context) ([]entity.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.APIKey), args.Error(1)
+}
+
+func (m *APIKeyRepositoryMock) FindByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.APIKey), args.Error(1)
+}
+
+func (m *APIKeyRepositoryMock) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIKeyRepositoryMock) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
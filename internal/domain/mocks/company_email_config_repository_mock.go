@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type CompanyEmailConfigRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *CompanyEmailConfigRepositoryMock) Create(ctx context.Context, config *entity.CompanyEmailConfig) error {
+	args := m.Called(ctx, config)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) Update(ctx context.Context, config *entity.CompanyEmailConfig) error {
+	args := m.Called(ctx, config)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) FindByID(ctx context.Context, id string) (*entity.CompanyEmailConfig, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.CompanyEmailConfig), args.Error(1)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) FindByCompanyID(ctx context.Context, companyID string) ([]*entity.CompanyEmailConfig, error) {
+	args := m.Called(ctx, companyID)
+	return args.Get(0).([]*entity.CompanyEmailConfig), args.Error(1)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) FindAllEnabled(ctx context.Context) ([]*entity.CompanyEmailConfig, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*entity.CompanyEmailConfig), args.Error(1)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error {
+	args := m.Called(ctx, id, syncTime)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) UpdateTokens(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	args := m.Called(ctx, id, accessToken, refreshToken, expiry)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) UpdateSyncStatus(ctx context.Context, id string, consecutiveFailures int, lastError string) error {
+	args := m.Called(ctx, id, consecutiveFailures, lastError)
+	return args.Error(0)
+}
+
+func (m *CompanyEmailConfigRepositoryMock) Disable(ctx context.Context, id, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
@@ -11,21 +11,21 @@ type CompanyRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *CompanyRepositoryMock) Create(company *entity.Company) error {
-	args := m.Called(company)
+func (m *CompanyRepositoryMock) Create(ctx context.Context, company *entity.Company) error {
+	args := m.Called(ctx, company)
 	return args.Error(0)
 }
 
-func (m *CompanyRepositoryMock) FindByID(id string) (*entity.Company, error) {
-	args := m.Called(id)
+func (m *CompanyRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Company, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Company), args.Error(1)
 }
 
-func (m *CompanyRepositoryMock) FindAll() ([]entity.Company, error) {
-	args := m.Called()
+func (m *CompanyRepositoryMock) FindAll(ctx context.Context) ([]entity.Company, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]entity.Company), args.Error(1)
 }
 
@@ -34,8 +34,8 @@ func (m *CompanyRepositoryMock) UpdatePartial(ctx context.Context, id string, fi
 	return args.Error(0)
 }
 
-func (m *CompanyRepositoryMock) Delete(id string) error {
-	args := m.Called(id)
+func (m *CompanyRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
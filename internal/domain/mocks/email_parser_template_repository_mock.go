@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type EmailParserTemplateRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *EmailParserTemplateRepositoryMock) Create(ctx context.Context, template *entity.EmailParserTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *EmailParserTemplateRepositoryMock) Update(ctx context.Context, template *entity.EmailParserTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *EmailParserTemplateRepositoryMock) FindByID(ctx context.Context, id string) (*entity.EmailParserTemplate, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.EmailParserTemplate), args.Error(1)
+}
+
+func (m *EmailParserTemplateRepositoryMock) FindAll(ctx context.Context) ([]entity.EmailParserTemplate, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.EmailParserTemplate), args.Error(1)
+}
+
+func (m *EmailParserTemplateRepositoryMock) FindEnabled(ctx context.Context) ([]entity.EmailParserTemplate, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.EmailParserTemplate), args.Error(1)
+}
+
+func (m *EmailParserTemplateRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
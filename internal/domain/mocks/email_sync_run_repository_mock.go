@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type EmailSyncRunRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *EmailSyncRunRepositoryMock) Create(ctx context.Context, run *entity.EmailSyncRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *EmailSyncRunRepositoryMock) FindRecentByConfigID(ctx context.Context, configID string, limit int) ([]entity.EmailSyncRun, error) {
+	args := m.Called(ctx, configID, limit)
+	return args.Get(0).([]entity.EmailSyncRun), args.Error(1)
+}
+
+func (m *EmailSyncRunRepositoryMock) DeleteBefore(ctx context.Context, configID string, before time.Time) error {
+	args := m.Called(ctx, configID, before)
+	return args.Error(0)
+}
@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+type FailedEmailRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *FailedEmailRepositoryMock) Create(ctx context.Context, failed *entity.FailedEmail) error {
+	args := m.Called(ctx, failed)
+	return args.Error(0)
+}
+
+func (m *FailedEmailRepositoryMock) Update(ctx context.Context, failed *entity.FailedEmail) error {
+	args := m.Called(ctx, failed)
+	return args.Error(0)
+}
+
+func (m *FailedEmailRepositoryMock) FindByID(ctx context.Context, id string) (*entity.FailedEmail, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.FailedEmail), args.Error(1)
+}
+
+func (m *FailedEmailRepositoryMock) FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.FailedEmail, error) {
+	args := m.Called(ctx, companyID, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.FailedEmail), args.Error(1)
+}
+
+func (m *FailedEmailRepositoryMock) FindAll(ctx context.Context, filter repository.FailedEmailFilter) ([]entity.FailedEmail, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.FailedEmail), args.Error(1)
+}
+
+func (m *FailedEmailRepositoryMock) FindUnresolvedByReference(ctx context.Context, reference string) ([]entity.FailedEmail, error) {
+	args := m.Called(ctx, reference)
+	return args.Get(0).([]entity.FailedEmail), args.Error(1)
+}
+
+func (m *FailedEmailRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
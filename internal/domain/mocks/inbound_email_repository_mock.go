@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type InboundEmailRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *InboundEmailRepositoryMock) Create(ctx context.Context, inbound *entity.InboundEmail) error {
+	args := m.Called(ctx, inbound)
+	return args.Error(0)
+}
+
+func (m *InboundEmailRepositoryMock) UpdateResult(ctx context.Context, inbound *entity.InboundEmail) error {
+	args := m.Called(ctx, inbound)
+	return args.Error(0)
+}
+
+func (m *InboundEmailRepositoryMock) FindByID(ctx context.Context, id string) (*entity.InboundEmail, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.InboundEmail), args.Error(1)
+}
+
+func (m *InboundEmailRepositoryMock) FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.InboundEmail, error) {
+	args := m.Called(ctx, companyID, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.InboundEmail), args.Error(1)
+}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type InvitationRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *InvitationRepositoryMock) Create(ctx context.Context, invitation *entity.Invitation) error {
+	args := m.Called(ctx, invitation)
+	return args.Error(0)
+}
+
+func (m *InvitationRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Invitation, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Invitation), args.Error(1)
+}
+
+func (m *InvitationRepositoryMock) FindByTokenHash(ctx context.Context, hash string) (*entity.Invitation, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Invitation), args.Error(1)
+}
+
+func (m *InvitationRepositoryMock) FindPending(ctx context.Context) ([]entity.Invitation, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.Invitation), args.Error(1)
+}
+
+func (m *InvitationRepositoryMock) RevokePendingForEmail(ctx context.Context, companyID, email string) error {
+	args := m.Called(ctx, companyID, email)
+	return args.Error(0)
+}
+
+func (m *InvitationRepositoryMock) MarkAsAccepted(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *InvitationRepositoryMock) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
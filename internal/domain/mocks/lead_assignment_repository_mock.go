@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type LeadAssignmentRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LeadAssignmentRepositoryMock) CreateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *LeadAssignmentRepositoryMock) UpdateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *LeadAssignmentRepositoryMock) FindRuleByID(ctx context.Context, id string) (*entity.LeadAssignmentRule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LeadAssignmentRule), args.Error(1)
+}
+
+func (m *LeadAssignmentRepositoryMock) FindRules(ctx context.Context) ([]entity.LeadAssignmentRule, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.LeadAssignmentRule), args.Error(1)
+}
+
+func (m *LeadAssignmentRepositoryMock) FindEnabledRules(ctx context.Context) ([]entity.LeadAssignmentRule, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.LeadAssignmentRule), args.Error(1)
+}
+
+func (m *LeadAssignmentRepositoryMock) DeleteRule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *LeadAssignmentRepositoryMock) Assign(ctx context.Context, assignment *entity.LeadAssignment) error {
+	args := m.Called(ctx, assignment)
+	return args.Error(0)
+}
+
+func (m *LeadAssignmentRepositoryMock) FindByLead(ctx context.Context, leadID string) ([]entity.LeadAssignment, error) {
+	args := m.Called(ctx, leadID)
+	return args.Get(0).([]entity.LeadAssignment), args.Error(1)
+}
+
+func (m *LeadAssignmentRepositoryMock) Workloads(ctx context.Context) ([]entity.AgentWorkload, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.AgentWorkload), args.Error(1)
+}
@@ -11,42 +11,65 @@ type LeadRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *LeadRepositoryMock) Create(lead *entity.Lead) error {
-	args := m.Called(lead)
+func (m *LeadRepositoryMock) Create(ctx context.Context, lead *entity.Lead) error {
+	args := m.Called(ctx, lead)
 	return args.Error(0)
 }
 
-func (m *LeadRepositoryMock) FindByID(id string) (*entity.Lead, error) {
-	args := m.Called(id)
+func (m *LeadRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Lead, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Lead), args.Error(1)
 }
 
-func (m *LeadRepositoryMock) FindAll() ([]entity.Lead, error) {
-	args := m.Called()
+func (m *LeadRepositoryMock) FindAll(ctx context.Context) ([]entity.Lead, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]entity.Lead), args.Error(1)
 }
 
-func (m *LeadRepositoryMock) Update(lead *entity.Lead) error {
-	args := m.Called(lead)
+func (m *LeadRepositoryMock) Update(ctx context.Context, lead *entity.Lead) error {
+	args := m.Called(ctx, lead)
 	return args.Error(0)
 }
 
-func (m *LeadRepositoryMock) Delete(id string) error {
-	args := m.Called(id)
+func (m *LeadRepositoryMock) UpdateWithHistory(ctx context.Context, lead *entity.Lead, change *entity.LeadStatusChange, assignment *entity.LeadAssignment) error {
+	args := m.Called(ctx, lead, change, assignment)
 	return args.Error(0)
 }
 
-func (m *LeadRepositoryMock) FindByEmail(ctx context.Context, email string) (*entity.Lead, error) {
-	args := m.Called(ctx, email)
+func (m *LeadRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *LeadRepositoryMock) FindByEmail(ctx context.Context, companyID, email string) (*entity.Lead, error) {
+	args := m.Called(ctx, companyID, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Lead), args.Error(1)
+}
+
+func (m *LeadRepositoryMock) FindByPhone(ctx context.Context, companyID, phone string) (*entity.Lead, error) {
+	args := m.Called(ctx, companyID, phone)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Lead), args.Error(1)
 }
 
+func (m *LeadRepositoryMock) FindDuplicates(ctx context.Context, lead *entity.Lead) ([]entity.Lead, error) {
+	args := m.Called(ctx, lead)
+	return args.Get(0).([]entity.Lead), args.Error(1)
+}
+
+func (m *LeadRepositoryMock) Merge(ctx context.Context, survivor *entity.Lead, duplicateID string) error {
+	args := m.Called(ctx, survivor, duplicateID)
+	return args.Error(0)
+}
+
 func (m *LeadRepositoryMock) FindByCompanyId(ctx context.Context, companyId string) ([]entity.Lead, error) {
 	args := m.Called(ctx, companyId)
 	return args.Get(0).([]entity.Lead), args.Error(1)
@@ -56,3 +79,11 @@ func (m *LeadRepositoryMock) FindByPropertyId(ctx context.Context, propertyId st
 	args := m.Called(ctx, propertyId)
 	return args.Get(0).([]entity.Lead), args.Error(1)
 }
+
+func (m *LeadRepositoryMock) Search(ctx context.Context, filter entity.LeadFilter) (*entity.LeadPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LeadPage), args.Error(1)
+}
@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type LeadScoreRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LeadScoreRepositoryMock) FindWeights(ctx context.Context) (*entity.LeadScoreWeights, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LeadScoreWeights), args.Error(1)
+}
+
+func (m *LeadScoreRepositoryMock) SaveWeights(ctx context.Context, weights *entity.LeadScoreWeights) error {
+	args := m.Called(ctx, weights)
+	return args.Error(0)
+}
+
+func (m *LeadScoreRepositoryMock) Signals(ctx context.Context, lead *entity.Lead, since time.Time) (*entity.LeadScoreSignals, error) {
+	args := m.Called(ctx, lead, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LeadScoreSignals), args.Error(1)
+}
+
+func (m *LeadScoreRepositoryMock) SaveScore(ctx context.Context, score *entity.LeadScore) error {
+	args := m.Called(ctx, score)
+	return args.Error(0)
+}
+
+func (m *LeadScoreRepositoryMock) LeadsAfter(ctx context.Context, afterID string, limit int) ([]entity.Lead, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.Lead), args.Error(1)
+}
+
+func (m *LeadScoreRepositoryMock) SignalsByLead(ctx context.Context, leads []entity.Lead, since time.Time) (map[string]entity.LeadScoreSignals, error) {
+	args := m.Called(ctx, leads, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]entity.LeadScoreSignals), args.Error(1)
+}
+
+func (m *LeadScoreRepositoryMock) SaveScores(ctx context.Context, scores []entity.LeadScore) error {
+	args := m.Called(ctx, scores)
+	return args.Error(0)
+}
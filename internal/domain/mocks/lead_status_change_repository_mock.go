@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type LeadStatusChangeRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LeadStatusChangeRepositoryMock) Record(ctx context.Context, change *entity.LeadStatusChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *LeadStatusChangeRepositoryMock) FindByLead(ctx context.Context, leadID string) ([]entity.LeadStatusChange, error) {
+	args := m.Called(ctx, leadID)
+	return args.Get(0).([]entity.LeadStatusChange), args.Error(1)
+}
+
+func (m *LeadStatusChangeRepositoryMock) Funnel(ctx context.Context, since, until time.Time) (int64, []entity.LeadStatusCount, error) {
+	args := m.Called(ctx, since, until)
+	return args.Get(0).(int64), args.Get(1).([]entity.LeadStatusCount), args.Error(2)
+}
@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type LoginThrottleRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LoginThrottleRepositoryMock) Find(ctx context.Context, kind, key string) (*entity.LoginThrottle, error) {
+	args := m.Called(ctx, kind, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LoginThrottle), args.Error(1)
+}
+
+func (m *LoginThrottleRepositoryMock) Hit(ctx context.Context, kind, key string, window time.Duration) (*entity.LoginThrottle, error) {
+	args := m.Called(ctx, kind, key, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.LoginThrottle), args.Error(1)
+}
+
+func (m *LoginThrottleRepositoryMock) Lock(ctx context.Context, id string, until time.Time) error {
+	args := m.Called(ctx, id, until)
+	return args.Error(0)
+}
+
+func (m *LoginThrottleRepositoryMock) Delete(ctx context.Context, kind, key string) error {
+	args := m.Called(ctx, kind, key)
+	return args.Error(0)
+}
+
+func (m *LoginThrottleRepositoryMock) CreateEvent(ctx context.Context, event *entity.LockoutEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *LoginThrottleRepositoryMock) FindEvents(ctx context.Context, limit int) ([]entity.LockoutEvent, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]entity.LockoutEvent), args.Error(1)
+}
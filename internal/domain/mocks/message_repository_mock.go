@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,12 +11,12 @@ type MessageRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *MessageRepositoryMock) Create(msg *entity.Message) error {
-	args := m.Called(msg)
+func (m *MessageRepositoryMock) Create(ctx context.Context, msg *entity.Message) error {
+	args := m.Called(ctx, msg)
 	return args.Error(0)
 }
 
-func (m *MessageRepositoryMock) FindByLeadID(leadID string) ([]entity.Message, error) {
-	args := m.Called(leadID)
+func (m *MessageRepositoryMock) FindByLeadID(ctx context.Context, leadID string) ([]entity.Message, error) {
+	args := m.Called(ctx, leadID)
 	return args.Get(0).([]entity.Message), args.Error(1)
 }
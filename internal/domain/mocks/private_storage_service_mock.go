@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PrivateStorageServiceMock struct {
+	mock.Mock
+}
+
+func (m *PrivateStorageServiceMock) Save(ctx context.Context, content io.Reader) (string, error) {
+	args := m.Called(ctx, content)
+	return args.String(0), args.Error(1)
+}
+
+func (m *PrivateStorageServiceMock) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
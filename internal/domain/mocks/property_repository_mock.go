@@ -11,31 +11,31 @@ type PropertyRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *PropertyRepositoryMock) Create(property *entity.Property) error {
-	args := m.Called(property)
+func (m *PropertyRepositoryMock) Create(ctx context.Context, property *entity.Property) error {
+	args := m.Called(ctx, property)
 	return args.Error(0)
 }
 
-func (m *PropertyRepositoryMock) FindByID(id string) (*entity.Property, error) {
-	args := m.Called(id)
+func (m *PropertyRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Property, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.Property), args.Error(1)
 }
 
-func (m *PropertyRepositoryMock) FindAll() ([]entity.Property, error) {
-	args := m.Called()
+func (m *PropertyRepositoryMock) FindAll(ctx context.Context) ([]entity.Property, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]entity.Property), args.Error(1)
 }
 
-func (m *PropertyRepositoryMock) Update(property *entity.Property) error {
-	args := m.Called(property)
+func (m *PropertyRepositoryMock) Update(ctx context.Context, property *entity.Property) error {
+	args := m.Called(ctx, property)
 	return args.Error(0)
 }
 
-func (m *PropertyRepositoryMock) Delete(id string) error {
-	args := m.Called(id)
+func (m *PropertyRepositoryMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type RecoveryCodeRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *RecoveryCodeRepositoryMock) ReplaceForAgent(ctx context.Context, agentID string, codes []entity.RecoveryCode) error {
+	args := m.Called(ctx, agentID, codes)
+	return args.Error(0)
+}
+
+func (m *RecoveryCodeRepositoryMock) FindUnusedByAgent(ctx context.Context, agentID string) ([]entity.RecoveryCode, error) {
+	args := m.Called(ctx, agentID)
+	return args.Get(0).([]entity.RecoveryCode), args.Error(1)
+}
+
+func (m *RecoveryCodeRepositoryMock) MarkAsUsed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *RecoveryCodeRepositoryMock) DeleteForAgent(ctx context.Context, agentID string) error {
+	args := m.Called(ctx, agentID)
+	return args.Error(0)
+}
@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/stretchr/testify/mock"
+)
+
+type SessionRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *SessionRepositoryMock) Create(ctx context.Context, session *entity.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *SessionRepositoryMock) FindByID(ctx context.Context, id string) (*entity.Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Session), args.Error(1)
+}
+
+func (m *SessionRepositoryMock) FindByTokenHash(ctx context.Context, hash string) (*entity.Session, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Session), args.Error(1)
+}
+
+func (m *SessionRepositoryMock) Rotate(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, id, oldHash, newHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *SessionRepositoryMock) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *SessionRepositoryMock) RevokeAllForAgent(ctx context.Context, agentID string) error {
+	args := m.Called(ctx, agentID)
+	return args.Error(0)
+}
+
+func (m *SessionRepositoryMock) DeleteExpired(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
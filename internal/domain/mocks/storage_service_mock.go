@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 
 	"github.com/stretchr/testify/mock"
@@ -15,3 +16,8 @@ func (m *StorageServiceMock) UploadFile(ctx context.Context, file multipart.File
 	args := m.Called(ctx, file, header)
 	return args.String(0), args.Error(1)
 }
+
+func (m *StorageServiceMock) SaveFile(ctx context.Context, filename string, content io.Reader) (string, error) {
+	args := m.Called(ctx, filename, content)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,26 @@
+// Package tenant carries the caller's company through a request context so
+// that repositories can restrict every query to the rows of that company.
+package tenant
+
+import "context"
+
+type contextKey struct{}
+
+// WithCompanyID returns a copy of ctx scoped to the given company
+func WithCompanyID(ctx context.Context, companyID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, companyID)
+}
+
+// CompanyID returns the company the context is scoped to, if any.
+// Contexts without a company (public endpoints, background jobs that work
+// across companies) are not scoped.
+func CompanyID(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	companyID, ok := ctx.Value(contextKey{}).(string)
+	if !ok || companyID == "" {
+		return "", false
+	}
+	return companyID, true
+}
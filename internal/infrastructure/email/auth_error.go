@@ -0,0 +1,38 @@
+package email
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrAuthFailed is returned when the mail server rejects the stored credentials
+var ErrAuthFailed = errors.New("authentication failed")
+
+// IsAuthError reports whether err means the inbox credentials no longer work:
+// a rejected IMAP login, a refresh token that was revoked or expired, or an
+// API call answered with 401. Outages of the token endpoint are not.
+func IsAuthError(err error) bool {
+	if errors.Is(err, ErrAuthFailed) {
+		return true
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.ErrorCode == "invalid_grant" {
+			return true
+		}
+		return retrieveErr.Response != nil &&
+			(retrieveErr.Response.StatusCode == http.StatusBadRequest || retrieveErr.Response.StatusCode == http.StatusUnauthorized)
+	}
+
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) {
+		return graphErr.StatusCode == http.StatusUnauthorized
+	}
+
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized
+}
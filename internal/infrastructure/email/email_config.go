@@ -1,6 +1,7 @@
 package email
 
 import (
+	"crypto/tls"
 	"os"
 	"strconv"
 )
@@ -16,6 +17,11 @@ type Config struct {
 	InboxFolder      string
 	PollIntervalSecs int
 	DefaultCompanyID string // Mocked for now: ecf4ed64-06b5-4129-af4e-72718751e087
+	DefaultAgentID   string // Agent new leads are assigned to, if any
+	ConfigID         string // Inbox the emails are read from, if any
+
+	// TLSConfig of the IMAP connection, nil for the system defaults
+	TLSConfig *tls.Config
 }
 
 // LoadConfig loads email configuration from environment variables
@@ -1,20 +1,30 @@
 package email
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
 )
 
+// TokenSaver persists an OAuth2 token that was refreshed while talking to the
+// API. Both tokens are already encrypted.
+type TokenSaver func(encryptedAccessToken, encryptedRefreshToken string, expiry time.Time) error
+
 // GmailClient handles Gmail API operations for OAuth2 authenticated accounts
 type GmailClient struct {
 	service       *gmail.Service
@@ -23,13 +33,39 @@ type GmailClient struct {
 	oauth2Config  *oauth2.Config
 }
 
-// NewGmailClient creates a new Gmail API client
-func NewGmailClient(config *entity.CompanyEmailConfig, encryptionKey string, oauth2Cfg *oauth2.Config) (*GmailClient, error) {
+// NewGmailClient creates a new Gmail API client. saveToken, when set, is called
+// whenever the access token is refreshed so the config keeps the latest one.
+// opts are passed on to the Gmail service.
+func NewGmailClient(config *entity.CompanyEmailConfig, encryptionKey string, oauth2Cfg *oauth2.Config, saveToken TokenSaver, opts ...option.ClientOption) (*GmailClient, error) {
 	if config.AuthMethod != "oauth2" {
 		return nil, fmt.Errorf("config is not using OAuth2 authentication")
 	}
 
-	// Decrypt tokens
+	token, err := storedToken(config, encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create token source (handles auto-refresh)
+	ctx := context.Background()
+	tokenSource := NewPersistingTokenSource(oauth2Cfg.TokenSource(ctx, token), token, encryptionKey, saveToken)
+
+	// Create Gmail service
+	service, err := gmail.NewService(ctx, append([]option.ClientOption{option.WithTokenSource(tokenSource)}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gmail service: %w", err)
+	}
+
+	return &GmailClient{
+		service:       service,
+		config:        config,
+		encryptionKey: encryptionKey,
+		oauth2Config:  oauth2Cfg,
+	}, nil
+}
+
+// storedToken decrypts the OAuth2 tokens saved in config
+func storedToken(config *entity.CompanyEmailConfig, encryptionKey string) (*oauth2.Token, error) {
 	accessToken, err := security.Decrypt(config.AccessToken, encryptionKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt access token: %w", err)
@@ -40,7 +76,6 @@ func NewGmailClient(config *entity.CompanyEmailConfig, encryptionKey string, oau
 		return nil, fmt.Errorf("failed to decrypt refresh token: %w", err)
 	}
 
-	// Create OAuth2 token
 	token := &oauth2.Token{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -49,128 +84,161 @@ func NewGmailClient(config *entity.CompanyEmailConfig, encryptionKey string, oau
 	if config.TokenExpiry != nil {
 		token.Expiry = *config.TokenExpiry
 	}
-
-	// Create token source (handles auto-refresh)
-	ctx := context.Background()
-	tokenSource := oauth2Cfg.TokenSource(ctx, token)
-
-	// Create Gmail service
-	service, err := gmail.NewService(ctx, option.WithTokenSource(tokenSource))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Gmail service: %w", err)
-	}
-
-	return &GmailClient{
-		service:       service,
-		config:        config,
-		encryptionKey: encryptionKey,
-		oauth2Config:  oauth2Cfg,
-	}, nil
+	return token, nil
 }
 
 // Email represents a parsed Gmail message
 type Email struct {
-	MessageID string
-	From      string
-	Subject   string
-	Body      string
-	Date      time.Time
-	IsUnread  bool
+	ParsedEmail
+	IsUnread bool
 }
 
-// FetchUnreadEmails fetches unread emails from the inbox
-func (c *GmailClient) FetchUnreadEmails() ([]Email, error) {
-	ctx := context.Background()
-
-	// Query for unread messages in inbox
-	query := "is:unread in:inbox"
-
-	listCall := c.service.Users.Messages.List("me").Q(query).MaxResults(50)
-	response, err := listCall.Context(ctx).Do()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list messages: %w", err)
+// FetchNewEmails fetches the inbox messages added since historyID and returns
+// the historyId to resume from. With no historyID, or one Gmail no longer
+// keeps history for, it backfills the last BackfillWindow instead.
+// Read state is ignored, so messages already opened by someone are included.
+func (c *GmailClient) FetchNewEmails(ctx context.Context, historyID uint64) ([]Email, uint64, error) {
+	var ids []string
+	var next uint64
+	var err error
+
+	if historyID != 0 {
+		ids, next, err = c.listAddedSince(ctx, historyID)
+		if isNotFound(err) {
+			log.Printf("[GmailClient] History %d expired, backfilling the last %s", historyID, BackfillWindow)
+			historyID = 0
+		} else if err != nil {
+			return nil, historyID, err
+		}
 	}
-
-	var emails []Email
-
-	for _, msg := range response.Messages {
-		// Get full message details
-		fullMsg, err := c.service.Users.Messages.Get("me", msg.Id).Format("full").Do()
+	if historyID == 0 {
+		ids, next, err = c.listRecent(ctx, BackfillWindow)
 		if err != nil {
-			log.Printf("[GmailClient] Error fetching message %s: %v", msg.Id, err)
-			continue
+			return nil, historyID, err
 		}
+	}
 
-		email := c.parseMessage(fullMsg)
-		emails = append(emails, email)
+	emails := make([]Email, 0, len(ids))
+	for _, id := range ids {
+		fullMsg, err := c.service.Users.Messages.Get("me", id).Format("raw").Context(ctx).Do()
+		if isNotFound(err) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			// Without this message the cursor must not move past it
+			return nil, historyID, fmt.Errorf("failed to fetch message %s: %w", id, err)
+		}
+		emails = append(emails, c.parseMessage(fullMsg))
 	}
 
-	log.Printf("[GmailClient] Fetched %d unread emails", len(emails))
-	return emails, nil
+	log.Printf("[GmailClient] Fetched %d new emails", len(emails))
+	return emails, next, nil
 }
 
-// parseMessage converts Gmail message to Email struct
-func (c *GmailClient) parseMessage(msg *gmail.Message) Email {
-	email := Email{
-		MessageID: msg.Id,
-		IsUnread:  true,
-	}
-
-	// Parse headers
-	for _, header := range msg.Payload.Headers {
-		switch header.Name {
-		case "From":
-			email.From = header.Value
-		case "Subject":
-			email.Subject = header.Value
-		case "Date":
-			// Parse date (simplified, could be improved)
-			if parsedDate, err := time.Parse(time.RFC1123Z, header.Value); err == nil {
-				email.Date = parsedDate
+// listAddedSince pages through the history of the inbox and returns the IDs
+// of the messages added after historyID, plus the latest historyId
+func (c *GmailClient) listAddedSince(ctx context.Context, historyID uint64) ([]string, uint64, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	latest := historyID
+	pageToken := ""
+
+	for {
+		call := c.service.Users.History.List("me").
+			StartHistoryId(historyID).
+			HistoryTypes("messageAdded").
+			LabelId("INBOX").
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		response, err := call.Do()
+		if err != nil {
+			return nil, historyID, fmt.Errorf("failed to list history: %w", err)
+		}
+
+		for _, h := range response.History {
+			for _, added := range h.MessagesAdded {
+				if added.Message == nil || seen[added.Message.Id] {
+					continue
+				}
+				seen[added.Message.Id] = true
+				ids = append(ids, added.Message.Id)
 			}
 		}
+		if response.HistoryId > latest {
+			latest = response.HistoryId
+		}
+
+		if response.NextPageToken == "" {
+			return ids, latest, nil
+		}
+		pageToken = response.NextPageToken
 	}
+}
 
-	// Extract body
-	email.Body = c.extractBody(msg.Payload)
+// listRecent pages through the inbox messages received within window. The
+// historyId is read first, so anything arriving during the listing is picked
+// up by the next incremental sync rather than lost.
+func (c *GmailClient) listRecent(ctx context.Context, window time.Duration) ([]string, uint64, error) {
+	profile, err := c.service.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get profile: %w", err)
+	}
 
-	return email
-}
+	query := fmt.Sprintf("in:inbox after:%d", time.Now().Add(-window).Unix())
 
-// extractBody extracts the email body from the message payload
-func (c *GmailClient) extractBody(payload *gmail.MessagePart) string {
-	// If payload has body data, decode it
-	if payload.Body != nil && payload.Body.Data != "" {
-		decoded, err := base64.URLEncoding.DecodeString(payload.Body.Data)
-		if err == nil {
-			return string(decoded)
+	var ids []string
+	pageToken := ""
+	for {
+		call := c.service.Users.Messages.List("me").Q(query).MaxResults(100).Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
 		}
+
+		response, err := call.Do()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		for _, msg := range response.Messages {
+			ids = append(ids, msg.Id)
+		}
+
+		if response.NextPageToken == "" {
+			return ids, profile.HistoryId, nil
+		}
+		pageToken = response.NextPageToken
 	}
+}
 
-	// Check parts recursively
-	if len(payload.Parts) > 0 {
-		for _, part := range payload.Parts {
-			// Prefer text/html, fallback to text/plain
-			if part.MimeType == "text/html" || part.MimeType == "text/plain" {
-				if part.Body != nil && part.Body.Data != "" {
-					decoded, err := base64.URLEncoding.DecodeString(part.Body.Data)
-					if err == nil {
-						return string(decoded)
-					}
-				}
-			}
+// isNotFound reports whether err is a 404 from the Gmail API, which is how it
+// answers for deleted messages and for historyIds that are too old
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
 
-			// Recursive check for multipart
-			if len(part.Parts) > 0 {
-				body := c.extractBody(part)
-				if body != "" {
-					return body
-				}
-			}
+// parseMessage converts a Gmail message fetched in raw format to Email struct.
+// The Gmail ID stays the MessageID, it is what processed emails are keyed on.
+func (c *GmailClient) parseMessage(msg *gmail.Message) Email {
+	email := Email{}
+	for _, label := range msg.LabelIds {
+		if label == "UNREAD" {
+			email.IsUnread = true
 		}
 	}
 
-	return ""
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(msg.Raw, "="))
+	if err != nil {
+		log.Printf("[GmailClient] Error decoding message %s: %v", msg.Id, err)
+	} else if err := ReadMessage(bytes.NewReader(raw), &email.ParsedEmail); err != nil {
+		log.Printf("[GmailClient] Error reading message %s: %v", msg.Id, err)
+	}
+	email.MessageID = msg.Id
+
+	return email
 }
 
 // MarkAsRead marks an email as read
@@ -189,53 +257,62 @@ func (c *GmailClient) MarkAsRead(messageID string) error {
 	return nil
 }
 
-// RefreshTokenIfNeeded checks if token needs refresh and updates the database
-func (c *GmailClient) RefreshTokenIfNeeded(updateCallback func(newAccessToken, newRefreshToken string, newExpiry time.Time) error) error {
-	// Token refresh is handled automatically by oauth2.TokenSource
-	// We just need to get the refreshed token and save it
-	ctx := context.Background()
+// persistingTokenSource hands out tokens from base and saves every new access
+// token through save, so a refresh is not lost when the worker restarts
+type persistingTokenSource struct {
+	mu            sync.Mutex
+	base          oauth2.TokenSource
+	last          oauth2.Token
+	encryptionKey string
+	save          TokenSaver
+}
 
-	token := &oauth2.Token{
-		RefreshToken: c.config.RefreshToken, // This is still encrypted
+// NewPersistingTokenSource wraps base, which starts from current, and calls
+// save with the encrypted tokens whenever base returns a new access token
+func NewPersistingTokenSource(base oauth2.TokenSource, current *oauth2.Token, encryptionKey string, save TokenSaver) oauth2.TokenSource {
+	return &persistingTokenSource{
+		base:          base,
+		last:          *current,
+		encryptionKey: encryptionKey,
+		save:          save,
 	}
+}
 
-	// Decrypt refresh token
-	refreshToken, err := security.Decrypt(c.config.RefreshToken, c.encryptionKey)
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.base.Token()
 	if err != nil {
-		return fmt.Errorf("failed to decrypt refresh token: %w", err)
+		return nil, err
 	}
 
-	token.RefreshToken = refreshToken
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Get fresh token (will refresh if expired)
-	tokenSource := c.oauth2Config.TokenSource(ctx, token)
-	newToken, err := tokenSource.Token()
-	if err != nil {
-		return fmt.Errorf("failed to refresh token: %w", err)
+	if s.save == nil || token.AccessToken == s.last.AccessToken {
+		return token, nil
 	}
 
-	// If token was refreshed, encrypt and update
-	if newToken.AccessToken != token.AccessToken {
-		encryptedAccess, err := security.Encrypt(newToken.AccessToken, c.encryptionKey)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt new access token: %w", err)
-		}
-
-		encryptedRefresh := c.config.RefreshToken // Refresh token typically doesn't change
-		if newToken.RefreshToken != "" && newToken.RefreshToken != refreshToken {
-			encryptedRefresh, err = security.Encrypt(newToken.RefreshToken, c.encryptionKey)
-			if err != nil {
-				return fmt.Errorf("failed to encrypt new refresh token: %w", err)
-			}
-		}
+	// Google usually keeps the refresh token when it issues a new access token
+	refreshToken := token.RefreshToken
+	if refreshToken == "" {
+		refreshToken = s.last.RefreshToken
+	}
 
-		// Call update callback
-		if err := updateCallback(encryptedAccess, encryptedRefresh, newToken.Expiry); err != nil {
-			return fmt.Errorf("failed to update tokens in database: %w", err)
-		}
+	encryptedAccess, err := security.Encrypt(token.AccessToken, s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt new access token: %w", err)
+	}
+	encryptedRefresh, err := security.Encrypt(refreshToken, s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt new refresh token: %w", err)
+	}
 
-		log.Println("[GmailClient] Token refreshed successfully")
+	// The token works either way, so a failed save only means refreshing again later
+	if err := s.save(encryptedAccess, encryptedRefresh, token.Expiry); err != nil {
+		log.Printf("[GmailClient] Failed to save refreshed token: %v", err)
+		return token, nil
 	}
 
-	return nil
+	s.last = oauth2.Token{AccessToken: token.AccessToken, RefreshToken: refreshToken}
+	log.Println("[GmailClient] Token refreshed successfully")
+	return token, nil
 }
@@ -0,0 +1,276 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+)
+
+// GraphBaseURL is the Microsoft Graph endpoint used when none is given
+const GraphBaseURL = "https://graph.microsoft.com/v1.0"
+
+// graphMessageFields are the message properties requested from Graph
+const graphMessageFields = "id,internetMessageId,subject,from,toRecipients,receivedDateTime,body,hasAttachments"
+
+// GraphClient reads a Microsoft 365 / Outlook mailbox through Microsoft Graph
+type GraphClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewGraphClient creates a Graph client from the tokens stored in config.
+// saveToken, when set, is called whenever the access token is refreshed.
+// baseURL overrides GraphBaseURL when not empty.
+func NewGraphClient(config *entity.CompanyEmailConfig, encryptionKey string, oauth2Cfg *oauth2.Config, saveToken TokenSaver, baseURL string) (*GraphClient, error) {
+	if config.AuthMethod != entity.EmailAuthMicrosoft {
+		return nil, fmt.Errorf("config is not using Microsoft authentication")
+	}
+
+	token, err := storedToken(config, encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseURL == "" {
+		baseURL = GraphBaseURL
+	}
+
+	ctx := context.Background()
+	tokenSource := NewPersistingTokenSource(oauth2Cfg.TokenSource(ctx, token), token, encryptionKey, saveToken)
+
+	return &GraphClient{
+		httpClient: oauth2.NewClient(ctx, tokenSource),
+		baseURL:    baseURL,
+	}, nil
+}
+
+type graphRecipient struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+type graphMessage struct {
+	ID                string           `json:"id"`
+	InternetMessageID string           `json:"internetMessageId"`
+	Subject           string           `json:"subject"`
+	ReceivedDateTime  time.Time        `json:"receivedDateTime"`
+	From              *graphRecipient  `json:"from"`
+	ToRecipients      []graphRecipient `json:"toRecipients"`
+	HasAttachments    bool             `json:"hasAttachments"`
+	Body              struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	Removed *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed"`
+}
+
+type graphAttachment struct {
+	ODataType    string `json:"@odata.type"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	Size         int    `json:"size"`
+	ContentBytes []byte `json:"contentBytes"` // base64 in JSON, only set on file attachments
+}
+
+type graphMessagePage struct {
+	Value     []graphMessage `json:"value"`
+	NextLink  string         `json:"@odata.nextLink"`
+	DeltaLink string         `json:"@odata.deltaLink"`
+}
+
+// GraphError is an error response from Microsoft Graph
+type GraphError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph API error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// FetchNewEmails returns the inbox messages that changed since deltaLink and
+// the deltaLink to resume from. With no deltaLink, or one Graph no longer
+// accepts, it backfills the last BackfillWindow instead. Read state is
+// ignored; messages that were only updated come back too, so callers dedupe
+// on MessageID.
+func (c *GraphClient) FetchNewEmails(ctx context.Context, deltaLink string) ([]ParsedEmail, string, error) {
+	link := deltaLink
+	if link == "" {
+		link = c.backfillURL(BackfillWindow)
+	}
+
+	emails, next, err := c.readDelta(ctx, link)
+	if graphErr, ok := err.(*GraphError); ok && deltaLink != "" && graphErr.StatusCode == http.StatusGone {
+		log.Printf("[GraphClient] Delta link expired, backfilling the last %s", BackfillWindow)
+		emails, next, err = c.readDelta(ctx, c.backfillURL(BackfillWindow))
+	}
+	if err != nil {
+		return nil, deltaLink, err
+	}
+
+	log.Printf("[GraphClient] Fetched %d new emails", len(emails))
+	return emails, next, nil
+}
+
+// backfillURL starts a delta query over the inbox messages received within window
+func (c *GraphClient) backfillURL(window time.Duration) string {
+	query := url.Values{}
+	query.Set("$select", graphMessageFields)
+	query.Set("$filter", "receivedDateTime ge "+time.Now().Add(-window).UTC().Format(time.RFC3339))
+	return c.baseURL + "/me/mailFolders/inbox/messages/delta?" + query.Encode()
+}
+
+// readDelta follows the nextLinks from link until Graph hands out a deltaLink
+func (c *GraphClient) readDelta(ctx context.Context, link string) ([]ParsedEmail, string, error) {
+	emails := []ParsedEmail{}
+
+	for {
+		page, err := c.getPage(ctx, link)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, msg := range page.Value {
+			if msg.Removed != nil {
+				continue
+			}
+			parsed := msg.toParsedEmail()
+			if msg.HasAttachments {
+				if parsed.Attachments, err = c.getAttachments(ctx, msg.ID); err != nil {
+					return nil, "", err
+				}
+			}
+			emails = append(emails, parsed)
+		}
+
+		if page.DeltaLink != "" {
+			return emails, page.DeltaLink, nil
+		}
+		if page.NextLink == "" {
+			return nil, "", fmt.Errorf("graph delta page has neither nextLink nor deltaLink")
+		}
+		link = page.NextLink
+	}
+}
+
+func (c *GraphClient) getPage(ctx context.Context, link string) (*graphMessagePage, error) {
+	var page graphMessagePage
+	if err := c.getJSON(ctx, link, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// getAttachments downloads the file attachments of a message. Attachments of
+// other kinds (attached emails, links to cloud files) and the ones above
+// MaxAttachmentSize are left out.
+func (c *GraphClient) getAttachments(ctx context.Context, messageID string) ([]Attachment, error) {
+	var resp struct {
+		Value []graphAttachment `json:"value"`
+	}
+	err := c.getJSON(ctx, c.baseURL+"/me/messages/"+url.PathEscape(messageID)+"/attachments", &resp)
+	if graphErr, ok := err.(*GraphError); ok && graphErr.StatusCode == http.StatusNotFound {
+		return nil, nil // deleted since it was listed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments of %s: %w", messageID, err)
+	}
+
+	attachments := []Attachment{}
+	for _, a := range resp.Value {
+		if a.ODataType != "#microsoft.graph.fileAttachment" {
+			continue
+		}
+		if a.Size > MaxAttachmentSize {
+			log.Printf("[GraphClient] Skipping attachment %q: larger than %d bytes", a.Name, MaxAttachmentSize)
+			continue
+		}
+		attachments = append(attachments, Attachment{Filename: a.Name, ContentType: a.ContentType, Data: a.ContentBytes})
+	}
+	return attachments, nil
+}
+
+// getJSON GETs link and decodes the response into v
+func (c *GraphClient) getJSON(ctx context.Context, link string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Prefer", `odata.maxpagesize=50, outlook.body-content-type="html"`)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Graph: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var errResp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &errResp)
+		return &GraphError{StatusCode: resp.StatusCode, Code: errResp.Error.Code, Message: errResp.Error.Message}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode Graph response: %w", err)
+	}
+	return nil
+}
+
+// toParsedEmail converts m. Graph does not hand out the raw headers in delta
+// queries, so Headers only has the ones rebuilt from the message fields.
+func (m graphMessage) toParsedEmail() ParsedEmail {
+	parsed := ParsedEmail{
+		MessageID: normalizeMessageID(m.InternetMessageID),
+		Subject:   m.Subject,
+		Body:      m.Body.Content,
+		Date:      m.ReceivedDateTime,
+		Headers:   map[string][]string{"Subject": {m.Subject}},
+	}
+	if strings.EqualFold(m.Body.ContentType, "text") {
+		parsed.TextBody = m.Body.Content
+	} else {
+		parsed.HTMLBody = m.Body.Content
+	}
+	if m.InternetMessageID != "" {
+		parsed.Headers["Message-Id"] = []string{m.InternetMessageID}
+	}
+	if !m.ReceivedDateTime.IsZero() {
+		parsed.Headers["Date"] = []string{m.ReceivedDateTime.Format(time.RFC1123Z)}
+	}
+	if parsed.MessageID == "" {
+		parsed.MessageID = m.ID
+	}
+	if m.From != nil {
+		parsed.From = m.From.EmailAddress.Address
+		parsed.Headers["From"] = []string{parsed.From}
+	}
+	to := make([]string, 0, len(m.ToRecipients))
+	for _, r := range m.ToRecipients {
+		to = append(to, r.EmailAddress.Address)
+	}
+	if len(to) > 0 {
+		parsed.To = strings.Join(to, ", ")
+		parsed.Headers["To"] = []string{parsed.To}
+	}
+	return parsed
+}
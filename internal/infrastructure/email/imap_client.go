@@ -1,14 +1,18 @@
 package email
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"log"
+	"net"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
-	"github.com/emersion/go-message/mail"
 )
 
 // EmailMessage represents a parsed email message
@@ -19,10 +23,104 @@ type EmailMessage struct {
 	UID     uint32
 }
 
+// IdleRestartInterval is how long a single IDLE command is kept open. RFC 2177
+// asks clients to re-issue it before the server's 30 minute inactivity timeout.
+const IdleRestartInterval = 29 * time.Minute
+
 // IMAPClient handles IMAP connections and email retrieval
 type IMAPClient struct {
 	config Config
 	client *client.Client
+
+	updates chan client.Update
+	// newMail is signalled when the server reports a mailbox change
+	newMail chan struct{}
+	done    chan struct{}
+}
+
+// selectMarker brackets the replies to a SELECT in the stream of updates.
+// go-imap also reports the message counts in every SELECT reply as updates,
+// which are no new mail. Embedding StatusUpdate makes it a client.Update.
+type selectMarker struct {
+	*client.StatusUpdate
+	selected bool // false before the SELECT, true once it completed
+}
+
+// replyConn is a connection to the IMAP server that can keep what the server
+// says. go-imap drops the response code of a failed LOGIN from its error, and
+// it is that code that tells rejected credentials from an unavailable server.
+type replyConn struct {
+	net.Conn
+
+	mu       sync.Mutex
+	watching bool
+	replies  []byte
+}
+
+// dialTLS connects to addr like client.DialTLS does
+func dialTLS(addr string, tlsConfig *tls.Config) (*replyConn, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+	conn, err := tls.Dial("tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &replyConn{Conn: conn}, nil
+}
+
+func (c *replyConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	if c.watching && len(c.replies) < maxWatchedReplies {
+		c.replies = append(c.replies, p[:n]...)
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+// maxWatchedReplies bounds what is kept of the server's replies to a command
+const maxWatchedReplies = 4096
+
+// watchReplies starts, or stops, keeping what the server says
+func (c *replyConn) watchReplies(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watching = on
+	if on {
+		c.replies = nil
+	}
+}
+
+// credentialsRejected reports whether the watched command was answered with a
+// NO that is about the credentials: without a response code, or with one of
+// the RFC 5530 codes for rejected credentials. Codes such as UNAVAILABLE say
+// the server could not check them right now.
+func (c *replyConn) credentialsRejected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, line := range strings.Split(string(c.replies), "\r\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "*" || fields[0] == "+" || !strings.EqualFold(fields[1], string(imap.StatusRespNo)) {
+			continue
+		}
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], "[") {
+			return true
+		}
+		switch strings.ToUpper(strings.Trim(fields[2], "[]")) {
+		case "AUTHENTICATIONFAILED", "AUTHORIZATIONFAILED", "EXPIRED":
+			return true
+		default:
+			return false
+		}
+	}
+	return false
 }
 
 // NewIMAPClient creates a new IMAP client
@@ -39,17 +137,35 @@ func (c *IMAPClient) Connect() error {
 
 	log.Printf("[IMAP] Connecting to %s...", addr)
 
-	var err error
-	c.client, err = client.DialTLS(addr, nil)
+	conn, err := dialTLS(addr, c.config.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to IMAP server: %w", err)
+	}
+	c.client, err = client.New(conn)
 	if err != nil {
+		conn.Close()
 		return fmt.Errorf("failed to connect to IMAP server: %w", err)
 	}
 
 	log.Printf("[IMAP] Connected to %s", addr)
 
+	// Unsolicited updates must always be read, or the connection stalls
+	c.updates = make(chan client.Update, 16)
+	c.newMail = make(chan struct{}, 1)
+	c.done = make(chan struct{})
+	c.client.Updates = c.updates
+	go c.watchUpdates(c.updates, c.newMail, c.done)
+
 	// Login
-	if err := c.client.Login(c.config.Username, c.config.Password); err != nil {
-		c.client.Logout()
+	conn.watchReplies(true)
+	err = c.client.Login(c.config.Username, c.config.Password)
+	conn.watchReplies(false)
+	if err != nil {
+		c.Disconnect()
+		// Only a rejection of the credentials needs the user to reconnect the inbox
+		if conn.credentialsRejected() {
+			return fmt.Errorf("%w: failed to login: %v", ErrAuthFailed, err)
+		}
 		return fmt.Errorf("failed to login: %w", err)
 	}
 
@@ -60,74 +176,218 @@ func (c *IMAPClient) Connect() error {
 
 // Disconnect closes the IMAP connection
 func (c *IMAPClient) Disconnect() error {
-	if c.client != nil {
-		return c.client.Logout()
+	if c.client == nil {
+		return nil
 	}
-	return nil
+	err := c.client.Logout()
+	close(c.done)
+	c.client = nil
+	return err
 }
 
-// FetchUnreadEmails retrieves all unread emails from the inbox
-func (c *IMAPClient) FetchUnreadEmails() ([]ParsedEmail, error) {
-	// Connect first
-	if err := c.Connect(); err != nil {
-		return nil, err
+// watchUpdates turns mailbox updates (new EXISTS or RECENT counts) into a
+// newMail signal and drops the rest. Updates within a selectMarker pair are
+// about messages the caller fetches next, so they clear the signal instead.
+func (c *IMAPClient) watchUpdates(updates <-chan client.Update, newMail chan struct{}, done <-chan struct{}) {
+	selecting := false
+	for {
+		select {
+		case <-done:
+			return
+		case update := <-updates:
+			switch update := update.(type) {
+			case *selectMarker:
+				selecting = !update.selected
+				if update.selected {
+					select {
+					case <-newMail:
+					default:
+					}
+				}
+			case *client.MailboxUpdate:
+				if selecting {
+					continue
+				}
+				select {
+				case newMail <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// SupportsIdle reports whether the server advertises the IDLE capability.
+// The client must be connected.
+func (c *IMAPClient) SupportsIdle() (bool, error) {
+	if c.client == nil {
+		return false, fmt.Errorf("not connected")
+	}
+	return c.client.Support("IDLE")
+}
+
+// WaitForNewMail idles on the mailbox selected by the last FetchNewEmails
+// until the server reports a change, ctx is cancelled or the connection
+// drops. IDLE is re-issued every IdleRestartInterval so the server keeps the
+// connection open.
+func (c *IMAPClient) WaitForNewMail(ctx context.Context) error {
+	if c.client == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.client.Idle(stop, &client.IdleOptions{
+			LogoutTimeout: IdleRestartInterval,
+			PollInterval:  -1, // never poll inside IDLE, the caller decides
+		})
+	}()
+
+	select {
+	case <-c.newMail:
+		close(stop)
+		return <-done
+	case <-ctx.Done():
+		close(stop)
+		<-done
+		return ctx.Err()
+	case err := <-done:
+		if err == nil {
+			err = fmt.Errorf("IDLE ended unexpectedly")
+		}
+		return err
+	}
+}
+
+// IMAPSyncState is how far a mailbox has been read: messages with a UID up
+// to LastUID were already fetched, as long as UIDVALIDITY has not changed
+type IMAPSyncState struct {
+	UIDValidity uint32
+	LastUID     uint32
+	// SkipBackfill starts a first sync after the newest message instead of
+	// going back BackfillWindow, for mailboxes already read some other way
+	SkipBackfill bool
+}
+
+// FetchNewEmails retrieves the messages that arrived after state and returns
+// the state to resume from next time. The mailbox is opened read-only and
+// bodies are peeked, so flags such as \Seen are never changed. When the
+// server reports a different UIDVALIDITY the old UIDs mean nothing anymore
+// and the whole mailbox is fetched again; callers dedupe on MessageID.
+// It connects first unless the client is already connected.
+func (c *IMAPClient) FetchNewEmails(state IMAPSyncState) ([]ParsedEmail, IMAPSyncState, error) {
+	if c.client == nil {
+		if err := c.Connect(); err != nil {
+			return nil, state, err
+		}
 	}
 
 	// Select mailbox
-	mbox, err := c.client.Select(c.config.InboxFolder, false)
+	c.updates <- &selectMarker{}
+	mbox, err := c.client.Select(c.config.InboxFolder, true)
+	c.updates <- &selectMarker{selected: true}
 	if err != nil {
-		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+		return nil, state, fmt.Errorf("failed to select mailbox: %w", err)
+	}
+
+	next := state
+	if mbox.UidValidity != state.UIDValidity {
+		if state.UIDValidity != 0 {
+			log.Printf("[IMAP] UIDVALIDITY changed from %d to %d, resyncing mailbox", state.UIDValidity, mbox.UidValidity)
+		}
+		next = IMAPSyncState{UIDValidity: mbox.UidValidity}
 	}
 
-	// No messages in mailbox
-	if mbox.Messages == 0 {
-		log.Printf("[IMAP] No messages in mailbox")
-		return []ParsedEmail{}, nil
+	if next.LastUID == 0 && state.SkipBackfill && mbox.UidNext > 0 {
+		log.Printf("[IMAP] Starting after UID %d instead of backfilling", mbox.UidNext-1)
+		next.LastUID = mbox.UidNext - 1
+		return []ParsedEmail{}, next, nil
 	}
 
-	// Search for unread messages
-	criteria := imap.NewSearchCriteria()
-	criteria.WithoutFlags = []string{imap.SeenFlag}
+	// No messages in mailbox, or nothing after the last UID we have seen
+	if mbox.Messages == 0 || (mbox.UidNext != 0 && mbox.UidNext <= next.LastUID+1) {
+		log.Printf("[IMAP] No new messages")
+		return []ParsedEmail{}, next, nil
+	}
 
-	uids, err := c.client.Search(criteria)
+	// The first sync only goes back BackfillWindow; later ones take every UID
+	// after the last one seen. "n:*" always matches the last message even when
+	// its UID is below n, so anything at or below LastUID is dropped.
+	criteria := imap.NewSearchCriteria()
+	if next.LastUID == 0 {
+		criteria.Since = time.Now().Add(-BackfillWindow)
+	} else {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(next.LastUID+1, 0)
+	}
+	found, err := c.client.UidSearch(criteria)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search for unread messages: %w", err)
+		return nil, state, fmt.Errorf("failed to search messages: %w", err)
 	}
+	uids := make([]uint32, 0, len(found))
+	for _, uid := range found {
+		if uid > next.LastUID {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
 
-	if len(uids) == 0 {
-		log.Printf("[IMAP] No unread messages")
-		return []ParsedEmail{}, nil
+	parsedEmails := []ParsedEmail{}
+	for len(uids) > 0 {
+		batch := uids[:min(len(uids), imapFetchBatchSize)]
+		uids = uids[len(batch):]
+
+		emails, err := c.fetchMessages(batch, next.UIDValidity)
+		if err != nil {
+			return nil, state, err
+		}
+		parsedEmails = append(parsedEmails, emails...)
+		next.LastUID = batch[len(batch)-1]
+	}
+
+	// Messages older than the backfill window are not looked at again
+	if mbox.UidNext > next.LastUID+1 {
+		next.LastUID = mbox.UidNext - 1
 	}
 
-	log.Printf("[IMAP] Found %d unread message(s)", len(uids))
+	log.Printf("[IMAP] Found %d new message(s)", len(parsedEmails))
+	return parsedEmails, next, nil
+}
+
+// imapFetchBatchSize is how many messages a single UID FETCH asks for
+const imapFetchBatchSize = 50
 
-	// Fetch messages
-	seqset := new(imap.SeqSet)
-	seqset.AddNum(uids...)
+// fetchMessages fetches and parses the messages with the given UIDs
+func (c *IMAPClient) fetchMessages(uids []uint32, uidValidity uint32) ([]ParsedEmail, error) {
+	uidset := new(imap.SeqSet)
+	uidset.AddNum(uids...)
 
 	messages := make(chan *imap.Message, 10)
 	done := make(chan error, 1)
 
-	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, section.FetchItem()}
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, section.FetchItem()}
 
 	go func() {
-		done <- c.client.Fetch(seqset, items, messages)
+		done <- c.client.UidFetch(uidset, items, messages)
 	}()
 
 	parsedEmails := []ParsedEmail{}
-
 	for msg := range messages {
 		if msg == nil {
 			continue
 		}
 
 		parsedEmail := ParsedEmail{
-			MessageID: fmt.Sprintf("%d", msg.Uid),
+			MessageID: fmt.Sprintf("imap:%d:%d", uidValidity, msg.Uid),
 		}
 
 		// Get envelope info
 		if msg.Envelope != nil {
+			if id := normalizeMessageID(msg.Envelope.MessageId); id != "" {
+				parsedEmail.MessageID = id
+			}
 			parsedEmail.Subject = msg.Envelope.Subject
 			if len(msg.Envelope.From) > 0 {
 				parsedEmail.From = msg.Envelope.From[0].Address()
@@ -137,71 +397,26 @@ func (c *IMAPClient) FetchUnreadEmails() ([]ParsedEmail, error) {
 			}
 		}
 
-		// Get body
-		r := msg.GetBody(section)
-		if r != nil {
-			mr, err := mail.CreateReader(r)
-			if err != nil {
+		// Get headers, bodies and attachments
+		if r := msg.GetBody(section); r != nil {
+			if err := ReadMessage(r, &parsedEmail); err != nil {
 				log.Printf("[IMAP] Error creating mail reader: %v", err)
-				continue
 			}
-
-			// Read all parts
-			body := ""
-			for {
-				p, err := mr.NextPart()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					log.Printf("[IMAP] Error reading part: %v", err)
-					break
-				}
-
-				switch h := p.Header.(type) {
-				case *mail.InlineHeader:
-					contentType, _, _ := h.ContentType()
-					b, _ := io.ReadAll(p.Body)
-
-					// Prefer HTML content, but also capture text
-					if strings.HasPrefix(contentType, "text/html") {
-						body = string(b)
-						break // HTML is preferred, stop reading
-					} else if strings.HasPrefix(contentType, "text/plain") && body == "" {
-						body = string(b)
-					}
-				}
-			}
-
-			parsedEmail.Body = body
 		}
 
-		// Mark as read
-		c.MarkAsRead(msg.Uid)
-
 		parsedEmails = append(parsedEmails, parsedEmail)
 	}
 
 	if err := <-done; err != nil {
 		return nil, fmt.Errorf("failed to fetch messages: %w", err)
 	}
-
 	return parsedEmails, nil
 }
 
-// MarkAsRead marks an email as read
-func (c *IMAPClient) MarkAsRead(uid uint32) error {
-	seqset := new(imap.SeqSet)
-	seqset.AddNum(uid)
-
-	item := imap.FormatFlagsOp(imap.AddFlags, true)
-	flags := []interface{}{imap.SeenFlag}
-
-	if err := c.client.Store(seqset, item, flags, nil); err != nil {
-		return fmt.Errorf("failed to mark message as read: %w", err)
-	}
-
-	return nil
+// normalizeMessageID strips the angle brackets around a Message-ID header so
+// the same message is recognised however the server formats it
+func normalizeMessageID(id string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(id), "<"), ">")
 }
 
 // Close closes the IMAP connection
@@ -0,0 +1,87 @@
+package email
+
+import (
+	"io"
+	"log"
+	"strings"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// ReadMessage fills parsed from the RFC 5322 message in r: its headers, its
+// text and HTML bodies and its attachments. From, To, Subject and Date are
+// only taken from the headers when parsed does not have them yet.
+func ReadMessage(r io.Reader, parsed *ParsedEmail) error {
+	mr, err := mail.CreateReader(r)
+	if err != nil {
+		return err
+	}
+
+	parsed.Headers = mr.Header.Map()
+	if parsed.From == "" {
+		parsed.From = mr.Header.Get("From")
+	}
+	if parsed.To == "" {
+		parsed.To = mr.Header.Get("To")
+	}
+	if parsed.Subject == "" {
+		if subject, err := mr.Header.Subject(); err == nil {
+			parsed.Subject = subject
+		} else {
+			parsed.Subject = mr.Header.Get("Subject")
+		}
+	}
+	if parsed.Date.IsZero() {
+		if date, err := mr.Header.Date(); err == nil {
+			parsed.Date = date
+		}
+	}
+
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("[Email] Error reading part: %v", err)
+			break
+		}
+
+		switch h := p.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ := h.ContentType()
+			b, _ := io.ReadAll(p.Body)
+
+			// Keep the first part of each kind, later ones are usually quoted replies
+			if strings.HasPrefix(contentType, "text/html") && parsed.HTMLBody == "" {
+				parsed.HTMLBody = string(b)
+			} else if strings.HasPrefix(contentType, "text/plain") && parsed.TextBody == "" {
+				parsed.TextBody = string(b)
+			}
+		case *mail.AttachmentHeader:
+			filename, _ := h.Filename()
+			contentType, _, _ := h.ContentType()
+			b, err := io.ReadAll(io.LimitReader(p.Body, MaxAttachmentSize+1))
+			if err != nil {
+				log.Printf("[Email] Error reading attachment %q: %v", filename, err)
+				continue
+			}
+			if len(b) > MaxAttachmentSize {
+				log.Printf("[Email] Skipping attachment %q: larger than %d bytes", filename, MaxAttachmentSize)
+				continue
+			}
+			parsed.Attachments = append(parsed.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				Data:        b,
+			})
+		}
+	}
+
+	// Prefer HTML content, but fall back to text
+	parsed.Body = parsed.HTMLBody
+	if parsed.Body == "" {
+		parsed.Body = parsed.TextBody
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package email
 import (
 	"crypto/tls"
 	"fmt"
+	"html"
 	"net/smtp"
 	"os"
 )
@@ -159,3 +160,51 @@ func (s *EmailSender) SendPasswordResetEmail(to, token string) error {
 
 	return s.SendEmail(to, subject, body)
 }
+
+// SendInvitationEmail sends an invitation to join a company
+func (s *EmailSender) SendInvitationEmail(to, companyName, token string) error {
+	// TODO: Get frontend URL from environment variable
+	inviteURL := fmt.Sprintf("http://localhost:5173/accept-invitation/%s", token)
+
+	subject := fmt.Sprintf("You're invited to join %s on MyEstatia", companyName)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+        .button { display: inline-block; padding: 12px 30px; background: #667eea; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
+        .footer { text-align: center; margin-top: 20px; font-size: 12px; color: #666; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Join %s</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>You have been invited to join <strong>%s</strong> on MyEstatia.</p>
+            <p>Click the button below to choose your password and activate your account:</p>
+            <p style="text-align: center;">
+                <a href="%s" class="button">Accept Invitation</a>
+            </p>
+            <p>Or copy and paste this link into your browser:</p>
+            <p style="word-break: break-all; color: #667eea;">%s</p>
+            <p><strong>This link will expire in 7 days and can only be used once.</strong></p>
+            <p>If you weren't expecting this invitation, you can safely ignore this email.</p>
+            <div class="footer">
+                <p>© 2025 MyEstatia. All rights reserved.</p>
+            </div>
+        </div>
+    </div>
+</body>
+</html>
+`, html.EscapeString(companyName), html.EscapeString(companyName), inviteURL, inviteURL)
+
+	return s.SendEmail(to, subject, body)
+}
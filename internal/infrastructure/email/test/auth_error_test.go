@@ -0,0 +1,34 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestIsAuthError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rejected IMAP login", fmt.Errorf("%w: failed to login: bad credentials", email.ErrAuthFailed), true},
+		{"revoked refresh token", fmt.Errorf("failed to fetch Gmail messages: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}), true},
+		{"token endpoint 401", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusUnauthorized}, ErrorCode: "invalid_client"}, true},
+		{"token endpoint 503", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, false},
+		{"graph 401", fmt.Errorf("failed to fetch Graph messages: %w", &email.GraphError{StatusCode: http.StatusUnauthorized}), true},
+		{"graph 503", &email.GraphError{StatusCode: http.StatusServiceUnavailable}, false},
+		{"network error", errors.New("failed to connect to IMAP server: connection refused"), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, email.IsAuthError(tc.err))
+		})
+	}
+}
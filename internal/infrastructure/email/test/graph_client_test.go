@@ -0,0 +1,231 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// graphStandIn serves the Graph delta endpoints the client uses, plus a token
+// endpoint, from a local HTTP server
+type graphStandIn struct {
+	*httptest.Server
+	pages       map[string]any // path -> JSON response, or a status code to fail with
+	bearers     []string
+	queries     []url.Values
+	tokenIssued bool
+}
+
+func newGraphStandIn(t *testing.T) *graphStandIn {
+	s := &graphStandIn{pages: map[string]any{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			s.tokenIssued = true
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh-access", "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+
+		s.bearers = append(s.bearers, r.Header.Get("Authorization"))
+		s.queries = append(s.queries, r.URL.Query())
+		page, ok := s.pages[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected Graph request %s", r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if status, ok := page.(int); ok {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "SyncStateNotFound", "message": "expired"}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newGraphClient(t *testing.T, s *graphStandIn, expiry time.Time, save email.TokenSaver) *email.GraphClient {
+	access, err := security.Encrypt("stored-access", encryptionKey)
+	require.NoError(t, err)
+	refresh, err := security.Encrypt("stored-refresh", encryptionKey)
+	require.NoError(t, err)
+
+	config := &entity.CompanyEmailConfig{
+		ID:           "CFG1",
+		AuthMethod:   entity.EmailAuthMicrosoft,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenExpiry:  &expiry,
+	}
+	oauth2Cfg := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: s.URL + "/token"},
+	}
+
+	client, err := email.NewGraphClient(config, encryptionKey, oauth2Cfg, save, s.URL)
+	require.NoError(t, err)
+	return client
+}
+
+func graphMessage(id, messageID, from, subject, body string) map[string]any {
+	return map[string]any{
+		"id":                id,
+		"internetMessageId": messageID,
+		"subject":           subject,
+		"receivedDateTime":  "2026-10-01T09:30:00Z",
+		"from":              map[string]any{"emailAddress": map[string]string{"address": from}},
+		"body":              map[string]string{"contentType": "html", "content": body},
+	}
+}
+
+func TestGraphClient_InitialSyncFollowsPages(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	s.pages["/me/mailFolders/inbox/messages/delta"] = map[string]any{
+		"value": []any{
+			graphMessage("g1", "<abc@idealista.com>", "noreply@idealista.com", "Nuevo contacto", "<p>Hola</p>"),
+			map[string]any{"id": "g0", "@removed": map[string]string{"reason": "deleted"}},
+		},
+		"@odata.nextLink": s.URL + "/page2",
+	}
+	s.pages["/page2"] = map[string]any{
+		"value":            []any{graphMessage("g2", "", "info@kyero.com", "Enquiry", "<p>Hi</p>")},
+		"@odata.deltaLink": s.URL + "/delta-next",
+	}
+	client := newGraphClient(t, s, time.Now().Add(time.Hour), nil)
+
+	// WHEN
+	emails, deltaLink, err := client.FetchNewEmails(t.Context(), "")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, s.URL+"/delta-next", deltaLink)
+	require.Len(t, emails, 2)
+	assert.Equal(t, "abc@idealista.com", emails[0].MessageID)
+	assert.Equal(t, "noreply@idealista.com", emails[0].From)
+	assert.Equal(t, "Nuevo contacto", emails[0].Subject)
+	assert.Equal(t, "<p>Hola</p>", emails[0].Body)
+	assert.Equal(t, time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC), emails[0].Date)
+	assert.Equal(t, "g2", emails[1].MessageID, "falls back to the Graph id without a Message-ID")
+	assert.Equal(t, []string{"Bearer stored-access", "Bearer stored-access"}, s.bearers)
+}
+
+func TestGraphClient_BackfillIsBounded(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	s.pages["/me/mailFolders/inbox/messages/delta"] = map[string]any{"value": []any{}, "@odata.deltaLink": "next"}
+	client := newGraphClient(t, s, time.Now().Add(time.Hour), nil)
+
+	// WHEN
+	_, _, err := client.FetchNewEmails(t.Context(), "")
+
+	// THEN
+	require.NoError(t, err)
+	require.Len(t, s.queries, 1)
+	filter := s.queries[0].Get("$filter")
+	require.True(t, strings.HasPrefix(filter, "receivedDateTime ge "), filter)
+	since, err := time.Parse(time.RFC3339, strings.TrimPrefix(filter, "receivedDateTime ge "))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-email.BackfillWindow), since, time.Minute)
+}
+
+func TestGraphClient_ExpiredDeltaLinkBackfills(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	s.pages["/delta-old"] = http.StatusGone
+	s.pages["/me/mailFolders/inbox/messages/delta"] = map[string]any{
+		"value":            []any{graphMessage("g1", "<m1@x>", "a@b.com", "Hi", "body")},
+		"@odata.deltaLink": s.URL + "/delta-new",
+	}
+	client := newGraphClient(t, s, time.Now().Add(time.Hour), nil)
+
+	// WHEN
+	emails, deltaLink, err := client.FetchNewEmails(t.Context(), s.URL+"/delta-old")
+
+	// THEN
+	require.NoError(t, err)
+	assert.Len(t, emails, 1)
+	assert.Equal(t, s.URL+"/delta-new", deltaLink)
+}
+
+func TestGraphClient_ErrorKeepsDeltaLink(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	s.pages["/delta-old"] = http.StatusServiceUnavailable
+	client := newGraphClient(t, s, time.Now().Add(time.Hour), nil)
+
+	// WHEN
+	_, deltaLink, err := client.FetchNewEmails(t.Context(), s.URL+"/delta-old")
+
+	// THEN
+	var graphErr *email.GraphError
+	require.ErrorAs(t, err, &graphErr)
+	assert.Equal(t, http.StatusServiceUnavailable, graphErr.StatusCode)
+	assert.Equal(t, s.URL+"/delta-old", deltaLink)
+}
+
+func TestGraphClient_RefreshesAndSavesExpiredToken(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	s.pages["/delta"] = map[string]any{"value": []any{}, "@odata.deltaLink": s.URL + "/delta"}
+
+	var savedAccess string
+	client := newGraphClient(t, s, time.Now().Add(-time.Hour), func(access, refresh string, expiry time.Time) error {
+		savedAccess = access
+		return nil
+	})
+
+	// WHEN
+	_, _, err := client.FetchNewEmails(t.Context(), s.URL+"/delta")
+
+	// THEN
+	require.NoError(t, err)
+	assert.True(t, s.tokenIssued)
+	assert.Equal(t, []string{"Bearer fresh-access"}, s.bearers)
+	plain, err := security.Decrypt(savedAccess, encryptionKey)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh-access", plain)
+}
+
+func TestGraphClient_FetchesFileAttachments(t *testing.T) {
+	// GIVEN
+	s := newGraphStandIn(t)
+	withAttachments := graphMessage("g1", "<m1@x>", "a@kyero.com", "Enquiry", "<p>Hi</p>")
+	withAttachments["hasAttachments"] = true
+	s.pages["/me/mailFolders/inbox/messages/delta"] = map[string]any{
+		"value":            []any{withAttachments},
+		"@odata.deltaLink": s.URL + "/delta-new",
+	}
+	s.pages["/me/messages/g1/attachments"] = map[string]any{
+		"value": []any{
+			map[string]any{"@odata.type": "#microsoft.graph.fileAttachment", "name": "plan.pdf", "contentType": "application/pdf", "size": 4, "contentBytes": "JVBERg=="},
+			map[string]any{"@odata.type": "#microsoft.graph.itemAttachment", "name": "Forwarded"},
+		},
+	}
+	client := newGraphClient(t, s, time.Now().Add(time.Hour), nil)
+
+	// WHEN
+	emails, _, err := client.FetchNewEmails(t.Context(), "")
+
+	// THEN
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	assert.Equal(t, "<p>Hi</p>", emails[0].HTMLBody)
+	require.Len(t, emails[0].Attachments, 1)
+	assert.Equal(t, "plan.pdf", emails[0].Attachments[0].Filename)
+	assert.Equal(t, []byte("%PDF"), emails[0].Attachments[0].Data)
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imapStandIn is an in-memory IMAP server over TLS, holding one message in
+// the INBOX of "username"/"password", that can announce new mail to clients
+type imapStandIn struct {
+	*memory.Backend
+	updates  chan backend.Update
+	config   email.Config
+	loginErr error // replaces the answer to every LOGIN when set
+}
+
+func (s *imapStandIn) Login(connInfo *imap.ConnInfo, username, password string) (backend.User, error) {
+	if s.loginErr != nil {
+		return nil, s.loginErr
+	}
+	return s.Backend.Login(connInfo, username, password)
+}
+
+func (s *imapStandIn) Updates() <-chan backend.Update {
+	return s.updates
+}
+
+func newIMAPStandIn(t *testing.T) *imapStandIn {
+	// Borrow the self-signed certificate of an httptest server
+	certs := httptest.NewUnstartedServer(nil)
+	certs.StartTLS()
+	roots := x509.NewCertPool()
+	roots.AddCert(certs.Certificate())
+	cert := certs.TLS.Certificates[0]
+	certs.Close()
+
+	s := &imapStandIn{Backend: memory.New(), updates: make(chan backend.Update, 1)}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	srv := server.New(s)
+	srv.AllowInsecureAuth = true
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	s.config = email.Config{
+		IMAPHost:    "127.0.0.1",
+		IMAPPort:    port,
+		Username:    "username",
+		Password:    "password",
+		InboxFolder: "INBOX",
+		TLSConfig:   &tls.Config{RootCAs: roots},
+	}
+	return s
+}
+
+// store appends count messages received at the given time without announcing them
+func (s *imapStandIn) store(t *testing.T, received time.Time, count int) {
+	user, err := s.Login(nil, "username", "password")
+	require.NoError(t, err)
+	mbox, err := user.GetMailbox("INBOX")
+	require.NoError(t, err)
+	for i := 0; i < count; i++ {
+		body := fmt.Sprintf("From: buyer@example.org\r\nSubject: Lead %d\r\nMessage-ID: <old-%d@localhost>\r\n\r\nHello", i, i)
+		require.NoError(t, mbox.CreateMessage(nil, received, strings.NewReader(body)))
+	}
+}
+
+// deliver appends a message to the INBOX and announces it to the clients that selected it
+func (s *imapStandIn) deliver(t *testing.T) {
+	user, err := s.Login(nil, "username", "password")
+	require.NoError(t, err)
+	mbox, err := user.GetMailbox("INBOX")
+	require.NoError(t, err)
+	body := "From: buyer@example.org\r\nSubject: New lead\r\nMessage-ID: <new@localhost>\r\n\r\nHello"
+	require.NoError(t, mbox.CreateMessage(nil, time.Now(), strings.NewReader(body)))
+
+	status, err := mbox.Status([]imap.StatusItem{imap.StatusMessages})
+	require.NoError(t, err)
+	s.updates <- &backend.MailboxUpdate{Update: backend.NewUpdate("username", "INBOX"), MailboxStatus: status}
+}
+
+func TestIMAPClient_WaitForNewMail_IgnoresTheSelectReply(t *testing.T) {
+	// GIVEN
+	s := newIMAPStandIn(t)
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	emails, _, err := client.FetchNewEmails(email.IMAPSyncState{})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+
+	// WHEN
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = client.WaitForNewMail(ctx)
+
+	// THEN
+	// The EXISTS count in the SELECT reply is no new mail, so it keeps idling
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIMAPClient_WaitForNewMail_ReturnsOnNewMessage(t *testing.T) {
+	// GIVEN
+	s := newIMAPStandIn(t)
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, state, err := client.FetchNewEmails(email.IMAPSyncState{})
+	require.NoError(t, err)
+
+	// WHEN
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.deliver(t)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = client.WaitForNewMail(ctx)
+
+	// THEN
+	require.NoError(t, err)
+	emails, _, err := client.FetchNewEmails(state)
+	require.NoError(t, err)
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, "New lead", emails[0].Subject)
+	}
+}
+
+func TestIMAPClient_FetchNewEmails_FirstSyncOnlyBackfillsTheWindow(t *testing.T) {
+	// GIVEN a recent message and older ones outside the backfill window
+	s := newIMAPStandIn(t)
+	s.store(t, time.Now().Add(-2*email.BackfillWindow), 3)
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// WHEN
+	emails, state, err := client.FetchNewEmails(email.IMAPSyncState{})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Len(t, emails, 1)
+	// The old messages are not fetched on the next sync either
+	emails, _, err = client.FetchNewEmails(state)
+	require.NoError(t, err)
+	assert.Empty(t, emails)
+}
+
+func TestIMAPClient_FetchNewEmails_SkipBackfillStartsAfterTheNewest(t *testing.T) {
+	// GIVEN an inbox already read before, with recent messages
+	s := newIMAPStandIn(t)
+	s.store(t, time.Now(), 3)
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// WHEN
+	emails, state, err := client.FetchNewEmails(email.IMAPSyncState{SkipBackfill: true})
+
+	// THEN
+	require.NoError(t, err)
+	assert.Empty(t, emails)
+	// Only what arrives afterwards is fetched
+	s.store(t, time.Now(), 1)
+	emails, _, err = client.FetchNewEmails(state)
+	require.NoError(t, err)
+	assert.Len(t, emails, 1)
+}
+
+func TestIMAPClient_FetchNewEmails_FetchesInBatches(t *testing.T) {
+	// GIVEN more new messages than a single fetch asks for
+	s := newIMAPStandIn(t)
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+	defer client.Close()
+	_, state, err := client.FetchNewEmails(email.IMAPSyncState{})
+	require.NoError(t, err)
+	s.store(t, time.Now(), 120)
+
+	// WHEN
+	emails, next, err := client.FetchNewEmails(state)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Len(t, emails, 120)
+	assert.Equal(t, state.LastUID+120, next.LastUID)
+}
+
+func TestIMAPClient_Connect_RejectedPasswordIsAnAuthError(t *testing.T) {
+	// GIVEN
+	s := newIMAPStandIn(t)
+	s.config.Password = "wrong"
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+
+	// WHEN
+	err = client.Connect()
+
+	// THEN
+	assert.True(t, email.IsAuthError(err), "%v", err)
+}
+
+func TestIMAPClient_Connect_UnavailableServerIsNoAuthError(t *testing.T) {
+	// GIVEN a server that cannot check credentials right now
+	s := newIMAPStandIn(t)
+	s.loginErr = &imap.ErrStatusResp{Resp: &imap.StatusResp{Type: imap.StatusRespNo, Code: "UNAVAILABLE", Info: "Try again later"}}
+	client, err := email.NewIMAPClient(s.config)
+	require.NoError(t, err)
+
+	// WHEN
+	err = client.Connect()
+
+	// THEN
+	assert.Error(t, err)
+	assert.False(t, email.IsAuthError(err), "%v", err)
+}
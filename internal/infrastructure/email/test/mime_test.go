@@ -0,0 +1,75 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multipartEmail = "From: Idealista <noreply@idealista.com>\r\n" +
+	"To: agency@example.com\r\n" +
+	"Subject: =?UTF-8?Q?Nuevo_mensaje_de_Mar=C3=ADa?=\r\n" +
+	"Date: Thu, 01 Oct 2026 09:30:00 +0000\r\n" +
+	"Message-ID: <abc@idealista.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hola\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Hola</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=\"dni.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERg==\r\n" +
+	"--outer--\r\n"
+
+func TestReadMessage_HeadersBodiesAndAttachments(t *testing.T) {
+	// GIVEN
+	parsed := email.ParsedEmail{MessageID: "m1"}
+
+	// WHEN
+	err := email.ReadMessage(strings.NewReader(multipartEmail), &parsed)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "Idealista <noreply@idealista.com>", parsed.From)
+	assert.Equal(t, "agency@example.com", parsed.To)
+	assert.Equal(t, "Nuevo mensaje de María", parsed.Subject)
+	assert.Equal(t, time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC), parsed.Date.UTC())
+	assert.Equal(t, []string{"<abc@idealista.com>"}, parsed.Headers["Message-Id"])
+	assert.Equal(t, "Hola", strings.TrimSpace(parsed.TextBody))
+	assert.Equal(t, "<p>Hola</p>", strings.TrimSpace(parsed.HTMLBody))
+	assert.Equal(t, parsed.HTMLBody, parsed.Body, "parsers read the HTML body")
+	require.Len(t, parsed.Attachments, 1)
+	assert.Equal(t, "dni.pdf", parsed.Attachments[0].Filename)
+	assert.Equal(t, "application/pdf", parsed.Attachments[0].ContentType)
+	assert.Equal(t, []byte("%PDF"), parsed.Attachments[0].Data)
+}
+
+func TestReadMessage_KeepsEnvelopeFields(t *testing.T) {
+	// GIVEN
+	parsed := email.ParsedEmail{From: "noreply@idealista.com", Subject: "From the envelope"}
+
+	// WHEN
+	err := email.ReadMessage(strings.NewReader(multipartEmail), &parsed)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "noreply@idealista.com", parsed.From)
+	assert.Equal(t, "From the envelope", parsed.Subject)
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const encryptionKey = "0123456789abcdef0123456789abcdef"
+
+// tokenSequence returns its tokens in order, repeating the last one
+type tokenSequence struct {
+	tokens []*oauth2.Token
+}
+
+func (s *tokenSequence) Token() (*oauth2.Token, error) {
+	token := s.tokens[0]
+	if len(s.tokens) > 1 {
+		s.tokens = s.tokens[1:]
+	}
+	return token, nil
+}
+
+type savedToken struct {
+	access, refresh string
+	expiry          time.Time
+}
+
+func TestPersistingTokenSource_SavesRefreshedToken(t *testing.T) {
+	// GIVEN
+	current := &oauth2.Token{AccessToken: "old-access", RefreshToken: "refresh"}
+	expiry := time.Now().Add(time.Hour)
+	base := &tokenSequence{tokens: []*oauth2.Token{
+		current,
+		{AccessToken: "new-access", Expiry: expiry}, // Google leaves the refresh token out
+	}}
+
+	var saved []savedToken
+	ts := email.NewPersistingTokenSource(base, current, encryptionKey, func(access, refresh string, exp time.Time) error {
+		saved = append(saved, savedToken{access, refresh, exp})
+		return nil
+	})
+
+	// WHEN
+	_, err1 := ts.Token()
+	token, err2 := ts.Token()
+	_, err3 := ts.Token()
+
+	// THEN
+	assert.NoError(t, errors.Join(err1, err2, err3))
+	assert.Equal(t, "new-access", token.AccessToken)
+	assert.Len(t, saved, 1, "only the refresh is saved, and only once")
+
+	access, err := security.Decrypt(saved[0].access, encryptionKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access", access)
+	refresh, err := security.Decrypt(saved[0].refresh, encryptionKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "refresh", refresh)
+	assert.Equal(t, expiry, saved[0].expiry)
+}
+
+func TestPersistingTokenSource_SaveFailureStillReturnsToken(t *testing.T) {
+	// GIVEN
+	current := &oauth2.Token{AccessToken: "old-access", RefreshToken: "refresh"}
+	base := &tokenSequence{tokens: []*oauth2.Token{{AccessToken: "new-access"}}}
+
+	attempts := 0
+	ts := email.NewPersistingTokenSource(base, current, encryptionKey, func(access, refresh string, exp time.Time) error {
+		attempts++
+		return errors.New("database is down")
+	})
+
+	// WHEN
+	token, err := ts.Token()
+	_, _ = ts.Token()
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access", token.AccessToken)
+	assert.Equal(t, 2, attempts, "an unsaved token is saved again on the next call")
+}
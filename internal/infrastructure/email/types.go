@@ -2,11 +2,32 @@ package email
 
 import "time"
 
+// BackfillWindow bounds the first sync of a mailbox, and any full resync after
+// its sync cursor expired, to the messages received this recently
+const BackfillWindow = 7 * 24 * time.Hour
+
+// MaxAttachmentSize is the largest attachment kept from an inbound email;
+// bigger ones are dropped
+const MaxAttachmentSize = 10 << 20
+
 // ParsedEmail represents a parsed email message (common interface for IMAP and Gmail API)
 type ParsedEmail struct {
 	MessageID string
 	From      string
+	To        string
 	Subject   string
-	Body      string
+	Body      string // HTML body, or the text body when there is none; what the lead parsers read
+	TextBody  string
+	HTMLBody  string
+	Headers   map[string][]string
 	Date      time.Time
+
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an inbound email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
 }
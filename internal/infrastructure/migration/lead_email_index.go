@@ -0,0 +1,20 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// LeadEmailIndex makes lead emails unique per company compared lowercased,
+// among the leads not deleted. It replaces idx_leads_company_email, which
+// compared them raw and counted deleted leads, in one transaction: should
+// two live leads still share an email the old index stays until they are
+// merged.
+func LeadEmailIndex(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DROP INDEX IF EXISTS idx_leads_company_email`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_leads_company_lower_email
+			ON leads (company_id, LOWER(email)) WHERE deleted_at IS NULL`).Error
+	})
+}
@@ -0,0 +1,162 @@
+package migration
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Email ingestion used to write each inbound contact into Lead.Notes: the first
+// one as "Mensaje inicial: ...", later ones as "[2006-01-02 15:04] Nuevo
+// contacto[ sobre propiedad REF (anterior: ...)]. Mensaje: ..." on a new line
+const initialMessagePrefix = "Mensaje inicial: "
+
+var noteContactPattern = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2})\] Nuevo contacto(?: sobre propiedad (\S+) \(anterior: [^)\n]*\))?\. Mensaje: `)
+
+// NoteContact is an inbound contact found in the notes of a lead
+type NoteContact struct {
+	At                time.Time
+	Message           string
+	PropertyReference string // Set when the contact switched the lead to another property
+}
+
+// SplitNotes separates the inbound contacts from notes and returns what is
+// left, usually what agents wrote. The initial message is dated createdAt;
+// the others carry their time, written in loc. A contact is the one line the
+// template wrote: the lines after it are left in the notes, as nothing tells
+// the rest of a message that spanned lines from what agents added below it.
+func SplitNotes(notes string, createdAt time.Time, loc *time.Location) (string, []NoteContact) {
+	var contacts []NoteContact
+	var kept []string
+	for i, line := range strings.Split(notes, "\n") {
+		if i == 0 && strings.HasPrefix(line, initialMessagePrefix) {
+			contacts = append(contacts, NoteContact{
+				At:      createdAt,
+				Message: strings.TrimSpace(strings.TrimPrefix(line, initialMessagePrefix)),
+			})
+			continue
+		}
+
+		m := noteContactPattern.FindStringSubmatchIndex(line)
+		if m == nil {
+			kept = append(kept, line)
+			continue
+		}
+		contact := NoteContact{Message: strings.TrimSpace(line[m[1]:])}
+		if at, err := time.ParseInLocation("2006-01-02 15:04", line[m[2]:m[3]], loc); err == nil {
+			contact.At = at
+		} else {
+			contact.At = createdAt
+		}
+		if m[4] >= 0 {
+			contact.PropertyReference = line[m[4]:m[5]]
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n")), contacts
+}
+
+// SplitLeadNotes moves the inbound contacts kept in lead notes into the lead
+// conversations, and derives LastInteraction from them. The notes keep the
+// rest, so running it again finds nothing left to move.
+func SplitLeadNotes(db *gorm.DB) error {
+	var leads []entity.Lead
+	moved := 0
+	err := db.Select("id", "company_id", "source", "notes", "created_at").
+		Where("notes LIKE ? OR notes LIKE ?", initialMessagePrefix+"%", "%] Nuevo contacto%").
+		FindInBatches(&leads, 100, func(tx *gorm.DB, batch int) error {
+			for i := range leads {
+				n, err := splitLeadNotes(db, &leads[i])
+				if err != nil {
+					return err
+				}
+				moved += n
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	if moved > 0 {
+		log.Printf("[Migration] Moved %d contact(s) from lead notes to conversations", moved)
+	}
+	return nil
+}
+
+// splitLeadNotes moves the contacts of one lead, all or none of them. The
+// notes are read again under a row lock, as every replica runs the migration
+// on startup and another one may have moved them already.
+func splitLeadNotes(db *gorm.DB, lead *entity.Lead) (int, error) {
+	moved := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var fresh entity.Lead
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "notes").
+			Where("id = ?", lead.ID).
+			Take(&fresh).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		remaining, contacts := SplitNotes(fresh.Notes, lead.CreatedAt, time.Local)
+		if len(contacts) == 0 {
+			return nil
+		}
+
+		messages := make([]entity.Message, 0, len(contacts))
+		var last time.Time
+		for _, c := range contacts {
+			msg := entity.Message{
+				ID:         uuid.New().String(),
+				LeadID:     lead.ID,
+				SenderType: entity.SenderLead,
+				Channel:    lead.Source,
+				Content:    c.Message,
+				Timestamp:  c.At,
+			}
+			if c.PropertyReference != "" {
+				var propertyID string
+				if err := tx.Model(&entity.Property{}).
+					Select("id").
+					Where("reference = ? AND company_id = ?", c.PropertyReference, lead.CompanyID).
+					Limit(1).
+					Scan(&propertyID).Error; err != nil {
+					return err
+				}
+				if propertyID != "" {
+					msg.PropertyID = &propertyID
+				}
+			}
+			if c.At.After(last) {
+				last = c.At
+			}
+			messages = append(messages, msg)
+		}
+
+		if err := tx.Create(&messages).Error; err != nil {
+			return err
+		}
+		moved = len(contacts)
+		return tx.Model(&entity.Lead{}).
+			Where("id = ?", lead.ID).
+			UpdateColumns(map[string]any{
+				"notes":            remaining,
+				"last_interaction": gorm.Expr("GREATEST(last_interaction, ?)", last),
+			}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
@@ -0,0 +1,37 @@
+package migration
+
+import (
+	"log"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+// NormalizeLeadPhones fills NormalizedPhone for the leads stored before it
+// existed, which have it NULL. Phones that cannot identify a lead get "", so
+// running it again finds nothing left to do.
+func NormalizeLeadPhones(db *gorm.DB) error {
+	var leads []entity.Lead
+	normalized := 0
+	err := db.Select("id", "phone").
+		Where("normalized_phone IS NULL").
+		FindInBatches(&leads, 500, func(tx *gorm.DB, batch int) error {
+			for _, lead := range leads {
+				if err := db.Model(&entity.Lead{}).
+					Where("id = ?", lead.ID).
+					UpdateColumn("normalized_phone", entity.NormalizePhone(lead.Phone)).Error; err != nil {
+					return err
+				}
+			}
+			normalized += len(leads)
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	if normalized > 0 {
+		log.Printf("[Migration] Normalized the phone of %d lead(s)", normalized)
+	}
+	return nil
+}
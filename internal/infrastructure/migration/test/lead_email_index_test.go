@@ -0,0 +1,64 @@
+package test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func setupMigrationSQLMock(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	require.NoError(t, err)
+
+	return gormDB, mock
+}
+
+func TestLeadEmailIndex_ReplacesTheRawIndex_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupMigrationSQLMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS idx_leads_company_email`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_leads_company_lower_email\s+ON leads \(company_id, LOWER\(email\)\) WHERE deleted_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := migration.LeadEmailIndex(db)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadEmailIndex_KeepsTheRawIndexWhileEmailsCollide_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupMigrationSQLMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS idx_leads_company_email`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX`)).
+		WillReturnError(errors.New(`could not create unique index "idx_leads_company_lower_email"`))
+	mock.ExpectRollback()
+
+	// WHEN
+	err := migration.LeadEmailIndex(db)
+
+	// THEN
+	assert.ErrorContains(t, err, "could not create unique index")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
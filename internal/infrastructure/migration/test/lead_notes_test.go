@@ -0,0 +1,93 @@
+package test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitNotes_ContactsBecomeMessages(t *testing.T) {
+	// GIVEN
+	createdAt := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	notes := "Mensaje inicial: Me interesa el piso\n" +
+		"[2026-09-03 18:45] Nuevo contacto. Mensaje: ¿Sigue disponible?\n" +
+		"[2026-09-10 09:05] Nuevo contacto sobre propiedad V-1020 (anterior: 5b0c). Mensaje: Y esta casa?"
+
+	// WHEN
+	remaining, contacts := migration.SplitNotes(notes, createdAt, time.UTC)
+
+	// THEN
+	assert.Empty(t, remaining)
+	require.Len(t, contacts, 3)
+	assert.Equal(t, createdAt, contacts[0].At)
+	assert.Equal(t, "Me interesa el piso", contacts[0].Message)
+	assert.Equal(t, time.Date(2026, 9, 3, 18, 45, 0, 0, time.UTC), contacts[1].At)
+	assert.Equal(t, "¿Sigue disponible?", contacts[1].Message)
+	assert.Empty(t, contacts[1].PropertyReference)
+	assert.Equal(t, "Y esta casa?", contacts[2].Message)
+	assert.Equal(t, "V-1020", contacts[2].PropertyReference)
+}
+
+func TestSplitNotes_AgentNotesAreKept(t *testing.T) {
+	// GIVEN
+	notes := "Call back after the summer\n[2026-09-03 18:45] Nuevo contacto. Mensaje: Hola"
+
+	// WHEN
+	remaining, contacts := migration.SplitNotes(notes, time.Now(), time.UTC)
+
+	// THEN
+	assert.Equal(t, "Call back after the summer", remaining)
+	require.Len(t, contacts, 1)
+	assert.Equal(t, "Hola", contacts[0].Message)
+}
+
+func TestSplitNotes_NothingToSplit(t *testing.T) {
+	remaining, contacts := migration.SplitNotes("Prefers mornings", time.Now(), time.UTC)
+
+	assert.Equal(t, "Prefers mornings", remaining)
+	assert.Empty(t, contacts)
+}
+
+func TestSplitNotes_TrailingAgentNotesAreKept(t *testing.T) {
+	// GIVEN
+	notes := "Mensaje inicial: Me interesa el piso\n" +
+		"Visited on Saturday, liked the terrace\n" +
+		"[2026-09-03 18:45] Nuevo contacto. Mensaje: ¿Sigue disponible?\n" +
+		"Offer 250k at most"
+
+	// WHEN
+	remaining, contacts := migration.SplitNotes(notes, time.Now(), time.UTC)
+
+	// THEN
+	assert.Equal(t, "Visited on Saturday, liked the terrace\nOffer 250k at most", remaining)
+	require.Len(t, contacts, 2)
+	assert.Equal(t, "Me interesa el piso", contacts[0].Message)
+	assert.Equal(t, "¿Sigue disponible?", contacts[1].Message)
+}
+
+func TestSplitLeadNotes_AlreadyMovedByAnotherReplica_SQLMock(t *testing.T) {
+	// GIVEN a lead read with contacts in its notes, which another replica
+	// moved before the row could be locked
+	db, mock := setupMigrationSQLMock(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","company_id","source","notes","created_at" FROM "leads"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "company_id", "source", "notes", "created_at"}).
+			AddRow("L1", "C1", "idealista", "Mensaje inicial: Hola", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","notes" FROM "leads" WHERE id = $1 AND "leads"."deleted_at" IS NULL LIMIT $2 FOR UPDATE`)).
+		WithArgs("L1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes"}).AddRow("L1", ""))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := migration.SplitLeadNotes(db)
+
+	// THEN no message is created twice
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,36 @@
+package oauth2
+
+import (
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// MicrosoftMailReadScope lets the app read the signed-in user's mailbox through Graph
+const MicrosoftMailReadScope = "https://graph.microsoft.com/Mail.Read"
+
+// NewMicrosoftOAuth2Config creates and returns the OAuth2 configuration for the
+// Microsoft identity platform. tenant is a directory ID or "common" to accept
+// accounts from any Microsoft 365 organisation.
+func NewMicrosoftOAuth2Config(clientID, clientSecret, redirectURL, tenant string) *OAuth2Config {
+	if tenant == "" {
+		tenant = "common"
+	}
+
+	config := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes: []string{
+			"offline_access", // Refresh token
+			MicrosoftMailReadScope,
+		},
+		Endpoint: microsoft.AzureADEndpoint(tenant),
+	}
+
+	return &OAuth2Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Config:       config,
+	}
+}
@@ -4,16 +4,19 @@ import (
 	"context"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"gorm.io/gorm"
 )
 
 type AgentRepository interface {
-	Create(agent *entity.Agent) error
-	FindByID(id string) (*entity.Agent, error)
-	FindAll() ([]entity.Agent, error)
+	Create(ctx context.Context, agent *entity.Agent) error
+	FindByID(ctx context.Context, id string) (*entity.Agent, error)
+	FindAll(ctx context.Context) ([]entity.Agent, error)
 	UpdatePartial(ctx context.Context, id string, fields map[string]interface{}) error
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 	FindByEmail(ctx context.Context, name string) (*entity.Agent, error)
+	// EmailInUse reports whether an agent of any company has email
+	EmailInUse(ctx context.Context, email string) (bool, error)
 }
 
 type agentRepository struct {
@@ -24,37 +27,68 @@ func NewAgentRepository(db *gorm.DB) AgentRepository {
 	return &agentRepository{db: db}
 }
 
-func (r *agentRepository) Create(agent *entity.Agent) error {
-	return r.db.Create(agent).Error
+func (r *agentRepository) Create(ctx context.Context, agent *entity.Agent) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		agent.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(agent).Error
 }
 
-func (r *agentRepository) FindByID(id string) (*entity.Agent, error) {
+func (r *agentRepository) FindByID(ctx context.Context, id string) (*entity.Agent, error) {
 	var agent entity.Agent
-	if err := r.db.First(&agent, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&agent, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &agent, nil
 }
 
-func (r *agentRepository) FindAll() ([]entity.Agent, error) {
+func (r *agentRepository) FindAll(ctx context.Context) ([]entity.Agent, error) {
 	var companies []entity.Agent
-	if err := r.db.Find(&companies).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Find(&companies).Error; err != nil {
 		return nil, err
 	}
 	return companies, nil
 }
 
 func (r *agentRepository) UpdatePartial(ctx context.Context, id string, fields map[string]interface{}) error {
-	return r.db.WithContext(ctx).Model(&entity.Agent{}).Where("id = ?", id).Updates(fields).Error
+	// An agent can never be moved to another company through a partial update
+	delete(fields, "company_id")
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(&entity.Agent{}).
+		Where("id = ?", id).
+		Updates(fields))
+}
+
+func (r *agentRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Unscoped().
+		Delete(&entity.Agent{}, "id = ?", id))
 }
 
-func (r *agentRepository) Delete(id string) error {
-	return r.db.Unscoped().Delete(&entity.Agent{}, "id = ?", id).Error
+// EmailInUse is not scoped by company, and counts deleted agents too: the
+// unique index on agent emails covers both
+func (r *agentRepository) EmailInUse(ctx context.Context, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Model(&entity.Agent{}).
+		Where("LOWER(email) = LOWER(?)", email).
+		Count(&count).Error
+	return count > 0, err
 }
 
 func (r *agentRepository) FindByEmail(ctx context.Context, email string) (*entity.Agent, error) {
 	var agent entity.Agent
-	query := r.db.WithContext(ctx).Where("email = ?", email).First(&agent)
+	query := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("email = ?", email).
+		First(&agent)
 
 	if query.Error != nil {
 		if query.Error == gorm.ErrRecordNotFound {
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *entity.APIKey) error
+	FindAll(ctx context.Context) ([]entity.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (*entity.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		key.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) FindAll(ctx context.Context) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// FindByHash is used by the auth middleware, before any company is known
+func (r *apiKeyRepository) FindByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	err := r.db.WithContext(ctx).
+		Where("key_hash = ?", hash).
+		First(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.APIKey{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()))
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+}
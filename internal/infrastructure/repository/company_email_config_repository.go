@@ -5,18 +5,22 @@ import (
 	"time"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"gorm.io/gorm"
 )
 
 // CompanyEmailConfigRepository manages company email configurations
 type CompanyEmailConfigRepository interface {
-	Create(config *entity.CompanyEmailConfig) error
-	Update(config *entity.CompanyEmailConfig) error
-	FindByID(id string) (*entity.CompanyEmailConfig, error)
-	FindByCompanyID(ctx context.Context, companyID string) (*entity.CompanyEmailConfig, error)
+	Create(ctx context.Context, config *entity.CompanyEmailConfig) error
+	Update(ctx context.Context, config *entity.CompanyEmailConfig) error
+	FindByID(ctx context.Context, id string) (*entity.CompanyEmailConfig, error)
+	FindByCompanyID(ctx context.Context, companyID string) ([]*entity.CompanyEmailConfig, error)
 	FindAllEnabled(ctx context.Context) ([]*entity.CompanyEmailConfig, error)
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 	UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error
+	UpdateTokens(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error
+	UpdateSyncStatus(ctx context.Context, id string, consecutiveFailures int, lastError string) error
+	Disable(ctx context.Context, id, reason string) error
 }
 
 type companyEmailConfigRepository struct {
@@ -28,17 +32,31 @@ func NewCompanyEmailConfigRepository(db *gorm.DB) CompanyEmailConfigRepository {
 	return &companyEmailConfigRepository{db: db}
 }
 
-func (r *companyEmailConfigRepository) Create(config *entity.CompanyEmailConfig) error {
-	return r.db.Create(config).Error
+func (r *companyEmailConfigRepository) Create(ctx context.Context, config *entity.CompanyEmailConfig) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		config.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(config).Error
 }
 
-func (r *companyEmailConfigRepository) Update(config *entity.CompanyEmailConfig) error {
-	return r.db.Save(config).Error
+func (r *companyEmailConfigRepository) Update(ctx context.Context, config *entity.CompanyEmailConfig) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		config.CompanyID = companyID
+	}
+	// See leadRepository.Update for why this is not a Save
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(config).
+		Select("*").
+		Omit("Company").
+		Updates(config))
 }
 
-func (r *companyEmailConfigRepository) FindByID(id string) (*entity.CompanyEmailConfig, error) {
+func (r *companyEmailConfigRepository) FindByID(ctx context.Context, id string) (*entity.CompanyEmailConfig, error) {
 	var config entity.CompanyEmailConfig
-	err := r.db.First(&config, "id = ?", id).Error
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&config, "id = ?", id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -48,24 +66,24 @@ func (r *companyEmailConfigRepository) FindByID(id string) (*entity.CompanyEmail
 	return &config, nil
 }
 
-func (r *companyEmailConfigRepository) FindByCompanyID(ctx context.Context, companyID string) (*entity.CompanyEmailConfig, error) {
-	var config entity.CompanyEmailConfig
+func (r *companyEmailConfigRepository) FindByCompanyID(ctx context.Context, companyID string) ([]*entity.CompanyEmailConfig, error) {
+	var configs []*entity.CompanyEmailConfig
 	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Where("company_id = ?", companyID).
-		First(&config).Error
+		Order("created_at").
+		Find(&configs).Error
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
-		}
 		return nil, err
 	}
-	return &config, nil
+	return configs, nil
 }
 
 func (r *companyEmailConfigRepository) FindAllEnabled(ctx context.Context) ([]*entity.CompanyEmailConfig, error) {
 	var configs []*entity.CompanyEmailConfig
 	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Where("is_enabled = ?", true).
 		Preload("Company").
 		Find(&configs).Error
@@ -76,13 +94,55 @@ func (r *companyEmailConfigRepository) FindAllEnabled(ctx context.Context) ([]*e
 	return configs, nil
 }
 
-func (r *companyEmailConfigRepository) Delete(id string) error {
-	return r.db.Unscoped().Delete(&entity.CompanyEmailConfig{}, "id = ?", id).Error
+func (r *companyEmailConfigRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Unscoped().
+		Delete(&entity.CompanyEmailConfig{}, "id = ?", id))
 }
 
+// UpdateLastSync, UpdateTokens, UpdateSyncStatus and Disable are called on
+// behalf of the workers themselves, so they leave updated_at alone: the worker
+// manager restarts a worker whenever the updated_at of its config moves.
+
 func (r *companyEmailConfigRepository) UpdateLastSync(ctx context.Context, id string, syncTime time.Time) error {
 	return r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(&entity.CompanyEmailConfig{}).
+		Where("id = ?", id).
+		UpdateColumn("last_sync_at", syncTime).Error
+}
+
+func (r *companyEmailConfigRepository) UpdateTokens(ctx context.Context, id, accessToken, refreshToken string, expiry time.Time) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(&entity.CompanyEmailConfig{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"access_token":  accessToken,
+			"refresh_token": refreshToken,
+			"token_expiry":  expiry,
+		}))
+}
+
+func (r *companyEmailConfigRepository) UpdateSyncStatus(ctx context.Context, id string, consecutiveFailures int, lastError string) error {
+	return r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(&entity.CompanyEmailConfig{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"consecutive_failures": consecutiveFailures,
+			"last_error":           lastError,
+		}).Error
+}
+
+func (r *companyEmailConfigRepository) Disable(ctx context.Context, id, reason string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Model(&entity.CompanyEmailConfig{}).
 		Where("id = ?", id).
-		Update("last_sync_at", syncTime).Error
+		UpdateColumns(map[string]interface{}{
+			"is_enabled":      false,
+			"disabled_reason": reason,
+		}))
 }
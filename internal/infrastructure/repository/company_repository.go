@@ -8,11 +8,11 @@ import (
 )
 
 type CompanyRepository interface {
-	Create(company *entity.Company) error
-	FindByID(id string) (*entity.Company, error)
-	FindAll() ([]entity.Company, error)
+	Create(ctx context.Context, company *entity.Company) error
+	FindByID(ctx context.Context, id string) (*entity.Company, error)
+	FindAll(ctx context.Context) ([]entity.Company, error)
 	UpdatePartial(ctx context.Context, id string, fields map[string]interface{}) error
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 	FindByName(ctx context.Context, name string) (*entity.Company, error)
 }
 
@@ -24,37 +24,51 @@ func NewCompanyRepository(db *gorm.DB) CompanyRepository {
 	return &companyRepository{db: db}
 }
 
-func (r *companyRepository) Create(company *entity.Company) error {
-	return r.db.Create(company).Error
+func (r *companyRepository) Create(ctx context.Context, company *entity.Company) error {
+	return r.db.WithContext(ctx).Create(company).Error
 }
 
-func (r *companyRepository) FindByID(id string) (*entity.Company, error) {
+func (r *companyRepository) FindByID(ctx context.Context, id string) (*entity.Company, error) {
 	var company entity.Company
-	if err := r.db.First(&company, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "id")).
+		First(&company, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &company, nil
 }
 
-func (r *companyRepository) FindAll() ([]entity.Company, error) {
+func (r *companyRepository) FindAll(ctx context.Context) ([]entity.Company, error) {
 	var companies []entity.Company
-	if err := r.db.Find(&companies).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "id")).
+		Find(&companies).Error; err != nil {
 		return nil, err
 	}
 	return companies, nil
 }
 
 func (r *companyRepository) UpdatePartial(ctx context.Context, id string, fields map[string]interface{}) error {
-	return r.db.WithContext(ctx).Model(&entity.Company{}).Where("id = ?", id).Updates(fields).Error
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "id")).
+		Model(&entity.Company{}).
+		Where("id = ?", id).
+		Updates(fields))
 }
 
-func (r *companyRepository) Delete(id string) error {
-	return r.db.Unscoped().Delete(&entity.Company{}, "id = ?", id).Error
+func (r *companyRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "id")).
+		Unscoped().
+		Delete(&entity.Company{}, "id = ?", id))
 }
 
 func (r *companyRepository) FindByName(ctx context.Context, name string) (*entity.Company, error) {
 	var company entity.Company
-	query := r.db.WithContext(ctx).Where("name = ?", name).First(&company)
+	query := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "id")).
+		Where("name = ?", name).
+		First(&company)
 
 	if query.Error != nil {
 		if query.Error == gorm.ErrRecordNotFound {
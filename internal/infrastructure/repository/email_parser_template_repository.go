@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type EmailParserTemplateRepository interface {
+	Create(ctx context.Context, template *entity.EmailParserTemplate) error
+	Update(ctx context.Context, template *entity.EmailParserTemplate) error
+	FindByID(ctx context.Context, id string) (*entity.EmailParserTemplate, error)
+	FindAll(ctx context.Context) ([]entity.EmailParserTemplate, error)
+	FindEnabled(ctx context.Context) ([]entity.EmailParserTemplate, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type emailParserTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailParserTemplateRepository(db *gorm.DB) EmailParserTemplateRepository {
+	return &emailParserTemplateRepository{db: db}
+}
+
+func (r *emailParserTemplateRepository) Create(ctx context.Context, template *entity.EmailParserTemplate) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		template.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(template).Error
+}
+
+func (r *emailParserTemplateRepository) Update(ctx context.Context, template *entity.EmailParserTemplate) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		template.CompanyID = companyID
+	}
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(template).
+		Select("*").
+		Omit("Company", "CreatedAt").
+		Updates(template))
+}
+
+func (r *emailParserTemplateRepository) FindByID(ctx context.Context, id string) (*entity.EmailParserTemplate, error) {
+	var template entity.EmailParserTemplate
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&template, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *emailParserTemplateRepository) FindAll(ctx context.Context) ([]entity.EmailParserTemplate, error) {
+	var templates []entity.EmailParserTemplate
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Order("priority, created_at").
+		Find(&templates).Error
+	return templates, err
+}
+
+// FindEnabled returns the templates the email workers try, in the order they are tried
+func (r *emailParserTemplateRepository) FindEnabled(ctx context.Context) ([]entity.EmailParserTemplate, error) {
+	var templates []entity.EmailParserTemplate
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("is_enabled = ?", true).
+		Order("priority, created_at").
+		Find(&templates).Error
+	return templates, err
+}
+
+func (r *emailParserTemplateRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Delete(&entity.EmailParserTemplate{}, "id = ?", id))
+}
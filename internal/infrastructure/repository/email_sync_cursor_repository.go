@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailSyncCursorRepository stores where each inbox was last read up to
+type EmailSyncCursorRepository interface {
+	FindByConfigID(ctx context.Context, configID string) (*entity.EmailSyncCursor, error)
+	Save(ctx context.Context, cursor *entity.EmailSyncCursor) error
+}
+
+type emailSyncCursorRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailSyncCursorRepository creates a new repository
+func NewEmailSyncCursorRepository(db *gorm.DB) EmailSyncCursorRepository {
+	return &emailSyncCursorRepository{db: db}
+}
+
+func (r *emailSyncCursorRepository) FindByConfigID(ctx context.Context, configID string) (*entity.EmailSyncCursor, error) {
+	var cursor entity.EmailSyncCursor
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&cursor, "config_id = ?", configID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// Save inserts the cursor or moves an existing one forward
+func (r *emailSyncCursorRepository) Save(ctx context.Context, cursor *entity.EmailSyncCursor) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		cursor.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "config_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"uid_validity", "last_uid", "history_id", "delta_link", "updated_at"}),
+		}).
+		Create(cursor).Error
+}
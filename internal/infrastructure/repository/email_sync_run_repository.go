@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+// EmailSyncRunRepository stores the outcome of each inbox poll
+type EmailSyncRunRepository interface {
+	Create(ctx context.Context, run *entity.EmailSyncRun) error
+	FindRecentByConfigID(ctx context.Context, configID string, limit int) ([]entity.EmailSyncRun, error)
+	DeleteBefore(ctx context.Context, configID string, before time.Time) error
+}
+
+type emailSyncRunRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailSyncRunRepository creates a new repository
+func NewEmailSyncRunRepository(db *gorm.DB) EmailSyncRunRepository {
+	return &emailSyncRunRepository{db: db}
+}
+
+func (r *emailSyncRunRepository) Create(ctx context.Context, run *entity.EmailSyncRun) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		run.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// FindRecentByConfigID returns the latest runs of an inbox, newest first
+func (r *emailSyncRunRepository) FindRecentByConfigID(ctx context.Context, configID string, limit int) ([]entity.EmailSyncRun, error) {
+	var runs []entity.EmailSyncRun
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("config_id = ?", configID).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// DeleteBefore removes the runs of an inbox that started before the given time
+func (r *emailSyncRunRepository) DeleteBefore(ctx context.Context, configID string, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("config_id = ? AND started_at < ?", configID, before).
+		Delete(&entity.EmailSyncRun{}).Error
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailWorkerLeaseRepository hands out the per-inbox leases that make sure a
+// single replica polls each inbox. Expiry is computed by the database clock,
+// so replicas with skewed clocks still agree on it.
+type EmailWorkerLeaseRepository interface {
+	// Acquire takes the lease of configID for holderID when it is free, expired
+	// or already held by holderID, and reports whether holderID now holds it
+	Acquire(ctx context.Context, configID, holderID string, ttl time.Duration) (bool, error)
+	// Renew extends a lease held by holderID and reports false when it was lost
+	Renew(ctx context.Context, configID, holderID string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, configID, holderID string) error
+}
+
+type emailWorkerLeaseRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailWorkerLeaseRepository creates a new repository
+func NewEmailWorkerLeaseRepository(db *gorm.DB) EmailWorkerLeaseRepository {
+	return &emailWorkerLeaseRepository{db: db}
+}
+
+func (r *emailWorkerLeaseRepository) Acquire(ctx context.Context, configID, holderID string, ttl time.Duration) (bool, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		INSERT INTO email_worker_leases (config_id, holder_id, acquired_at, expires_at)
+		VALUES (?, ?, now(), now() + make_interval(secs => ?))
+		ON CONFLICT (config_id) DO UPDATE
+		SET holder_id = excluded.holder_id, acquired_at = excluded.acquired_at, expires_at = excluded.expires_at
+		WHERE email_worker_leases.expires_at < now() OR email_worker_leases.holder_id = excluded.holder_id`,
+		configID, holderID, ttl.Seconds())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *emailWorkerLeaseRepository) Renew(ctx context.Context, configID, holderID string, ttl time.Duration) (bool, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE email_worker_leases SET expires_at = now() + make_interval(secs => ?)
+		WHERE config_id = ? AND holder_id = ?`,
+		ttl.Seconds(), configID, holderID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *emailWorkerLeaseRepository) Release(ctx context.Context, configID, holderID string) error {
+	return r.db.WithContext(ctx).Exec(
+		"DELETE FROM email_worker_leases WHERE config_id = ? AND holder_id = ?",
+		configID, holderID).Error
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+// FailedEmailFilter narrows the dead-letter list; zero values mean "any"
+type FailedEmailFilter struct {
+	Category        entity.EmailFailureCategory
+	IncludeResolved bool
+}
+
+type FailedEmailRepository interface {
+	Create(ctx context.Context, failed *entity.FailedEmail) error
+	Update(ctx context.Context, failed *entity.FailedEmail) error
+	FindByID(ctx context.Context, id string) (*entity.FailedEmail, error)
+	FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.FailedEmail, error)
+	FindAll(ctx context.Context, filter FailedEmailFilter) ([]entity.FailedEmail, error)
+	FindUnresolvedByReference(ctx context.Context, reference string) ([]entity.FailedEmail, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type failedEmailRepository struct {
+	db *gorm.DB
+}
+
+func NewFailedEmailRepository(db *gorm.DB) FailedEmailRepository {
+	return &failedEmailRepository{db: db}
+}
+
+func (r *failedEmailRepository) Create(ctx context.Context, failed *entity.FailedEmail) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		failed.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(failed).Error
+}
+
+func (r *failedEmailRepository) Update(ctx context.Context, failed *entity.FailedEmail) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.FailedEmail{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ?", failed.ID).
+		Select("category", "error", "property_reference", "attempts", "last_attempt_at", "resolved_at", "updated_at").
+		Updates(failed))
+}
+
+func (r *failedEmailRepository) FindByID(ctx context.Context, id string) (*entity.FailedEmail, error) {
+	var failed entity.FailedEmail
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&failed, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &failed, nil
+}
+
+func (r *failedEmailRepository) FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.FailedEmail, error) {
+	var failed entity.FailedEmail
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("company_id = ? AND message_id = ?", companyID, messageID).
+		First(&failed).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &failed, nil
+}
+
+func (r *failedEmailRepository) FindAll(ctx context.Context, filter FailedEmailFilter) ([]entity.FailedEmail, error) {
+	query := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id"))
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if !filter.IncludeResolved {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	var failed []entity.FailedEmail
+	err := query.Order("last_attempt_at DESC").Find(&failed).Error
+	return failed, err
+}
+
+func (r *failedEmailRepository) FindUnresolvedByReference(ctx context.Context, reference string) ([]entity.FailedEmail, error) {
+	var failed []entity.FailedEmail
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("category = ? AND property_reference = ? AND resolved_at IS NULL", entity.EmailFailureUnknownReference, reference).
+		Order("created_at").
+		Find(&failed).Error
+	return failed, err
+}
+
+func (r *failedEmailRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Delete(&entity.FailedEmail{}, "id = ?", id))
+}
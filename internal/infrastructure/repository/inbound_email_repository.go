@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type InboundEmailRepository interface {
+	// Create stores the email together with its attachments
+	Create(ctx context.Context, inbound *entity.InboundEmail) error
+	// UpdateResult saves what processing the email gave: parse result and lead links
+	UpdateResult(ctx context.Context, inbound *entity.InboundEmail) error
+	FindByID(ctx context.Context, id string) (*entity.InboundEmail, error)
+	FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.InboundEmail, error)
+}
+
+type inboundEmailRepository struct {
+	db *gorm.DB
+}
+
+func NewInboundEmailRepository(db *gorm.DB) InboundEmailRepository {
+	return &inboundEmailRepository{db: db}
+}
+
+func (r *inboundEmailRepository) Create(ctx context.Context, inbound *entity.InboundEmail) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		inbound.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(inbound).Error
+}
+
+func (r *inboundEmailRepository) UpdateResult(ctx context.Context, inbound *entity.InboundEmail) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.InboundEmail{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ?", inbound.ID).
+		Select("source", "parse_status", "parse_error", "parsed_lead", "lead_id", "conversation_message_id", "updated_at").
+		Updates(inbound))
+}
+
+func (r *inboundEmailRepository) FindByID(ctx context.Context, id string) (*entity.InboundEmail, error) {
+	var inbound entity.InboundEmail
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&inbound, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inbound, nil
+}
+
+func (r *inboundEmailRepository) FindByMessageID(ctx context.Context, companyID, messageID string) (*entity.InboundEmail, error) {
+	var inbound entity.InboundEmail
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("company_id = ? AND message_id = ?", companyID, messageID).
+		First(&inbound).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inbound, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *entity.Invitation) error
+	FindByID(ctx context.Context, id string) (*entity.Invitation, error)
+	FindByTokenHash(ctx context.Context, hash string) (*entity.Invitation, error)
+	FindPending(ctx context.Context) ([]entity.Invitation, error)
+	RevokePendingForEmail(ctx context.Context, companyID, email string) error
+	MarkAsAccepted(ctx context.Context, id string) error
+	Revoke(ctx context.Context, id string) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(ctx context.Context, invitation *entity.Invitation) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		invitation.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *invitationRepository) FindByID(ctx context.Context, id string) (*entity.Invitation, error) {
+	var invitation entity.Invitation
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ?", id).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindByTokenHash is used by the public accept endpoint, before any company is known
+func (r *invitationRepository) FindByTokenHash(ctx context.Context, hash string) (*entity.Invitation, error) {
+	var invitation entity.Invitation
+	err := r.db.WithContext(ctx).
+		Preload("Company").
+		Where("token_hash = ?", hash).
+		First(&invitation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) FindPending(ctx context.Context) ([]entity.Invitation, error) {
+	var invitations []entity.Invitation
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *invitationRepository) RevokePendingForEmail(ctx context.Context, companyID, email string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.Invitation{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("company_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", companyID, email).
+		Update("revoked_at", time.Now()).Error
+}
+
+// MarkAsAccepted only succeeds once per invitation, so a token cannot be used twice
+func (r *invitationRepository) MarkAsAccepted(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("accepted_at", time.Now()))
+}
+
+func (r *invitationRepository) Revoke(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.Invitation{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()))
+}
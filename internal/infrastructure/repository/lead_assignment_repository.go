@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type LeadAssignmentRepository interface {
+	CreateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error
+	UpdateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error
+	FindRuleByID(ctx context.Context, id string) (*entity.LeadAssignmentRule, error)
+	FindRules(ctx context.Context) ([]entity.LeadAssignmentRule, error)
+	// FindEnabledRules returns the rules new leads go through, in the order they are tried
+	FindEnabledRules(ctx context.Context) ([]entity.LeadAssignmentRule, error)
+	DeleteRule(ctx context.Context, id string) error
+
+	// Assign sets the agent of the lead of assignment to ToAgentID and stores
+	// assignment, all or nothing
+	Assign(ctx context.Context, assignment *entity.LeadAssignment) error
+	// FindByLead returns the assignment history of a lead, oldest first
+	FindByLead(ctx context.Context, leadID string) ([]entity.LeadAssignment, error)
+	// Workloads returns the agents of the company who can take leads, with
+	// their open leads and when they were last assigned one
+	Workloads(ctx context.Context) ([]entity.AgentWorkload, error)
+}
+
+type leadAssignmentRepository struct {
+	db *gorm.DB
+}
+
+func NewLeadAssignmentRepository(db *gorm.DB) LeadAssignmentRepository {
+	return &leadAssignmentRepository{db: db}
+}
+
+func (r *leadAssignmentRepository) CreateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		rule.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *leadAssignmentRepository) UpdateRule(ctx context.Context, rule *entity.LeadAssignmentRule) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		rule.CompanyID = companyID
+	}
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(rule).
+		Select("*").
+		Omit("Company", "CreatedAt").
+		Updates(rule))
+}
+
+func (r *leadAssignmentRepository) FindRuleByID(ctx context.Context, id string) (*entity.LeadAssignmentRule, error) {
+	var rule entity.LeadAssignmentRule
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&rule, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *leadAssignmentRepository) FindRules(ctx context.Context) ([]entity.LeadAssignmentRule, error) {
+	var rules []entity.LeadAssignmentRule
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Order("priority, created_at").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (r *leadAssignmentRepository) FindEnabledRules(ctx context.Context) ([]entity.LeadAssignmentRule, error) {
+	var rules []entity.LeadAssignmentRule
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("is_enabled = ?", true).
+		Order("priority, created_at").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (r *leadAssignmentRepository) DeleteRule(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Delete(&entity.LeadAssignmentRule{}, "id = ?", id))
+}
+
+func (r *leadAssignmentRepository) Assign(ctx context.Context, assignment *entity.LeadAssignment) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		assignment.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkAffected(tx.Model(&entity.Lead{}).
+			Scopes(scopeByCompany(ctx, "company_id")).
+			Where("id = ?", assignment.LeadID).
+			Update("assigned_agent_id", assignment.ToAgentID)); err != nil {
+			return err
+		}
+		return tx.Create(assignment).Error
+	})
+}
+
+func (r *leadAssignmentRepository) FindByLead(ctx context.Context, leadID string) ([]entity.LeadAssignment, error) {
+	var assignments []entity.LeadAssignment
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("lead_id = ?", leadID).
+		Order("created_at").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+func (r *leadAssignmentRepository) Workloads(ctx context.Context) ([]entity.AgentWorkload, error) {
+	// Agents who can work leads: read-only ones cannot
+	var roles []entity.AgentRole
+	for _, role := range entity.Roles {
+		if role.Can(entity.PermissionLeadWrite) {
+			roles = append(roles, role)
+		}
+	}
+	var open []entity.LeadStatus
+	for _, status := range entity.LeadStatuses {
+		if status.IsOpen() {
+			open = append(open, status)
+		}
+	}
+
+	var workloads []entity.AgentWorkload
+	err := r.db.WithContext(ctx).
+		Model(&entity.Agent{}).
+		Select(`agents.id AS agent_id,
+			(SELECT COUNT(*) FROM leads l WHERE l.assigned_agent_id = agents.id::text AND l.status IN ? AND l.deleted_at IS NULL) AS open_leads,
+			(SELECT MAX(a.created_at) FROM lead_assignments a WHERE a.to_agent_id = agents.id) AS last_assigned_at`, open).
+		Scopes(scopeByCompany(ctx, "agents.company_id")).
+		Where("agents.role IN ?", roles).
+		Order("agents.created_at").
+		Scan(&workloads).Error
+	return workloads, err
+}
@@ -2,19 +2,43 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"gorm.io/gorm"
 )
 
 type LeadRepository interface {
-	Create(lead *entity.Lead) error
-	FindByID(id string) (*entity.Lead, error)
-	FindAll() ([]entity.Lead, error)
-	Update(lead *entity.Lead) error
-	Delete(id string) error
-	FindByEmail(ctx context.Context, email string) (*entity.Lead, error)
+	Create(ctx context.Context, lead *entity.Lead) error
+	FindByID(ctx context.Context, id string) (*entity.Lead, error)
+	FindAll(ctx context.Context) ([]entity.Lead, error)
+	// Update saves every field of lead but its status
+	Update(ctx context.Context, lead *entity.Lead) error
+	// UpdateWithHistory is Update plus the status change and the reassignment
+	// of lead, each nil when it does not happen, all or nothing
+	UpdateWithHistory(ctx context.Context, lead *entity.Lead, change *entity.LeadStatusChange, assignment *entity.LeadAssignment) error
+	Delete(ctx context.Context, id string) error
+	// FindByEmail and FindByPhone return the lead of companyID with that email
+	// or phone, compared normalized
+	FindByEmail(ctx context.Context, companyID, email string) (*entity.Lead, error)
+	FindByPhone(ctx context.Context, companyID, phone string) (*entity.Lead, error)
+	// FindDuplicates returns the other leads of the company of lead sharing its email or phone
+	FindDuplicates(ctx context.Context, lead *entity.Lead) ([]entity.Lead, error)
+	// Merge moves the messages, original emails, status and assignment history,
+	// presentation views and summary of duplicateID to survivor, saves survivor
+	// and deletes duplicateID, all or nothing
+	Merge(ctx context.Context, survivor *entity.Lead, duplicateID string) error
 	FindByCompanyId(ctx context.Context, companyId string) ([]entity.Lead, error)
+	// Search returns the page of the leads matching filter after filter.Cursor,
+	// sorted by filter.Sort, with filter.Limit leads at most
+	Search(ctx context.Context, filter entity.LeadFilter) (*entity.LeadPage, error)
 	FindByPropertyId(ctx context.Context, propertyId string) ([]entity.Lead, error)
 }
 
@@ -26,84 +50,481 @@ func NewLeadRepository(db *gorm.DB) LeadRepository {
 	return &leadRepository{db: db}
 }
 
-func (r *leadRepository) Create(lead *entity.Lead) error {
-	return r.db.Create(lead).Error
+func (r *leadRepository) Create(ctx context.Context, lead *entity.Lead) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		lead.CompanyID = companyID
+	}
+	lead.NormalizedPhone = entity.NormalizePhone(lead.Phone)
+	return r.db.WithContext(ctx).Create(lead).Error
 }
 
-func (r *leadRepository) FindByID(id string) (*entity.Lead, error) {
+func (r *leadRepository) FindByID(ctx context.Context, id string) (*entity.Lead, error) {
 	var lead entity.Lead
-	if err := r.db.Preload("Property").First(&lead, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Preload("Property", scopeByCompany(ctx, "company_id")).
+		First(&lead, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &lead, nil
 }
 
-func (r *leadRepository) FindAll() ([]entity.Lead, error) {
+func (r *leadRepository) FindAll(ctx context.Context) ([]entity.Lead, error) {
 	var leads []entity.Lead
-	if err := r.db.Order("created_at DESC").Find(&leads).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Order("created_at DESC").
+		Find(&leads).Error; err != nil {
 		return nil, err
 	}
 
-	for i := range leads {
-		leads[i].SuggestedPropertiesCount = 0
-		if leads[i].PropertyID != nil && *leads[i].PropertyID != "" && leads[i].Status != "discarded" {
-			leads[i].SuggestedPropertiesCount += 1
+	countSuggestedProperties(leads)
+	return leads, nil
+}
+
+func (r *leadRepository) Update(ctx context.Context, lead *entity.Lead) error {
+	return r.update(ctx, r.db, lead)
+}
+
+func (r *leadRepository) UpdateWithHistory(ctx context.Context, lead *entity.Lead, change *entity.LeadStatusChange, assignment *entity.LeadAssignment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if assignment != nil {
+			lead.AssignedAgentID = assignment.ToAgentID
+		}
+		if err := r.update(ctx, tx, lead); err != nil {
+			return err
 		}
+		if change != nil {
+			change.CompanyID = lead.CompanyID
+			if err := recordStatusChange(ctx, tx, change); err != nil {
+				return err
+			}
+		}
+		if assignment != nil {
+			assignment.CompanyID = lead.CompanyID
+			if err := tx.Create(assignment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *leadRepository) update(ctx context.Context, db *gorm.DB, lead *entity.Lead) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		lead.CompanyID = companyID
 	}
-	return leads, nil
+	lead.NormalizedPhone = entity.NormalizePhone(lead.Phone)
+	// Select("*") + Updates instead of Save: Save falls back to an upsert when
+	// no row matches, which would let a caller overwrite another company's lead.
+	// The status only changes through LeadStatusChangeRepository.Record, the
+	// score through LeadScoreRepository.SaveScore and the last interaction
+	// with each message, which a stale copy of the lead must not undo.
+	return checkAffected(db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(lead).
+		Select("*").
+		Omit("Status", "Score", "ScoredAt", "LastInteraction", "Property", "Company", "Messages", "Summaries").
+		Updates(lead))
 }
 
-func (r *leadRepository) Update(lead *entity.Lead) error {
-	return r.db.Save(lead).Error
+func (r *leadRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Unscoped().
+		Delete(&entity.Lead{}, "id = ?", id))
 }
 
-func (r *leadRepository) Delete(id string) error {
-	return r.db.Unscoped().Delete(&entity.Lead{}, "id = ?", id).Error
+func (r *leadRepository) FindByEmail(ctx context.Context, companyID, email string) (*entity.Lead, error) {
+	return r.findOne(ctx, "company_id = ? AND LOWER(email) = ?", companyID, entity.NormalizeEmail(email))
 }
 
-func (r *leadRepository) FindByEmail(ctx context.Context, email string) (*entity.Lead, error) {
-	var lead entity.Lead
-	query := r.db.WithContext(ctx).
-		Where("email = ?", email).
-		Preload("Property").
-		First(&lead)
+func (r *leadRepository) FindByPhone(ctx context.Context, companyID, phone string) (*entity.Lead, error) {
+	normalized := entity.NormalizePhone(phone)
+	if normalized == "" {
+		return nil, nil
+	}
+	// Several leads may share a phone, e.g. a couple; the oldest is the match
+	return r.findOne(ctx, "company_id = ? AND normalized_phone = ?", companyID, normalized)
+}
 
-	if query.Error != nil {
-		if query.Error == gorm.ErrRecordNotFound {
+func (r *leadRepository) findOne(ctx context.Context, query string, args ...any) (*entity.Lead, error) {
+	var lead entity.Lead
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where(query, args...).
+		Preload("Property", scopeByCompany(ctx, "company_id")).
+		Order("created_at").
+		First(&lead).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
-		return nil, query.Error
+		return nil, err
 	}
-
 	return &lead, nil
 }
 
+func (r *leadRepository) FindDuplicates(ctx context.Context, lead *entity.Lead) ([]entity.Lead, error) {
+	query := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("company_id = ? AND id <> ?", lead.CompanyID, lead.ID)
+
+	match := r.db.Where("LOWER(email) = ?", entity.NormalizeEmail(lead.Email))
+	if phone := entity.NormalizePhone(lead.Phone); phone != "" {
+		match = match.Or("normalized_phone = ?", phone)
+	}
+
+	var leads []entity.Lead
+	if err := query.Where(match).Order("created_at").Find(&leads).Error; err != nil {
+		return nil, err
+	}
+	return leads, nil
+}
+
+func (r *leadRepository) Merge(ctx context.Context, survivor *entity.Lead, duplicateID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Deleted messages too, they still point to the duplicate
+		if err := tx.Unscoped().Model(&entity.Message{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.InboundEmail{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.LeadStatusChange{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.LeadAssignment{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.PresentationView{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+
+		// A lead has one summary: append the duplicate's to the survivor's, or
+		// hand it over when the survivor has none
+		if err := tx.Exec(`UPDATE summaries s SET summary_text = s.summary_text || E'\n\n' || d.summary_text, updated_at = ?
+			FROM summaries d WHERE s.lead_id = ? AND d.lead_id = ?`, time.Now(), survivor.ID, duplicateID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM summaries WHERE lead_id = ? AND EXISTS (SELECT 1 FROM summaries WHERE lead_id = ?)`,
+			duplicateID, survivor.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.Summary{}).
+			Where("lead_id = ?", duplicateID).
+			Update("lead_id", survivor.ID).Error; err != nil {
+			return err
+		}
+
+		if err := r.update(ctx, tx, survivor); err != nil {
+			return err
+		}
+		// GREATEST skips NULLs: the latest interaction of either lead
+		if err := tx.Exec(`UPDATE leads s SET last_interaction = GREATEST(s.last_interaction, d.last_interaction)
+			FROM leads d WHERE s.id = ? AND d.id = ?`, survivor.ID, duplicateID).Error; err != nil {
+			return err
+		}
+		return checkAffected(tx.
+			Scopes(scopeByCompany(ctx, "company_id")).
+			Unscoped().
+			Delete(&entity.Lead{}, "id = ?", duplicateID))
+	})
+}
+
 func (r *leadRepository) FindByCompanyId(ctx context.Context, companyID string) ([]entity.Lead, error) {
 	var leads []entity.Lead
 	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Where("company_id = ?", companyID).
-		Preload("Company").       //Carga company por detrás
-		Order("created_at DESC"). // Newest first
+		Preload("Company", scopeByCompany(ctx, "id")). //Carga company por detrás
+		Order("created_at DESC").                      // Newest first
 		Find(&leads).Error; err != nil {
 		return nil, err
 	}
-	// Calculate SuggestedPropertiesCount
-	for i := range leads {
-		leads[i].SuggestedPropertiesCount = 0
-		if leads[i].PropertyID != nil && *leads[i].PropertyID != "" && leads[i].Status != "discarded" {
-			leads[i].SuggestedPropertiesCount += 1
-		}
-	}
+	countSuggestedProperties(leads)
 	return leads, nil
 }
 
 func (r *leadRepository) FindByPropertyId(ctx context.Context, propertyID string) ([]entity.Lead, error) {
 	var leads []entity.Lead
 	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Where("property_id = ?", propertyID).
-		Preload("Property"). //Carga property por detrás
+		Preload("Property", scopeByCompany(ctx, "company_id")). //Carga property por detrás
 		Find(&leads).Error; err != nil {
 		return nil, err
 	}
 	return leads, nil
 }
+
+// countSuggestedProperties sets the SuggestedPropertiesCount of leads
+func countSuggestedProperties(leads []entity.Lead) {
+	for i := range leads {
+		leads[i].SuggestedPropertiesCount = 0
+		if leads[i].PropertyID != nil && *leads[i].PropertyID != "" && leads[i].Status != "discarded" {
+			leads[i].SuggestedPropertiesCount += 1
+		}
+	}
+}
+
+// leadSortColumn is the SQL expression leads are sorted by for a field, and the
+// value of a lead for it as stored in cursors
+type leadSortColumn struct {
+	expr  string
+	value func(lead *entity.Lead) any
+}
+
+var leadSortColumns = map[entity.LeadSortField]leadSortColumn{
+	entity.LeadSortCreatedAt: {"created_at", func(l *entity.Lead) any { return l.CreatedAt }},
+	entity.LeadSortUpdatedAt: {"updated_at", func(l *entity.Lead) any { return l.UpdatedAt }},
+	entity.LeadSortLastInteraction: {"COALESCE(last_interaction, '0001-01-01')", func(l *entity.Lead) any {
+		if l.LastInteraction == nil {
+			return time.Time{}
+		}
+		return *l.LastInteraction
+	}},
+	entity.LeadSortScore:  {"score", func(l *entity.Lead) any { return l.Score }},
+	entity.LeadSortBudget: {"budget", func(l *entity.Lead) any { return l.Budget }},
+	entity.LeadSortName:   {"name", func(l *entity.Lead) any { return l.Name }},
+	entity.LeadSortStatus: {"status", func(l *entity.Lead) any { return string(l.Status) }},
+}
+
+// leadIDSortColumn breaks ties, so every lead has a single place in the order
+var leadIDSortColumn = leadSortColumn{"id", func(l *entity.Lead) any { return l.ID }}
+
+type leadSortKey struct {
+	leadSortColumn
+	desc bool
+}
+
+func (r *leadRepository) Search(ctx context.Context, filter entity.LeadFilter) (*entity.LeadPage, error) {
+	if filter.Limit <= 0 {
+		return nil, errors.New("invalid limit: required to be above 0")
+	}
+	sorts := filter.Sort
+	if len(sorts) == 0 {
+		sorts = []entity.LeadSort{{Field: entity.LeadSortCreatedAt, Desc: true}}
+	}
+	keys := make([]leadSortKey, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column, ok := leadSortColumns[sort.Field]
+		if !ok {
+			return nil, fmt.Errorf("invalid sort field %q", sort.Field)
+		}
+		keys = append(keys, leadSortKey{column, sort.Desc})
+	}
+	keys = append(keys, leadSortKey{leadIDSortColumn, false})
+
+	// The window count runs before the cursor and the limit apply, so a single
+	// query returns both the page and how many leads match in total
+	filtered := r.filterLeads(ctx, filter)
+	query := r.db.WithContext(ctx).
+		Table("(?) AS leads", filtered.Select("leads.*, COUNT(*) OVER () AS total_count"))
+
+	if filter.Cursor != "" {
+		condition, args, err := keysetCondition(keys, filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = key.expr
+		if key.desc {
+			order[i] += " DESC"
+		}
+	}
+
+	var rows []struct {
+		entity.Lead
+		TotalCount int64
+	}
+	// One lead more than the page tells whether there is a next one
+	if err := query.Order(strings.Join(order, ", ")).Limit(filter.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &entity.LeadPage{Leads: make([]entity.Lead, 0, len(rows))}
+	for i := range rows {
+		if i == filter.Limit {
+			page.NextCursor = encodeLeadCursor(keys, &page.Leads[i-1])
+			break
+		}
+		page.Leads = append(page.Leads, rows[i].Lead)
+		page.Total = rows[i].TotalCount
+	}
+	if len(rows) == 0 && filter.Cursor != "" {
+		// Past the last lead the page has no row to read the total from
+		if err := r.filterLeads(ctx, filter).Count(&page.Total).Error; err != nil {
+			return nil, err
+		}
+	}
+	countSuggestedProperties(page.Leads)
+
+	if filter.CompanyID != nil && len(page.Leads) > 0 {
+		// Every lead shares the company, loaded once instead of joined to each row
+		var company entity.Company
+		if err := r.db.WithContext(ctx).
+			Scopes(scopeByCompany(ctx, "id")).
+			First(&company, "id = ?", *filter.CompanyID).Error; err != nil {
+			return nil, err
+		}
+		for i := range page.Leads {
+			page.Leads[i].Company = &company
+		}
+	}
+	return page, nil
+}
+
+// filterLeads selects the leads matching filter, ignoring its sort, cursor and limit
+func (r *leadRepository) filterLeads(ctx context.Context, filter entity.LeadFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&entity.Lead{}).Scopes(scopeByCompany(ctx, "company_id"))
+
+	if filter.CompanyID != nil {
+		query = query.Where("company_id = ?", *filter.CompanyID)
+	}
+	if filter.SearchTerm != nil && *filter.SearchTerm != "" {
+		pattern := containsPattern(*filter.SearchTerm)
+		query = query.Where(
+			r.db.Where("name ILIKE ?", pattern).
+				Or("email ILIKE ?", pattern).
+				Or("phone ILIKE ?", pattern).
+				Or("zone ILIKE ?", pattern).
+				Or("notes ILIKE ?", pattern),
+		)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.Sources) > 0 {
+		query = query.Where("LOWER(source) IN ?", lowerAll(filter.Sources))
+	}
+	if len(filter.Channels) > 0 {
+		query = query.Where("LOWER(channel) IN ?", lowerAll(filter.Channels))
+	}
+	if filter.AssignedAgentID != nil {
+		if *filter.AssignedAgentID == entity.LeadFilterUnassigned {
+			query = query.Where("assigned_agent_id IS NULL")
+		} else {
+			query = query.Where("assigned_agent_id = ?", *filter.AssignedAgentID)
+		}
+	}
+	if filter.Zone != nil && *filter.Zone != "" {
+		query = query.Where("zone ILIKE ?", containsPattern(*filter.Zone))
+	}
+	if filter.Language != nil && *filter.Language != "" {
+		query = query.Where("LOWER(language) = ?", strings.ToLower(*filter.Language))
+	}
+
+	// Range Filters
+	if filter.MinBudget != nil {
+		query = query.Where("budget >= ?", *filter.MinBudget)
+	}
+	if filter.MaxBudget != nil {
+		query = query.Where("budget <= ?", *filter.MaxBudget)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.LastInteractionFrom != nil {
+		query = query.Where("last_interaction >= ?", *filter.LastInteractionFrom)
+	}
+	if filter.LastInteractionTo != nil {
+		query = query.Where("last_interaction < ?", *filter.LastInteractionTo)
+	}
+	return query
+}
+
+// keysetCondition matches the leads after cursor in the order of keys: those
+// equal to it in the first keys and after it in the next one
+func keysetCondition(keys []leadSortKey, cursor string) (string, []any, error) {
+	values, err := decodeLeadCursor(keys, cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var conditions []string
+	var args []any
+	for i, key := range keys {
+		parts := make([]string, 0, i+1)
+		for _, previous := range keys[:i] {
+			parts = append(parts, previous.expr+" = ?")
+		}
+		args = append(args, values[:i]...)
+
+		op := " > ?"
+		if key.desc {
+			op = " < ?"
+		}
+		parts = append(parts, key.expr+op)
+		args = append(args, values[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(conditions, " OR "), args, nil
+}
+
+// encodeLeadCursor encodes the values of lead for keys, where the next page starts after
+func encodeLeadCursor(keys []leadSortKey, lead *entity.Lead) string {
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = key.value(lead)
+	}
+	raw, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeLeadCursor decodes the values of a cursor for keys, each of the type
+// encodeLeadCursor took it from
+func decodeLeadCursor(keys []leadSortKey, cursor string) ([]any, error) {
+	invalid := errors.New("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var encoded []json.RawMessage
+	if err := json.Unmarshal(raw, &encoded); err != nil || len(encoded) != len(keys) {
+		return nil, invalid
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		value := reflect.New(reflect.TypeOf(key.value(&entity.Lead{})))
+		if err := json.Unmarshal(encoded[i], value.Interface()); err != nil {
+			return nil, invalid
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, nil
+}
+
+// likeEscaper escapes the LIKE wildcards, and backslash, the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern is the LIKE pattern of the values containing term literally
+func containsPattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+func lowerAll(values []string) []string {
+	lower := make([]string, len(values))
+	for i, value := range values {
+		lower[i] = strings.ToLower(value)
+	}
+	return lower
+}
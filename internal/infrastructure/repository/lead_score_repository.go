@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LeadScoreRepository interface {
+	// FindWeights returns the weights of the company, nil when it set none
+	FindWeights(ctx context.Context) (*entity.LeadScoreWeights, error)
+	// SaveWeights creates or replaces the weights of the company
+	SaveWeights(ctx context.Context, weights *entity.LeadScoreWeights) error
+	// Signals returns what lead did since since, and the properties of its
+	// company in its budget
+	Signals(ctx context.Context, lead *entity.Lead, since time.Time) (*entity.LeadScoreSignals, error)
+	// SaveScore stores the score of a lead, leaving its UpdatedAt alone
+	SaveScore(ctx context.Context, score *entity.LeadScore) error
+
+	// LeadsAfter returns up to limit leads with an ID above afterID, by ID, so
+	// every lead can be scored in batches
+	LeadsAfter(ctx context.Context, afterID string, limit int) ([]entity.Lead, error)
+	// SignalsByLead is Signals for a batch of leads, in a single query
+	SignalsByLead(ctx context.Context, leads []entity.Lead, since time.Time) (map[string]entity.LeadScoreSignals, error)
+	// SaveScores is SaveScore for a batch of scores, in a single UPDATE
+	SaveScores(ctx context.Context, scores []entity.LeadScore) error
+}
+
+type leadScoreRepository struct {
+	db *gorm.DB
+}
+
+func NewLeadScoreRepository(db *gorm.DB) LeadScoreRepository {
+	return &leadScoreRepository{db: db}
+}
+
+func (r *leadScoreRepository) FindWeights(ctx context.Context) (*entity.LeadScoreWeights, error) {
+	var weights entity.LeadScoreWeights
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&weights).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &weights, nil
+}
+
+func (r *leadScoreRepository) SaveWeights(ctx context.Context, weights *entity.LeadScoreWeights) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		weights.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "company_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"completeness", "recency", "frequency", "presentations", "budget_fit", "source", "source_quality", "updated_at"}),
+		}).
+		Create(weights).Error
+}
+
+func (r *leadScoreRepository) Signals(ctx context.Context, lead *entity.Lead, since time.Time) (*entity.LeadScoreSignals, error) {
+	var signals entity.LeadScoreSignals
+	err := r.db.WithContext(ctx).Raw(`SELECT
+		(SELECT COUNT(*) FROM messages WHERE lead_id = @lead AND sender_type = @sender AND timestamp >= @since AND deleted_at IS NULL) AS lead_messages,
+		(SELECT MAX(timestamp) FROM messages WHERE lead_id = @lead AND sender_type = @sender AND deleted_at IS NULL) AS last_lead_message_at,
+		(SELECT COUNT(*) FROM presentation_views WHERE lead_id = @lead) AS presentation_views,
+		(SELECT COUNT(*) FROM properties WHERE company_id = @company AND price BETWEEN @min AND @max AND deleted_at IS NULL) AS properties_in_budget`,
+		map[string]any{
+			"lead":    lead.ID,
+			"sender":  entity.SenderLead,
+			"since":   since,
+			"company": lead.CompanyID,
+			"min":     lead.Budget * (1 - entity.BudgetFitMargin),
+			"max":     lead.Budget * (1 + entity.BudgetFitMargin),
+		}).
+		Scan(&signals).Error
+	if err != nil {
+		return nil, err
+	}
+	return &signals, nil
+}
+
+func (r *leadScoreRepository) SaveScore(ctx context.Context, score *entity.LeadScore) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.Lead{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ?", score.LeadID).
+		UpdateColumns(map[string]any{"score": score.Score, "scored_at": score.ScoredAt}))
+}
+
+func (r *leadScoreRepository) LeadsAfter(ctx context.Context, afterID string, limit int) ([]entity.Lead, error) {
+	var leads []entity.Lead
+	query := r.db.WithContext(ctx).Scopes(scopeByCompany(ctx, "company_id"))
+	if afterID != "" {
+		query = query.Where("id > ?", afterID)
+	}
+	err := query.Order("id").Limit(limit).Find(&leads).Error
+	return leads, err
+}
+
+func (r *leadScoreRepository) SignalsByLead(ctx context.Context, leads []entity.Lead, since time.Time) (map[string]entity.LeadScoreSignals, error) {
+	signals := make(map[string]entity.LeadScoreSignals, len(leads))
+	if len(leads) == 0 {
+		return signals, nil
+	}
+	ids := make([]string, len(leads))
+	for i := range leads {
+		ids[i] = leads[i].ID
+	}
+
+	var rows []struct {
+		LeadID             string
+		LeadMessages       int64
+		LastLeadMessageAt  *time.Time
+		PresentationViews  int64
+		PropertiesInBudget int64
+	}
+	err := r.db.WithContext(ctx).Raw(`SELECT l.id AS lead_id,
+		(SELECT COUNT(*) FROM messages m WHERE m.lead_id = l.id AND m.sender_type = @sender AND m.timestamp >= @since AND m.deleted_at IS NULL) AS lead_messages,
+		(SELECT MAX(m.timestamp) FROM messages m WHERE m.lead_id = l.id AND m.sender_type = @sender AND m.deleted_at IS NULL) AS last_lead_message_at,
+		(SELECT COUNT(*) FROM presentation_views v WHERE v.lead_id = l.id) AS presentation_views,
+		(SELECT COUNT(*) FROM properties p WHERE p.company_id = l.company_id AND p.price BETWEEN l.budget * @low AND l.budget * @high AND p.deleted_at IS NULL) AS properties_in_budget
+		FROM leads l WHERE l.id IN @ids`,
+		map[string]any{
+			"ids":    ids,
+			"sender": entity.SenderLead,
+			"since":  since,
+			"low":    1 - entity.BudgetFitMargin,
+			"high":   1 + entity.BudgetFitMargin,
+		}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		signals[row.LeadID] = entity.LeadScoreSignals{
+			LeadMessages:       row.LeadMessages,
+			LastLeadMessageAt:  row.LastLeadMessageAt,
+			PresentationViews:  row.PresentationViews,
+			PropertiesInBudget: row.PropertiesInBudget,
+		}
+	}
+	return signals, nil
+}
+
+func (r *leadScoreRepository) SaveScores(ctx context.Context, scores []entity.LeadScore) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	values := make([]string, len(scores))
+	args := make([]any, 0, 3*len(scores)+1)
+	for i, score := range scores {
+		values[i] = "(?::uuid, ?::integer, ?::timestamptz)"
+		args = append(args, score.LeadID, score.Score, score.ScoredAt)
+	}
+
+	query := `UPDATE leads SET score = v.score, scored_at = v.scored_at
+		FROM (VALUES ` + strings.Join(values, ", ") + `) AS v(id, score, scored_at)
+		WHERE leads.id = v.id`
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		query += " AND leads.company_id = ?"
+		args = append(args, companyID)
+	}
+	return r.db.WithContext(ctx).Exec(query, args...).Error
+}
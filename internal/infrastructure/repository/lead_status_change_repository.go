@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+type LeadStatusChangeRepository interface {
+	// Record moves the lead of change from FromStatus to ToStatus and stores
+	// change, all or nothing. It fails when the lead is no longer in
+	// FromStatus, e.g. because someone else moved it meanwhile.
+	Record(ctx context.Context, change *entity.LeadStatusChange) error
+	// FindByLead returns the status history of a lead, oldest first
+	FindByLead(ctx context.Context, leadID string) ([]entity.LeadStatusChange, error)
+	// Funnel counts the leads created between since and until, and how many
+	// of them reached each status, in total and by reason code
+	Funnel(ctx context.Context, since, until time.Time) (int64, []entity.LeadStatusCount, error)
+}
+
+type leadStatusChangeRepository struct {
+	db *gorm.DB
+}
+
+func NewLeadStatusChangeRepository(db *gorm.DB) LeadStatusChangeRepository {
+	return &leadStatusChangeRepository{db: db}
+}
+
+func (r *leadStatusChangeRepository) Record(ctx context.Context, change *entity.LeadStatusChange) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		change.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordStatusChange(ctx, tx, change)
+	})
+}
+
+// recordStatusChange moves the lead of change to its new status, unless
+// someone moved it first, and records change, on tx
+func recordStatusChange(ctx context.Context, tx *gorm.DB, change *entity.LeadStatusChange) error {
+	result := tx.Model(&entity.Lead{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("id = ? AND status = ?", change.LeadID, change.FromStatus).
+		Update("status", change.ToStatus)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invalid transition: lead %s is not %s", change.LeadID, change.FromStatus)
+	}
+	return tx.Create(change).Error
+}
+
+func (r *leadStatusChangeRepository) FindByLead(ctx context.Context, leadID string) ([]entity.LeadStatusChange, error) {
+	var changes []entity.LeadStatusChange
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("lead_id = ?", leadID).
+		Order("created_at").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *leadStatusChangeRepository) Funnel(ctx context.Context, since, until time.Time) (int64, []entity.LeadStatusCount, error) {
+	var created int64
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Lead{}).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("created_at >= ? AND created_at < ?", since, until).
+		Count(&created).Error; err != nil {
+		return 0, nil, err
+	}
+
+	// The (to_status) grouping set gives the totals, with a NULL reason code
+	var counts []entity.LeadStatusCount
+	if err := r.db.WithContext(ctx).
+		Table("lead_status_changes AS c").
+		Select("c.to_status AS status, c.reason_code, COUNT(DISTINCT c.lead_id) AS leads").
+		Joins("JOIN leads l ON l.id = c.lead_id").
+		Scopes(scopeByCompany(ctx, "l.company_id")).
+		Where("l.created_at >= ? AND l.created_at < ? AND l.deleted_at IS NULL", since, until).
+		Group("GROUPING SETS ((c.to_status), (c.to_status, c.reason_code))").
+		Scan(&counts).Error; err != nil {
+		return 0, nil, err
+	}
+	return created, counts, nil
+}
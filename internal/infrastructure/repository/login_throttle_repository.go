@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+// LoginThrottleRepository is not scoped by company: throttles are checked
+// before anyone is authenticated. Lockout events are read scoped.
+type LoginThrottleRepository interface {
+	Find(ctx context.Context, kind, key string) (*entity.LoginThrottle, error)
+	// Hit adds one attempt to the throttle of kind and key and returns it, all
+	// in one statement so parallel attempts are all counted. It creates the
+	// throttle, or starts a new window when the current one began more than
+	// window ago and is not locked.
+	Hit(ctx context.Context, kind, key string, window time.Duration) (*entity.LoginThrottle, error)
+	// Lock locks the throttle id until until, unless it is locked for longer
+	Lock(ctx context.Context, id string, until time.Time) error
+	Delete(ctx context.Context, kind, key string) error
+	CreateEvent(ctx context.Context, event *entity.LockoutEvent) error
+	FindEvents(ctx context.Context, limit int) ([]entity.LockoutEvent, error)
+}
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+func (r *loginThrottleRepository) Find(ctx context.Context, kind, key string) (*entity.LoginThrottle, error) {
+	var throttle entity.LoginThrottle
+	err := r.db.WithContext(ctx).
+		Where("kind = ? AND key = ?", kind, key).
+		First(&throttle).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *loginThrottleRepository) Hit(ctx context.Context, kind, key string, window time.Duration) (*entity.LoginThrottle, error) {
+	now := time.Now()
+	// The current window is over and no lock holds: start counting again
+	const expired = `login_throttles.window_start < @since AND (login_throttles.locked_until IS NULL OR login_throttles.locked_until <= @now)`
+
+	var throttle entity.LoginThrottle
+	err := r.db.WithContext(ctx).Raw(`INSERT INTO login_throttles (kind, key, attempts, window_start, updated_at)
+		VALUES (@kind, @key, 1, @now, @now)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			attempts = CASE WHEN `+expired+` THEN 1 ELSE login_throttles.attempts + 1 END,
+			window_start = CASE WHEN `+expired+` THEN @now ELSE login_throttles.window_start END,
+			locked_until = CASE WHEN `+expired+` THEN NULL ELSE login_throttles.locked_until END,
+			updated_at = @now
+		RETURNING *`,
+		sql.Named("kind", kind), sql.Named("key", key),
+		sql.Named("now", now), sql.Named("since", now.Add(-window))).
+		Scan(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *loginThrottleRepository) Lock(ctx context.Context, id string, until time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.LoginThrottle{}).
+		Where("id = ?", id).
+		Update("locked_until", gorm.Expr("GREATEST(locked_until, ?)", until)).Error
+}
+
+func (r *loginThrottleRepository) Delete(ctx context.Context, kind, key string) error {
+	return r.db.WithContext(ctx).
+		Where("kind = ? AND key = ?", kind, key).
+		Delete(&entity.LoginThrottle{}).Error
+}
+
+func (r *loginThrottleRepository) CreateEvent(ctx context.Context, event *entity.LockoutEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *loginThrottleRepository) FindEvents(ctx context.Context, limit int) ([]entity.LockoutEvent, error) {
+	var events []entity.LockoutEvent
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
@@ -1,13 +1,18 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"gorm.io/gorm"
 )
 
 type MessageRepository interface {
-	Create(message *entity.Message) error
-	FindByLeadID(leadID string) ([]entity.Message, error)
+	// Create adds message to the conversation of its lead and moves the lead's
+	// LastInteraction up to the message timestamp
+	Create(ctx context.Context, message *entity.Message) error
+	FindByLeadID(ctx context.Context, leadID string) ([]entity.Message, error)
 }
 
 type messageRepository struct {
@@ -18,13 +23,53 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 	return &messageRepository{db: db}
 }
 
-func (r *messageRepository) Create(message *entity.Message) error {
-	return r.db.Create(message).Error
+// scopeByLeadCompany restricts messages to leads of the company in ctx.
+// Messages have no company of their own, they belong to whoever owns the lead.
+func scopeByLeadCompany(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		companyID, ok := tenant.CompanyID(ctx)
+		if !ok {
+			return db
+		}
+		return db.Where("lead_id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).
+				Model(&entity.Lead{}).
+				Select("id").
+				Where("company_id = ?", companyID))
+	}
+}
+
+func (r *messageRepository) Create(ctx context.Context, message *entity.Message) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		var count int64
+		if err := r.db.WithContext(ctx).
+			Model(&entity.Lead{}).
+			Where("id = ? AND company_id = ?", message.LeadID, companyID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		// The last interaction of a lead is its latest message
+		return tx.Model(&entity.Lead{}).
+			Where("id = ?", message.LeadID).
+			UpdateColumn("last_interaction", gorm.Expr("GREATEST(last_interaction, ?)", message.Timestamp)).Error
+	})
 }
 
-func (r *messageRepository) FindByLeadID(leadID string) ([]entity.Message, error) {
+func (r *messageRepository) FindByLeadID(ctx context.Context, leadID string) ([]entity.Message, error) {
 	var messages []entity.Message
-	if err := r.db.Where("lead_id = ?", leadID).Order("timestamp asc").Find(&messages).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByLeadCompany(ctx)).
+		Where("lead_id = ?", leadID).
+		Order("timestamp asc").
+		Find(&messages).Error; err != nil {
 		return nil, err
 	}
 	return messages, nil
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+type PresentationViewRepository interface {
+	Create(ctx context.Context, view *entity.PresentationView) error
+}
+
+type presentationViewRepository struct {
+	db *gorm.DB
+}
+
+func NewPresentationViewRepository(db *gorm.DB) PresentationViewRepository {
+	return &presentationViewRepository{db: db}
+}
+
+func (r *presentationViewRepository) Create(ctx context.Context, view *entity.PresentationView) error {
+	return r.db.WithContext(ctx).Create(view).Error
+}
@@ -6,11 +6,14 @@ import (
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProcessedEmailRepository interface {
-	Exists(ctx context.Context, messageID string, companyID string) (bool, error)
-	Save(ctx context.Context, processedEmail *entity.ProcessedEmail) error
+	Claim(ctx context.Context, processedEmail *entity.ProcessedEmail, lease time.Duration) (bool, error)
+	MarkProcessed(ctx context.Context, processedEmail *entity.ProcessedEmail) error
+	Release(ctx context.Context, processedEmail *entity.ProcessedEmail) error
+	IsProcessed(ctx context.Context, companyID, messageID string) (bool, error)
 	CleanupOldEmails(ctx context.Context) error
 }
 
@@ -22,20 +25,54 @@ func NewProcessedEmailRepository(db *gorm.DB) ProcessedEmailRepository {
 	return &processedEmailRepository{db: db}
 }
 
-func (r *processedEmailRepository) Exists(ctx context.Context, messageID string, companyID string) (bool, error) {
+// Claim reserves the email for processing and reports whether the caller got
+// it. It is a single INSERT ... ON CONFLICT, so of two workers racing on the
+// same message exactly one gets true. A claim that was neither marked processed
+// nor released within lease, because its worker died, can be taken over.
+func (r *processedEmailRepository) Claim(ctx context.Context, processedEmail *entity.ProcessedEmail, lease time.Duration) (bool, error) {
+	now := time.Now()
+	processedEmail.Status = entity.ProcessedEmailProcessing
+	processedEmail.ProcessedAt = now
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "company_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"processed_at": now}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "processed_emails.status = ? AND processed_emails.processed_at < ?", Vars: []interface{}{entity.ProcessedEmailProcessing, now.Add(-lease)}},
+			}},
+		}).
+		Create(processedEmail)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkProcessed completes a claim, so the email is never processed again
+func (r *processedEmailRepository) MarkProcessed(ctx context.Context, processedEmail *entity.ProcessedEmail) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.ProcessedEmail{}).
+		Where("company_id = ? AND message_id = ?", processedEmail.CompanyID, processedEmail.MessageID).
+		Updates(map[string]interface{}{"status": entity.ProcessedEmailProcessed, "processed_at": time.Now()}).Error
+}
+
+// Release drops a claim that could not be completed, so the email is processed
+// again the next time it is fetched
+func (r *processedEmailRepository) Release(ctx context.Context, processedEmail *entity.ProcessedEmail) error {
+	return r.db.WithContext(ctx).
+		Where("company_id = ? AND message_id = ? AND status = ?", processedEmail.CompanyID, processedEmail.MessageID, entity.ProcessedEmailProcessing).
+		Delete(&entity.ProcessedEmail{}).Error
+}
+
+// IsProcessed reports whether the email was processed, not merely claimed
+func (r *processedEmailRepository) IsProcessed(ctx context.Context, companyID, messageID string) (bool, error) {
 	var count int64
 	err := r.db.WithContext(ctx).
 		Model(&entity.ProcessedEmail{}).
-		Where("message_id = ? AND company_id = ?", messageID, companyID).
+		Where("company_id = ? AND message_id = ? AND status = ?", companyID, messageID, entity.ProcessedEmailProcessed).
 		Count(&count).Error
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
-
-func (r *processedEmailRepository) Save(ctx context.Context, processedEmail *entity.ProcessedEmail) error {
-	return r.db.WithContext(ctx).Create(processedEmail).Error
+	return count > 0, err
 }
 
 func (r *processedEmailRepository) CleanupOldEmails(ctx context.Context) error {
@@ -6,15 +6,16 @@ import (
 	"strings"
 
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"gorm.io/gorm"
 )
 
 type PropertyRepository interface {
-	Create(property *entity.Property) error
-	FindByID(id string) (*entity.Property, error)
-	FindAll() ([]entity.Property, error)
-	Update(property *entity.Property) error
-	Delete(id string) error
+	Create(ctx context.Context, property *entity.Property) error
+	FindByID(ctx context.Context, id string) (*entity.Property, error)
+	FindAll(ctx context.Context) ([]entity.Property, error)
+	Update(ctx context.Context, property *entity.Property) error
+	Delete(ctx context.Context, id string) error
 	FindByReference(ctx context.Context, ref string) (*entity.Property, error)
 	FindAllByCompanyID(ctx context.Context, companyID string) ([]entity.Property, error)
 	Search(ctx context.Context, filter entity.PropertyFilter) ([]entity.Property, error)
@@ -29,13 +30,18 @@ func NewPropertyRepository(db *gorm.DB) PropertyRepository {
 	return &propertyRepository{db: db}
 }
 
-func (r *propertyRepository) Create(property *entity.Property) error {
-	return r.db.Create(property).Error
+func (r *propertyRepository) Create(ctx context.Context, property *entity.Property) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		property.CompanyID = companyID
+	}
+	return r.db.WithContext(ctx).Create(property).Error
 }
 
-func (r *propertyRepository) FindByID(id string) (*entity.Property, error) {
+func (r *propertyRepository) FindByID(ctx context.Context, id string) (*entity.Property, error) {
 	var prop entity.Property
-	err := r.db.First(&prop, "id = ?", id).Error
+	err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		First(&prop, "id = ?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// Si no se encuentra la propiedad, devolvemos nil sin error
@@ -47,9 +53,11 @@ func (r *propertyRepository) FindByID(id string) (*entity.Property, error) {
 	return &prop, nil
 }
 
-func (r *propertyRepository) FindAll() ([]entity.Property, error) {
+func (r *propertyRepository) FindAll(ctx context.Context) ([]entity.Property, error) {
 	var props []entity.Property
-	if err := r.db.Find(&props).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Find(&props).Error; err != nil {
 		return nil, err
 	}
 	return props, nil
@@ -57,7 +65,10 @@ func (r *propertyRepository) FindAll() ([]entity.Property, error) {
 
 func (r *propertyRepository) FindByReference(ctx context.Context, ref string) (*entity.Property, error) {
 	var p entity.Property
-	query := r.db.WithContext(ctx).Where("reference = ?", ref).First(&p)
+	query := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Where("reference = ?", ref).
+		First(&p)
 	if query.Error != nil {
 		if query.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -67,17 +78,30 @@ func (r *propertyRepository) FindByReference(ctx context.Context, ref string) (*
 	return &p, nil
 }
 
-func (r *propertyRepository) Update(property *entity.Property) error {
-	return r.db.Save(property).Error
+func (r *propertyRepository) Update(ctx context.Context, property *entity.Property) error {
+	if companyID, ok := tenant.CompanyID(ctx); ok {
+		property.CompanyID = companyID
+	}
+	// See leadRepository.Update for why this is not a Save
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Model(property).
+		Select("*").
+		Omit("Company").
+		Updates(property))
 }
 
-func (r *propertyRepository) Delete(id string) error {
-	return r.db.Unscoped().Delete(&entity.Property{}, "id = ?", id).Error
+func (r *propertyRepository) Delete(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
+		Unscoped().
+		Delete(&entity.Property{}, "id = ?", id))
 }
 
 func (r *propertyRepository) FindAllByCompanyID(ctx context.Context, companyID string) ([]entity.Property, error) {
 	var properties []entity.Property
 	if err := r.db.WithContext(ctx).
+		Scopes(scopeByCompany(ctx, "company_id")).
 		Where("company_id = ?", companyID).
 		Preload("Company"). //Carga company por detrás
 		Find(&properties).Error; err != nil {
@@ -89,7 +113,7 @@ func (r *propertyRepository) FindAllByCompanyID(ctx context.Context, companyID s
 func (r *propertyRepository) Search(ctx context.Context, filter entity.PropertyFilter) ([]entity.Property, error) {
 	var properties []entity.Property
 
-	query := r.db.WithContext(ctx)
+	query := r.db.WithContext(ctx).Scopes(scopeByCompany(ctx, "company_id"))
 
 	// Explicit Filters
 	if filter.Status != nil && *filter.Status != "" && *filter.Status != "all" && *filter.Status != "todos" {
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceForAgent(ctx context.Context, agentID string, codes []entity.RecoveryCode) error
+	FindUnusedByAgent(ctx context.Context, agentID string) ([]entity.RecoveryCode, error)
+	MarkAsUsed(ctx context.Context, id string) error
+	DeleteForAgent(ctx context.Context, agentID string) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceForAgent drops every previous code of the agent and stores the new ones
+func (r *recoveryCodeRepository) ReplaceForAgent(ctx context.Context, agentID string, codes []entity.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("agent_id = ?", agentID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepository) FindUnusedByAgent(ctx context.Context, agentID string) ([]entity.RecoveryCode, error) {
+	var codes []entity.RecoveryCode
+	err := r.db.WithContext(ctx).
+		Where("agent_id = ? AND used_at IS NULL", agentID).
+		Find(&codes).Error
+	return codes, err
+}
+
+// MarkAsUsed only succeeds once per code
+func (r *recoveryCodeRepository) MarkAsUsed(ctx context.Context, id string) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now()))
+}
+
+func (r *recoveryCodeRepository) DeleteForAgent(ctx context.Context, agentID string) error {
+	return r.db.WithContext(ctx).
+		Where("agent_id = ?", agentID).
+		Delete(&entity.RecoveryCode{}).Error
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+// SessionRepository is not scoped by company: sessions are looked up before
+// the caller's company is known, always by ID, token hash or agent.
+type SessionRepository interface {
+	Create(ctx context.Context, session *entity.Session) error
+	FindByID(ctx context.Context, id string) (*entity.Session, error)
+	FindByTokenHash(ctx context.Context, hash string) (*entity.Session, error)
+	Rotate(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForAgent(ctx context.Context, agentID string) error
+	DeleteExpired(ctx context.Context) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *entity.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(ctx context.Context, id string) (*entity.Session, error) {
+	var session entity.Session
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByTokenHash matches both the current and the previous refresh token of a session
+func (r *sessionRepository) FindByTokenHash(ctx context.Context, hash string) (*entity.Session, error) {
+	var session entity.Session
+	err := r.db.WithContext(ctx).
+		Where("refresh_token_hash = ? OR previous_token_hash = ?", hash, hash).
+		First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate replaces the refresh token of an active session only if it is still
+// oldHash, so two concurrent refreshes with the same token cannot both succeed.
+func (r *sessionRepository) Rotate(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	return checkAffected(r.db.WithContext(ctx).
+		Model(&entity.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"previous_token_hash": oldHash,
+			"refresh_token_hash":  newHash,
+			"expires_at":          expiresAt,
+			"last_used_at":        time.Now(),
+		}))
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllForAgent(ctx context.Context, agentID string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.Session{}).
+		Where("agent_id = ? AND revoked_at IS NULL", agentID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) DeleteExpired(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Delete(&entity.Session{}).Error
+}
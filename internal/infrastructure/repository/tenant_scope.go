@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"gorm.io/gorm"
+)
+
+// scopeByCompany restricts a query to the company carried in ctx.
+// column is the column holding the company ID ("company_id" for most tables,
+// "id" for the companies table itself).
+func scopeByCompany(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		companyID, ok := tenant.CompanyID(ctx)
+		if !ok {
+			return db
+		}
+		return db.Where(column+" = ?", companyID)
+	}
+}
+
+// checkAffected turns an update/delete that matched no rows into
+// gorm.ErrRecordNotFound, so that writes on another company's rows look
+// exactly like writes on rows that do not exist.
+func checkAffected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
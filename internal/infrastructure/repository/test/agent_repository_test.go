@@ -7,6 +7,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
@@ -36,7 +37,7 @@ func TestAgentRepository_Create_SQLMock(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	// GORM order for Agent: Name, Email, Phone, Password, Role, CompanyID, CreatedAt, UpdatedAt, DeletedAt, ID
+	// GORM order for Agent: Name, Email, Phone, Password, Role, TOTPSecret, TwoFactorEnabled, TOTPLastStep, CompanyID, CreatedAt, UpdatedAt, DeletedAt, ID
 	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"agents\"")).
 		WithArgs(
 			agent.Name,       // $1
@@ -44,17 +45,20 @@ func TestAgentRepository_Create_SQLMock(t *testing.T) {
 			sqlmock.AnyArg(), // $3 Phone
 			sqlmock.AnyArg(), // $4 Password
 			sqlmock.AnyArg(), // $5 Role (GORM default tag might use 'agent')
-			sqlmock.AnyArg(), // $6 CompanyID
-			sqlmock.AnyArg(), // $7 CreatedAt
-			sqlmock.AnyArg(), // $8 UpdatedAt
-			sqlmock.AnyArg(), // $9 DeletedAt
-			agent.ID,         // $10
+			sqlmock.AnyArg(), // $6 TOTPSecret
+			sqlmock.AnyArg(), // $7 TwoFactorEnabled
+			sqlmock.AnyArg(), // $8 TOTPLastStep
+			sqlmock.AnyArg(), // $9 CompanyID
+			sqlmock.AnyArg(), // $10 CreatedAt
+			sqlmock.AnyArg(), // $11 UpdatedAt
+			sqlmock.AnyArg(), // $12 DeletedAt
+			agent.ID,         // $13
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(agent.ID))
 	mock.ExpectCommit()
 
 	// WHEN
-	err := repo.Create(agent)
+	err := repo.Create(context.Background(), agent)
 
 	// THEN
 	assert.NoError(t, err)
@@ -84,3 +88,22 @@ func TestAgentRepository_FindByEmail_SQLMock(t *testing.T) {
 	assert.Equal(t, email, result.Email)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestAgentRepository_EmailInUse_IgnoresTenant_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupAgentSQLMock(t)
+	repo := repository.NewAgentRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), "C1")
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "agents" WHERE LOWER(email) = LOWER($1)`)).
+		WithArgs("taken@other.com").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	// WHEN
+	inUse, err := repo.EmailInUse(ctx, "taken@other.com")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, inUse)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompanyEmailConfigRepository_UpdateLastSync_KeepsUpdatedAt(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewCompanyEmailConfigRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	syncTime := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"company_email_configs\" SET \"last_sync_at\"=$1 WHERE id = $2 AND company_id = $3")).
+		WithArgs(syncTime, "CFG1", companyA).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.UpdateLastSync(ctx, "CFG1", syncTime)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompanyEmailConfigRepository_UpdateTokens_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewCompanyEmailConfigRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	expiry := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"company_email_configs\" SET \"access_token\"=$1,\"refresh_token\"=$2,\"token_expiry\"=$3 WHERE id = $4 AND company_id = $5")).
+		WithArgs("enc-access", "enc-refresh", expiry, "config-of-a", companyB).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.UpdateTokens(ctx, "config-of-a", "enc-access", "enc-refresh", expiry)
+
+	// THEN
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -37,7 +37,7 @@ func TestCompanyRepository_Create_SQLMock(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	// GORM with Postgres: Name, Address, PostalCode, City, Province, Country, OfficeLocation, ContactPerson, Email1, Email2, Phone1, Phone2, Website, PageLink, WebDeveloper, CreatedAt, UpdatedAt, DeletedAt, ID
+	// GORM with Postgres: Name, Address, PostalCode, City, Province, Country, OfficeLocation, ContactPerson, Email1, Email2, Phone1, Phone2, Website, PageLink, WebDeveloper, RequireTwoFactor, CreatedAt, UpdatedAt, DeletedAt, ID
 	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"companies\"")).
 		WithArgs(
 			company.Name,     // $1
@@ -55,16 +55,17 @@ func TestCompanyRepository_Create_SQLMock(t *testing.T) {
 			sqlmock.AnyArg(), // $13 Website
 			sqlmock.AnyArg(), // $14 PageLink
 			sqlmock.AnyArg(), // $15 WebDeveloper
-			sqlmock.AnyArg(), // $16 CreatedAt
-			sqlmock.AnyArg(), // $17 UpdatedAt
-			sqlmock.AnyArg(), // $18 DeletedAt
-			company.ID,       // $19 (ID at the end)
+			sqlmock.AnyArg(), // $16 RequireTwoFactor
+			sqlmock.AnyArg(), // $17 CreatedAt
+			sqlmock.AnyArg(), // $18 UpdatedAt
+			sqlmock.AnyArg(), // $19 DeletedAt
+			company.ID,       // $20 (ID at the end)
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(company.ID))
 	mock.ExpectCommit()
 
 	// WHEN
-	err := repo.Create(company)
+	err := repo.Create(context.Background(), company)
 
 	// THEN
 	assert.NoError(t, err)
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailSyncCursorRepository_FindByConfigID_NotFoundIsNil(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewEmailSyncCursorRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"email_sync_cursors\" WHERE config_id = $1 AND company_id = $2")).
+		WithArgs("CFG1", companyA, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"config_id"}))
+
+	// WHEN
+	cursor, err := repo.FindByConfigID(ctx, "CFG1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Nil(t, cursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailSyncCursorRepository_Save_Upserts(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewEmailSyncCursorRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	cursor := &entity.EmailSyncCursor{ConfigID: "CFG1", UIDValidity: 7, LastUID: 42}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO \"email_sync_cursors\"")+".*"+
+		regexp.QuoteMeta("ON CONFLICT (\"config_id\") DO UPDATE SET \"uid_validity\"=\"excluded\".\"uid_validity\",\"last_uid\"=\"excluded\".\"last_uid\",\"history_id\"=\"excluded\".\"history_id\",\"delta_link\"=\"excluded\".\"delta_link\"")).
+		WithArgs("CFG1", companyA, 7, 42, 0, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Save(ctx, cursor)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, companyA, cursor.CompanyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
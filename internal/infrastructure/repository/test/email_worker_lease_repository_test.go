@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailWorkerLeaseRepository_Acquire_HeldByOtherReplica(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewEmailWorkerLeaseRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO email_worker_leases")+".*"+
+		regexp.QuoteMeta("WHERE email_worker_leases.expires_at < now() OR email_worker_leases.holder_id = excluded.holder_id")).
+		WithArgs("CFG1", "replica-a", float64(60)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// WHEN
+	acquired, err := repo.Acquire(context.Background(), "CFG1", "replica-a", time.Minute)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailWorkerLeaseRepository_Renew_OnlyOwnLease(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewEmailWorkerLeaseRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE email_worker_leases SET expires_at = now() + make_interval(secs => $1)")+".*"+
+		regexp.QuoteMeta("WHERE config_id = $2 AND holder_id = $3")).
+		WithArgs(float64(60), "CFG1", "replica-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// WHEN
+	held, err := repo.Renew(context.Background(), "CFG1", "replica-a", time.Minute)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestInboundEmailRepository_FindByID_OtherCompanyIsNil(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewInboundEmailRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "inbound_emails" WHERE id = $1 AND company_id = $2`)).
+		WithArgs("IE1", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	inbound, err := repo.FindByID(ctx, "IE1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Nil(t, inbound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInboundEmailRepository_UpdateResult_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewInboundEmailRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	leadID := "L1"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "inbound_emails" SET`) + ".*" +
+		regexp.QuoteMeta(`"lead_id"=$5`) + ".*" +
+		regexp.QuoteMeta(`WHERE id = $8 AND company_id = $9`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.UpdateResult(ctx, &entity.InboundEmail{ID: "IE1", ParseStatus: entity.InboundEmailParsed, LeadID: &leadID})
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeadAssignmentRepository_Assign_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadAssignmentRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	agentID := "A2"
+	assignment := &entity.LeadAssignment{LeadID: "L1", ToAgentID: &agentID}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "assigned_agent_id"=$1,"updated_at"=$2 WHERE id = $3 AND company_id = $4`)).
+		WithArgs(&agentID, sqlmock.AnyArg(), "L1", companyA).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "lead_assignments"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("LA1"))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Assign(ctx, assignment)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, companyA, assignment.CompanyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadAssignmentRepository_Assign_OtherCompanyLead_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadAssignmentRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	agentID := "A2"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "assigned_agent_id"=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// WHEN
+	err := repo.Assign(ctx, &entity.LeadAssignment{LeadID: "L1", ToAgentID: &agentID})
+
+	// THEN
+	assert.ErrorContains(t, err, "not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadAssignmentRepository_Workloads_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadAssignmentRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+
+	mock.ExpectQuery(`SELECT agents.id AS agent_id, .* FROM "agents" WHERE agents.role IN \(.*\) AND agents.company_id = \$\d+ AND "agents"."deleted_at" IS NULL ORDER BY agents.created_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"agent_id", "open_leads", "last_assigned_at"}).
+			AddRow("A1", 3, nil).
+			AddRow("A2", 0, nil))
+
+	// WHEN
+	workloads, err := repo.Workloads(ctx)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, []entity.AgentWorkload{{AgentID: "A1", OpenLeads: 3}, {AgentID: "A2"}}, workloads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
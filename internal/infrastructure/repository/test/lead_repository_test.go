@@ -4,6 +4,7 @@ import (
 	"context"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
@@ -37,34 +38,38 @@ func TestLeadRepository_Create_SQLMock(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	// GORM order for Lead (approximate based on struct): Name, Email, Phone, Status, PropertyID, CompanyID, AssignedAgentID, LastInteraction, Language, Source, Budget, Zone, PropertyType, Channel, SuggestedPropertiesCount, CreatedAt, UpdatedAt, DeletedAt, ID
+	// GORM order for Lead (approximate based on struct): Name, Email, Phone, NormalizedPhone, Status, PropertyID, CompanyID, AssignedAgentID, LastInteraction, Language, Source, Budget, Zone, PropertyType, Channel, SuggestedPropertiesCount, Notes, Score, ScoredAt, CreatedAt, UpdatedAt, DeletedAt, ID
 	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"leads\"")).
 		WithArgs(
 			lead.Name,        // $1
 			lead.Email,       // $2
 			lead.Phone,       // $3
-			sqlmock.AnyArg(), // $4 Status
-			sqlmock.AnyArg(), // $5 PropertyID
-			sqlmock.AnyArg(), // $6 CompanyID
-			sqlmock.AnyArg(), // $7 AssignedAgentID
-			sqlmock.AnyArg(), // $8 LastInteraction
-			sqlmock.AnyArg(), // $9 Language
-			sqlmock.AnyArg(), // $10 Source
-			sqlmock.AnyArg(), // $11 Budget
-			sqlmock.AnyArg(), // $12 Zone
-			sqlmock.AnyArg(), // $13 PropertyType
-			sqlmock.AnyArg(), // $14 Channel
-			sqlmock.AnyArg(), // $15 SuggestedPropertiesCount
-			sqlmock.AnyArg(), // $16 CreatedAt
-			sqlmock.AnyArg(), // $17 UpdatedAt
-			sqlmock.AnyArg(), // $18 DeletedAt
-			lead.ID,          // $19
+			"",               // $4 NormalizedPhone, too short to match on
+			sqlmock.AnyArg(), // $5 Status
+			sqlmock.AnyArg(), // $6 PropertyID
+			sqlmock.AnyArg(), // $7 CompanyID
+			sqlmock.AnyArg(), // $8 AssignedAgentID
+			sqlmock.AnyArg(), // $9 LastInteraction
+			sqlmock.AnyArg(), // $10 Language
+			sqlmock.AnyArg(), // $11 Source
+			sqlmock.AnyArg(), // $12 Budget
+			sqlmock.AnyArg(), // $13 Zone
+			sqlmock.AnyArg(), // $14 PropertyType
+			sqlmock.AnyArg(), // $15 Channel
+			sqlmock.AnyArg(), // $16 SuggestedPropertiesCount
+			sqlmock.AnyArg(), // $17 Notes
+			sqlmock.AnyArg(), // $18 Score
+			sqlmock.AnyArg(), // $19 ScoredAt
+			sqlmock.AnyArg(), // $20 CreatedAt
+			sqlmock.AnyArg(), // $21 UpdatedAt
+			sqlmock.AnyArg(), // $22 DeletedAt
+			lead.ID,          // $23
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(lead.ID))
 	mock.ExpectCommit()
 
 	// WHEN
-	err := repo.Create(lead)
+	err := repo.Create(context.Background(), lead)
 
 	// THEN
 	assert.NoError(t, err)
@@ -80,12 +85,12 @@ func TestLeadRepository_FindByEmail_SQLMock(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "email", "deleted_at"}).
 		AddRow("L2", email, nil)
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE email = $1 AND \"leads\".\"deleted_at\" IS NULL ORDER BY \"leads\".\"id\" LIMIT $2")).
-		WithArgs(email, 1).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE (company_id = $1 AND LOWER(email) = $2) AND \"leads\".\"deleted_at\" IS NULL ORDER BY created_at,\"leads\".\"id\" LIMIT $3")).
+		WithArgs("C1", email, 1).
 		WillReturnRows(rows)
 
 	// WHEN
-	result, err := repo.FindByEmail(context.Background(), email)
+	result, err := repo.FindByEmail(context.Background(), "C1", "Search@Lead.com ")
 
 	// THEN
 	assert.NoError(t, err)
@@ -94,3 +99,199 @@ func TestLeadRepository_FindByEmail_SQLMock(t *testing.T) {
 	assert.Equal(t, email, result.Email)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestLeadRepository_Merge_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	survivor := &entity.Lead{ID: "L1", CompanyID: "C1", Email: "ana@example.com", Name: "Ana", Phone: "600123456"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "messages" SET "lead_id"=$1,"updated_at"=$2 WHERE lead_id = $3`)).
+		WithArgs("L1", sqlmock.AnyArg(), "L2").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "inbound_emails" SET "lead_id"=$1,"updated_at"=$2 WHERE lead_id = $3`)).
+		WithArgs("L1", sqlmock.AnyArg(), "L2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "lead_status_changes" SET "lead_id"=$1 WHERE lead_id = $2`)).
+		WithArgs("L1", "L2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "lead_assignments" SET "lead_id"=$1 WHERE lead_id = $2`)).
+		WithArgs("L1", "L2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "presentation_views" SET "lead_id"=$1 WHERE lead_id = $2`)).
+		WithArgs("L1", "L2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE summaries s SET summary_text`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM summaries WHERE lead_id = $1 AND EXISTS`)).
+		WithArgs("L2", "L1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "summaries" SET "lead_id"=$1,"updated_at"=$2 WHERE lead_id = $3`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE leads s SET last_interaction = GREATEST(s.last_interaction, d.last_interaction)`)).
+		WithArgs("L1", "L2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "leads" WHERE id = $1`)).
+		WithArgs("L2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Merge(context.Background(), survivor, "L2")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "+34600123456", survivor.NormalizedPhone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_Merge_RollsBackWhenDuplicateIsGone_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	survivor := &entity.Lead{ID: "L1", CompanyID: "C1", Email: "ana@example.com"}
+
+	mock.ExpectBegin()
+	for i := 0; i < 10; i++ {
+		mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "leads" WHERE id = $1`)).
+		WithArgs("L2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// WHEN
+	err := repo.Merge(context.Background(), survivor, "L2")
+
+	// THEN
+	assert.ErrorContains(t, err, "not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_Update_LeavesLastInteraction_SQLMock(t *testing.T) {
+	// GIVEN a lead loaded before a message moved its last interaction
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	loaded := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Name: "Ana", LastInteraction: &loaded}
+
+	var query string
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	db.Callback().Update().After("gorm:update").Register("capture", func(tx *gorm.DB) { query = tx.Statement.SQL.String() })
+
+	// WHEN
+	err := repo.Update(context.Background(), lead)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Contains(t, query, `"name"=`)
+	assert.NotContains(t, query, "last_interaction")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_UpdateWithHistory_RollsBackWhenLeadMovedMeanwhile_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	agentID := "A2"
+	lead := &entity.Lead{ID: "L1", CompanyID: "C1", Name: "Ana", Status: entity.LeadStatusNew}
+	change := &entity.LeadStatusChange{LeadID: "L1", FromStatus: entity.LeadStatusNew, ToStatus: entity.LeadStatusContacted}
+	assignment := &entity.LeadAssignment{LeadID: "L1", ToAgentID: &agentID}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "name"=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "status"=$1,"updated_at"=$2 WHERE (id = $3 AND status = $4)`)).
+		WithArgs(entity.LeadStatusContacted, sqlmock.AnyArg(), "L1", entity.LeadStatusNew).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// WHEN
+	err := repo.UpdateWithHistory(context.Background(), lead, change, assignment)
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid transition")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_Search_PagesWithCursor_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	agent := entity.LeadFilterUnassigned
+	minBudget := 200000.0
+	filter := entity.LeadFilter{
+		Statuses:        []entity.LeadStatus{entity.LeadStatusNew, entity.LeadStatusContacted},
+		Sources:         []string{"Idealista"},
+		AssignedAgentID: &agent,
+		MinBudget:       &minBudget,
+		Sort:            []entity.LeadSort{{Field: entity.LeadSortScore, Desc: true}},
+		Limit:           1,
+	}
+	inner := `SELECT * FROM (SELECT leads.*, COUNT(*) OVER () AS total_count FROM "leads" ` +
+		`WHERE status IN ($1,$2) AND LOWER(source) IN ($3) AND assigned_agent_id IS NULL AND budget >= $4 AND "leads"."deleted_at" IS NULL) AS leads `
+
+	mock.ExpectQuery(regexp.QuoteMeta(inner+`WHERE "leads"."deleted_at" IS NULL ORDER BY score DESC, id LIMIT $5`)).
+		WithArgs("new", "contacted", "idealista", minBudget, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "score", "total_count"}).
+			AddRow("L1", 80, 3).
+			AddRow("L2", 70, 3))
+	mock.ExpectQuery(regexp.QuoteMeta(inner+`WHERE ((score < $5) OR (score = $6 AND id > $7)) AND "leads"."deleted_at" IS NULL ORDER BY score DESC, id LIMIT $8`)).
+		WithArgs("new", "contacted", "idealista", minBudget, 80, 80, "L1", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "score", "total_count"}).
+			AddRow("L2", 70, 3))
+
+	// WHEN
+	first, err := repo.Search(context.Background(), filter)
+	assert.NoError(t, err)
+	filter.Cursor = first.NextCursor
+	second, err := repo.Search(context.Background(), filter)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), first.Total)
+	assert.Len(t, first.Leads, 1)
+	assert.Equal(t, "L1", first.Leads[0].ID)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, "L2", second.Leads[0].ID)
+	assert.Empty(t, second.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_Search_TermIsMatchedLiterally_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	term := `50%_off\`
+	pattern := `%50\%\_off\\%`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE (name ILIKE $1 OR email ILIKE $2 OR phone ILIKE $3 OR zone ILIKE $4 OR notes ILIKE $5)`)).
+		WithArgs(pattern, pattern, pattern, pattern, pattern, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "total_count"}))
+
+	// WHEN
+	_, err := repo.Search(context.Background(), entity.LeadFilter{SearchTerm: &term, Limit: 10})
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadRepository_Search_InvalidCursor_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupLeadSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+
+	// WHEN
+	_, err := repo.Search(context.Background(), entity.LeadFilter{Cursor: "not-a-cursor", Limit: 10})
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid cursor")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
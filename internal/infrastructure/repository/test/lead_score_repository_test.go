@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeadScoreRepository_SaveScore_OtherCompanyLead_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadScoreRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	score := &entity.LeadScore{LeadID: "L1", Score: 80, ScoredAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "score"=$1,"scored_at"=$2 WHERE id = $3 AND company_id = $4`)).
+		WithArgs(80, sqlmock.AnyArg(), "L1", companyB).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.SaveScore(ctx, score)
+
+	// THEN
+	assert.ErrorContains(t, err, "not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadScoreRepository_Signals_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadScoreRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	lead := &entity.Lead{ID: "L1", CompanyID: companyA, Budget: 100000}
+	since := time.Now().Add(-entity.LeadScoreWindow)
+	last := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
+		WithArgs("L1", entity.SenderLead, since, "L1", entity.SenderLead, "L1", companyA, 80000.0, 120000.0).
+		WillReturnRows(sqlmock.NewRows([]string{"lead_messages", "last_lead_message_at", "presentation_views", "properties_in_budget"}).
+			AddRow(2, last, 1, 7))
+
+	// WHEN
+	signals, err := repo.Signals(ctx, lead, since)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), signals.LeadMessages)
+	assert.Equal(t, int64(7), signals.PropertiesInBudget)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadScoreRepository_SaveScores_SingleUpdate_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadScoreRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	now := time.Now()
+	scores := []entity.LeadScore{{LeadID: "L1", Score: 80, ScoredAt: now}, {LeadID: "L2", Score: 40, ScoredAt: now}}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE leads SET score = v.score, scored_at = v.scored_at`)+`.*`+
+		regexp.QuoteMeta(`(VALUES ($1::uuid, $2::integer, $3::timestamptz), ($4::uuid, $5::integer, $6::timestamptz)) AS v(id, score, scored_at)`)+`.*`+
+		regexp.QuoteMeta(`WHERE leads.id = v.id AND leads.company_id = $7`)).
+		WithArgs("L1", 80, now, "L2", 40, now, companyA).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// WHEN
+	err := repo.SaveScores(ctx, scores)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
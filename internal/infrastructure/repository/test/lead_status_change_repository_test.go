@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeadStatusChangeRepository_Record_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadStatusChangeRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	change := &entity.LeadStatusChange{LeadID: "L1", FromStatus: entity.LeadStatusNew, ToStatus: entity.LeadStatusContacted}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "status"=$1,"updated_at"=$2 WHERE (id = $3 AND status = $4) AND company_id = $5`)).
+		WithArgs(entity.LeadStatusContacted, sqlmock.AnyArg(), "L1", entity.LeadStatusNew, companyA).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "lead_status_changes"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("SC1"))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Record(ctx, change)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, companyA, change.CompanyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadStatusChangeRepository_Record_LeadMovedMeanwhile_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadStatusChangeRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	change := &entity.LeadStatusChange{LeadID: "L1", FromStatus: entity.LeadStatusNew, ToStatus: entity.LeadStatusContacted}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "leads" SET "status"=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// WHEN
+	err := repo.Record(ctx, change)
+
+	// THEN
+	assert.ErrorContains(t, err, "invalid transition")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeadStatusChangeRepository_Funnel_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadStatusChangeRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "leads" WHERE (created_at >= $1 AND created_at < $2) AND company_id = $3`)).
+		WithArgs(since, until, companyA).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.to_status AS status, c.reason_code, COUNT(DISTINCT c.lead_id) AS leads FROM lead_status_changes AS c JOIN leads l ON l.id = c.lead_id WHERE (l.created_at >= $1 AND l.created_at < $2 AND l.deleted_at IS NULL) AND l.company_id = $3 GROUP BY GROUPING SETS ((c.to_status), (c.to_status, c.reason_code))`)).
+		WithArgs(since, until, companyA).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reason_code", "leads"}).
+			AddRow("dismissed", nil, 3).
+			AddRow("dismissed", "spam", 2))
+
+	// WHEN
+	created, counts, err := repo.Funnel(ctx, since, until)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), created)
+	assert.Len(t, counts, 2)
+	assert.Nil(t, counts[0].ReasonCode)
+	assert.Equal(t, entity.LeadReasonSpam, *counts[1].ReasonCode)
+	assert.Equal(t, int64(2), counts[1].Leads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottleRepository_Hit_CountsInOneStatement_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLoginThrottleRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO login_throttles (kind, key, attempts, window_start, updated_at)")+".*"+
+		regexp.QuoteMeta("ON CONFLICT (kind, key) DO UPDATE SET")+".*"+
+		regexp.QuoteMeta("ELSE login_throttles.attempts + 1 END")+".*"+
+		regexp.QuoteMeta("RETURNING *")).
+		WithArgs(entity.ThrottleAccount, "a@acme.com", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "key", "attempts"}).
+			AddRow("T1", entity.ThrottleAccount, "a@acme.com", 6))
+
+	// WHEN
+	throttle, err := repo.Hit(context.Background(), entity.ThrottleAccount, "a@acme.com", 24*time.Hour)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "T1", throttle.ID)
+	assert.Equal(t, 6, throttle.Attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginThrottleRepository_Lock_NeverShortensALock_SQLMock(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLoginThrottleRepository(db)
+	until := time.Now().Add(time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "login_throttles" SET "locked_until"=GREATEST(locked_until, $1),"updated_at"=$2 WHERE id = $3`)).
+		WithArgs(until, sqlmock.AnyArg(), "T1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Lock(context.Background(), "T1", until)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,8 +1,10 @@
 package test
 
 import (
+	"context"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
@@ -33,10 +35,12 @@ func TestMessageRepository_Create_Simplified(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"messages\"")).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(msg.ID))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"leads\" SET \"last_interaction\"=GREATEST(last_interaction, $1) WHERE id = $2")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	// WHEN
-	err := repo.Create(msg)
+	err := repo.Create(context.Background(), msg)
 
 	// THEN
 	assert.NoError(t, err)
@@ -58,7 +62,7 @@ func TestMessageRepository_FindByLeadID_Simplified(t *testing.T) {
 		WillReturnRows(rows)
 
 	// WHEN
-	result, err := repo.FindByLeadID(leadID)
+	result, err := repo.FindByLeadID(context.Background(), leadID)
 
 	// THEN
 	assert.NoError(t, err)
@@ -66,3 +70,26 @@ func TestMessageRepository_FindByLeadID_Simplified(t *testing.T) {
 	assert.Equal(t, "Hello", result[0].Content)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestMessageRepository_Create_MovesLastInteractionUp(t *testing.T) {
+	// GIVEN
+	db, mock := setupMessageSQLMock(t)
+	repo := repository.NewMessageRepository(db)
+	sent := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	msg := &entity.Message{ID: "M1", LeadID: "L1", Content: "Hola", Timestamp: sent}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"messages\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(msg.ID))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"leads\" SET \"last_interaction\"=GREATEST(last_interaction, $1) WHERE id = $2")).
+		WithArgs(sent, "L1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Create(context.Background(), msg)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
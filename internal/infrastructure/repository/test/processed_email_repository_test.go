@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedEmailRepository_Claim_HeldByAnotherWorker(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewProcessedEmailRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO \"processed_emails\"") + ".*" +
+		regexp.QuoteMeta("ON CONFLICT (\"message_id\",\"company_id\") DO UPDATE SET \"processed_at\"=") + ".*" +
+		regexp.QuoteMeta("WHERE processed_emails.status = ") + ".*" + regexp.QuoteMeta("AND processed_emails.processed_at < ")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	claimed, err := repo.Claim(context.Background(), &entity.ProcessedEmail{MessageID: "m1@example.com", CompanyID: companyA}, 10*time.Minute)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessedEmailRepository_Claim_FirstWorker(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewProcessedEmailRepository(db)
+	processedEmail := &entity.ProcessedEmail{MessageID: "m1@example.com", CompanyID: companyA}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO \"processed_emails\"")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	claimed, err := repo.Claim(context.Background(), processedEmail, 10*time.Minute)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	// Nothing is processed until the worker says so
+	assert.Equal(t, entity.ProcessedEmailProcessing, processedEmail.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessedEmailRepository_MarkProcessed(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewProcessedEmailRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"processed_emails\" SET \"processed_at\"=$1,\"status\"=$2 WHERE company_id = $3 AND message_id = $4")).
+		WithArgs(sqlmock.AnyArg(), entity.ProcessedEmailProcessed, companyA, "m1@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.MarkProcessed(context.Background(), &entity.ProcessedEmail{MessageID: "m1@example.com", CompanyID: companyA})
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -42,7 +42,7 @@ func TestPropertyRepository_Create_Simplified(t *testing.T) {
 	mock.ExpectCommit()
 
 	// WHEN
-	err := repo.Create(prop)
+	err := repo.Create(context.Background(), prop)
 
 	// THEN
 	assert.NoError(t, err)
@@ -0,0 +1,269 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
+	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	companyA = "company-a"
+	companyB = "company-b"
+)
+
+func setupTenantSQLMock(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	return gormDB, mock
+}
+
+func TestTenant_LeadFindByID_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	// Lead L1 belongs to company A, so the company B filter matches nothing
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("L1", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	result, err := repo.FindByID(ctx, "L1")
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_LeadFindAll_OnlyOwnCompany(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+
+	rows := sqlmock.NewRows([]string{"id", "company_id"}).AddRow("L1", companyA)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE company_id = $1")).
+		WithArgs(companyA).
+		WillReturnRows(rows)
+
+	// WHEN
+	leads, err := repo.FindAll(ctx)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Len(t, leads, 1)
+	assert.Equal(t, companyA, leads[0].CompanyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_LeadFindByCompanyId_OtherCompanyReturnsNothing(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"leads\" WHERE company_id = $1 AND company_id = $2")).
+		WithArgs(companyA, companyB).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	leads, err := repo.FindByCompanyId(ctx, companyA)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Empty(t, leads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_LeadCreate_ForcesCallerCompany(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	lead := &entity.Lead{ID: "L2", Name: "Lead", Email: "lead@b.com", CompanyID: companyA}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO \"leads\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(lead.ID))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Create(ctx, lead)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, companyB, lead.CompanyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_LeadUpdate_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	lead := &entity.Lead{ID: "L1", Name: "Hijacked", Email: "lead@a.com", CompanyID: companyA}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"leads\" SET .* WHERE company_id = \\$\\d+ AND .*\"id\" = \\$\\d+").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Update(ctx, lead)
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_LeadDelete_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewLeadRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM \"leads\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("L1", companyB).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.Delete(ctx, "L1")
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_PropertyFindByID_OtherCompanyIsNil(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewPropertyRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"properties\" WHERE id = $1 AND company_id = $2")).
+		WithArgs("P1", companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	result, err := repo.FindByID(ctx, "P1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_AgentUpdatePartial_CannotChangeCompany(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewAgentRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyA)
+	fields := map[string]interface{}{"name": "Renamed", "company_id": companyB}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE \"agents\" SET \"name\"=$1,\"updated_at\"=$2 WHERE id = $3 AND company_id = $4")).
+		WithArgs("Renamed", sqlmock.AnyArg(), "A1", companyA).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	err := repo.UpdatePartial(ctx, "A1", fields)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_CompanyFindByID_OtherCompanyIsNotFound(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewCompanyRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"companies\" WHERE id = $1 AND id = $2")).
+		WithArgs(companyA, companyB, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	result, err := repo.FindByID(ctx, companyA)
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_MessageCreate_OnOtherCompanyLeadIsRejected(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewMessageRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+	msg := &entity.Message{ID: "M1", LeadID: "L1", Content: "Hello"}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM \"leads\" WHERE (id = $1 AND company_id = $2)")).
+		WithArgs("L1", companyB).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	// WHEN
+	err := repo.Create(ctx, msg)
+
+	// THEN
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_MessageFindByLeadID_ScopedThroughLead(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewMessageRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"messages\" WHERE lead_id = $1 AND lead_id IN (SELECT \"id\" FROM \"leads\" WHERE company_id = $2")).
+		WithArgs("L1", companyB).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	messages, err := repo.FindByLeadID(ctx, "L1")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_EmailConfigFindByCompanyID_OtherCompanyIsEmpty(t *testing.T) {
+	// GIVEN
+	db, mock := setupTenantSQLMock(t)
+	repo := repository.NewCompanyEmailConfigRepository(db)
+	ctx := tenant.WithCompanyID(context.Background(), companyB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"company_email_configs\" WHERE company_id = $1 AND company_id = $2")).
+		WithArgs(companyA, companyB).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	result, err := repo.FindByCompanyID(ctx, companyA)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -16,24 +16,30 @@ func getJwtKey() []byte {
 	return []byte(key)
 }
 
+// AccessTokenTTL is how long an access token is valid. Clients get a new one
+// with their refresh token.
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
 	AgentID   string `json:"agent_id"`
 	CompanyID string `json:"company_id"`
 	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(agentID, companyID, role string) (string, error) {
+func GenerateToken(agentID, companyID, role, sessionID string) (string, error) {
 	key := getJwtKey()
 	if len(key) == 0 {
 		return "", errors.New("JWT_SECRET_KEY is not set")
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	claims := &Claims{
 		AgentID:   agentID,
 		CompanyID: companyID,
 		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -65,3 +71,57 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ChallengeTokenTTL is how long the second login step can wait after the password step
+const ChallengeTokenTTL = 5 * time.Minute
+
+// Audiences of the challenge tokens issued between the password and the second factor
+const (
+	ChallengeTwoFactor      = "2fa-challenge"
+	ChallengeTwoFactorSetup = "2fa-setup"
+)
+
+// GenerateChallengeToken issues a short-lived token proving that the agent
+// passed the password step. It carries no session, so it is never accepted
+// as an access token.
+func GenerateChallengeToken(agentID, audience string) (string, error) {
+	key := getJwtKey()
+	if len(key) == 0 {
+		return "", errors.New("JWT_SECRET_KEY is not set")
+	}
+
+	claims := &jwt.RegisteredClaims{
+		Subject:   agentID,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ChallengeTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(key)
+}
+
+// ValidateChallengeToken returns the agent ID and audience of a challenge token
+func ValidateChallengeToken(tokenString string) (string, string, error) {
+	key := getJwtKey()
+	if len(key) == 0 {
+		return "", "", errors.New("JWT_SECRET_KEY is not set")
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", "", err
+	}
+	if !token.Valid || claims.Subject == "" || len(claims.Audience) != 1 {
+		return "", "", errors.New("invalid challenge token")
+	}
+
+	audience := claims.Audience[0]
+	if audience != ChallengeTwoFactor && audience != ChallengeTwoFactorSetup {
+		return "", "", errors.New("invalid challenge token")
+	}
+	return claims.Subject, audience, nil
+}
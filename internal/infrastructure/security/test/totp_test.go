@@ -0,0 +1,86 @@
+package test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/infrastructure/security"
+	"github.com/stretchr/testify/assert"
+)
+
+// Secret of the RFC 6238 SHA-1 test vectors ("12345678901234567890")
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes; authenticator apps use the last 6
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := security.TOTPCode(rfcSecret, security.TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTP_AcceptsOneStepOfDrift(t *testing.T) {
+	// GIVEN
+	now := time.Unix(1111111111, 0)
+	previous, _ := security.TOTPCode(rfcSecret, security.TOTPStep(now)-1)
+	tooOld, _ := security.TOTPCode(rfcSecret, security.TOTPStep(now)-2)
+
+	// WHEN
+	step, ok := security.ValidateTOTP(rfcSecret, previous, now)
+	_, okOld := security.ValidateTOTP(rfcSecret, tooOld, now)
+
+	// THEN
+	assert.True(t, ok)
+	assert.Equal(t, security.TOTPStep(now)-1, step)
+	assert.False(t, okOld)
+}
+
+func TestTOTPURI(t *testing.T) {
+	// WHEN
+	uri := security.TOTPURI("MyEstatia", "agent@acme.com", "ABC")
+
+	// THEN
+	parsed, err := url.Parse(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.True(t, strings.HasPrefix(parsed.Path, "/MyEstatia:agent@acme.com"))
+	assert.Equal(t, "ABC", parsed.Query().Get("secret"))
+	assert.Equal(t, "MyEstatia", parsed.Query().Get("issuer"))
+}
+
+func TestChallengeToken_IsNotAnAccessToken(t *testing.T) {
+	// GIVEN
+	challenge, err := security.GenerateChallengeToken("A1", security.ChallengeTwoFactor)
+	assert.NoError(t, err)
+
+	// WHEN
+	agentID, audience, err := security.ValidateChallengeToken(challenge)
+	claims, accessErr := security.ValidateToken(challenge)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "A1", agentID)
+	assert.Equal(t, security.ChallengeTwoFactor, audience)
+	// It parses as a JWT, but without a session the auth middleware refuses it
+	if accessErr == nil {
+		assert.Empty(t, claims.SessionID)
+	}
+
+	access, _ := security.GenerateToken("A1", "C1", "admin", "S1")
+	_, _, err = security.ValidateChallengeToken(access)
+	assert.Error(t, err)
+}
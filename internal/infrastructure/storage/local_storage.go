@@ -30,8 +30,12 @@ func NewLocalStorageService(baseDir, baseURL string) service.StorageService {
 }
 
 func (s *LocalStorageService) UploadFile(ctx context.Context, file multipart.File, header *multipart.FileHeader) (string, error) {
+	return s.SaveFile(ctx, header.Filename, file)
+}
+
+func (s *LocalStorageService) SaveFile(ctx context.Context, filename string, content io.Reader) (string, error) {
 	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
+	ext := filepath.Ext(filename)
 	uniqueName := fmt.Sprintf("%s_%s%s",
 		time.Now().Format("20060102"),
 		uuid.New().String()[:8],
@@ -47,7 +51,7 @@ func (s *LocalStorageService) UploadFile(ctx context.Context, file multipart.Fil
 	defer dst.Close()
 
 	// Copy content
-	if _, err := io.Copy(dst, file); err != nil {
+	if _, err := io.Copy(dst, content); err != nil {
 		return "", fmt.Errorf("failed to save file: %w", err)
 	}
 
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/myestatia/myestatia-go/internal/application/service"
+)
+
+// LocalPrivateStorageService keeps files in a directory that is not served,
+// under random names without extension
+type LocalPrivateStorageService struct {
+	BaseDir string
+}
+
+func NewLocalPrivateStorageService(baseDir string) service.PrivateStorageService {
+	_ = os.MkdirAll(baseDir, 0700)
+	return &LocalPrivateStorageService{BaseDir: baseDir}
+}
+
+func (s *LocalPrivateStorageService) Save(ctx context.Context, content io.Reader) (string, error) {
+	key := uuid.New().String()
+	dst, err := os.OpenFile(filepath.Join(s.BaseDir, key), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, content); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return key, nil
+}
+
+func (s *LocalPrivateStorageService) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	// Keys are always UUIDs, which also keeps them inside BaseDir
+	if _, err := uuid.Parse(key); err != nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	file, err := os.Open(filepath.Join(s.BaseDir, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found")
+		}
+		return nil, err
+	}
+	return file, nil
+}
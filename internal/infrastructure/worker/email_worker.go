@@ -8,17 +8,23 @@ import (
 
 	"github.com/myestatia/myestatia-go/internal/application/service"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
 	repository "github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 )
 
+// processingLease is how long a claimed email is left to the worker that
+// claimed it before another one may take it over
+const processingLease = 10 * time.Minute
+
 // CompanyEmailWorker handles email polling for a single company
-// Supports both IMAP (password auth) and Gmail API (OAuth2)
+// Supports IMAP (password auth), Gmail API (OAuth2) and Microsoft Graph
 type CompanyEmailWorker struct {
 	companyID        string
 	companyName      string
 	configID         string
-	authMethod       string // "password" or "oauth2"
+	authMethod       string // "password", "oauth2" or "microsoft"
+	syncMode         string // "poll" or "idle"
 	emailLeadService *service.EmailLeadService
 
 	// IMAP fields (for password auth)
@@ -28,7 +34,13 @@ type CompanyEmailWorker struct {
 	// Gmail API fields (for OAuth2)
 	gmailClient *email.GmailClient
 
+	// Microsoft Graph fields (for Microsoft 365)
+	graphClient *email.GraphClient
+
 	processedEmailRepo repository.ProcessedEmailRepository // New field
+	syncCursorRepo     repository.EmailSyncCursorRepository
+	failedEmails       *service.FailedEmailService
+	emailConfigService *service.CompanyEmailConfigService // records the outcome of each poll
 
 	pollIntervalSecs int
 }
@@ -39,10 +51,15 @@ func NewCompanyEmailWorker(
 	companyName string,
 	configID string,
 	authMethod string,
+	syncMode string,
 	emailLeadService *service.EmailLeadService,
 	processedEmailRepo repository.ProcessedEmailRepository, // New arg
+	syncCursorRepo repository.EmailSyncCursorRepository,
+	failedEmails *service.FailedEmailService,
+	emailConfigService *service.CompanyEmailConfigService,
 	imapConfig *email.Config, // nil for OAuth2
 	gmailClient *email.GmailClient, // nil for IMAP
+	graphClient *email.GraphClient, // nil unless Microsoft
 	pollIntervalSecs int,
 ) *CompanyEmailWorker {
 	var safeIMAPConfig email.Config
@@ -55,19 +72,38 @@ func NewCompanyEmailWorker(
 		companyName:        companyName,
 		configID:           configID,
 		authMethod:         authMethod,
+		syncMode:           syncMode,
 		emailLeadService:   emailLeadService,
 		processedEmailRepo: processedEmailRepo,
+		syncCursorRepo:     syncCursorRepo,
+		failedEmails:       failedEmails,
+		emailConfigService: emailConfigService,
 		imapConfig:         safeIMAPConfig,
 		gmailClient:        gmailClient,
+		graphClient:        graphClient,
 		pollIntervalSecs:   pollIntervalSecs,
 	}
 }
 
-// Start begins the email polling loop
+// Start begins the email polling loop, or the IDLE loop for IMAP inboxes in idle mode
 func (w *CompanyEmailWorker) Start(ctx context.Context) {
-	log.Printf("[CompanyEmailWorker][%s] Starting worker for company: %s (auth: %s)",
-		w.companyID, w.companyName, w.authMethod)
+	log.Printf("[CompanyEmailWorker][%s] Starting worker for company: %s (auth: %s, sync: %s)",
+		w.companyID, w.companyName, w.authMethod, w.syncMode)
+
+	// Leads and property references from this inbox only resolve within its company
+	ctx = tenant.WithCompanyID(ctx, w.companyID)
+
+	if w.authMethod == "password" && w.syncMode == entity.EmailSyncModeIdle {
+		w.runIdle(ctx)
+	} else {
+		w.runPolling(ctx)
+	}
+
+	log.Printf("[CompanyEmailWorker][%s] Stopping worker", w.companyID)
+}
 
+// runPolling polls the inbox every pollIntervalSecs until ctx is cancelled
+func (w *CompanyEmailWorker) runPolling(ctx context.Context) {
 	// Initial poll
 	w.pollEmails(ctx)
 
@@ -78,7 +114,6 @@ func (w *CompanyEmailWorker) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[CompanyEmailWorker][%s] Stopping worker", w.companyID)
 			return
 		case <-ticker.C:
 			w.pollEmails(ctx)
@@ -86,109 +121,311 @@ func (w *CompanyEmailWorker) Start(ctx context.Context) {
 	}
 }
 
-// pollEmails fetches and processes emails based on auth method
-func (w *CompanyEmailWorker) pollEmails(ctx context.Context) {
+const (
+	idleMinBackoff = 5 * time.Second
+	idleMaxBackoff = 5 * time.Minute
+)
+
+// runIdle keeps an IMAP connection open and fetches as soon as the server
+// reports new mail. Dropped connections are re-established with exponential
+// backoff; servers without IDLE are polled instead.
+func (w *CompanyEmailWorker) runIdle(ctx context.Context) {
+	backoff := idleMinBackoff
+
+	for ctx.Err() == nil {
+		started := time.Now()
+		supported, err := w.idleSession(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if !supported {
+			log.Printf("[CompanyEmailWorker][%s] Server does not support IDLE, falling back to polling", w.companyID)
+			w.runPolling(ctx)
+			return
+		}
+
+		// A session that stayed up for a while was healthy, start counting again
+		if time.Since(started) > idleMaxBackoff {
+			backoff = idleMinBackoff
+		}
+
+		log.Printf("[CompanyEmailWorker][%s] IDLE connection lost: %v (reconnecting in %s)", w.companyID, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > idleMaxBackoff {
+			backoff = idleMaxBackoff
+		}
+	}
+}
+
+// idleSession connects, fetches whatever is new and then alternates between
+// IDLE and fetching until the connection fails. It reports false, without an
+// error, when the server does not advertise IDLE.
+func (w *CompanyEmailWorker) idleSession(ctx context.Context) (bool, error) {
+	imapClient, err := email.NewIMAPClient(w.imapConfig)
+	if err != nil {
+		return true, fmt.Errorf("failed to create IMAP client: %w", err)
+	}
+	defer imapClient.Close()
+
+	if err := imapClient.Connect(); err != nil {
+		// Polls record their own outcome; a session that can't even connect is recorded here
+		run := w.newSyncRun()
+		w.recordSyncRun(ctx, run, err)
+		return true, err
+	}
+
+	supported, err := imapClient.SupportsIdle()
+	if err != nil {
+		return true, fmt.Errorf("failed to read server capabilities: %w", err)
+	}
+	if !supported {
+		return false, nil
+	}
+
+	// fetchIMAPEmails reuses this connection while the session lasts
+	w.imapClient = imapClient
+	defer func() { w.imapClient = nil }()
+
+	for {
+		if err := w.pollEmails(ctx); err != nil {
+			return true, err
+		}
+		if err := imapClient.WaitForNewMail(ctx); err != nil {
+			return true, err
+		}
+	}
+}
+
+// pollEmails fetches and processes emails based on auth method. The error is
+// only about fetching; emails that fail to process are recorded, not returned.
+func (w *CompanyEmailWorker) pollEmails(ctx context.Context) (err error) {
+	// Deferred first so it runs last, after a panic was turned into err
+	run := w.newSyncRun()
+	defer func() { w.recordSyncRun(ctx, run, err) }()
+
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[CompanyEmailWorker][%s] PANIC in pollEmails: %v", w.companyID, r)
+			err = fmt.Errorf("panic in pollEmails: %v", r)
 		}
 	}()
 
 	log.Printf("[CompanyEmailWorker][%s] Polling inbox...", w.companyID)
 
 	var emails []email.ParsedEmail
-	var err error
+	var cursor *entity.EmailSyncCursor // where to resume from once these emails are handled
 
 	switch w.authMethod {
 	case "oauth2":
-		emails, err = w.fetchGmailEmails(ctx)
+		emails, cursor, err = w.fetchGmailEmails(ctx)
 	case "password":
-		emails, err = w.fetchIMAPEmails(ctx)
+		emails, cursor, err = w.fetchIMAPEmails(ctx)
+	case entity.EmailAuthMicrosoft:
+		emails, cursor, err = w.fetchGraphEmails(ctx)
 	default:
 		log.Printf("[CompanyEmailWorker][%s] Unknown auth method: %s", w.companyID, w.authMethod)
-		return
+		return fmt.Errorf("unknown auth method: %s", w.authMethod)
 	}
 
 	if err != nil {
 		log.Printf("[CompanyEmailWorker][%s] Error fetching emails: %v", w.companyID, err)
-		return
+		return err
 	}
 
-	log.Printf("[CompanyEmailWorker][%s] Found %d unread emails", w.companyID, len(emails))
+	log.Printf("[CompanyEmailWorker][%s] Found %d new emails", w.companyID, len(emails))
+	run.EmailsSeen = len(emails)
 
 	// Process each email
 	for _, email := range emails {
-		// Check if already processed locally
-		exists, err := w.processedEmailRepo.Exists(ctx, email.MessageID, w.companyID)
+		// Claim it first: only the worker that holds the claim processes it
+		processedEmail := &entity.ProcessedEmail{
+			MessageID: email.MessageID,
+			CompanyID: w.companyID,
+		}
+		claimed, err := w.processedEmailRepo.Claim(ctx, processedEmail, processingLease)
 		if err != nil {
-			log.Printf("[CompanyEmailWorker][%s] Error checking processed email %s: %v", w.companyID, email.MessageID, err)
+			log.Printf("[CompanyEmailWorker][%s] Error claiming email %s: %v", w.companyID, email.MessageID, err)
+			// Keep the cursor where it was so this email is fetched again next poll
+			cursor = nil
 			continue
 		}
-		if exists {
+		if !claimed {
+			// Another worker is on it; fetch it again in case that worker dies before it is done
+			if done, err := w.processedEmailRepo.IsProcessed(ctx, w.companyID, email.MessageID); err != nil || !done {
+				cursor = nil
+			}
 			continue
 		}
 
 		// Process the email (try to extract lead)
-		processErr := w.emailLeadService.ProcessEmail(ctx, email)
+		outcome, processErr := w.emailLeadService.ProcessEmailOutcome(ctx, email)
+		switch {
+		case processErr == nil && outcome == service.LeadOutcomeCreated:
+			run.LeadsCreated++
+		case processErr == nil && outcome == service.LeadOutcomeUpdated:
+			run.LeadsUpdated++
+		}
 		if processErr != nil {
+			run.ParseFailures++
 			log.Printf("[CompanyEmailWorker][%s] Email processing result: %v", w.companyID, processErr)
-			// We continue to save it as processed so we don't try again forever
-			// specially for "unsupported source" or parser errors.
+			// It is marked as processed so it is not fetched again forever;
+			// the dead-letter record is what gets reprocessed later
+			if err := w.failedEmails.Record(ctx, w.companyID, w.configID, email, processErr); err != nil {
+				log.Printf("[CompanyEmailWorker][%s] Error saving failed email %s: %v", w.companyID, email.MessageID, err)
+				// Nothing was kept of it, so let the next poll try again
+				if err := w.processedEmailRepo.Release(ctx, processedEmail); err != nil {
+					log.Printf("[CompanyEmailWorker][%s] Error releasing email %s: %v", w.companyID, email.MessageID, err)
+				}
+				cursor = nil
+				continue
+			}
 		}
 
-		// Mark as processed locally ALWAYS
-		processedEmail := &entity.ProcessedEmail{
-			MessageID: email.MessageID,
-			CompanyID: w.companyID,
-		}
-		if err := w.processedEmailRepo.Save(ctx, processedEmail); err != nil {
-			log.Printf("[CompanyEmailWorker][%s] Error saving processed email record %s: %v", w.companyID, email.MessageID, err)
+		// Only now that the lead or the failed email is saved
+		if err := w.processedEmailRepo.MarkProcessed(ctx, processedEmail); err != nil {
+			log.Printf("[CompanyEmailWorker][%s] Error marking email %s as processed: %v", w.companyID, email.MessageID, err)
 		}
 
 		// DO NOT mark as read in Gmail/IMAP to respect user privacy
 
 	}
+
+	if cursor != nil {
+		if err := w.syncCursorRepo.Save(ctx, cursor); err != nil {
+			log.Printf("[CompanyEmailWorker][%s] Error saving sync cursor: %v", w.companyID, err)
+		}
+	}
+	return nil
+}
+
+// newSyncRun starts recording a poll of this inbox
+func (w *CompanyEmailWorker) newSyncRun() *entity.EmailSyncRun {
+	return &entity.EmailSyncRun{
+		ConfigID:  w.configID,
+		CompanyID: w.companyID,
+		StartedAt: time.Now(),
+	}
 }
 
-// fetchGmailEmails fetches emails using Gmail API (OAuth2)
-func (w *CompanyEmailWorker) fetchGmailEmails(ctx context.Context) ([]email.ParsedEmail, error) {
+// recordSyncRun saves run with err, the error that kept the inbox from being read
+func (w *CompanyEmailWorker) recordSyncRun(ctx context.Context, run *entity.EmailSyncRun, err error) {
+	if w.emailConfigService == nil || ctx.Err() != nil {
+		return // shutting down: an interrupted poll says nothing about the inbox
+	}
+
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+		run.AuthFailure = email.IsAuthError(err)
+	}
+	if recordErr := w.emailConfigService.RecordSyncRun(ctx, run); recordErr != nil {
+		log.Printf("[CompanyEmailWorker][%s] Error recording sync run: %v", w.companyID, recordErr)
+	}
+}
+
+// fetchGmailEmails fetches the emails added since the stored historyId (Gmail
+// API, OAuth2) and returns the cursor to save once they are processed
+func (w *CompanyEmailWorker) fetchGmailEmails(ctx context.Context) ([]email.ParsedEmail, *entity.EmailSyncCursor, error) {
 	if w.gmailClient == nil {
-		return nil, fmt.Errorf("Gmail client not initialized")
+		return nil, nil, fmt.Errorf("Gmail client not initialized")
+	}
+
+	cursor, err := w.loadSyncCursor(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	gmailMessages, err := w.gmailClient.FetchUnreadEmails()
+	gmailMessages, historyID, err := w.gmailClient.FetchNewEmails(ctx, cursor.HistoryID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Gmail messages: %w", err)
+		return nil, nil, fmt.Errorf("failed to fetch Gmail messages: %w", err)
 	}
 
 	// Convert Gmail messages to ParsedEmail format
 	var emails []email.ParsedEmail
 	for _, msg := range gmailMessages {
-		emails = append(emails, email.ParsedEmail{
-			MessageID: msg.MessageID,
-			From:      msg.From,
-			Subject:   msg.Subject,
-			Body:      msg.Body,
-			Date:      msg.Date,
-		})
+		emails = append(emails, msg.ParsedEmail)
 	}
 
-	return emails, nil
+	cursor.HistoryID = historyID
+	return emails, cursor, nil
 }
 
-// fetchIMAPEmails fetches emails using IMAP (password auth)
-func (w *CompanyEmailWorker) fetchIMAPEmails(ctx context.Context) ([]email.ParsedEmail, error) {
-	// Create fresh IMAP client for this poll
-	imapClient, err := email.NewIMAPClient(w.imapConfig)
+// fetchGraphEmails fetches the emails added since the stored deltaLink
+// (Microsoft Graph) and returns the cursor to save once they are processed
+func (w *CompanyEmailWorker) fetchGraphEmails(ctx context.Context) ([]email.ParsedEmail, *entity.EmailSyncCursor, error) {
+	if w.graphClient == nil {
+		return nil, nil, fmt.Errorf("Graph client not initialized")
+	}
+
+	cursor, err := w.loadSyncCursor(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create IMAP client: %w", err)
+		return nil, nil, err
+	}
+
+	emails, deltaLink, err := w.graphClient.FetchNewEmails(ctx, cursor.DeltaLink)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch Graph messages: %w", err)
+	}
+
+	cursor.DeltaLink = deltaLink
+	return emails, cursor, nil
+}
+
+// loadSyncCursor returns the stored sync cursor of this inbox, or an empty one
+// on the first sync
+func (w *CompanyEmailWorker) loadSyncCursor(ctx context.Context) (*entity.EmailSyncCursor, error) {
+	cursor, err := w.syncCursorRepo.FindByConfigID(ctx, w.configID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync cursor: %w", err)
+	}
+	if cursor == nil {
+		cursor = &entity.EmailSyncCursor{ConfigID: w.configID, CompanyID: w.companyID}
+	}
+	return cursor, nil
+}
+
+// fetchIMAPEmails fetches the emails that arrived since the stored sync cursor
+// (password auth) and returns the cursor to save once they are processed
+func (w *CompanyEmailWorker) fetchIMAPEmails(ctx context.Context) ([]email.ParsedEmail, *entity.EmailSyncCursor, error) {
+	cursor, err := w.loadSyncCursor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// In idle mode the session's connection is reused; otherwise create a fresh IMAP client for this poll
+	imapClient := w.imapClient
+	if imapClient == nil {
+		imapClient, err = email.NewIMAPClient(w.imapConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create IMAP client: %w", err)
+		}
+		defer imapClient.Close()
+	}
+
+	state := email.IMAPSyncState{UIDValidity: cursor.UIDValidity, LastUID: cursor.LastUID}
+	if cursor.UIDValidity == 0 {
+		// Inboxes synced before cursors existed recorded their emails as
+		// processed by UID, which the Message-ID dedupe does not recognise:
+		// backfilling would process them all again
+		config, err := w.emailConfigService.GetConfig(ctx, w.companyID, w.configID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load email config: %w", err)
+		}
+		state.SkipBackfill = config.LastSyncAt != nil
 	}
-	defer imapClient.Close()
 
-	emails, err := imapClient.FetchUnreadEmails()
+	emails, state, err := imapClient.FetchNewEmails(state)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch IMAP emails: %w", err)
+		return nil, nil, fmt.Errorf("failed to fetch IMAP emails: %w", err)
 	}
 
-	return emails, nil
+	cursor.UIDValidity = state.UIDValidity
+	cursor.LastUID = state.LastUID
+	return emails, cursor, nil
 }
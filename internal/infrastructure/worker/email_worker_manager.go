@@ -2,28 +2,57 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/myestatia/myestatia-go/internal/application/service"
 	"github.com/myestatia/myestatia-go/internal/domain/entity"
+	"github.com/myestatia/myestatia-go/internal/domain/tenant"
 	"github.com/myestatia/myestatia-go/internal/infrastructure/email"
+	oauthconfig "github.com/myestatia/myestatia-go/internal/infrastructure/oauth2"
 	repository "github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 	"golang.org/x/oauth2"
 )
 
-// EmailWorkerManager manages multiple company email workers
+const (
+	// workerLeaseTTL is how long an inbox stays with a replica that stopped
+	// renewing its lease, e.g. because it crashed
+	workerLeaseTTL = 60 * time.Second
+	// workerLeaseRenewInterval leaves room for two failed renewals before expiry
+	workerLeaseRenewInterval = workerLeaseTTL / 3
+)
+
+// errLeaseHeld means another replica is polling the inbox
+var errLeaseHeld = errors.New("inbox is polled by another replica")
+
+// EmailWorkerManager manages one email worker per connected inbox. When
+// several replicas run, each inbox is only polled by the one holding its lease.
 type EmailWorkerManager struct {
 	emailConfigService *service.CompanyEmailConfigService
 	propertyRepo       repository.PropertyRepository
 	leadRepo           repository.LeadRepository
+	messageRepo        repository.MessageRepository
 	processedEmailRepo repository.ProcessedEmailRepository // New repo
-	workers            map[string]*CompanyEmailWorker      // key: companyID
-	workerContexts     map[string]context.CancelFunc       // key: companyID
-	wg                 sync.WaitGroup                      // Wait for all workers to finish
+	syncCursorRepo     repository.EmailSyncCursorRepository
+	leaseRepo          repository.EmailWorkerLeaseRepository
+	parserTemplateRepo repository.EmailParserTemplateRepository
+	failedEmails       *service.FailedEmailService
+	inboundEmails      *service.InboundEmailService
+	leadStatuses       *service.LeadStatusService
+	leadAssignments    *service.LeadAssignmentService
+	onLeadActivity     []func(ctx context.Context, leadID string)
+	holderID           string                         // identifies this replica in the leases it holds
+	workers            map[string]*CompanyEmailWorker // key: config ID
+	workerContexts     map[string]context.CancelFunc  // key: config ID
+	workerDone         map[string]chan struct{}       // key: config ID, closed when the worker returns
+	workerVersions     map[string]time.Time           // key: config ID, UpdatedAt of the config it runs with
+	wg                 sync.WaitGroup                 // Wait for all workers to finish
 	mu                 sync.RWMutex
 	configReloadSecs   int
 }
@@ -33,7 +62,15 @@ func NewEmailWorkerManager(
 	emailConfigService *service.CompanyEmailConfigService,
 	propertyRepo repository.PropertyRepository,
 	leadRepo repository.LeadRepository,
+	messageRepo repository.MessageRepository,
 	processedEmailRepo repository.ProcessedEmailRepository, // Add this
+	syncCursorRepo repository.EmailSyncCursorRepository,
+	leaseRepo repository.EmailWorkerLeaseRepository,
+	parserTemplateRepo repository.EmailParserTemplateRepository,
+	failedEmails *service.FailedEmailService,
+	inboundEmails *service.InboundEmailService,
+	leadStatuses *service.LeadStatusService,
+	leadAssignments *service.LeadAssignmentService,
 ) *EmailWorkerManager {
 	// Default poll interval for prod, can be overridden elsewhere
 	// In dev we might want faster reload
@@ -42,9 +79,20 @@ func NewEmailWorkerManager(
 		emailConfigService: emailConfigService,
 		propertyRepo:       propertyRepo,
 		leadRepo:           leadRepo,
+		messageRepo:        messageRepo,
 		processedEmailRepo: processedEmailRepo,
+		syncCursorRepo:     syncCursorRepo,
+		leaseRepo:          leaseRepo,
+		parserTemplateRepo: parserTemplateRepo,
+		failedEmails:       failedEmails,
+		inboundEmails:      inboundEmails,
+		leadStatuses:       leadStatuses,
+		leadAssignments:    leadAssignments,
+		holderID:           newHolderID(),
 		workers:            make(map[string]*CompanyEmailWorker),
 		workerContexts:     make(map[string]context.CancelFunc),
+		workerDone:         make(map[string]chan struct{}),
+		workerVersions:     make(map[string]time.Time),
 		configReloadSecs:   600, // Reload configs every 10 minutes
 	}
 
@@ -56,7 +104,25 @@ func NewEmailWorkerManager(
 	return manager
 }
 
-// Start begins the worker manager
+// newHolderID names this replica: the host for humans, plus a random part so
+// two processes on the same host never share leases
+func newHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + uuid.New().String()
+}
+
+// OnLeadActivity registers fn to be called after an inbound email creates or
+// updates a lead. Register before Start.
+func (m *EmailWorkerManager) OnLeadActivity(fn func(ctx context.Context, leadID string)) {
+	m.onLeadActivity = append(m.onLeadActivity, fn)
+}
+
+// Start begins the worker manager. Changes made through the email config
+// service restart the affected worker right away; changes made by other
+// instances are picked up by the periodic reload.
 func (m *EmailWorkerManager) Start(ctx context.Context) {
 	log.Println("[EmailWorkerManager] Starting email worker manager")
 
@@ -75,10 +141,39 @@ func (m *EmailWorkerManager) Start(ctx context.Context) {
 			return
 		case <-ticker.C:
 			m.reloadWorkers(ctx)
+		case change := <-m.emailConfigService.Changes():
+			m.applyChange(ctx, change)
 		}
 	}
 }
 
+// applyChange restarts the worker of the changed inbox, or leaves it stopped
+// when the inbox was deleted or disabled
+func (m *EmailWorkerManager) applyChange(ctx context.Context, change service.EmailConfigChange) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[EmailWorkerManager] PANIC in applyChange: %v", r)
+		}
+	}()
+
+	log.Printf("[EmailWorkerManager] Email config %s of company %s changed", change.ConfigID, change.CompanyID)
+	m.stopWorker(change.ConfigID)
+
+	config, err := m.emailConfigService.GetConfig(tenant.WithCompanyID(ctx, change.CompanyID), change.CompanyID, change.ConfigID)
+	if err != nil {
+		log.Printf("[EmailWorkerManager] Not restarting worker for config %s: %v", change.ConfigID, err)
+		return
+	}
+	if !config.IsEnabled {
+		return
+	}
+
+	if err := m.startWorker(ctx, config); err != nil && !errors.Is(err, errLeaseHeld) {
+		log.Printf("[EmailWorkerManager] Failed to restart worker for config %s of company %s: %v",
+			config.ID, config.CompanyID, err)
+	}
+}
+
 // reloadWorkers loads all enabled email configurations and starts/stops workers
 func (m *EmailWorkerManager) reloadWorkers(ctx context.Context) {
 	defer func() {
@@ -98,8 +193,8 @@ func (m *EmailWorkerManager) reloadWorkers(ctx context.Context) {
 
 	log.Printf("[EmailWorkerManager] Found %d enabled email configuration(s)", len(configs))
 
-	// Track active company IDs
-	activeCompanyIDs := make(map[string]bool)
+	// Track active config IDs
+	activeConfigIDs := make(map[string]bool)
 
 	// Start or update workers for each config
 	for i, config := range configs {
@@ -108,55 +203,95 @@ func (m *EmailWorkerManager) reloadWorkers(ctx context.Context) {
 			continue
 		}
 
-		log.Printf("[EmailWorkerManager] Processing config for company %s (Auth: %s)", config.CompanyID, config.AuthMethod)
-		activeCompanyIDs[config.CompanyID] = true
+		log.Printf("[EmailWorkerManager] Processing config %s for company %s (Auth: %s)", config.ID, config.CompanyID, config.AuthMethod)
+		activeConfigIDs[config.ID] = true
 
 		m.mu.RLock()
-		_, exists := m.workers[config.CompanyID]
+		_, exists := m.workers[config.ID]
+		version := m.workerVersions[config.ID]
+		done := m.workerDone[config.ID]
 		m.mu.RUnlock()
 
+		// The worker returned on its own, e.g. after losing its lease: clear it
+		// so the inbox can be picked up again
+		if exists && isClosed(done) {
+			m.stopWorker(config.ID)
+			exists = false
+		}
+
+		// The config was changed since its worker started, possibly by
+		// another instance: restart the worker with the new settings
+		if exists && !config.UpdatedAt.Equal(version) {
+			log.Printf("[EmailWorkerManager] Config %s changed since its worker started, restarting it", config.ID)
+			m.stopWorker(config.ID)
+			exists = false
+		}
+
 		if !exists {
 			// Start new worker
-			if err := m.startWorker(ctx, config); err != nil {
-				log.Printf("[EmailWorkerManager] Failed to start worker for company %s: %v",
-					config.CompanyID, err)
+			if err := m.startWorker(ctx, config); errors.Is(err, errLeaseHeld) {
+				log.Printf("[EmailWorkerManager] Config %s is polled by another replica", config.ID)
+			} else if err != nil {
+				log.Printf("[EmailWorkerManager] Failed to start worker for config %s of company %s: %v",
+					config.ID, config.CompanyID, err)
 			}
 		}
-		// Note: If worker already exists, it continues running with its config
-		// To update config, user would disable/enable or restart the application
 	}
 
-	// Stop workers for companies no longer enabled
+	// Stop workers for inboxes no longer enabled
 	m.mu.RLock()
 	currentWorkers := make(map[string]bool)
-	for companyID := range m.workers {
-		currentWorkers[companyID] = true
+	for configID := range m.workers {
+		currentWorkers[configID] = true
 	}
 	m.mu.RUnlock()
 
-	for companyID := range currentWorkers {
-		if !activeCompanyIDs[companyID] {
-			m.stopWorker(companyID)
+	for configID := range currentWorkers {
+		if !activeConfigIDs[configID] {
+			m.stopWorker(configID)
 		}
 	}
 }
 
-// startWorker starts a new worker for a company (supports both IMAP and Gmail API)
+// startWorker starts a new worker for a company (supports IMAP, Gmail API and Microsoft Graph)
 func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *entity.CompanyEmailConfig) error {
 	companyName := "Unknown"
 	if config.Company != nil {
 		companyName = config.Company.Name
 	}
 
+	// Only one replica may poll an inbox
+	acquired, err := m.leaseRepo.Acquire(parentCtx, config.ID, m.holderID, workerLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	if !acquired {
+		return errLeaseHeld
+	}
+	started := false
+	defer func() {
+		if !started {
+			m.releaseLease(config.ID)
+		}
+	}()
+
 	var worker *CompanyEmailWorker
-	var err error
 
-	// Create email lead service for this company (same for both auth methods)
+	// Create email lead service for this inbox (same for all auth methods)
+	leadConfig := service.EmailLeadConfig(config)
 	emailLeadService := service.NewEmailLeadService(
 		m.propertyRepo,
 		m.leadRepo,
-		email.Config{DefaultCompanyID: config.CompanyID},
+		m.parserTemplateRepo,
+		m.messageRepo,
+		m.inboundEmails,
+		m.leadStatuses,
+		m.leadAssignments,
+		leadConfig,
 	)
+	for _, fn := range m.onLeadActivity {
+		emailLeadService.OnLeadActivity(fn)
+	}
 
 	// Validate dependencies
 	if m.emailConfigService == nil {
@@ -194,7 +329,14 @@ func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *enti
 			return fmt.Errorf("encryption key is empty")
 		}
 
-		gmailClient, err := email.NewGmailClient(config, encryptionKey, oauth2Cfg)
+		// Tokens refreshed by the client are written back to this config
+		configID := config.ID
+		tokenCtx := tenant.WithCompanyID(parentCtx, config.CompanyID)
+		saveToken := func(encryptedAccessToken, encryptedRefreshToken string, expiry time.Time) error {
+			return m.emailConfigService.RefreshOAuth2Token(tokenCtx, configID, encryptedAccessToken, encryptedRefreshToken, expiry)
+		}
+
+		gmailClient, err := email.NewGmailClient(config, encryptionKey, oauth2Cfg, saveToken)
 		if err != nil {
 			return fmt.Errorf("failed to create Gmail client: %w", err)
 		}
@@ -204,10 +346,15 @@ func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *enti
 			companyName,
 			config.ID,
 			"oauth2",
+			entity.EmailSyncModePoll,
 			emailLeadService,
 			m.processedEmailRepo, // New arg
-			nil,                  // No IMAP config
+			m.syncCursorRepo,
+			m.failedEmails,
+			m.emailConfigService,
+			nil, // No IMAP config
 			gmailClient,
+			nil, // No Graph client
 			config.PollIntervalSecs,
 		)
 
@@ -233,7 +380,8 @@ func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *enti
 			Password:         password,
 			InboxFolder:      config.InboxFolder,
 			PollIntervalSecs: config.PollIntervalSecs,
-			DefaultCompanyID: config.CompanyID,
+			DefaultCompanyID: leadConfig.DefaultCompanyID,
+			DefaultAgentID:   leadConfig.DefaultAgentID,
 		}
 
 		worker = NewCompanyEmailWorker(
@@ -241,10 +389,66 @@ func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *enti
 			companyName,
 			config.ID,
 			"password",
+			config.SyncMode,
 			emailLeadService,
 			m.processedEmailRepo, // New arg
+			m.syncCursorRepo,
+			m.failedEmails,
+			m.emailConfigService,
 			imapConfig,
 			nil, // No Gmail client
+			nil, // No Graph client
+			config.PollIntervalSecs,
+		)
+
+	case entity.EmailAuthMicrosoft:
+		// Microsoft 365 through Graph (OAuth2)
+		log.Printf("[EmailWorkerManager] Creating Microsoft Graph worker for company %s", config.CompanyID)
+
+		clientID := os.Getenv("MICROSOFT_CLIENT_ID")
+		clientSecret := os.Getenv("MICROSOFT_CLIENT_SECRET")
+		if clientID == "" || clientSecret == "" {
+			log.Printf("[EmailWorkerManager] WARNING: Microsoft OAuth credentials missing in environment")
+		}
+
+		oauth2Cfg := oauthconfig.NewMicrosoftOAuth2Config(
+			clientID,
+			clientSecret,
+			os.Getenv("MICROSOFT_REDIRECT_URL"),
+			os.Getenv("MICROSOFT_TENANT_ID"),
+		).Config
+
+		encryptionKey := m.emailConfigService.GetEncryptionKey()
+		if encryptionKey == "" {
+			return fmt.Errorf("encryption key is empty")
+		}
+
+		// Tokens refreshed by the client are written back to this config
+		configID := config.ID
+		tokenCtx := tenant.WithCompanyID(parentCtx, config.CompanyID)
+		saveToken := func(encryptedAccessToken, encryptedRefreshToken string, expiry time.Time) error {
+			return m.emailConfigService.RefreshOAuth2Token(tokenCtx, configID, encryptedAccessToken, encryptedRefreshToken, expiry)
+		}
+
+		graphClient, err := email.NewGraphClient(config, encryptionKey, oauth2Cfg, saveToken, os.Getenv("MICROSOFT_GRAPH_URL"))
+		if err != nil {
+			return fmt.Errorf("failed to create Graph client: %w", err)
+		}
+
+		worker = NewCompanyEmailWorker(
+			config.CompanyID,
+			companyName,
+			config.ID,
+			entity.EmailAuthMicrosoft,
+			entity.EmailSyncModePoll,
+			emailLeadService,
+			m.processedEmailRepo,
+			m.syncCursorRepo,
+			m.failedEmails,
+			m.emailConfigService,
+			nil, // No IMAP config
+			nil, // No Gmail client
+			graphClient,
 			config.PollIntervalSecs,
 		)
 
@@ -262,52 +466,121 @@ func (m *EmailWorkerManager) startWorker(parentCtx context.Context, config *enti
 	// Increment wait group
 	m.wg.Add(1)
 
-	// Start worker in goroutine
+	// Start worker in goroutine, for as long as this replica holds its lease
+	done := make(chan struct{})
 	go func() {
 		defer m.wg.Done()
+		defer close(done)
+		defer m.releaseLease(config.ID)
+		go m.renewLease(workerCtx, cancel, config.ID)
 		worker.Start(workerCtx)
 	}()
+	started = true
 
 	// Store worker and cancel function
 	m.mu.Lock()
-	m.workers[config.CompanyID] = worker
-	m.workerContexts[config.CompanyID] = cancel
+	m.workers[config.ID] = worker
+	m.workerContexts[config.ID] = cancel
+	m.workerDone[config.ID] = done
+	m.workerVersions[config.ID] = config.UpdatedAt
 	m.mu.Unlock()
 
-	log.Printf("[EmailWorkerManager] Started %s worker for company %s (%s)", config.AuthMethod, config.CompanyID, companyName)
+	log.Printf("[EmailWorkerManager] Started %s worker for config %s of company %s (%s)", config.AuthMethod, config.ID, config.CompanyID, companyName)
 	return nil
 }
 
-// stopWorker stops a worker for a company
-func (m *EmailWorkerManager) stopWorker(companyID string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// renewLease keeps the lease of configID while ctx lasts and stops the worker,
+// through cancel, once the lease is lost or could not be renewed before expiring
+func (m *EmailWorkerManager) renewLease(ctx context.Context, cancel context.CancelFunc, configID string) {
+	ticker := time.NewTicker(workerLeaseRenewInterval)
+	defer ticker.Stop()
+
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		held, err := m.leaseRepo.Renew(ctx, configID, m.holderID, workerLeaseTTL)
+		switch {
+		case err == nil && held:
+			renewedAt = time.Now()
+			continue
+		case err == nil:
+			log.Printf("[EmailWorkerManager] Lost lease of config %s, stopping its worker", configID)
+		case time.Since(renewedAt) < workerLeaseTTL-workerLeaseRenewInterval:
+			log.Printf("[EmailWorkerManager] Error renewing lease of config %s: %v", configID, err)
+			continue
+		default:
+			log.Printf("[EmailWorkerManager] Could not renew lease of config %s before it expires, stopping its worker: %v", configID, err)
+		}
+		cancel()
+		return
+	}
+}
 
-	cancel, exists := m.workerContexts[companyID]
+// releaseLease hands the inbox over to the other replicas right away
+func (m *EmailWorkerManager) releaseLease(configID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.leaseRepo.Release(ctx, configID, m.holderID); err != nil {
+		log.Printf("[EmailWorkerManager] Error releasing lease of config %s: %v", configID, err)
+	}
+}
+
+// isClosed reports whether done was closed
+func isClosed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopWorker stops the worker of an inbox and waits for it to return, so a
+// replacement never reads the mailbox at the same time
+func (m *EmailWorkerManager) stopWorker(configID string) {
+	m.mu.Lock()
+	cancel, exists := m.workerContexts[configID]
 	if !exists {
+		m.mu.Unlock()
 		return
 	}
+	done := m.workerDone[configID]
 
 	// Cancel context to stop worker
 	cancel()
 
 	// Remove from maps
-	delete(m.workers, companyID)
-	delete(m.workerContexts, companyID)
+	delete(m.workers, configID)
+	delete(m.workerContexts, configID)
+	delete(m.workerDone, configID)
+	delete(m.workerVersions, configID)
+	m.mu.Unlock()
 
-	log.Printf("[EmailWorkerManager] Stopped worker for company %s", companyID)
+	select {
+	case <-done:
+		log.Printf("[EmailWorkerManager] Stopped worker for config %s", configID)
+	case <-time.After(5 * time.Second):
+		log.Printf("[EmailWorkerManager] Timeout waiting for worker of config %s to stop", configID)
+	}
 }
 
 // stopAllWorkers stops all running workers
 func (m *EmailWorkerManager) stopAllWorkers() {
 	m.mu.Lock()
-	for companyID, cancel := range m.workerContexts {
+	for configID, cancel := range m.workerContexts {
 		cancel()
-		log.Printf("[EmailWorkerManager] Stopped worker for company %s", companyID)
+		log.Printf("[EmailWorkerManager] Stopped worker for config %s", configID)
 	}
 
 	m.workers = make(map[string]*CompanyEmailWorker)
 	m.workerContexts = make(map[string]context.CancelFunc)
+	m.workerDone = make(map[string]chan struct{})
+	m.workerVersions = make(map[string]time.Time)
 	m.mu.Unlock()
 
 	// Wait for all workers to finish with timeout
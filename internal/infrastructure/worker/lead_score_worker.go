@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/myestatia/myestatia-go/internal/application/service"
+)
+
+// LeadScoreWorker rescores every lead periodically: recency fades even when
+// nothing happens to a lead
+type LeadScoreWorker struct {
+	scores   *service.LeadScoreService
+	interval time.Duration
+}
+
+// NewLeadScoreWorker creates a new lead scoring worker
+func NewLeadScoreWorker(scores *service.LeadScoreService) *LeadScoreWorker {
+	return &LeadScoreWorker{
+		scores:   scores,
+		interval: 24 * time.Hour,
+	}
+}
+
+// Start begins the lead scoring worker
+func (w *LeadScoreWorker) Start(ctx context.Context) {
+	log.Printf("[LeadScoreWorker] Worker started, running every %v", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[LeadScoreWorker] Worker stopped")
+			return
+		case <-ticker.C:
+			w.rescore(ctx)
+		}
+	}
+}
+
+func (w *LeadScoreWorker) rescore(ctx context.Context) {
+	scored, err := w.scores.RecomputeAll(ctx)
+	if err != nil {
+		log.Printf("[LeadScoreWorker] ERROR: Failed to rescore leads: %v", err)
+		return
+	}
+	log.Printf("[LeadScoreWorker] ✓ Rescored %d leads", scored)
+}
@@ -8,16 +8,18 @@ import (
 	"github.com/myestatia/myestatia-go/internal/infrastructure/repository"
 )
 
-// PasswordResetCleanupWorker handles periodic cleanup of expired password reset tokens
+// PasswordResetCleanupWorker handles periodic cleanup of expired password reset tokens and sessions
 type PasswordResetCleanupWorker struct {
 	passwordResetRepo repository.PasswordResetRepository
+	sessionRepo       repository.SessionRepository
 	interval          time.Duration
 }
 
 // NewPasswordResetCleanupWorker creates a new cleanup worker
-func NewPasswordResetCleanupWorker(passwordResetRepo repository.PasswordResetRepository) *PasswordResetCleanupWorker {
+func NewPasswordResetCleanupWorker(passwordResetRepo repository.PasswordResetRepository, sessionRepo repository.SessionRepository) *PasswordResetCleanupWorker {
 	return &PasswordResetCleanupWorker{
 		passwordResetRepo: passwordResetRepo,
+		sessionRepo:       sessionRepo,
 		interval:          2 * time.Hour, // Run every 2 hours
 	}
 }
@@ -52,5 +54,10 @@ func (w *PasswordResetCleanupWorker) cleanup(ctx context.Context) {
 		return
 	}
 
+	if err := w.sessionRepo.DeleteExpired(ctx); err != nil {
+		log.Printf("[PasswordResetCleanup] ERROR: Failed to delete expired sessions: %v", err)
+		return
+	}
+
 	log.Println("[PasswordResetCleanup] ✓ Cleanup completed successfully")
 }